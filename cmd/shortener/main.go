@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/oidc"
 	"github.com/ypxd99/yandex-practicm/internal/repository"
 	"github.com/ypxd99/yandex-practicm/internal/repository/postgres"
 	"github.com/ypxd99/yandex-practicm/internal/repository/storage"
@@ -66,9 +67,26 @@ func main() {
 	defer repo.Close()
 
 	service := service.InitService(repo)
+	if cfg.Auth.OIDC.Issuer != "" {
+		service.SetIdentityProvider(oidc.NewProvider(cfg.Auth.OIDC, nil))
+	}
 	h := handler.InitHandler(service)
 
+	// Фоновые задачи останавливаются после сервера, чтобы сохранить активность последних запросов.
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		service.RunJobs(jobsCtx)
+	}()
+
 	router := gin.Default()
+	// Адрес клиента берется из заголовков прокси только для доверенных прокси,
+	// иначе ограничения по адресу обходятся подменой X-Forwarded-For.
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Errorf("Failed to set trusted proxies: %v", err)
+		return
+	}
 	h.InitRoutes(router)
 
 	srv := server.NewServer(router)
@@ -88,6 +106,8 @@ func main() {
 	if err := srv.Stop(ctx); err != nil {
 		logger.Errorf("Server forced to shutdown: %s", err.Error())
 	}
+	stopJobs()
+	<-jobsDone
 	logger.Info("HTTP SHORTENER service stopped")
 }
 
@@ -0,0 +1,266 @@
+package mocks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+)
+
+// fakeIDPKeyID идентификатор ключа подписи ID токенов тестового провайдера
+const fakeIDPKeyID = "fake-idp"
+
+// fakeCodeTTL время действия кода авторизации тестового провайдера
+const fakeCodeTTL = time.Minute
+
+// FakeIdentityProvider представляет локальный провайдер OpenID Connect для тестирования входа.
+// Поддерживает discovery, страницу входа, которая сразу возвращает код авторизации,
+// обмен кода с проверкой секрета клиента и PKCE, а также публикацию ключа подписи.
+type FakeIdentityProvider struct {
+	*httptest.Server
+
+	// ClientID идентификатор зарегистрированного клиента
+	ClientID string
+	// ClientSecret секрет зарегистрированного клиента
+	ClientSecret string
+	// ModifyToken, если задан, вызывается перед подписью ID токена,
+	// чтобы тест мог изменить утверждения или заголовок токена.
+	ModifyToken func(token *jwt.Token)
+
+	key   *rsa.PrivateKey
+	keyID string
+	mu    sync.Mutex
+	user  model.OIDCIdentity
+	codes map[string]fakeAuthRequest
+}
+
+// fakeAuthRequest представляет выданный, но еще не обмененный код авторизации.
+type fakeAuthRequest struct {
+	redirectURI string
+	nonce       string
+	challenge   string
+	user        model.OIDCIdentity
+	expiresAt   time.Time
+}
+
+// NewFakeIdentityProvider запускает тестовый провайдер с зарегистрированным клиентом clientID.
+// Сервер нужно остановить методом Close.
+func NewFakeIdentityProvider(clientID, clientSecret string) *FakeIdentityProvider {
+	idp := &FakeIdentityProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          generateKey(),
+		keyID:        fakeIDPKeyID,
+		codes:        make(map[string]fakeAuthRequest),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.handleDiscovery)
+	mux.HandleFunc("/authorize", idp.handleAuthorize)
+	mux.HandleFunc("/token", idp.handleToken)
+	mux.HandleFunc("/jwks", idp.handleJWKS)
+	idp.Server = httptest.NewServer(mux)
+
+	return idp
+}
+
+// SetUser задает пользователя, который войдет на странице входа провайдера.
+func (idp *FakeIdentityProvider) SetUser(subject, email string, emailVerified bool) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.user = model.OIDCIdentity{Subject: subject, Email: email, EmailVerified: emailVerified}
+}
+
+// RotateKey заменяет ключ подписи ID токенов новым ключом с другим идентификатором.
+// Провайдер публикует только новый ключ.
+func (idp *FakeIdentityProvider) RotateKey() {
+	key := generateKey()
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.key = key
+	idp.keyID = fakeIDPKeyID + "-" + randomToken()
+}
+
+// ExpireCodes делает просроченными все выданные, но еще не обмененные коды авторизации.
+func (idp *FakeIdentityProvider) ExpireCodes() {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	for code, req := range idp.codes {
+		req.expiresAt = time.Now().Add(-time.Second)
+		idp.codes[code] = req
+	}
+}
+
+// Authorize выполняет вход на странице провайдера по адресу authURL, полученному от клиента.
+// Возвращает код авторизации и state из адреса возврата или ошибку провайдера.
+func (idp *FakeIdentityProvider) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	location, err := resp.Location()
+	if err != nil {
+		return "", "", err
+	}
+	q := location.Query()
+	if q.Get("error") != "" {
+		return "", "", errors.New("authorization failed: " + q.Get("error"))
+	}
+	return q.Get("code"), q.Get("state"), nil
+}
+
+// handleDiscovery возвращает настройки провайдера.
+func (idp *FakeIdentityProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                idp.URL,
+		"authorization_endpoint":                idp.URL + "/authorize",
+		"token_endpoint":                        idp.URL + "/token",
+		"jwks_uri":                              idp.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// handleAuthorize выдает код авторизации текущему пользователю и перенаправляет на адрес возврата клиента.
+func (idp *FakeIdentityProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	params := redirect.Query()
+	params.Set("state", q.Get("state"))
+	if q.Get("client_id") != idp.ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		params.Set("error", "invalid_request")
+	} else {
+		code := randomToken()
+		idp.mu.Lock()
+		idp.codes[code] = fakeAuthRequest{
+			redirectURI: q.Get("redirect_uri"),
+			nonce:       q.Get("nonce"),
+			challenge:   q.Get("code_challenge"),
+			user:        idp.user,
+			expiresAt:   time.Now().Add(fakeCodeTTL),
+		}
+		idp.mu.Unlock()
+		params.Set("code", code)
+	}
+
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// handleToken обменивает код авторизации на подписанный ID токен.
+// Код можно обменять только один раз и только до истечения его срока действия.
+func (idp *FakeIdentityProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok || clientID != url.QueryEscape(idp.ClientID) || secret != url.QueryEscape(idp.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	idp.mu.Lock()
+	req, exists := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	key, keyID, modify := idp.key, idp.keyID, idp.ModifyToken
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !exists || time.Now().After(req.expiresAt) || req.redirectURI != r.PostForm.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            idp.URL,
+		"sub":            req.user.Subject,
+		"aud":            idp.ClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          req.nonce,
+		"email":          req.user.Email,
+		"email_verified": req.user.EmailVerified,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	if modify != nil {
+		modify(token)
+	}
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomToken(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// handleJWKS публикует ключ проверки ID токенов.
+func (idp *FakeIdentityProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	pub, keyID := idp.key.PublicKey, idp.keyID
+	idp.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, model.JWKS{Keys: []model.JWK{{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: "RS256",
+		Kid: keyID,
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+// generateKey создает ключ подписи ID токенов.
+func generateKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// randomToken возвращает случайную строку для кодов авторизации и токенов доступа.
+func randomToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// writeJSON отправляет ответ провайдера в формате JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
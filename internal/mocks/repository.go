@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -16,10 +17,10 @@ type MockLinkRepository struct {
 }
 
 // CreateLink создает новую запись о сокращенной ссылке.
-// Принимает контекст, идентификатор ссылки, оригинальный URL и ID пользователя.
+// Принимает контекст, идентификатор ссылки, оригинальный URL, ID пользователя и параметры ссылки.
 // Возвращает созданную запись или ошибку.
-func (m *MockLinkRepository) CreateLink(ctx context.Context, id, url string, userID uuid.UUID) (*model.Link, error) {
-	args := m.Called(ctx, id, url, userID)
+func (m *MockLinkRepository) CreateLink(ctx context.Context, id, url string, userID uuid.UUID, opts model.LinkOptions) (*model.Link, error) {
+	args := m.Called(ctx, id, url, userID, opts)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -37,14 +38,100 @@ func (m *MockLinkRepository) FindLink(ctx context.Context, id string) (*model.Li
 	return args.Get(0).(*model.Link), args.Error(1)
 }
 
+// UpdateLink сохраняет изменяемые параметры ссылки.
+// Принимает контекст и ссылку с новыми параметрами.
+// Возвращает ошибку в случае неудачи.
+func (m *MockLinkRepository) UpdateLink(ctx context.Context, link *model.Link) error {
+	args := m.Called(ctx, link)
+	return args.Error(0)
+}
+
+// FindLinkHistory возвращает историю изменений ссылки.
+// Принимает контекст и идентификатор ссылки.
+// Возвращает список версий или ошибку.
+func (m *MockLinkRepository) FindLinkHistory(ctx context.Context, id string) ([]model.LinkVersion, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]model.LinkVersion), args.Error(1)
+}
+
+// ConsumeClick списывает один переход по ссылке.
+// Принимает контекст и идентификатор ссылки.
+// Возвращает признак успешного списания и ошибку.
+func (m *MockLinkRepository) ConsumeClick(ctx context.Context, id string) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+// RecordVariants учитывает переходы на варианты адресов ссылок.
+// Принимает контекст и количество переходов по ссылкам и вариантам.
+// Возвращает ошибку.
+func (m *MockLinkRepository) RecordVariants(ctx context.Context, clicks map[string]map[string]int64) error {
+	args := m.Called(ctx, clicks)
+	return args.Error(0)
+}
+
+// FindVariantClicks возвращает количество переходов по вариантам адреса ссылки.
+// Принимает контекст и идентификатор ссылки.
+// Возвращает список счетчиков и ошибку.
+func (m *MockLinkRepository) FindVariantClicks(ctx context.Context, id string) ([]model.VariantStats, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]model.VariantStats), args.Error(1)
+}
+
 // FindUserLinks возвращает все ссылки, созданные указанным пользователем.
-// Принимает контекст и ID пользователя.
+// Принимает контекст, ID пользователя и фильтр.
 // Возвращает список ссылок или ошибку.
-func (m *MockLinkRepository) FindUserLinks(ctx context.Context, userID uuid.UUID) ([]model.Link, error) {
-	args := m.Called(ctx, userID)
+func (m *MockLinkRepository) FindUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter) ([]model.Link, error) {
+	args := m.Called(ctx, userID, filter)
 	return args.Get(0).([]model.Link), args.Error(1)
 }
 
+// WalkUserLinks передает функции ссылки, созданные указанным пользователем.
+// Принимает контекст, ID пользователя, фильтр и функцию обработки ссылки.
+// Возвращает первую ошибку функции или ошибку.
+func (m *MockLinkRepository) WalkUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter, fn func(*model.Link) error) error {
+	args := m.Called(ctx, userID, filter)
+	links := args.Get(0).([]model.Link)
+	for i := range links {
+		if err := fn(&links[i]); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+// AssignLabels назначает метки и папку ссылкам пользователя.
+// Принимает контекст, ID пользователя и запрос на изменение.
+// Возвращает количество затронутых ссылок и ошибку.
+func (m *MockLinkRepository) AssignLabels(ctx context.Context, userID uuid.UUID, req model.LabelRequest) (int, error) {
+	args := m.Called(ctx, userID, req)
+	return args.Int(0), args.Error(1)
+}
+
+// FindUserTags возвращает метки пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает список меток или ошибку.
+func (m *MockLinkRepository) FindUserTags(ctx context.Context, userID uuid.UUID) ([]model.TagInfo, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]model.TagInfo), args.Error(1)
+}
+
+// RenameTag переименовывает метку пользователя.
+// Принимает контекст, ID пользователя, текущее и новое название.
+// Возвращает количество затронутых ссылок и ошибку.
+func (m *MockLinkRepository) RenameTag(ctx context.Context, userID uuid.UUID, from, to string) (int, error) {
+	args := m.Called(ctx, userID, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+// DeleteTag удаляет метку пользователя.
+// Принимает контекст, ID пользователя и название метки.
+// Возвращает количество затронутых ссылок и ошибку.
+func (m *MockLinkRepository) DeleteTag(ctx context.Context, userID uuid.UUID, tag string) (int, error) {
+	args := m.Called(ctx, userID, tag)
+	return args.Int(0), args.Error(1)
+}
+
 // BatchCreate создает несколько записей о сокращенных ссылках.
 // Принимает контекст и список ссылок для создания.
 // Возвращает ошибку в случае неудачи.
@@ -61,6 +148,249 @@ func (m *MockLinkRepository) MarkDeletedURLs(ctx context.Context, ids []string,
 	return args.Int(0), args.Error(1)
 }
 
+// RestoreURLs снимает пометку удаления с указанных ссылок.
+// Принимает контекст, список идентификаторов ссылок, ID пользователя и границу срока хранения.
+// Возвращает идентификаторы восстановленных ссылок, ссылок с конфликтом и ошибку.
+func (m *MockLinkRepository) RestoreURLs(ctx context.Context, ids []string, userID uuid.UUID, deletedAfter time.Time) ([]string, []string, error) {
+	args := m.Called(ctx, ids, userID, deletedAfter)
+	return args.Get(0).([]string), args.Get(1).([]string), args.Error(2)
+}
+
+// TransferLinks передает ссылки другому пользователю.
+// Принимает контекст, идентификатор токена, список идентификаторов ссылок, ID отправителя и получателя.
+// Возвращает идентификаторы переданных ссылок и ошибку.
+func (m *MockLinkRepository) TransferLinks(ctx context.Context, tokenID string, ids []string, from, to uuid.UUID) ([]string, error) {
+	args := m.Called(ctx, tokenID, ids, from, to)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// FindTransfers возвращает журнал передачи ссылок пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает список записей журнала и ошибку.
+func (m *MockLinkRepository) FindTransfers(ctx context.Context, userID uuid.UUID) ([]model.LinkTransfer, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]model.LinkTransfer), args.Error(1)
+}
+
+// CreateAPIKey сохраняет API-ключ пользователя.
+// Принимает контекст и ключ.
+// Возвращает ошибку.
+func (m *MockLinkRepository) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+// FindAPIKeys возвращает API-ключи пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает список ключей и ошибку.
+func (m *MockLinkRepository) FindAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+// FindAPIKeyByHash находит API-ключ по хэшу.
+// Принимает контекст и хэш ключа.
+// Возвращает найденный ключ или ошибку.
+func (m *MockLinkRepository) FindAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.APIKey), args.Error(1)
+}
+
+// RevokeAPIKey отзывает API-ключ пользователя.
+// Принимает контекст, ID ключа и ID пользователя.
+// Возвращает ошибку.
+func (m *MockLinkRepository) RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+// CreateUser сохраняет учетную запись.
+// Принимает контекст и учетную запись.
+// Возвращает ошибку.
+func (m *MockLinkRepository) CreateUser(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+// FindUserByEmail находит учетную запись по адресу электронной почты.
+// Принимает контекст и адрес.
+// Возвращает найденную учетную запись или ошибку.
+func (m *MockLinkRepository) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+// FindUserByID находит учетную запись по идентификатору пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает найденную учетную запись или ошибку.
+func (m *MockLinkRepository) FindUserByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+// MergeUser передает данные одного пользователя другому.
+// Принимает контекст, ID исходного и ID целевого пользователя.
+// Возвращает количество переданных ссылок и ошибку.
+func (m *MockLinkRepository) MergeUser(ctx context.Context, from, to uuid.UUID) (int, error) {
+	args := m.Called(ctx, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+// SearchLinks находит ссылки любых пользователей.
+// Принимает контекст и условия поиска.
+// Возвращает список ссылок и ошибку.
+func (m *MockLinkRepository) SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.Link, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]model.Link), args.Error(1)
+}
+
+// SetLinkDisabled отключает или включает ссылку.
+// Принимает контекст, ID ссылки, момент отключения и причину.
+// Возвращает ошибку.
+func (m *MockLinkRepository) SetLinkDisabled(ctx context.Context, id string, disabledAt *time.Time, reason string) error {
+	args := m.Called(ctx, id, disabledAt, reason)
+	return args.Error(0)
+}
+
+// FindUserStats возвращает количество ссылок и API-ключей пользователей.
+// Принимает контекст, ID пользователя и максимальное количество записей.
+// Возвращает список записей и ошибку.
+func (m *MockLinkRepository) FindUserStats(ctx context.Context, userID *uuid.UUID, limit int) ([]model.UserStats, error) {
+	args := m.Called(ctx, userID, limit)
+	return args.Get(0).([]model.UserStats), args.Error(1)
+}
+
+// PurgeUser удаляет ссылки пользователя и отзывает его API-ключи.
+// Принимает контекст и ID пользователя.
+// Возвращает количество удаленных ссылок и ошибку.
+func (m *MockLinkRepository) PurgeUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+// RecordAdminAction добавляет запись в журнал действий администраторов.
+// Принимает контекст и запись.
+// Возвращает ошибку.
+func (m *MockLinkRepository) RecordAdminAction(ctx context.Context, action *model.AdminAction) error {
+	args := m.Called(ctx, action)
+	return args.Error(0)
+}
+
+// FindAdminActions возвращает последние записи журнала действий администраторов.
+// Принимает контекст и максимальное количество записей.
+// Возвращает список записей и ошибку.
+func (m *MockLinkRepository) FindAdminActions(ctx context.Context, limit int) ([]model.AdminAction, error) {
+	args := m.Called(ctx, limit)
+	return args.Get(0).([]model.AdminAction), args.Error(1)
+}
+
+// FindUserByIdentity находит учетную запись по пользователю провайдера.
+// Принимает контекст, идентификатор провайдера и пользователя у провайдера.
+// Возвращает учетную запись и ошибку.
+func (m *MockLinkRepository) FindUserByIdentity(ctx context.Context, issuer, subject string) (*model.User, error) {
+	args := m.Called(ctx, issuer, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+// LinkIdentity привязывает пользователя провайдера к учетной записи.
+// Принимает контекст и привязку.
+// Возвращает ошибку.
+func (m *MockLinkRepository) LinkIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	args := m.Called(ctx, identity)
+	return args.Error(0)
+}
+
+// TouchUsers сохраняет время последней активности пользователей.
+// Принимает контекст и время активности по пользователям.
+// Возвращает ошибку.
+func (m *MockLinkRepository) TouchUsers(ctx context.Context, seen map[uuid.UUID]time.Time) error {
+	args := m.Called(ctx, seen)
+	return args.Error(0)
+}
+
+// CountUsers возвращает количество пользователей.
+// Принимает контекст и начало периода активности.
+// Возвращает количество пользователей и ошибку.
+func (m *MockLinkRepository) CountUsers(ctx context.Context, activeSince time.Time) (*model.UserCount, error) {
+	args := m.Called(ctx, activeSince)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserCount), args.Error(1)
+}
+
+// FindInactiveUsers возвращает неактивных анонимных пользователей.
+// Принимает контекст, границу активности и максимальное количество пользователей.
+// Возвращает список идентификаторов и ошибку.
+func (m *MockLinkRepository) FindInactiveUsers(ctx context.Context, seenBefore time.Time, limit int) ([]uuid.UUID, error) {
+	args := m.Called(ctx, seenBefore, limit)
+	ids, _ := args.Get(0).([]uuid.UUID)
+	return ids, args.Error(1)
+}
+
+// PurgeInactiveUser удаляет ссылки неактивного анонимного пользователя.
+// Принимает контекст, ID пользователя и границу активности.
+// Возвращает количество удаленных ссылок и ошибку.
+func (m *MockLinkRepository) PurgeInactiveUser(ctx context.Context, userID uuid.UUID, seenBefore time.Time) (int, error) {
+	args := m.Called(ctx, userID, seenBefore)
+	return args.Int(0), args.Error(1)
+}
+
+// CreateDeviceCode сохраняет код привязки устройства.
+// Принимает контекст и код.
+// Возвращает ошибку.
+func (m *MockLinkRepository) CreateDeviceCode(ctx context.Context, code *model.DeviceCode) error {
+	args := m.Called(ctx, code)
+	return args.Error(0)
+}
+
+// ClaimDeviceCode находит и удаляет код привязки устройства.
+// Принимает контекст и хэш кода.
+// Возвращает код или ошибку.
+func (m *MockLinkRepository) ClaimDeviceCode(ctx context.Context, hash string) (*model.DeviceCode, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.DeviceCode), args.Error(1)
+}
+
+// RevokeToken добавляет токен в список отозванных.
+// Принимает контекст, идентификатор токена и момент его истечения.
+// Возвращает ошибку.
+func (m *MockLinkRepository) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, expiresAt)
+	return args.Error(0)
+}
+
+// RevokeUserTokens отзывает все токены пользователя.
+// Принимает контекст, ID пользователя и момент отзыва.
+// Возвращает ошибку.
+func (m *MockLinkRepository) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	args := m.Called(ctx, userID, before)
+	return args.Error(0)
+}
+
+// IsTokenRevoked проверяет, отозван ли токен.
+// Принимает контекст, ID пользователя, идентификатор токена и момент выпуска токена.
+// Возвращает признак отзыва или ошибку.
+func (m *MockLinkRepository) IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, userID, jti, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
 // Close закрывает соединение с хранилищем.
 // Возвращает ошибку в случае неудачи.
 func (m *MockLinkRepository) Close() error {
@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
@@ -16,17 +17,66 @@ type MockLinkService struct {
 }
 
 // ShorterLink создает сокращенную ссылку для указанного URL.
-// Принимает контекст, оригинальный URL и ID пользователя.
+// Принимает контекст, оригинальный URL, ID пользователя и параметры ссылки.
 // Возвращает сокращенную ссылку или ошибку.
-func (m *MockLinkService) ShorterLink(ctx context.Context, url string, userID uuid.UUID) (string, error) {
-	args := m.Called(ctx, url, userID)
+func (m *MockLinkService) ShorterLink(ctx context.Context, url string, userID uuid.UUID, opts model.LinkOptions) (string, error) {
+	args := m.Called(ctx, url, userID, opts)
+	return args.String(0), args.Error(1)
+}
+
+// CreateBundle создает страницу-подборку из нескольких ссылок.
+// Принимает контекст, ID пользователя и параметры подборки.
+// Возвращает сокращенный URL подборки или ошибку.
+func (m *MockLinkService) CreateBundle(ctx context.Context, userID uuid.UUID, opts model.LinkOptions) (string, error) {
+	args := m.Called(ctx, userID, opts)
 	return args.String(0), args.Error(1)
 }
 
 // FindLink ищет оригинальный URL по сокращенному идентификатору.
+// Принимает контекст, идентификатор сокращенной ссылки и сведения о переходе.
+// Возвращает параметры редиректа или ошибку.
+func (m *MockLinkService) FindLink(ctx context.Context, id string, visit model.Visit) (*model.Redirect, error) {
+	args := m.Called(ctx, id, visit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Redirect), args.Error(1)
+}
+
+// LookupLink возвращает параметры редиректа без учета перехода.
+// Принимает контекст, идентификатор сокращенной ссылки и сведения о переходе.
+// Возвращает параметры редиректа или ошибку.
+func (m *MockLinkService) LookupLink(ctx context.Context, id string, visit model.Visit) (*model.Redirect, error) {
+	args := m.Called(ctx, id, visit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Redirect), args.Error(1)
+}
+
+// PreviewLink возвращает сведения о ссылке без перехода по ней.
 // Принимает контекст и идентификатор сокращенной ссылки.
-// Возвращает оригинальный URL или ошибку.
-func (m *MockLinkService) FindLink(ctx context.Context, id string) (string, error) {
+// Возвращает описание ссылки или ошибку.
+func (m *MockLinkService) PreviewLink(ctx context.Context, id string) (*model.LinkPreview, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.LinkPreview), args.Error(1)
+}
+
+// GetShortURL возвращает сокращенный URL ссылки пользователя.
+// Принимает контекст, идентификатор ссылки и ID пользователя.
+// Возвращает сокращенный URL или ошибку.
+func (m *MockLinkService) GetShortURL(ctx context.Context, id string, userID uuid.UUID) (string, error) {
+	args := m.Called(ctx, id, userID)
+	return args.String(0), args.Error(1)
+}
+
+// GetPublicShortURL возвращает сокращенный URL неудаленной ссылки.
+// Принимает контекст и идентификатор ссылки.
+// Возвращает сокращенный URL или ошибку.
+func (m *MockLinkService) GetPublicShortURL(ctx context.Context, id string) (string, error) {
 	args := m.Called(ctx, id)
 	return args.String(0), args.Error(1)
 }
@@ -47,14 +97,147 @@ func (m *MockLinkService) BatchShorten(ctx context.Context, batch []model.BatchR
 	return args.Get(0).([]model.BatchResponse), args.Error(1)
 }
 
-// GetUserURLs возвращает все сокращенные ссылки пользователя.
-// Принимает контекст и ID пользователя.
+// GetUserURLs возвращает сокращенные ссылки пользователя.
+// Принимает контекст, ID пользователя и фильтр.
 // Возвращает список сокращенных ссылок или ошибку.
-func (m *MockLinkService) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]model.UserURLResponse, error) {
-	args := m.Called(ctx, userID)
+func (m *MockLinkService) GetUserURLs(ctx context.Context, userID uuid.UUID, filter model.LinkFilter) ([]model.UserURLResponse, error) {
+	args := m.Called(ctx, userID, filter)
 	return args.Get(0).([]model.UserURLResponse), args.Error(1)
 }
 
+// AssignLabels назначает метки и папку ссылкам пользователя.
+// Принимает контекст, ID пользователя и запрос на изменение.
+// Возвращает количество затронутых ссылок и ошибку.
+func (m *MockLinkService) AssignLabels(ctx context.Context, userID uuid.UUID, req model.LabelRequest) (int, error) {
+	args := m.Called(ctx, userID, req)
+	return args.Int(0), args.Error(1)
+}
+
+// GetUserTags возвращает метки пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает список меток или ошибку.
+func (m *MockLinkService) GetUserTags(ctx context.Context, userID uuid.UUID) ([]model.TagInfo, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]model.TagInfo), args.Error(1)
+}
+
+// RenameTag переименовывает метку пользователя.
+// Принимает контекст, ID пользователя, текущее и новое название.
+// Возвращает количество затронутых ссылок и ошибку.
+func (m *MockLinkService) RenameTag(ctx context.Context, userID uuid.UUID, from, to string) (int, error) {
+	args := m.Called(ctx, userID, from, to)
+	return args.Int(0), args.Error(1)
+}
+
+// DeleteTag удаляет метку пользователя.
+// Принимает контекст, ID пользователя и название метки.
+// Возвращает количество затронутых ссылок и ошибку.
+func (m *MockLinkService) DeleteTag(ctx context.Context, userID uuid.UUID, tag string) (int, error) {
+	args := m.Called(ctx, userID, tag)
+	return args.Int(0), args.Error(1)
+}
+
+// UpdateLink изменяет параметры ссылки пользователя.
+// Принимает контекст, идентификатор ссылки, ID пользователя и запрос на изменение.
+// Возвращает обновленное описание ссылки или ошибку.
+func (m *MockLinkService) UpdateLink(ctx context.Context, id string, userID uuid.UUID, req model.UpdateLinkRequest) (*model.UserURLResponse, error) {
+	args := m.Called(ctx, id, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserURLResponse), args.Error(1)
+}
+
+// GetLinkHistory возвращает историю изменений ссылки пользователя.
+// Принимает контекст, идентификатор ссылки и ID пользователя.
+// Возвращает список версий или ошибку.
+func (m *MockLinkService) GetLinkHistory(ctx context.Context, id string, userID uuid.UUID) ([]model.LinkVersion, error) {
+	args := m.Called(ctx, id, userID)
+	return args.Get(0).([]model.LinkVersion), args.Error(1)
+}
+
+// GetVariantStats возвращает количество переходов по вариантам адреса ссылки.
+// Принимает контекст, идентификатор ссылки и ID пользователя.
+// Возвращает список счетчиков или ошибку.
+func (m *MockLinkService) GetVariantStats(ctx context.Context, id string, userID uuid.UUID) ([]model.VariantStats, error) {
+	args := m.Called(ctx, id, userID)
+	return args.Get(0).([]model.VariantStats), args.Error(1)
+}
+
+// RollbackLink восстанавливает версию ссылки из истории.
+// Принимает контекст, идентификатор ссылки, ID пользователя и номер версии.
+// Возвращает обновленное описание ссылки или ошибку.
+func (m *MockLinkService) RollbackLink(ctx context.Context, id string, userID uuid.UUID, version int) (*model.UserURLResponse, error) {
+	args := m.Called(ctx, id, userID, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserURLResponse), args.Error(1)
+}
+
+// RestoreURLs снимает пометку удаления с указанных ссылок.
+// Принимает контекст, список идентификаторов ссылок и ID пользователя.
+// Возвращает итог восстановления или ошибку.
+func (m *MockLinkService) RestoreURLs(ctx context.Context, ids []string, userID uuid.UUID) (*model.RestoreResult, error) {
+	args := m.Called(ctx, ids, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RestoreResult), args.Error(1)
+}
+
+// CreateTransfer выпускает токен передачи ссылок.
+// Принимает контекст, список идентификаторов ссылок и ID пользователя.
+// Возвращает токен передачи или ошибку.
+func (m *MockLinkService) CreateTransfer(ctx context.Context, ids []string, userID uuid.UUID) (*model.TransferToken, error) {
+	args := m.Called(ctx, ids, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TransferToken), args.Error(1)
+}
+
+// RedeemTransfer принимает ссылки по токену передачи.
+// Принимает контекст, токен и ID получателя.
+// Возвращает итог передачи или ошибку.
+func (m *MockLinkService) RedeemTransfer(ctx context.Context, token string, userID uuid.UUID) (*model.TransferResult, error) {
+	args := m.Called(ctx, token, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.TransferResult), args.Error(1)
+}
+
+// GetTransfers возвращает журнал передачи ссылок пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает список записей журнала или ошибку.
+func (m *MockLinkService) GetTransfers(ctx context.Context, userID uuid.UUID) ([]model.LinkTransfer, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]model.LinkTransfer), args.Error(1)
+}
+
+// ExportLinks передает функции ссылки пользователя для экспорта.
+// Принимает контекст, ID пользователя и функцию обработки записи.
+// Возвращает первую ошибку функции или ошибку.
+func (m *MockLinkService) ExportLinks(ctx context.Context, userID uuid.UUID, fn func(*model.LinkRecord) error) error {
+	args := m.Called(ctx, userID)
+	records := args.Get(0).([]model.LinkRecord)
+	for i := range records {
+		if err := fn(&records[i]); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+// ImportLink создает ссылку по записи импорта.
+// Принимает контекст, ID пользователя и запись импорта.
+// Возвращает итог импорта записи.
+func (m *MockLinkService) ImportLink(ctx context.Context, userID uuid.UUID, rec model.LinkRecord) model.ImportResult {
+	args := m.Called(ctx, userID, rec)
+	return args.Get(0).(model.ImportResult)
+}
+
 // DeleteURLs помечает указанные сокращенные ссылки как удаленные.
 // Принимает контекст, список идентификаторов ссылок и ID пользователя.
 // Возвращает количество удаленных ссылок и ошибку.
@@ -63,4 +246,213 @@ func (m *MockLinkService) DeleteURLs(ctx context.Context, ids []string, userID u
 	return args.Int(0), args.Error(1)
 }
 
+// CreateAPIKey создает API-ключ пользователя.
+// Принимает контекст, ID пользователя и параметры ключа.
+// Возвращает созданный ключ или ошибку.
+func (m *MockLinkService) CreateAPIKey(ctx context.Context, userID uuid.UUID, req model.APIKeyRequest) (*model.CreatedAPIKey, error) {
+	args := m.Called(ctx, userID, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CreatedAPIKey), args.Error(1)
+}
+
+// GetAPIKeys возвращает API-ключи пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает список ключей или ошибку.
+func (m *MockLinkService) GetAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.APIKey), args.Error(1)
+}
+
+// RevokeAPIKey отзывает API-ключ пользователя.
+// Принимает контекст, ID ключа и ID пользователя.
+// Возвращает ошибку.
+func (m *MockLinkService) RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+// ResolveAPIKey находит владельца и области доступа API-ключа.
+// Принимает контекст и API-ключ.
+// Возвращает ID владельца, области доступа или ошибку.
+func (m *MockLinkService) ResolveAPIKey(ctx context.Context, key string) (uuid.UUID, []string, error) {
+	args := m.Called(ctx, key)
+	scopes, _ := args.Get(1).([]string)
+	return args.Get(0).(uuid.UUID), scopes, args.Error(2)
+}
+
+// SignUp регистрирует учетную запись.
+// Принимает контекст, ID текущего пользователя и данные для регистрации.
+// Возвращает учетную запись, количество перенесенных ссылок или ошибку.
+func (m *MockLinkService) SignUp(ctx context.Context, anonID uuid.UUID, creds model.Credentials) (*model.User, int, error) {
+	args := m.Called(ctx, anonID, creds)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).(*model.User), args.Int(1), args.Error(2)
+}
+
+// Login выполняет вход в учетную запись.
+// Принимает контекст, ID текущего пользователя и данные для входа.
+// Возвращает учетную запись, количество перенесенных ссылок или ошибку.
+func (m *MockLinkService) Login(ctx context.Context, anonID uuid.UUID, creds model.Credentials) (*model.User, int, error) {
+	args := m.Called(ctx, anonID, creds)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).(*model.User), args.Int(1), args.Error(2)
+}
+
+// StartOIDCLogin начинает вход через провайдер OpenID Connect.
+// Принимает контекст.
+// Возвращает адрес страницы входа, параметры входа или ошибку.
+func (m *MockLinkService) StartOIDCLogin(ctx context.Context) (string, *model.OIDCFlow, error) {
+	args := m.Called(ctx)
+	flow, _ := args.Get(1).(*model.OIDCFlow)
+	return args.String(0), flow, args.Error(2)
+}
+
+// CompleteOIDCLogin завершает вход через провайдер OpenID Connect.
+// Принимает контекст, ID текущего пользователя, параметры входа, state и код авторизации.
+// Возвращает учетную запись, количество перенесенных ссылок или ошибку.
+func (m *MockLinkService) CompleteOIDCLogin(ctx context.Context, anonID uuid.UUID, flow model.OIDCFlow, state, code string) (*model.User, int, error) {
+	args := m.Called(ctx, anonID, flow, state, code)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).(*model.User), args.Int(1), args.Error(2)
+}
+
+// RevokeToken отзывает JWT токен.
+// Принимает контекст, идентификатор токена и момент его истечения.
+// Возвращает ошибку.
+func (m *MockLinkService) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	args := m.Called(ctx, jti, expiresAt)
+	return args.Error(0)
+}
+
+// RevokeUserTokens отзывает все JWT токены пользователя.
+// Принимает контекст и ID пользователя.
+// Возвращает ошибку.
+func (m *MockLinkService) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// IsTokenRevoked проверяет, отозван ли JWT токен.
+// Принимает контекст, ID пользователя, идентификатор токена и момент его выпуска.
+// Возвращает признак отзыва или ошибку.
+func (m *MockLinkService) IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error) {
+	args := m.Called(ctx, userID, jti, issuedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+// UserRole возвращает роль учетной записи.
+// Принимает контекст и ID пользователя.
+// Возвращает роль или ошибку.
+func (m *MockLinkService) UserRole(ctx context.Context, userID uuid.UUID) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+// CreateDeviceCode выдает код привязки устройства.
+// Принимает контекст и ID пользователя.
+// Возвращает код или ошибку.
+func (m *MockLinkService) CreateDeviceCode(ctx context.Context, userID uuid.UUID) (*model.CreatedDeviceCode, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.CreatedDeviceCode), args.Error(1)
+}
+
+// ClaimDeviceCode привязывает устройство по коду.
+// Принимает контекст, ID пользователя, адрес клиента и запрос с кодом.
+// Возвращает пользователя, выдавшего код, количество перенесенных ссылок или ошибку.
+func (m *MockLinkService) ClaimDeviceCode(ctx context.Context, userID uuid.UUID, client string, req model.DeviceClaimRequest) (*model.User, int, error) {
+	args := m.Called(ctx, userID, client, req)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).(*model.User), args.Int(1), args.Error(2)
+}
+
+// TouchUser отмечает обращение пользователя.
+// Принимает ID пользователя.
+func (m *MockLinkService) TouchUser(userID uuid.UUID) {
+	m.Called(userID)
+}
+
+// SearchLinks находит ссылки любых пользователей.
+// Принимает контекст, ID администратора и условия поиска.
+// Возвращает список ссылок или ошибку.
+func (m *MockLinkService) SearchLinks(ctx context.Context, adminID uuid.UUID, filter model.AdminLinkFilter) ([]model.AdminLink, error) {
+	args := m.Called(ctx, adminID, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AdminLink), args.Error(1)
+}
+
+// DisableLink отключает ссылку.
+// Принимает контекст, ID администратора, ID ссылки и причину.
+// Возвращает ошибку.
+func (m *MockLinkService) DisableLink(ctx context.Context, adminID uuid.UUID, id, reason string) error {
+	args := m.Called(ctx, adminID, id, reason)
+	return args.Error(0)
+}
+
+// EnableLink снимает отключение со ссылки.
+// Принимает контекст, ID администратора, ID ссылки и причину.
+// Возвращает ошибку.
+func (m *MockLinkService) EnableLink(ctx context.Context, adminID uuid.UUID, id, reason string) error {
+	args := m.Called(ctx, adminID, id, reason)
+	return args.Error(0)
+}
+
+// GetUserStats возвращает количество ссылок и API-ключей пользователей.
+// Принимает контекст, ID администратора, ID пользователя и количество записей.
+// Возвращает список записей или ошибку.
+func (m *MockLinkService) GetUserStats(ctx context.Context, adminID uuid.UUID, userID *uuid.UUID, limit int) ([]model.UserStats, error) {
+	args := m.Called(ctx, adminID, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.UserStats), args.Error(1)
+}
+
+// PurgeUser удаляет ссылки пользователя и отзывает его API-ключи.
+// Принимает контекст, ID администратора, ID пользователя и причину.
+// Возвращает количество удаленных ссылок или ошибку.
+func (m *MockLinkService) PurgeUser(ctx context.Context, adminID, userID uuid.UUID, reason string) (int, error) {
+	args := m.Called(ctx, adminID, userID, reason)
+	return args.Int(0), args.Error(1)
+}
+
+// GetAdminActions возвращает записи журнала действий администраторов.
+// Принимает контекст, ID администратора и количество записей.
+// Возвращает список записей или ошибку.
+func (m *MockLinkService) GetAdminActions(ctx context.Context, adminID uuid.UUID, limit int) ([]model.AdminAction, error) {
+	args := m.Called(ctx, adminID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.AdminAction), args.Error(1)
+}
+
+// CountUsers возвращает количество пользователей.
+// Принимает контекст и ID администратора.
+// Возвращает количество пользователей или ошибку.
+func (m *MockLinkService) CountUsers(ctx context.Context, adminID uuid.UUID) (*model.UserCount, error) {
+	args := m.Called(ctx, adminID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.UserCount), args.Error(1)
+}
+
 var _ service.LinkService = (*MockLinkService)(nil)
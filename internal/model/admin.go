@@ -0,0 +1,84 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoleAdmin роль администратора, которой доступны эндпоинты /api/admin.
+// Роль назначается вне сервиса записью в поле role учетной записи.
+const RoleAdmin = "admin"
+
+// Действия администратора, записываемые в журнал.
+const (
+	AdminActionSearchLinks = "search_links"
+	AdminActionDisableLink = "disable_link"
+	AdminActionEnableLink  = "enable_link"
+	AdminActionViewUsers   = "view_users"
+	AdminActionPurgeUser   = "purge_user"
+	AdminActionViewActions = "view_actions"
+)
+
+// AdminLinkFilter представляет условия поиска ссылок администратором.
+type AdminLinkFilter struct {
+	// URL подстрока оригинального URL без учета регистра
+	URL string
+	// UserID владелец ссылок, nil означает любого владельца
+	UserID *uuid.UUID
+	// Limit максимальное количество найденных ссылок
+	Limit int
+}
+
+// AdminRequest представляет тело запроса на изменяющее действие администратора.
+type AdminRequest struct {
+	// Reason причина действия, сохраняемая в журнале
+	Reason string `json:"reason"`
+}
+
+// UserStats представляет количество ссылок и API-ключей пользователя.
+type UserStats struct {
+	// UserID идентификатор пользователя
+	UserID uuid.UUID `bun:"user_id" json:"user_id"`
+	// Email адрес электронной почты, если пользователь зарегистрирован
+	Email string `bun:"email" json:"email,omitempty"`
+	// Links общее количество ссылок, включая удаленные
+	Links int `bun:"links" json:"links"`
+	// ActiveLinks количество неудаленных и неотключенных ссылок
+	ActiveLinks int `bun:"active_links" json:"active_links"`
+	// DeletedLinks количество удаленных ссылок
+	DeletedLinks int `bun:"deleted_links" json:"deleted_links"`
+	// DisabledLinks количество ссылок, отключенных администратором
+	DisabledLinks int `bun:"disabled_links" json:"disabled_links"`
+	// APIKeys количество неотозванных API-ключей
+	APIKeys int `bun:"api_keys" json:"api_keys"`
+}
+
+// AdminAction представляет запись журнала действий администраторов.
+type AdminAction struct {
+	// ID порядковый номер записи
+	ID int64 `bun:"id,pk,autoincrement" json:"id"`
+	// AdminID идентификатор администратора
+	AdminID uuid.UUID `bun:"admin_id,notnull" json:"admin_id"`
+	// Action действие, одно из AdminAction*
+	Action string `bun:"action,notnull" json:"action"`
+	// Target объект действия: идентификатор ссылки, пользователя или условия поиска
+	Target string `bun:"target,notnull" json:"target,omitempty"`
+	// Reason причина действия
+	Reason string `bun:"reason,notnull" json:"reason,omitempty"`
+	// CreatedAt момент действия
+	CreatedAt time.Time `bun:"time_created,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// AdminLink представляет ссылку в результатах поиска администратора.
+type AdminLink struct {
+	Link
+	// ShortURL сокращенный URL
+	ShortURL string `json:"short_url"`
+}
+
+// PurgeResponse представляет результат удаления ссылок пользователя администратором.
+type PurgeResponse struct {
+	// Deleted количество удаленных ссылок
+	Deleted int `json:"deleted"`
+}
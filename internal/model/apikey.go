@@ -0,0 +1,67 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Области доступа API-ключей.
+// ScopeLinksRead чтение ссылок, меток, истории и статистики пользователя
+// ScopeLinksWrite создание и изменение ссылок и меток пользователя
+// ScopeLinksDelete удаление ссылок пользователя
+const (
+	ScopeLinksRead   = "links:read"
+	ScopeLinksWrite  = "links:write"
+	ScopeLinksDelete = "links:delete"
+)
+
+// APIKeyPrefix префикс, по которому API-ключ отличается от JWT токена
+const APIKeyPrefix = "usk_"
+
+// APIKey представляет API-ключ пользователя.
+// Сам ключ не хранится, вместо него хранится его хэш.
+type APIKey struct {
+	// ID идентификатор ключа
+	ID uuid.UUID `bun:"id,pk" json:"id"`
+	// UserID идентификатор владельца ключа
+	UserID uuid.UUID `bun:"user_id,notnull" json:"-"`
+	// Name название ключа, заданное владельцем
+	Name string `bun:"name" json:"name,omitempty"`
+	// Prefix начало ключа, по которому владелец может его узнать
+	Prefix string `bun:"prefix,notnull" json:"prefix"`
+	// Hash хэш ключа
+	Hash string `bun:"key_hash,notnull" json:"-"`
+	// Scopes области доступа ключа
+	Scopes []string `bun:"scopes,array" json:"scopes"`
+	// ExpiresAt момент, после которого ключ перестает действовать, nil означает бессрочный ключ
+	ExpiresAt *time.Time `bun:"expires_at" json:"expires_at,omitempty"`
+	// CreatedAt момент создания ключа
+	CreatedAt time.Time `bun:"time_created,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	// RevokedAt момент отзыва ключа
+	RevokedAt *time.Time `bun:"time_revoked" json:"revoked_at,omitempty"`
+}
+
+// HasScope сообщает, разрешает ли ключ указанную область доступа.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// APIKeyRequest представляет запрос на создание API-ключа.
+type APIKeyRequest struct {
+	// Name название ключа
+	Name string `json:"name"`
+	// Scopes области доступа ключа
+	Scopes []string `json:"scopes"`
+	// ExpiresAt момент, после которого ключ перестает действовать
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreatedAPIKey представляет созданный API-ключ.
+// Ключ возвращается только при создании и больше не может быть получен.
+type CreatedAPIKey struct {
+	APIKey
+	// Key API-ключ, передаваемый в заголовке "Authorization: Bearer <key>"
+	Key string `json:"key"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// AuthToken представляет JWT токен пользователя для аутентификации без cookie.
+type AuthToken struct {
+	// Token токен, передаваемый в заголовке "Authorization: Bearer <token>"
+	Token string `json:"token"`
+	// TokenType схема аутентификации, с которой передается токен
+	TokenType string `json:"token_type"`
+	// ExpiresAt момент, после которого токен перестает действовать
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// JWK представляет публичный ключ проверки подписи токенов в формате JSON Web Key.
+type JWK struct {
+	// Kty тип ключа: RSA, EC или OKP
+	Kty string `json:"kty"`
+	// Use назначение ключа, всегда sig
+	Use string `json:"use"`
+	// Alg алгоритм подписи: RS256, ES256 или EdDSA
+	Alg string `json:"alg"`
+	// Kid идентификатор ключа, совпадающий с заголовком kid токена
+	Kid string `json:"kid"`
+	// N модуль ключа RSA
+	N string `json:"n,omitempty"`
+	// E открытая экспонента ключа RSA
+	E string `json:"e,omitempty"`
+	// Crv кривая ключа EC или OKP
+	Crv string `json:"crv,omitempty"`
+	// X публичный ключ OKP или координата X ключа EC
+	X string `json:"x,omitempty"`
+	// Y координата Y ключа EC
+	Y string `json:"y,omitempty"`
+}
+
+// JWKS представляет набор публичных ключей, которыми другие сервисы могут проверять токены.
+type JWKS struct {
+	// Keys публичные ключи асимметричных алгоритмов подписи
+	Keys []JWK `json:"keys"`
+}
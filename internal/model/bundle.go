@@ -0,0 +1,34 @@
+package model
+
+// BundleEntryParam параметр запроса перехода, выбирающий ссылку страницы-подборки
+const BundleEntryParam = "entry"
+
+// BundleEntry представляет одну из ссылок страницы-подборки.
+type BundleEntry struct {
+	// Name название ссылки, уникальное в пределах подборки и используемое для подсчета переходов
+	Name string `json:"name"`
+	// Title текст ссылки на странице подборки
+	Title string `json:"title"`
+	// URL адрес, на который ведет ссылка
+	URL string `json:"url"`
+}
+
+// BundlePage представляет страницу-подборку, отображаемую по сокращенной ссылке.
+type BundlePage struct {
+	// ShortURL сокращенный URL подборки
+	ShortURL string `json:"short_url"`
+	// Title название подборки
+	Title string `json:"title,omitempty"`
+	// Entries ссылки подборки в порядке, заданном владельцем
+	Entries []BundlePageEntry `json:"entries"`
+}
+
+// BundlePageEntry представляет ссылку на странице-подборке.
+type BundlePageEntry struct {
+	// Name название ссылки
+	Name string `json:"name"`
+	// Title текст ссылки
+	Title string `json:"title"`
+	// URL адрес перехода по ссылке через сервис, учитывающий переход
+	URL string `json:"url"`
+}
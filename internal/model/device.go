@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceCode представляет код привязки устройства, выданный пользователю.
+// Сам код не хранится, вместо него хранится его хэш.
+type DeviceCode struct {
+	// Hash хэш кода
+	Hash string `bun:"code_hash,pk" json:"-"`
+	// UserID идентификатор пользователя, выдавшего код
+	UserID uuid.UUID `bun:"user_id,notnull" json:"-"`
+	// CreatedAt момент выдачи кода
+	CreatedAt time.Time `bun:"time_created,nullzero,notnull,default:current_timestamp" json:"-"`
+	// ExpiresAt момент, после которого код перестает действовать
+	ExpiresAt time.Time `bun:"time_expires,notnull" json:"expires_at"`
+}
+
+// CreatedDeviceCode представляет выданный код привязки устройства.
+// Код возвращается только при выдаче и больше не может быть получен.
+type CreatedDeviceCode struct {
+	// Code код, который нужно ввести на другом устройстве
+	Code string `json:"code"`
+	// ExpiresAt момент, после которого код перестает действовать
+	ExpiresAt time.Time `json:"expires_at"`
+	// QR изображение QR-кода с кодом привязки в виде data URI, если оно запрошено
+	QR string `json:"qr,omitempty"`
+}
+
+// DeviceClaimRequest представляет запрос на привязку устройства по коду.
+type DeviceClaimRequest struct {
+	// Code код, выданный на другом устройстве
+	Code string `json:"code"`
+	// RefuseMerge отказ от переноса ссылок текущего пользователя пользователю, выдавшему код
+	RefuseMerge bool `json:"refuse_merge"`
+}
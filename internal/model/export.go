@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// Форматы экспорта и импорта ссылок пользователя.
+// FormatCSV таблица с заголовком, сложные поля записываются в ячейки в виде JSON
+// FormatJSON JSON-массив записей
+// FormatNDJSON по одной JSON-записи в строке
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Результаты импорта строки.
+// ImportCreated ссылка создана
+// ImportExists оригинальный URL уже сокращен, новая ссылка не создавалась
+// ImportFailed строка не прошла проверку или не была сохранена
+const (
+	ImportCreated = "created"
+	ImportExists  = "exists"
+	ImportFailed  = "failed"
+)
+
+// LinkRecord представляет ссылку пользователя при экспорте и импорте.
+type LinkRecord struct {
+	// ID идентификатор ссылки, при импорте сохраняется, если он свободен
+	ID string `json:"id,omitempty"`
+	// ShortURL сокращенный URL, при импорте не учитывается
+	ShortURL string `json:"short_url,omitempty"`
+	// OriginalURL оригинальный URL
+	OriginalURL string `json:"original_url"`
+	// Folder папка ссылки
+	Folder string `json:"folder,omitempty"`
+	// Tags метки ссылки
+	Tags []string `json:"tags,omitempty"`
+	// CreatedAt момент создания ссылки, при импорте не учитывается
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// LinkOptions параметры ссылки, при экспорте max_clicks содержит оставшееся количество переходов
+	LinkOptions
+}
+
+// ImportResult представляет итог импорта одной строки.
+type ImportResult struct {
+	// Row номер строки данных, начиная с 1
+	Row int `json:"row"`
+	// Status результат импорта: created, exists или failed
+	Status string `json:"status"`
+	// ID идентификатор созданной или существующей ссылки
+	ID string `json:"id,omitempty"`
+	// ShortURL сокращенный URL созданной или существующей ссылки
+	ShortURL string `json:"short_url,omitempty"`
+	// AliasChanged признак того, что идентификатор из строки занят и ссылка получила новый
+	AliasChanged bool `json:"alias_changed,omitempty"`
+	// Error описание ошибки для строк со статусом failed
+	Error string `json:"error,omitempty"`
+}
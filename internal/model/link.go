@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -15,5 +17,151 @@ type Link struct {
 	UserID uuid.UUID `bun:",notnull" json:"user_id"`
 	// IsDeleted флаг, указывающий, была ли ссылка помечена как удаленная
 	IsDeleted bool `bun:",default:false" json:"is_deleted"`
-	// TimeCreated time.Time `bun:",default:now()" json:"time_created"`
+	// DeletedAt момент пометки ссылки как удаленной
+	DeletedAt *time.Time `bun:"time_deleted" json:"deleted_at,omitempty"`
+	// ClicksLeft оставшееся количество переходов, nil означает отсутствие ограничения
+	ClicksLeft *int64 `bun:"clicks_left" json:"clicks_left,omitempty"`
+	// ActiveFrom момент, начиная с которого ссылка доступна для перехода
+	ActiveFrom *time.Time `bun:"active_from" json:"active_from,omitempty"`
+	// ActiveUntil момент, после которого ссылка перестает быть доступной
+	ActiveUntil *time.Time `bun:"active_until" json:"active_until,omitempty"`
+	// Folder папка, в которой владелец хранит ссылку
+	Folder string `bun:"folder" json:"folder,omitempty"`
+	// Tags метки, назначенные ссылке владельцем
+	Tags []string `bun:"tags,array,scanonly" json:"tags,omitempty"`
+	// Title название ссылки, заданное владельцем
+	Title string `bun:"title" json:"title,omitempty"`
+	// RedirectCode HTTP-статус редиректа, nil означает значение из конфигурации
+	RedirectCode *int `bun:"redirect_code" json:"redirect_code,omitempty"`
+	// CacheMaxAge время кэширования редиректа в секундах, nil означает значение из конфигурации
+	CacheMaxAge *int `bun:"cache_max_age" json:"cache_max_age,omitempty"`
+	// Passthrough режим передачи пути и параметров запроса, пустое значение равно PassthroughOff
+	Passthrough string `bun:"passthrough" json:"passthrough,omitempty"`
+	// UTM набор UTM-меток, добавляемых к оригинальному URL
+	UTM *UTMParams `bun:"utm,type:jsonb" json:"utm,omitempty"`
+	// Targets варианты адреса с весами, при их наличии переход выполняется на один из них
+	Targets []LinkTarget `bun:"targets,type:jsonb" json:"targets,omitempty"`
+	// Rules правила выбора адреса, проверяемые по порядку до выбора варианта
+	Rules []TargetRule `bun:"rules,type:jsonb" json:"rules,omitempty"`
+	// Entries ссылки страницы-подборки, при их наличии вместо редиректа отображается подборка
+	Entries []BundleEntry `bun:"entries,type:jsonb" json:"entries,omitempty"`
+	// DisabledAt момент отключения ссылки администратором, nil для действующих ссылок
+	DisabledAt *time.Time `bun:"time_disabled" json:"disabled_at,omitempty"`
+	// DisabledReason причина отключения ссылки администратором
+	DisabledReason string `bun:"disabled_reason" json:"disabled_reason,omitempty"`
+	// TimeCreated момент создания ссылки
+	TimeCreated time.Time `bun:"time_created,nullzero,notnull,default:current_timestamp" json:"time_created"`
+}
+
+// Области дедупликации оригинальных URL при создании ссылок.
+// DedupeGlobal возвращает существующую ссылку на тот же URL, кем бы она ни была создана
+// DedupeUser возвращает существующую ссылку на тот же URL только среди ссылок пользователя
+// DedupeNone всегда создает новую ссылку
+const (
+	DedupeGlobal = "global"
+	DedupeUser   = "user"
+	DedupeNone   = "none"
+)
+
+// LinkOptions представляет необязательные параметры, задаваемые при создании ссылки.
+type LinkOptions struct {
+	// MaxClicks максимальное количество переходов по ссылке
+	MaxClicks *int64 `json:"max_clicks,omitempty"`
+	// ActiveFrom начало окна активности ссылки
+	ActiveFrom *time.Time `json:"active_from,omitempty"`
+	// ActiveUntil окончание окна активности ссылки
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	// Title название ссылки, отображаемое при предпросмотре
+	Title string `json:"title,omitempty"`
+	// RedirectCode HTTP-статус редиректа: 301, 302, 303, 307 или 308
+	RedirectCode *int `json:"redirect_code,omitempty"`
+	// CacheMaxAge время кэширования редиректа в секундах
+	CacheMaxAge *int `json:"cache_max_age,omitempty"`
+	// Passthrough режим передачи пути и параметров запроса: off, path или query
+	Passthrough string `json:"passthrough,omitempty"`
+	// UTM набор UTM-меток, добавляемых к оригинальному URL
+	UTM *UTMParams `json:"utm,omitempty"`
+	// Targets варианты адреса с весами для распределения трафика
+	Targets []LinkTarget `json:"targets,omitempty"`
+	// Rules правила выбора адреса по платформе, языку и времени суток
+	Rules []TargetRule `json:"rules,omitempty"`
+	// Entries ссылки страницы-подборки, задаются только при создании подборки
+	Entries []BundleEntry `json:"entries,omitempty"`
+}
+
+// Apply переносит параметры создания в запись ссылки.
+func (o LinkOptions) Apply(link *Link) {
+	if o.MaxClicks != nil {
+		left := *o.MaxClicks
+		link.ClicksLeft = &left
+	}
+	link.ActiveFrom = o.ActiveFrom
+	link.ActiveUntil = o.ActiveUntil
+	link.Title = o.Title
+	link.RedirectCode = o.RedirectCode
+	link.CacheMaxAge = o.CacheMaxAge
+	link.Passthrough = o.Passthrough
+	link.UTM = o.UTM
+	link.Targets = o.Targets
+	link.Rules = o.Rules
+	link.Entries = o.Entries
+}
+
+// IsBundle сообщает, является ли ссылка страницей-подборкой.
+func (l *Link) IsBundle() bool {
+	return len(l.Entries) > 0
+}
+
+// IsActiveAt сообщает, попадает ли указанный момент в окно активности ссылки.
+// Возвращает признаки того, что окно еще не открылось или уже закрылось.
+func (l *Link) IsActiveAt(t time.Time) (notYet, expired bool) {
+	if l.ActiveFrom != nil && t.Before(*l.ActiveFrom) {
+		return true, false
+	}
+	if l.ActiveUntil != nil && !t.Before(*l.ActiveUntil) {
+		return false, true
+	}
+	return false, false
+}
+
+// LinkVersion представляет одно из предыдущих значений оригинального URL ссылки.
+type LinkVersion struct {
+	// Version порядковый номер версии, начиная с 1
+	Version int `bun:"version" json:"version"`
+	// OriginalURL оригинальный URL, на который указывала ссылка
+	OriginalURL string `bun:"link" json:"original_url"`
+	// ReplacedAt момент, когда значение было заменено
+	ReplacedAt time.Time `bun:"time_replaced" json:"replaced_at"`
+}
+
+// LinkFilter представляет условия отбора ссылок пользователя.
+type LinkFilter struct {
+	// Tag метка, которая должна быть назначена ссылке
+	Tag string
+	// Folder папка, в которой должна находиться ссылка, nil означает любую папку
+	Folder *string
+}
+
+// Match проверяет, удовлетворяет ли ссылка условиям отбора.
+func (f LinkFilter) Match(link *Link) bool {
+	if f.Folder != nil && link.Folder != *f.Folder {
+		return false
+	}
+	if f.Tag == "" {
+		return true
+	}
+	for _, tag := range link.Tags {
+		if tag == f.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// TagInfo представляет метку пользователя и количество ссылок с ней.
+type TagInfo struct {
+	// Name название метки
+	Name string `bun:"tag" json:"name"`
+	// Links количество ссылок с меткой
+	Links int `bun:"links" json:"links"`
 }
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCIdentity представляет пользователя, подтвержденного провайдером OpenID Connect.
+type OIDCIdentity struct {
+	// Issuer идентификатор провайдера
+	Issuer string
+	// Subject идентификатор пользователя у провайдера
+	Subject string
+	// Email адрес электронной почты пользователя, если провайдер его передал
+	Email string
+	// EmailVerified признак того, что провайдер подтвердил адрес электронной почты
+	EmailVerified bool
+}
+
+// OIDCFlow представляет параметры незавершенного входа через провайдер OpenID Connect.
+// Хранится у клиента в cookie между перенаправлением к провайдеру и возвратом от него.
+type OIDCFlow struct {
+	// State значение, которое провайдер возвращает вместе с кодом авторизации
+	State string `json:"state"`
+	// Nonce значение, которое провайдер включает в ID токен
+	Nonce string `json:"nonce"`
+	// Verifier секрет PKCE, по которому провайдер проверяет обмен кода авторизации
+	Verifier string `json:"verifier"`
+}
+
+// UserIdentity представляет привязку пользователя провайдера OpenID Connect к учетной записи.
+type UserIdentity struct {
+	// Issuer идентификатор провайдера
+	Issuer string `bun:"issuer,pk" json:"issuer"`
+	// Subject идентификатор пользователя у провайдера
+	Subject string `bun:"subject,pk" json:"subject"`
+	// UserID идентификатор учетной записи
+	UserID uuid.UUID `bun:"user_id,notnull" json:"user_id"`
+	// CreatedAt момент привязки
+	CreatedAt time.Time `bun:"time_created,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package model
+
+import "encoding/json"
+
+// Optional представляет поле запроса на частичное изменение.
+// Позволяет отличить отсутствующее поле от явно переданного null.
+type Optional[T any] struct {
+	// Value новое значение поля, nil означает сброс значения
+	Value *T
+	// Set признак того, что поле присутствовало в запросе
+	Set bool
+}
+
+// UnmarshalJSON декодирует значение поля и отмечает его как переданное.
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+
+	var v T
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	o.Value = &v
+	return nil
+}
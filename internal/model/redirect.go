@@ -0,0 +1,127 @@
+package model
+
+import (
+	"net/url"
+	"time"
+)
+
+// Режимы передачи пути и параметров запроса при редиректе.
+// PassthroughOff оставляет оригинальный URL без изменений
+// PassthroughPath дописывает к оригинальному URL дополнительный путь и параметры запроса
+// PassthroughQuery добавляет к оригинальному URL только параметры запроса
+const (
+	PassthroughOff   = "off"
+	PassthroughPath  = "path"
+	PassthroughQuery = "query"
+)
+
+// Платформы посетителя, различаемые правилами выбора адреса.
+// PlatformIOS устройства iPhone, iPad и iPod
+// PlatformAndroid устройства на Android
+// PlatformDesktop остальные устройства
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformDesktop = "desktop"
+)
+
+// UTMParams представляет набор UTM-меток, добавляемых к оригинальному URL при редиректе.
+type UTMParams struct {
+	// Source значение utm_source
+	Source string `json:"source,omitempty"`
+	// Medium значение utm_medium
+	Medium string `json:"medium,omitempty"`
+	// Campaign значение utm_campaign
+	Campaign string `json:"campaign,omitempty"`
+	// Term значение utm_term
+	Term string `json:"term,omitempty"`
+	// Content значение utm_content
+	Content string `json:"content,omitempty"`
+}
+
+// Values возвращает UTM-метки в виде параметров запроса, пропуская пустые значения.
+func (u *UTMParams) Values() url.Values {
+	res := url.Values{}
+	if u == nil {
+		return res
+	}
+	for key, value := range map[string]string{
+		"utm_source":   u.Source,
+		"utm_medium":   u.Medium,
+		"utm_campaign": u.Campaign,
+		"utm_term":     u.Term,
+		"utm_content":  u.Content,
+	} {
+		if value != "" {
+			res.Set(key, value)
+		}
+	}
+	return res
+}
+
+// LinkTarget представляет один из вариантов адреса ссылки с распределением трафика по весу.
+type LinkTarget struct {
+	// Name название варианта, уникальное в пределах ссылки
+	Name string `json:"name"`
+	// URL адрес варианта
+	URL string `json:"url"`
+	// Weight доля трафика варианта относительно суммы весов
+	Weight int `json:"weight"`
+}
+
+// HourRange представляет интервал часов суток по UTC.
+// Час начала входит в интервал, час окончания нет; начало позже окончания означает переход через полночь.
+type HourRange struct {
+	// From час начала интервала, от 0 до 23
+	From int `json:"from"`
+	// To час окончания интервала, от 0 до 23
+	To int `json:"to"`
+}
+
+// Contains сообщает, попадает ли час указанного момента по UTC в интервал.
+func (r HourRange) Contains(t time.Time) bool {
+	hour := t.UTC().Hour()
+	if r.From < r.To {
+		return hour >= r.From && hour < r.To
+	}
+	return hour >= r.From || hour < r.To
+}
+
+// TargetRule представляет правило выбора адреса ссылки по сведениям о запросе перехода.
+// Правило срабатывает, если выполнены все заданные в нем условия.
+type TargetRule struct {
+	// Platform платформа посетителя: ios, android или desktop
+	Platform string `json:"platform,omitempty"`
+	// Language язык из заголовка Accept-Language, например "de" или "pt-br"
+	Language string `json:"language,omitempty"`
+	// Hours интервал часов суток по UTC
+	Hours *HourRange `json:"hours,omitempty"`
+	// URL адрес, на который выполняется переход при срабатывании правила
+	URL string `json:"url"`
+}
+
+// VariantStats представляет количество переходов на вариант адреса ссылки.
+type VariantStats struct {
+	// Name название варианта
+	Name string `bun:"variant" json:"name"`
+	// URL адрес варианта
+	URL string `bun:"-" json:"url"`
+	// Weight вес варианта
+	Weight int `bun:"-" json:"weight"`
+	// Clicks количество переходов на вариант
+	Clicks int64 `bun:"clicks" json:"clicks"`
+}
+
+// Visit представляет сведения о запросе перехода по сокращенной ссылке.
+type Visit struct {
+	// Path дополнительный путь после идентификатора ссылки
+	Path string
+	// Query параметры запроса перехода
+	Query url.Values
+	// VisitorID идентификатор посетителя для закрепления за ним варианта адреса
+	VisitorID string
+	// UserAgent значение заголовка User-Agent
+	UserAgent string
+	// AcceptLanguage значение заголовка Accept-Language
+	AcceptLanguage string
+}
@@ -1,10 +1,14 @@
 package model
 
+import "time"
+
 // ShortenRequest представляет запрос на сокращение URL.
 // Используется в API для получения URL, который нужно сократить.
 type ShortenRequest struct {
 	// URL оригинальный URL, который нужно сократить
 	URL string `json:"url"`
+	// LinkOptions необязательные параметры ссылки
+	LinkOptions
 }
 
 // ShortenResponse представляет ответ на запрос сокращения URL.
@@ -21,6 +25,8 @@ type BatchRequest struct {
 	CorrelationID string `json:"correlation_id"`
 	// OriginalURL оригинальный URL, который нужно сократить
 	OriginalURL string `json:"original_url"`
+	// LinkOptions необязательные параметры ссылки
+	LinkOptions
 }
 
 // BatchResponse представляет ответ на пакетный запрос сокращения URL.
@@ -39,8 +45,146 @@ type UserURLResponse struct {
 	ShortURL string `json:"short_url"`
 	// OriginalURL оригинальный URL
 	OriginalURL string `json:"original_url"`
+	// ActiveFrom начало окна активности ссылки
+	ActiveFrom *time.Time `json:"active_from,omitempty"`
+	// ActiveUntil окончание окна активности ссылки
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+	// Folder папка ссылки
+	Folder string `json:"folder,omitempty"`
+	// Tags метки ссылки
+	Tags []string `json:"tags,omitempty"`
+	// Title название ссылки
+	Title string `json:"title,omitempty"`
+	// RedirectCode HTTP-статус редиректа, если он задан для ссылки
+	RedirectCode *int `json:"redirect_code,omitempty"`
+	// CacheMaxAge время кэширования редиректа, если оно задано для ссылки
+	CacheMaxAge *int `json:"cache_max_age,omitempty"`
+	// Passthrough режим передачи пути и параметров запроса
+	Passthrough string `json:"passthrough,omitempty"`
+	// UTM набор UTM-меток ссылки
+	UTM *UTMParams `json:"utm,omitempty"`
+	// Targets варианты адреса ссылки с весами
+	Targets []LinkTarget `json:"targets,omitempty"`
+	// Rules правила выбора адреса ссылки
+	Rules []TargetRule `json:"rules,omitempty"`
+	// Entries ссылки страницы-подборки
+	Entries []BundleEntry `json:"entries,omitempty"`
+	// DisabledAt момент отключения ссылки администратором
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// DisabledReason причина отключения ссылки администратором
+	DisabledReason string `json:"disabled_reason,omitempty"`
+}
+
+// Redirect представляет результат разрешения сокращенной ссылки.
+type Redirect struct {
+	// URL адрес, на который выполняется редирект
+	URL string
+	// Code HTTP-статус редиректа
+	Code int
+	// Variant название выбранного варианта адреса, пустое для ссылок без вариантов
+	Variant string
+	// MaxAge время кэширования редиректа в секундах, 0 запрещает кэширование
+	MaxAge int
+	// Bundle страница-подборка, отображаемая вместо редиректа
+	Bundle *BundlePage
+}
+
+// LinkPreview представляет сведения о ссылке, показываемые перед переходом по ней.
+type LinkPreview struct {
+	// ShortURL сокращенный URL
+	ShortURL string `json:"short_url"`
+	// OriginalURL оригинальный URL, на который ведет ссылка
+	OriginalURL string `json:"original_url"`
+	// Title название ссылки, заданное владельцем
+	Title string `json:"title,omitempty"`
+	// CreatedAt момент создания ссылки, если он известен
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// UpdateLinkRequest представляет запрос владельца на изменение параметров ссылки.
+// Отсутствующие поля не изменяются, поля со значением null сбрасываются.
+type UpdateLinkRequest struct {
+	// OriginalURL новый оригинальный URL ссылки
+	OriginalURL Optional[string] `json:"original_url"`
+	// ActiveFrom новое начало окна активности ссылки
+	ActiveFrom Optional[time.Time] `json:"active_from"`
+	// ActiveUntil новое окончание окна активности ссылки
+	ActiveUntil Optional[time.Time] `json:"active_until"`
+	// Folder новая папка ссылки, пустое значение или null переносит ссылку в корень
+	Folder Optional[string] `json:"folder"`
+	// Title новое название ссылки, пустое значение или null удаляет название
+	Title Optional[string] `json:"title"`
+	// RedirectCode новый HTTP-статус редиректа, null возвращает значение по умолчанию
+	RedirectCode Optional[int] `json:"redirect_code"`
+	// CacheMaxAge новое время кэширования редиректа, null возвращает значение по умолчанию
+	CacheMaxAge Optional[int] `json:"cache_max_age"`
+	// Passthrough новый режим передачи пути и параметров запроса, null отключает передачу
+	Passthrough Optional[string] `json:"passthrough"`
+	// UTM новый набор UTM-меток, null удаляет метки
+	UTM Optional[UTMParams] `json:"utm"`
+	// Targets новые варианты адреса, null или пустой список отключает распределение
+	Targets Optional[[]LinkTarget] `json:"targets"`
+	// Rules новые правила выбора адреса, null или пустой список удаляет правила
+	Rules Optional[[]TargetRule] `json:"rules"`
+	// Entries новые ссылки страницы-подборки, изменяются только у подборок
+	Entries Optional[[]BundleEntry] `json:"entries"`
+}
+
+// LabelRequest представляет запрос на массовое назначение меток и папки ссылкам пользователя.
+type LabelRequest struct {
+	// IDs идентификаторы изменяемых ссылок
+	IDs []string `json:"ids"`
+	// AddTags метки, которые нужно назначить
+	AddTags []string `json:"add_tags,omitempty"`
+	// RemoveTags метки, которые нужно снять
+	RemoveTags []string `json:"remove_tags,omitempty"`
+	// Folder новая папка ссылок, если поле передано
+	Folder Optional[string] `json:"folder"`
+}
+
+// RenameTagRequest представляет запрос на переименование метки.
+type RenameTagRequest struct {
+	// Name новое название метки
+	Name string `json:"name"`
+}
+
+// LabelResponse представляет результат операции над метками.
+type LabelResponse struct {
+	// Updated количество затронутых ссылок
+	Updated int `json:"updated"`
+}
+
+// RollbackRequest представляет запрос на восстановление предыдущей версии ссылки.
+type RollbackRequest struct {
+	// Version номер восстанавливаемой версии из истории ссылки
+	Version int `json:"version"`
+}
+
+// QRRequest представляет параметры запроса на генерацию QR-кода ссылки.
+type QRRequest struct {
+	// Format формат изображения: png или svg
+	Format string `form:"format"`
+	// Size размер стороны изображения в пикселях
+	Size int `form:"size"`
+	// ECC уровень коррекции ошибок: l, m, q или h
+	ECC string `form:"ecc"`
 }
 
 // DeleteRequest представляет запрос на удаление сокращенных ссылок.
 // Содержит список идентификаторов ссылок для удаления.
 type DeleteRequest []string
+
+// RestoreRequest представляет запрос на восстановление удаленных ссылок.
+// Содержит список идентификаторов ссылок для восстановления.
+type RestoreRequest []string
+
+// RestoreResult представляет итог восстановления удаленных ссылок.
+type RestoreResult struct {
+	// Restored идентификаторы восстановленных ссылок
+	Restored []string `json:"restored"`
+	// Conflicts идентификаторы ссылок, оригинальный URL которых уже сокращен другой ссылкой
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Skipped идентификаторы ссылок, которые не найдены, не удалены,
+	// принадлежат другому пользователю или удалены раньше срока хранения
+	Skipped []string `json:"skipped,omitempty"`
+}
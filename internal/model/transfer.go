@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransferRequest представляет запрос владельца на передачу ссылок другому пользователю.
+type TransferRequest struct {
+	// IDs идентификаторы передаваемых ссылок
+	IDs []string `json:"ids"`
+}
+
+// TransferToken представляет подписанный токен передачи ссылок.
+type TransferToken struct {
+	// Token токен, который получатель предъявляет для принятия ссылок
+	Token string `json:"token"`
+	// ExpiresAt момент, после которого токен перестает действовать
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// RedeemRequest представляет запрос получателя на принятие ссылок по токену передачи.
+type RedeemRequest struct {
+	// Token токен передачи ссылок
+	Token string `json:"token"`
+}
+
+// TransferResult представляет итог принятия ссылок по токену передачи.
+type TransferResult struct {
+	// Transferred идентификаторы ссылок, перешедших к получателю
+	Transferred []string `json:"transferred"`
+}
+
+// LinkTransfer представляет запись журнала передачи ссылки между пользователями.
+type LinkTransfer struct {
+	// TokenID идентификатор токена, по которому выполнена передача
+	TokenID string `bun:"token_id" json:"token_id"`
+	// LinkID идентификатор переданной ссылки
+	LinkID string `bun:"link_id" json:"link_id"`
+	// FromUser идентификатор прежнего владельца
+	FromUser uuid.UUID `bun:"from_user" json:"from_user"`
+	// ToUser идентификатор нового владельца
+	ToUser uuid.UUID `bun:"to_user" json:"to_user"`
+	// TransferredAt момент передачи
+	TransferredAt time.Time `bun:"time_transferred" json:"transferred_at"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User представляет зарегистрированную учетную запись пользователя.
+// Идентификатор учетной записи используется как идентификатор пользователя в токенах и ссылках.
+type User struct {
+	// ID идентификатор пользователя
+	ID uuid.UUID `bun:"id,pk" json:"id"`
+	// Email адрес электронной почты в нижнем регистре, используемый для входа.
+	// Может быть пустым у учетных записей, созданных при входе через OpenID Connect
+	Email string `bun:"email,nullzero" json:"email,omitempty"`
+	// PasswordHash хэш пароля bcrypt, пустой у учетных записей без пароля
+	PasswordHash string `bun:"password_hash,notnull" json:"-"`
+	// Role роль пользователя, пустая для обычных пользователей
+	Role string `bun:"role,notnull" json:"role,omitempty"`
+	// CreatedAt момент регистрации
+	CreatedAt time.Time `bun:"time_created,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// Credentials представляет данные для регистрации и входа.
+type Credentials struct {
+	// Email адрес электронной почты
+	Email string `json:"email"`
+	// Password пароль
+	Password string `json:"password"`
+}
+
+// AccountResponse представляет ответ на регистрацию или вход.
+type AccountResponse struct {
+	// User учетная запись, от имени которой теперь выполняются запросы
+	User User `json:"user"`
+	// MergedLinks количество ссылок анонимного пользователя, перенесенных в учетную запись
+	MergedLinks int `json:"merged_links"`
+	AuthToken
+}
+
+// UserActivity представляет запись реестра пользователей, включая анонимных.
+type UserActivity struct {
+	// UserID идентификатор пользователя
+	UserID uuid.UUID `json:"user_id"`
+	// CreatedAt момент первого обращения пользователя
+	CreatedAt time.Time `json:"created_at"`
+	// LastSeenAt момент последнего обращения пользователя
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+// UserCount представляет количество пользователей сервиса.
+type UserCount struct {
+	// Total общее количество пользователей
+	Total int `bun:"total" json:"total"`
+	// Registered количество учетных записей
+	Registered int `bun:"registered" json:"registered"`
+	// Anonymous количество анонимных пользователей из реестра
+	Anonymous int `bun:"anonymous" json:"anonymous"`
+	// Active количество пользователей, обращавшихся к сервису за последние сутки
+	Active int `bun:"active" json:"active"`
+}
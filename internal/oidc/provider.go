@@ -0,0 +1,336 @@
+// Package oidc реализует вход через провайдер OpenID Connect по коду авторизации с PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// callbackPath путь возврата от провайдера, если RedirectURL не задан в конфигурации
+const callbackPath = "/api/auth/oidc/callback"
+
+// requestTimeout время ожидания ответа провайдера клиентом по умолчанию
+const requestTimeout = 10 * time.Second
+
+// maxResponseSize максимальный размер ответа провайдера в байтах
+const maxResponseSize = 1 << 20
+
+// signingMethods алгоритмы подписи ID токенов, которые принимает клиент
+var signingMethods = []string{"RS256", "ES256", "EdDSA"}
+
+// Provider представляет клиент провайдера OpenID Connect.
+// Настройки провайдера и его ключи загружаются при первом обращении и кэшируются,
+// поэтому сервис запускается, даже если провайдер временно недоступен.
+type Provider struct {
+	cfg    util.OIDC
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discovery
+	keys      map[string]crypto.PublicKey
+}
+
+// discovery представляет настройки провайдера из документа /.well-known/openid-configuration.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims представляет данные ID токена, которые использует сервис.
+type idTokenClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	jwt.RegisteredClaims
+}
+
+// NewProvider создает клиент провайдера OpenID Connect по конфигурации.
+// Если client равен nil, используется клиент с ограниченным временем ожидания ответа.
+func NewProvider(cfg util.OIDC, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = strings.TrimSuffix(util.GetConfig().Server.BaseURL, "/") + callbackPath
+	}
+	return &Provider{cfg: cfg, client: client, keys: make(map[string]crypto.PublicKey)}
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера.
+// Принимает значения state и nonce и код PKCE, вычисленный методом S256.
+// Возвращает ошибку, если настройки провайдера не удалось загрузить.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", errors.WithMessage(err, "invalid authorization endpoint")
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange обменивает код авторизации на ID токен и проверяет его подпись, издателя,
+// получателя, срок действия и nonce.
+// Принимает код авторизации, секрет PKCE и nonce, переданный в AuthCodeURL.
+// Возвращает подтвержденного провайдером пользователя и ошибку, если обмен или проверка не удались.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*model.OIDCIdentity, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &token)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while exchanging authorization code")
+	}
+	if status != http.StatusOK {
+		return nil, errors.Errorf("token endpoint returned %d: %s %s", status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.verify(ctx, d, token.IDToken, nonce)
+}
+
+// verify проверяет ID токен и возвращает пользователя из него.
+func (p *Provider) verify(ctx context.Context, d *discovery, raw, nonce string) (*model.OIDCIdentity, error) {
+	var claims idTokenClaims
+	parser := jwt.NewParser(jwt.WithValidMethods(signingMethods))
+	_, err := parser.ParseWithClaims(raw, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, d, kid)
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid id token")
+	}
+
+	switch {
+	case claims.Issuer != d.Issuer:
+		return nil, errors.Errorf("unexpected id token issuer %q", claims.Issuer)
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return nil, errors.New("id token is issued for another client")
+	case claims.ExpiresAt == nil:
+		return nil, errors.New("id token has no expiration time")
+	case claims.Subject == "":
+		return nil, errors.New("id token has no subject")
+	case nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	return &model.OIDCIdentity{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}, nil
+}
+
+// discover загружает настройки провайдера, если они еще не загружены.
+// Издатель в настройках должен совпадать с адресом провайдера из конфигурации.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(p.cfg.Issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d discovery
+	status, err := p.doJSON(req, &d)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while loading provider configuration")
+	}
+	if status != http.StatusOK {
+		return nil, errors.Errorf("provider configuration returned %d", status)
+	}
+	if strings.TrimSuffix(d.Issuer, "/") != issuer {
+		return nil, errors.Errorf("provider issuer %q does not match %q", d.Issuer, p.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("provider configuration is incomplete")
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+// key возвращает ключ провайдера с идентификатором kid.
+// Если ключ неизвестен, набор ключей загружается заново, чтобы учесть их смену у провайдера.
+// Токен без kid принимается, только если у провайдера один ключ.
+func (p *Provider) key(ctx context.Context, d *discovery, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set model.JWKS
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while loading provider keys")
+	}
+	if status != http.StatusOK {
+		return nil, errors.Errorf("provider keys returned %d", status)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := parseJWK(jwk); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, errors.Errorf("unknown provider key %q", kid)
+}
+
+// lookupKey находит загруженный ключ провайдера. Вызывается под блокировкой.
+func (p *Provider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// doJSON выполняет запрос к провайдеру и разбирает JSON ответа в v.
+// Возвращает HTTP-статус ответа и ошибку, если запрос или разбор не удались.
+func (p *Provider) doJSON(req *http.Request, v interface{}) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return 0, err
+	}
+	if err = json.Unmarshal(data, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// parseJWK преобразует публичный ключ из формата JSON Web Key.
+// Поддерживаются ключи RSA, EC на кривой P-256 и OKP Ed25519.
+func parseJWK(jwk model.JWK) (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, errors.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return key, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, errors.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
@@ -0,0 +1,255 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/ypxd99/yandex-practicm/internal/mocks"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// testRedirectURL адрес возврата тестового клиента
+const testRedirectURL = "http://shortener.example/api/auth/oidc/callback"
+
+// newTestProvider запускает тестовый провайдер и создает клиент, зарегистрированный у него.
+func newTestProvider(t *testing.T) (*Provider, *mocks.FakeIdentityProvider) {
+	idp := mocks.NewFakeIdentityProvider("shortener", "client secret")
+	t.Cleanup(idp.Close)
+	idp.SetUser("employee-1", "employee@corp.example", true)
+
+	p := NewProvider(util.OIDC{
+		Issuer:       idp.URL,
+		ClientID:     "shortener",
+		ClientSecret: "client secret",
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"email"},
+	}, idp.Client())
+	return p, idp
+}
+
+// authorize выполняет вход на странице провайдера с nonce и возвращает код авторизации и секрет PKCE.
+func authorize(t *testing.T, p *Provider, idp *mocks.FakeIdentityProvider, nonce string) (code, verifier string) {
+	verifier = base64.RawURLEncoding.EncodeToString([]byte(t.Name()))
+	sum := sha256.Sum256([]byte(verifier))
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state", nonce, base64.RawURLEncoding.EncodeToString(sum[:]))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	code, _, err = idp.Authorize(authURL)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return code, verifier
+}
+
+// login выполняет вход и обмен кода авторизации с одним и тем же nonce.
+func login(t *testing.T, p *Provider, idp *mocks.FakeIdentityProvider) (*model.OIDCIdentity, error) {
+	code, verifier := authorize(t, p, idp, "nonce")
+	return p.Exchange(context.Background(), code, verifier, "nonce")
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	p, idp := newTestProvider(t)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	assert.NoError(t, err)
+	u, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, idp.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+
+	q := u.Query()
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "shortener", q.Get("client_id"))
+	assert.Equal(t, testRedirectURL, q.Get("redirect_uri"))
+	assert.Equal(t, "openid email", q.Get("scope"))
+	assert.Equal(t, "state", q.Get("state"))
+	assert.Equal(t, "nonce", q.Get("nonce"))
+	assert.Equal(t, "challenge", q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+}
+
+func TestExchange(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		p, idp := newTestProvider(t)
+
+		identity, err := login(t, p, idp)
+		assert.NoError(t, err)
+		assert.Equal(t, &model.OIDCIdentity{
+			Issuer:        idp.URL,
+			Subject:       "employee-1",
+			Email:         "employee@corp.example",
+			EmailVerified: true,
+		}, identity)
+	})
+
+	tests := []struct {
+		name   string
+		modify func(token *jwt.Token)
+		err    string
+	}{
+		{
+			name:   "wrong issuer",
+			modify: func(token *jwt.Token) { token.Claims.(jwt.MapClaims)["iss"] = "https://evil.example" },
+			err:    "unexpected id token issuer",
+		},
+		{
+			name:   "wrong audience",
+			modify: func(token *jwt.Token) { token.Claims.(jwt.MapClaims)["aud"] = "other" },
+			err:    "issued for another client",
+		},
+		{
+			name:   "missing exp",
+			modify: func(token *jwt.Token) { delete(token.Claims.(jwt.MapClaims), "exp") },
+			err:    "no expiration time",
+		},
+		{
+			name: "expired token",
+			modify: func(token *jwt.Token) {
+				token.Claims.(jwt.MapClaims)["exp"] = time.Now().Add(-time.Minute).Unix()
+			},
+			err: "token is expired",
+		},
+		{
+			name:   "missing subject",
+			modify: func(token *jwt.Token) { delete(token.Claims.(jwt.MapClaims), "sub") },
+			err:    "no subject",
+		},
+		{
+			name:   "unknown kid",
+			modify: func(token *jwt.Token) { token.Header["kid"] = "unknown" },
+			err:    "unknown provider key",
+		},
+		{
+			name: "unexpected algorithm",
+			modify: func(token *jwt.Token) {
+				token.Method = jwt.SigningMethodRS384
+				token.Header["alg"] = jwt.SigningMethodRS384.Alg()
+			},
+			err: "signing method RS384 is invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, idp := newTestProvider(t)
+			idp.ModifyToken = tt.modify
+
+			_, err := login(t, p, idp)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		p, idp := newTestProvider(t)
+
+		code, verifier := authorize(t, p, idp, "nonce")
+		_, err := p.Exchange(context.Background(), code, verifier, "other nonce")
+		assert.ErrorContains(t, err, "nonce mismatch")
+
+		code, verifier = authorize(t, p, idp, "")
+		_, err = p.Exchange(context.Background(), code, verifier, "")
+		assert.ErrorContains(t, err, "nonce mismatch")
+	})
+
+	t.Run("expired code", func(t *testing.T) {
+		p, idp := newTestProvider(t)
+
+		code, verifier := authorize(t, p, idp, "nonce")
+		idp.ExpireCodes()
+		_, err := p.Exchange(context.Background(), code, verifier, "nonce")
+		assert.ErrorContains(t, err, "invalid_grant")
+	})
+
+	t.Run("replayed code", func(t *testing.T) {
+		p, idp := newTestProvider(t)
+
+		code, verifier := authorize(t, p, idp, "nonce")
+		_, err := p.Exchange(context.Background(), code, verifier, "nonce")
+		assert.NoError(t, err)
+		_, err = p.Exchange(context.Background(), code, verifier, "nonce")
+		assert.ErrorContains(t, err, "invalid_grant")
+	})
+
+	t.Run("wrong verifier", func(t *testing.T) {
+		p, idp := newTestProvider(t)
+
+		code, _ := authorize(t, p, idp, "nonce")
+		_, err := p.Exchange(context.Background(), code, "wrong verifier", "nonce")
+		assert.ErrorContains(t, err, "invalid_grant")
+	})
+
+	t.Run("wrong client secret", func(t *testing.T) {
+		p, idp := newTestProvider(t)
+		p.cfg.ClientSecret = "wrong secret"
+
+		_, err := login(t, p, idp)
+		assert.ErrorContains(t, err, "invalid_client")
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	p, idp := newTestProvider(t)
+
+	_, err := login(t, p, idp)
+	assert.NoError(t, err)
+
+	// После смены ключа у провайдера клиент загружает новый набор ключей.
+	idp.RotateKey()
+	_, err = login(t, p, idp)
+	assert.NoError(t, err)
+	assert.Len(t, p.keys, 1)
+
+	// Токен без kid принимается, пока у провайдера один ключ.
+	idp.ModifyToken = func(token *jwt.Token) { delete(token.Header, "kid") }
+	_, err = login(t, p, idp)
+	assert.NoError(t, err)
+}
+
+func TestParseJWK(t *testing.T) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	key, err := parseJWK(model.JWK{Kty: "RSA", N: encode(rsaKey.N.Bytes()), E: "AQAB"})
+	assert.NoError(t, err)
+	assert.True(t, rsaKey.PublicKey.Equal(key))
+
+	key, err = parseJWK(model.JWK{Kty: "EC", Crv: "P-256", X: encode(ecKey.X.Bytes()), Y: encode(ecKey.Y.Bytes())})
+	assert.NoError(t, err)
+	assert.True(t, ecKey.PublicKey.Equal(key))
+
+	key, err = parseJWK(model.JWK{Kty: "OKP", Crv: "Ed25519", X: encode(edKey)})
+	assert.NoError(t, err)
+	assert.True(t, edKey.Equal(key))
+
+	invalid := []model.JWK{
+		{Kty: "RSA", N: encode(rsaKey.N.Bytes()), E: "AQ"},
+		{Kty: "RSA", N: "not base64!", E: "AQAB"},
+		{Kty: "EC", Crv: "P-384", X: encode(ecKey.X.Bytes()), Y: encode(ecKey.Y.Bytes())},
+		{Kty: "EC", Crv: "P-256", X: encode(ecKey.X.Bytes()), Y: encode(ecKey.X.Bytes())},
+		{Kty: "OKP", Crv: "Ed25519", X: encode(edKey[:16])},
+		{Kty: "OKP", Crv: "X25519", X: encode(edKey)},
+		{Kty: "oct"},
+	}
+	for _, jwk := range invalid {
+		_, err = parseJWK(jwk)
+		assert.Error(t, err, jwk)
+	}
+}
@@ -2,12 +2,19 @@ package repository_test
 
 import (
 	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/ypxd99/yandex-practicm/internal/model"
 	"github.com/ypxd99/yandex-practicm/internal/repository/storage"
+	"github.com/ypxd99/yandex-practicm/internal/service"
 	"github.com/ypxd99/yandex-practicm/util"
 )
 
@@ -28,7 +35,7 @@ func TestCreateAndFindLink(t *testing.T) {
 	assert.NoError(t, err)
 	defer repo.Close()
 
-	createdLink, err := repo.CreateLink(ctx, testID, testURL, testUserID)
+	createdLink, err := repo.CreateLink(ctx, testID, testURL, testUserID, model.LinkOptions{})
 	assert.NoError(t, err)
 	assert.Equal(t, testID, createdLink.ID)
 	assert.Equal(t, testURL, createdLink.Link)
@@ -44,7 +51,7 @@ func TestCreateAndFindLink(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, storage.ErrNotFound, err)
 
-	userLinks, err := repo.FindUserLinks(ctx, testUserID)
+	userLinks, err := repo.FindUserLinks(ctx, testUserID, model.LinkFilter{})
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, len(userLinks), 1)
 
@@ -52,3 +59,721 @@ func TestCreateAndFindLink(t *testing.T) {
 		assert.Equal(t, testUserID, link.UserID)
 	}
 }
+
+func TestMaxClicksConcurrentRedirects(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+
+	repo, err := storage.InitStorage("")
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	const maxClicks = 5
+	limit := int64(maxClicks)
+	_, err = repo.CreateLink(ctx, "limited", "https://example.com", uuid.New(), model.LinkOptions{MaxClicks: &limit})
+	assert.NoError(t, err)
+
+	svc := service.InitService(repo)
+
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int64
+		exhausted atomic.Int64
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.FindLink(ctx, "limited", model.Visit{})
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, service.ErrURLExhausted):
+				exhausted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(maxClicks), succeeded.Load())
+	assert.Equal(t, int64(50-maxClicks), exhausted.Load())
+
+	link, err := repo.FindLink(ctx, "limited")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), *link.ClicksLeft)
+}
+
+func TestUpdateLinkActiveWindow(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "store")
+	testUserID := uuid.New()
+	from := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	repo, err := storage.InitStorage(filePath)
+	assert.NoError(t, err)
+
+	link, err := repo.CreateLink(ctx, "window", "https://example.com", testUserID, model.LinkOptions{})
+	assert.NoError(t, err)
+
+	link.ActiveFrom = &from
+	assert.NoError(t, repo.UpdateLink(ctx, link))
+
+	link.UserID = uuid.New()
+	assert.ErrorIs(t, repo.UpdateLink(ctx, link), storage.ErrNotFound)
+	assert.NoError(t, repo.Close())
+
+	reopened, err := storage.InitStorage(filePath)
+	assert.NoError(t, err)
+
+	found, err := reopened.FindLink(ctx, "window")
+	assert.NoError(t, err)
+	assert.Equal(t, testUserID, found.UserID)
+	assert.True(t, from.Equal(*found.ActiveFrom))
+	assert.Nil(t, found.ActiveUntil)
+}
+
+func TestUpdateLinkDestinationHistory(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	repo, err := storage.InitStorage("")
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	_, err = repo.CreateLink(ctx, "edit", "https://v1.example.com", testUserID, model.LinkOptions{})
+	assert.NoError(t, err)
+
+	svc := service.InitService(repo)
+
+	newURL := "https://v2.example.com"
+	_, err = svc.UpdateLink(ctx, "edit", testUserID, model.UpdateLinkRequest{
+		OriginalURL: model.Optional[string]{Value: &newURL, Set: true},
+	})
+	assert.NoError(t, err)
+
+	redirect, err := svc.FindLink(ctx, "edit", model.Visit{})
+	assert.NoError(t, err)
+	assert.Equal(t, newURL, redirect.URL)
+
+	history, err := svc.GetLinkHistory(ctx, "edit", testUserID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+	assert.Equal(t, 1, history[0].Version)
+	assert.Equal(t, "https://v1.example.com", history[0].OriginalURL)
+
+	resp, err := svc.RollbackLink(ctx, "edit", testUserID, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://v1.example.com", resp.OriginalURL)
+
+	history, err = svc.GetLinkHistory(ctx, "edit", testUserID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, newURL, history[1].OriginalURL)
+
+	_, err = svc.RollbackLink(ctx, "edit", testUserID, 5)
+	assert.ErrorIs(t, err, service.ErrVersionNotFound)
+
+	// Адрес ссылки с постоянным редиректом не меняется, пока она не переведена на временный.
+	permanent := 308
+	_, err = svc.UpdateLink(ctx, "edit", testUserID, model.UpdateLinkRequest{
+		RedirectCode: model.Optional[int]{Value: &permanent, Set: true},
+	})
+	assert.NoError(t, err)
+	_, err = svc.UpdateLink(ctx, "edit", testUserID, model.UpdateLinkRequest{
+		OriginalURL: model.Optional[string]{Value: &newURL, Set: true},
+	})
+	assert.ErrorIs(t, err, service.ErrURLCached)
+	_, err = svc.RollbackLink(ctx, "edit", testUserID, 2)
+	assert.ErrorIs(t, err, service.ErrURLCached)
+
+	temporary := 307
+	_, err = svc.UpdateLink(ctx, "edit", testUserID, model.UpdateLinkRequest{
+		RedirectCode: model.Optional[int]{Value: &temporary, Set: true},
+	})
+	assert.NoError(t, err)
+	_, err = svc.RollbackLink(ctx, "edit", testUserID, 2)
+	assert.NoError(t, err)
+}
+
+func TestUserLinkTags(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	repo, err := storage.InitStorage("")
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	for _, id := range []string{"tag1", "tag2", "tag3"} {
+		_, err = repo.CreateLink(ctx, id, "https://example.com/"+id, testUserID, model.LinkOptions{})
+		assert.NoError(t, err)
+	}
+	_, err = repo.CreateLink(ctx, "foreign", "https://example.com/foreign", uuid.New(), model.LinkOptions{})
+	assert.NoError(t, err)
+
+	svc := service.InitService(repo)
+
+	folder := "promo"
+	count, err := svc.AssignLabels(ctx, testUserID, model.LabelRequest{
+		IDs:     []string{"tag1", "tag2", "foreign"},
+		AddTags: []string{"Launch", "print"},
+		Folder:  model.Optional[string]{Value: &folder, Set: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	urls, err := svc.GetUserURLs(ctx, testUserID, model.LinkFilter{Tag: "launch"})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+	assert.Equal(t, []string{"launch", "print"}, urls[0].Tags)
+
+	urls, err = svc.GetUserURLs(ctx, testUserID, model.LinkFilter{Folder: &folder})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	query := " /promo/ "
+	urls, err = svc.GetUserURLs(ctx, testUserID, model.LinkFilter{Folder: &query})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	count, err = svc.RenameTag(ctx, testUserID, "launch", "release")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = svc.DeleteTag(ctx, testUserID, "print")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	tags, err := svc.GetUserTags(ctx, testUserID)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.TagInfo{{Name: "release", Links: 2}}, tags)
+
+	foreign, err := repo.FindLink(ctx, "foreign")
+	assert.NoError(t, err)
+	assert.Empty(t, foreign.Tags)
+}
+
+func TestLinkVariantClicks(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	repo, err := storage.InitStorage("")
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	svc := service.InitService(repo)
+
+	_, err = svc.ShorterLink(ctx, "https://example.com", testUserID, model.LinkOptions{
+		Targets: []model.LinkTarget{
+			{URL: "https://a.example.com", Weight: 1},
+			{URL: "https://b.example.com", Weight: 1},
+		},
+	})
+	assert.NoError(t, err)
+
+	urls, err := svc.GetUserURLs(ctx, testUserID, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 1)
+	id := urls[0].ShortURL[strings.LastIndex(urls[0].ShortURL, "/")+1:]
+
+	visits := map[string]int64{}
+	for i := 0; i < 20; i++ {
+		redirect, err := svc.FindLink(ctx, id, model.Visit{VisitorID: uuid.New().String()})
+		assert.NoError(t, err)
+		visits[redirect.Variant]++
+	}
+
+	stats, err := svc.GetVariantStats(ctx, id, testUserID)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "a", stats[0].Name)
+	assert.Equal(t, "b", stats[1].Name)
+	assert.Equal(t, visits["a"], stats[0].Clicks)
+	assert.Equal(t, visits["b"], stats[1].Clicks)
+
+	_, err = svc.GetVariantStats(ctx, id, uuid.New())
+	assert.ErrorIs(t, err, service.ErrURLNotFound)
+}
+
+func TestRestoreURLs(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	repo, err := storage.InitStorage(filepath.Join(t.TempDir(), "store"))
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	for id, link := range map[string]string{"keep": "https://example.com/keep", "taken": "https://example.com/taken"} {
+		_, err = repo.CreateLink(ctx, id, link, testUserID, model.LinkOptions{})
+		assert.NoError(t, err)
+	}
+
+	svc := service.InitService(repo)
+
+	count, err := svc.DeleteURLs(ctx, []string{"keep", "taken"}, testUserID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	deleted, err := repo.FindLink(ctx, "keep")
+	assert.NoError(t, err)
+	assert.NotNil(t, deleted.DeletedAt)
+
+	_, err = repo.CreateLink(ctx, "again", "https://example.com/taken", testUserID, model.LinkOptions{})
+	assert.NoError(t, err)
+
+	res, err := svc.RestoreURLs(ctx, []string{"keep", "taken", "missing"}, uuid.New())
+	assert.NoError(t, err)
+	assert.Empty(t, res.Restored)
+	assert.Equal(t, []string{"keep", "missing", "taken"}, res.Skipped)
+
+	res, err = svc.RestoreURLs(ctx, []string{"keep", "taken", "keep", "missing"}, testUserID)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"keep"}, res.Restored)
+	assert.Equal(t, []string{"taken"}, res.Conflicts)
+	assert.Equal(t, []string{"missing"}, res.Skipped)
+
+	restored, err := repo.FindLink(ctx, "keep")
+	assert.NoError(t, err)
+	assert.False(t, restored.IsDeleted)
+	assert.Nil(t, restored.DeletedAt)
+
+	redirect, err := svc.FindLink(ctx, "keep", model.Visit{})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/keep", redirect.URL)
+
+	_, err = svc.FindLink(ctx, "taken", model.Visit{})
+	assert.ErrorIs(t, err, service.ErrURLDeleted)
+}
+
+func TestTransferLinks(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	owner, recipient := uuid.New(), uuid.New()
+
+	repo, err := storage.InitStorage(filepath.Join(t.TempDir(), "store"))
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	for _, id := range []string{"move1", "move2", "gone"} {
+		_, err = repo.CreateLink(ctx, id, "https://example.com/"+id, owner, model.LinkOptions{})
+		assert.NoError(t, err)
+	}
+
+	svc := service.InitService(repo)
+
+	_, err = svc.CreateTransfer(ctx, []string{"move1"}, recipient)
+	assert.ErrorIs(t, err, service.ErrURLNotFound)
+
+	token, err := svc.CreateTransfer(ctx, []string{"move1", "move2", "gone"}, owner)
+	assert.NoError(t, err)
+	assert.True(t, token.ExpiresAt.After(time.Now()))
+
+	_, err = svc.DeleteURLs(ctx, []string{"gone"}, owner)
+	assert.NoError(t, err)
+
+	_, err = svc.RedeemTransfer(ctx, token.Token, owner)
+	assert.ErrorIs(t, err, service.ErrTransferInvalid)
+
+	res, err := svc.RedeemTransfer(ctx, token.Token, recipient)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"move1", "move2"}, res.Transferred)
+
+	_, err = svc.RedeemTransfer(ctx, token.Token, uuid.New())
+	assert.ErrorIs(t, err, service.ErrTransferUsed)
+
+	urls, err := svc.GetUserURLs(ctx, recipient, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	_, err = svc.GetShortURL(ctx, "move1", owner)
+	assert.ErrorIs(t, err, service.ErrURLNotFound)
+
+	for _, user := range []uuid.UUID{owner, recipient} {
+		transfers, err := svc.GetTransfers(ctx, user)
+		assert.NoError(t, err)
+		assert.Len(t, transfers, 2)
+		assert.Equal(t, "move1", transfers[0].LinkID)
+		assert.Equal(t, owner, transfers[0].FromUser)
+		assert.Equal(t, recipient, transfers[0].ToUser)
+	}
+
+	transfers, err := svc.GetTransfers(ctx, uuid.New())
+	assert.NoError(t, err)
+	assert.Empty(t, transfers)
+}
+
+func TestExportImportLinks(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	owner, other := uuid.New(), uuid.New()
+
+	repo, err := storage.InitStorage("")
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	svc := service.InitService(repo)
+
+	limit := int64(3)
+	_, err = repo.CreateLink(ctx, "docs", "https://example.com/docs", owner, model.LinkOptions{MaxClicks: &limit, Title: "Docs"})
+	assert.NoError(t, err)
+	folder := "work"
+	_, err = svc.AssignLabels(ctx, owner, model.LabelRequest{
+		IDs:     []string{"docs"},
+		AddTags: []string{"manual"},
+		Folder:  model.Optional[string]{Value: &folder, Set: true},
+	})
+	assert.NoError(t, err)
+
+	export := func(userID uuid.UUID) ([]model.LinkRecord, error) {
+		var records []model.LinkRecord
+		err := svc.ExportLinks(ctx, userID, func(rec *model.LinkRecord) error {
+			records = append(records, *rec)
+			return nil
+		})
+		return records, err
+	}
+
+	records, err := export(owner)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "docs", records[0].ID)
+	assert.Equal(t, "work", records[0].Folder)
+	assert.Equal(t, []string{"manual"}, records[0].Tags)
+	assert.Equal(t, limit, *records[0].MaxClicks)
+	assert.NotNil(t, records[0].CreatedAt)
+
+	res := svc.ImportLink(ctx, owner, records[0])
+	assert.Equal(t, model.ImportExists, res.Status)
+	assert.Equal(t, "docs", res.ID)
+
+	res = svc.ImportLink(ctx, other, records[0])
+	assert.Equal(t, model.ImportCreated, res.Status)
+	assert.True(t, res.AliasChanged)
+	assert.NotEqual(t, "docs", res.ID)
+
+	imported, err := export(other)
+	assert.NoError(t, err)
+	assert.Len(t, imported, 1)
+	assert.Equal(t, "Docs", imported[0].Title)
+	assert.Equal(t, "work", imported[0].Folder)
+	assert.Equal(t, []string{"manual"}, imported[0].Tags)
+
+	res = svc.ImportLink(ctx, other, model.LinkRecord{ID: "fresh", OriginalURL: "https://example.com/fresh"})
+	assert.Equal(t, model.ImportCreated, res.Status)
+	assert.Equal(t, "fresh", res.ID)
+	assert.False(t, res.AliasChanged)
+
+	// Ссылки передаются по порядку, а обход прекращается при первой ошибке обработчика записи.
+	imported, err = export(other)
+	assert.NoError(t, err)
+	assert.Len(t, imported, 2)
+	assert.Less(t, imported[0].ID, imported[1].ID)
+	errStop := errors.New("stop")
+	visited := 0
+	err = svc.ExportLinks(ctx, other, func(rec *model.LinkRecord) error {
+		visited++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, visited)
+
+	res = svc.ImportLink(ctx, other, model.LinkRecord{OriginalURL: "https://example.com/bad", LinkOptions: model.LinkOptions{Passthrough: "all"}})
+	assert.Equal(t, model.ImportFailed, res.Status)
+	assert.NotEmpty(t, res.Error)
+}
+
+func TestDedupeScope(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	prevScope := cfg.Links.DedupeScope
+	t.Cleanup(func() { cfg.Links.DedupeScope = prevScope })
+
+	ctx := context.Background()
+	const url = "https://example.com/shared"
+
+	tests := []struct {
+		scope         string
+		sameUserDup   bool
+		otherUserDup  bool
+		restoreBlocks bool
+	}{
+		{scope: model.DedupeGlobal, sameUserDup: true, otherUserDup: true, restoreBlocks: true},
+		{scope: model.DedupeUser, sameUserDup: true, otherUserDup: false, restoreBlocks: false},
+		{scope: model.DedupeNone, sameUserDup: false, otherUserDup: false, restoreBlocks: false},
+		{scope: "", sameUserDup: true, otherUserDup: true, restoreBlocks: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			cfg.Links.DedupeScope = tt.scope
+			owner, other := uuid.New(), uuid.New()
+
+			repo, err := storage.InitStorage(filepath.Join(t.TempDir(), "store"))
+			assert.NoError(t, err)
+			defer repo.Close()
+
+			svc := service.InitService(repo)
+
+			first, err := svc.ShorterLink(ctx, url, owner, model.LinkOptions{})
+			assert.NoError(t, err)
+
+			again, err := svc.ShorterLink(ctx, url, owner, model.LinkOptions{})
+			if tt.sameUserDup {
+				assert.ErrorIs(t, err, service.ErrURLExist)
+				assert.Equal(t, first, again)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEqual(t, first, again)
+			}
+
+			foreign, err := svc.ShorterLink(ctx, url, other, model.LinkOptions{})
+			if tt.otherUserDup {
+				assert.ErrorIs(t, err, service.ErrURLExist)
+				assert.Equal(t, first, foreign)
+			} else {
+				assert.NoError(t, err)
+				assert.NotEqual(t, first, foreign)
+
+				links, err := svc.GetUserURLs(ctx, other, model.LinkFilter{})
+				assert.NoError(t, err)
+				assert.Len(t, links, 1)
+				assert.Equal(t, foreign, links[0].ShortURL)
+			}
+
+			batch, err := svc.BatchShorten(ctx, []model.BatchRequest{
+				{CorrelationID: "1", OriginalURL: url},
+				{CorrelationID: "2", OriginalURL: url},
+			}, owner)
+			assert.NoError(t, err)
+			assert.Len(t, batch, 2)
+			if tt.sameUserDup {
+				assert.Equal(t, first, batch[0].ShortURL)
+				assert.Equal(t, first, batch[1].ShortURL)
+			} else {
+				assert.NotEqual(t, batch[0].ShortURL, batch[1].ShortURL)
+			}
+
+			// Удаленная ссылка не участвует в дедупликации, а ее восстановление
+			// блокируется только ссылками из той же области.
+			_, err = repo.CreateLink(ctx, "gone", "https://example.com/gone", owner, model.LinkOptions{})
+			assert.NoError(t, err)
+			_, err = svc.DeleteURLs(ctx, []string{"gone"}, owner)
+			assert.NoError(t, err)
+
+			replaced, err := repo.CreateLink(ctx, "replaced", "https://example.com/gone", other, model.LinkOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, "replaced", replaced.ID)
+
+			res, err := svc.RestoreURLs(ctx, []string{"gone"}, owner)
+			assert.NoError(t, err)
+			if tt.restoreBlocks {
+				assert.Equal(t, []string{"gone"}, res.Conflicts)
+			} else {
+				assert.Equal(t, []string{"gone"}, res.Restored)
+			}
+		})
+	}
+}
+
+func TestDedupeOnOwnerChange(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	prevScope := cfg.Links.DedupeScope
+	t.Cleanup(func() { cfg.Links.DedupeScope = prevScope })
+	cfg.Links.DedupeScope = model.DedupeUser
+
+	ctx := context.Background()
+	owner, other := uuid.New(), uuid.New()
+
+	repo, err := storage.InitStorage(filepath.Join(t.TempDir(), "store"))
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	for id, userID := range map[string]uuid.UUID{"own1": owner, "own2": owner, "theirs": other} {
+		url := "https://example.com/shared"
+		if id == "own2" {
+			url = "https://example.com/unique"
+		}
+		_, err = repo.CreateLink(ctx, id, url, userID, model.LinkOptions{})
+		assert.NoError(t, err)
+	}
+
+	moved, err := repo.TransferLinks(ctx, uuid.New().String(), []string{"own1", "own2"}, owner, other)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"own2"}, moved)
+
+	link, err := repo.FindLink(ctx, "own1")
+	assert.NoError(t, err)
+	assert.Equal(t, owner, link.UserID)
+
+	count, err := repo.MergeUser(ctx, owner, other)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	link, err = repo.FindLink(ctx, "own1")
+	assert.NoError(t, err)
+	assert.Equal(t, owner, link.UserID)
+
+	cfg.Links.DedupeScope = model.DedupeGlobal
+	count, err = repo.MergeUser(ctx, owner, other)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestBundleLinks(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	owner := uuid.New()
+
+	repo, err := storage.InitStorage(filepath.Join(t.TempDir(), "store"))
+	assert.NoError(t, err)
+	defer repo.Close()
+
+	svc := service.InitService(repo)
+
+	opts := model.LinkOptions{
+		Title: "My links",
+		Entries: []model.BundleEntry{
+			{Title: "Blog", URL: "https://blog.example.com"},
+			{Name: "shop", Title: "Shop", URL: "https://shop.example.com"},
+		},
+	}
+	shortURL, err := svc.CreateBundle(ctx, owner, opts)
+	assert.NoError(t, err)
+	id := shortURL[strings.LastIndex(shortURL, "/")+1:]
+
+	// Вторая подборка того же пользователя не дедуплицируется с первой.
+	other, err := svc.CreateBundle(ctx, owner, opts)
+	assert.NoError(t, err)
+	assert.NotEqual(t, shortURL, other)
+
+	redirect, err := svc.FindLink(ctx, id, model.Visit{})
+	assert.NoError(t, err)
+	if assert.NotNil(t, redirect.Bundle) {
+		assert.Equal(t, "1", redirect.Bundle.Entries[0].Name)
+		assert.Equal(t, shortURL+"?entry=shop", redirect.Bundle.Entries[1].URL)
+	}
+
+	for range 2 {
+		redirect, err = svc.FindLink(ctx, id, model.Visit{Query: map[string][]string{"entry": {"shop"}}})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://shop.example.com", redirect.URL)
+	}
+
+	stats, err := svc.GetVariantStats(ctx, id, owner)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.VariantStats{
+		{Name: "1", URL: "https://blog.example.com"},
+		{Name: "shop", URL: "https://shop.example.com", Clicks: 2},
+	}, stats)
+
+	entries := []model.BundleEntry{{Name: "news", Title: "News", URL: "https://news.example.com"}}
+	updated, err := svc.UpdateLink(ctx, id, owner, model.UpdateLinkRequest{
+		Entries: model.Optional[[]model.BundleEntry]{Value: &entries, Set: true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, entries, updated.Entries)
+
+	newURL := "https://example.com"
+	_, err = svc.UpdateLink(ctx, id, owner, model.UpdateLinkRequest{
+		OriginalURL: model.Optional[string]{Value: &newURL, Set: true},
+	})
+	assert.ErrorIs(t, err, service.ErrInvalidOptions)
+
+	_, err = svc.UpdateLink(ctx, id, uuid.New(), model.UpdateLinkRequest{
+		Entries: model.Optional[[]model.BundleEntry]{Value: &entries, Set: true},
+	})
+	assert.ErrorIs(t, err, service.ErrURLNotFound)
+
+	count, err := svc.DeleteURLs(ctx, []string{id}, owner)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = svc.FindLink(ctx, id, model.Visit{})
+	assert.ErrorIs(t, err, service.ErrURLDeleted)
+}
+
+func TestAPIKeys(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	owner := uuid.New()
+	filePath := filepath.Join(t.TempDir(), "store")
+
+	repo, err := storage.InitStorage(filePath)
+	assert.NoError(t, err)
+	svc := service.InitService(repo)
+
+	_, err = svc.CreateAPIKey(ctx, owner, model.APIKeyRequest{Name: "ci", Scopes: []string{"admin"}})
+	assert.ErrorIs(t, err, service.ErrInvalidOptions)
+	_, err = svc.CreateAPIKey(ctx, owner, model.APIKeyRequest{Name: "ci"})
+	assert.ErrorIs(t, err, service.ErrInvalidOptions)
+	past := time.Now().Add(-time.Minute)
+	_, err = svc.CreateAPIKey(ctx, owner, model.APIKeyRequest{Name: "ci", Scopes: []string{model.ScopeLinksRead}, ExpiresAt: &past})
+	assert.ErrorIs(t, err, service.ErrInvalidOptions)
+
+	created, err := svc.CreateAPIKey(ctx, owner, model.APIKeyRequest{
+		Name:   "ci",
+		Scopes: []string{"LINKS:WRITE", model.ScopeLinksRead, model.ScopeLinksRead},
+	})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, model.APIKeyPrefix))
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+	assert.Equal(t, []string{model.ScopeLinksRead, model.ScopeLinksWrite}, created.Scopes)
+	assert.NoError(t, repo.Close())
+
+	// Ключи переживают перезапуск хранилища, сам ключ не сохраняется.
+	repo, err = storage.InitStorage(filePath)
+	assert.NoError(t, err)
+	defer repo.Close()
+	svc = service.InitService(repo)
+
+	userID, scopes, err := svc.ResolveAPIKey(ctx, created.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, owner, userID)
+	assert.Equal(t, created.Scopes, scopes)
+
+	_, _, err = svc.ResolveAPIKey(ctx, created.Key+"x")
+	assert.ErrorIs(t, err, service.ErrAPIKeyInvalid)
+
+	keys, err := svc.GetAPIKeys(ctx, owner)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, created.Prefix, keys[0].Prefix)
+
+	// Отозвать ключ может только его владелец и только один раз.
+	assert.ErrorIs(t, svc.RevokeAPIKey(ctx, created.ID, uuid.New()), service.ErrAPIKeyNotFound)
+	assert.NoError(t, svc.RevokeAPIKey(ctx, created.ID, owner))
+	assert.ErrorIs(t, svc.RevokeAPIKey(ctx, created.ID, owner), service.ErrAPIKeyNotFound)
+
+	_, _, err = svc.ResolveAPIKey(ctx, created.Key)
+	assert.ErrorIs(t, err, service.ErrAPIKeyInvalid)
+
+	keys, err = svc.GetAPIKeys(ctx, uuid.New())
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -1,13 +1,17 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
 	"github.com/ypxd99/yandex-practicm/util"
 )
 
@@ -16,173 +20,306 @@ import (
 type Claims struct {
 	// UserID идентификатор пользователя
 	UserID string `json:"user_id"`
+	// Role роль пользователя, пустая для обычных пользователей
+	Role string `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// defaultTokenTTL время жизни JWT токена пользователя, если оно не задано в конфигурации
+const defaultTokenTTL = 30 * 24 * time.Hour
+
+// bearerScheme схема аутентификации в заголовке Authorization
+const bearerScheme = "Bearer"
+
+// apiKeyScopesKey ключ контекста с областями доступа API-ключа, которым аутентифицирован запрос
+const apiKeyScopesKey = "api_key_scopes"
+
+// roleKey ключ контекста с ролью пользователя из токена
+const roleKey = "user_role"
+
+// claimsKey ключ контекста с данными токена, которым аутентифицирован запрос
+const claimsKey = "token_claims"
+
+// cookieAuthKey ключ контекста, отмечающий запросы, аутентифицированные токеном из cookie
+const cookieAuthKey = "cookie_auth"
+
+// errInvalidToken ошибка, возникающая при проверке недействительного токена
+// errTokenRevoked ошибка, возникающая при использовании отозванного токена
+var (
+	errInvalidToken = errors.New("invalid token")
+	errTokenRevoked = errors.New("token revoked")
+)
+
+// APIKeyResolver определяет источник API-ключей для аутентификации.
+type APIKeyResolver interface {
+	// ResolveAPIKey возвращает владельца и области доступа действующего API-ключа
+	// и ошибку, если ключ недействителен.
+	ResolveAPIKey(ctx context.Context, key string) (uuid.UUID, []string, error)
+}
+
+// Authenticator определяет источник данных для аутентификации запросов.
+type Authenticator interface {
+	APIKeyResolver
+
+	// IsTokenRevoked проверяет, отозван ли токен пользователя userID с идентификатором jti, выпущенный в issuedAt.
+	IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error)
+
+	// UserRole возвращает текущую роль пользователя, которая записывается в продленный токен.
+	UserRole(ctx context.Context, userID uuid.UUID) (string, error)
+
+	// TouchUser отмечает обращение аутентифицированного пользователя к сервису.
+	TouchUser(userID uuid.UUID)
+}
+
 // AuthMiddleware создает middleware для аутентификации пользователей.
-// Проверяет наличие и валидность JWT токена в cookie.
-// Если токен отсутствует или невалиден, создает нового пользователя.
+// Принимает JWT токен из заголовка "Authorization: Bearer <token>" или из cookie.
+// Значение заголовка Authorization с префиксом model.APIKeyPrefix считается API-ключом
+// и разрешается через auth в пользователя и области доступа.
+// Отозванные через auth токены не принимаются. Токен, до истечения которого осталось
+// меньше Auth.RenewBefore часов, продлевается: новый токен передается клиенту в заголовке
+// ответа, а для токена из cookie - и в cookie.
+// Запрос с невалидным токеном или ключом в заголовке Authorization отклоняется со статусом 401.
+// Если токен в cookie отсутствует или невалиден, создает нового пользователя и возвращает
+// его токен в cookie и в заголовке ответа, заданном в конфигурации.
+// Обращение каждого аутентифицированного или нового пользователя отмечается через auth.
 // Возвращает gin.HandlerFunc.
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(auth Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		cookieName := util.GetConfig().Auth.CookieName
-		cookie, err := c.Cookie(cookieName)
 		logger := util.GetLogger()
 
-		cfg := util.GetConfig()
-		secretKey := []byte(cfg.Auth.SecretKey)
-
-		if err != nil || !isValidToken(cookie, secretKey) {
-			newUserID := uuid.New()
-			userIDStr := newUserID.String()
-
-			var token string
-			token, err = generateToken(userIDStr, secretKey)
-			if err != nil {
-				logger.Errorf("failed to generate token: %v", err)
-				c.AbortWithStatus(http.StatusInternalServerError)
+		bearer, ok := bearerToken(c.GetHeader("Authorization"))
+		if ok && strings.HasPrefix(bearer, model.APIKeyPrefix) {
+			if auth == nil {
+				c.AbortWithStatus(http.StatusUnauthorized)
 				return
 			}
-
-			c.SetCookie(
-				cookieName,
-				token,
-				3600*24*30,
-				"/",
-				"",
-				false,
-				true,
-			)
-
-			c.Set(cookieName, newUserID)
-			logger.Infof("created new user with ID: %s", userIDStr)
-		} else {
-			userIDStr, err := extractUserIDFromToken(cookie, secretKey)
+			userID, scopes, err := auth.ResolveAPIKey(c.Request.Context(), bearer)
 			if err != nil {
-				newUserID := uuid.New()
-				userIDStr = newUserID.String()
-
-				var token string
-				token, err = generateToken(userIDStr, secretKey)
-				if err != nil {
-					logger.Errorf("failed to generate token: %v", err)
-					c.AbortWithStatus(http.StatusInternalServerError)
-					return
-				}
-
-				c.SetCookie(
-					cookieName,
-					token,
-					3600*24*30,
-					"/",
-					"",
-					false,
-					true,
-				)
-
-				c.Set(cookieName, newUserID)
-				logger.Infof("regenerated token for user, new ID: %s", userIDStr)
-				c.Next()
+				logger.Warnf("rejected api key: %v", err)
+				c.AbortWithStatus(http.StatusUnauthorized)
 				return
 			}
 
-			var userID uuid.UUID
-			userID, err = uuid.Parse(userIDStr)
-			if err != nil {
-				userID = uuid.New()
-				userIDStr = userID.String()
-
-				var token string
-				token, err = generateToken(userIDStr, secretKey)
-				if err != nil {
-					logger.Errorf("failed to generate token: %v", err)
-					c.AbortWithStatus(http.StatusInternalServerError)
-					return
-				}
+			c.Set(cookieName, userID)
+			c.Set(apiKeyScopesKey, scopes)
+			auth.TouchUser(userID)
+			logger.Infof("authenticated user with ID %s by api key", userID)
+			c.Next()
+			return
+		}
 
-				c.SetCookie(
-					cookieName,
-					token,
-					3600*24*30,
-					"/",
-					"",
-					false,
-					true,
-				)
-
-				logger.Infof("regenerated UUID for user, new ID: %s", userIDStr)
+		token, fromCookie := bearer, false
+		if !ok {
+			cookie, err := c.Cookie(cookieName)
+			if err != nil || cookie == "" {
+				startSession(c, auth)
+				return
 			}
+			token, fromCookie = cookie, true
+		}
 
-			c.Set(cookieName, userID)
-			logger.Infof("authenticated user with ID: %s", userIDStr)
+		claims, userID, err := authenticate(c.Request.Context(), auth, token)
+		switch {
+		case err == nil:
+		case !errors.Is(err, errInvalidToken):
+			logger.Errorf("failed to check token: %v", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		case fromCookie:
+			logger.Warnf("rejected session cookie: %v", err)
+			startSession(c, auth)
+			return
+		default:
+			logger.Warnf("rejected bearer token: %v", err)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
 		}
 
+		c.Set(cookieName, userID)
+		c.Set(roleKey, claims.Role)
+		c.Set(claimsKey, claims)
+		c.Set(cookieAuthKey, fromCookie)
+		if auth != nil {
+			auth.TouchUser(userID)
+		}
+		if err = renewToken(c, auth, userID, claims, fromCookie); err != nil {
+			logger.Errorf("failed to renew token: %v", err)
+		}
+		logger.Infof("authenticated user with ID: %s", userID)
 		c.Next()
 	}
 }
 
-// generateToken создает новый JWT токен для указанного пользователя.
-// Принимает идентификатор пользователя и секретный ключ.
-// Возвращает строку токена и ошибку.
-func generateToken(userID string, key []byte) (string, error) {
-	claims := &Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(30 * 24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+// authenticate проверяет подпись, срок действия и отзыв JWT токена.
+// Возвращает данные токена, идентификатор пользователя и ошибку, оборачивающую errInvalidToken,
+// если токен недействителен. Другие ошибки означают, что отзыв токена проверить не удалось.
+func authenticate(ctx context.Context, auth Authenticator, token string) (*Claims, uuid.UUID, error) {
+	claims, err := GetKeySet().Parse(token)
+	if err != nil {
+		return nil, uuid.Nil, errors.Wrap(errInvalidToken, err.Error())
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, uuid.Nil, errors.Wrap(errInvalidToken, "invalid user ID")
+	}
+
+	if auth != nil {
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		revoked, err := auth.IsTokenRevoked(ctx, userID, claims.ID, issuedAt)
+		if err != nil {
+			return nil, uuid.Nil, err
+		}
+		if revoked {
+			return nil, uuid.Nil, errors.Wrap(errInvalidToken, errTokenRevoked.Error())
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return claims, userID, nil
+}
 
-	tokenString, err := token.SignedString(key)
-	if err != nil {
-		return "", err
+// startSession создает нового анонимного пользователя, передает клиенту его токен
+// и продолжает обработку запроса от его имени.
+// Новый пользователь попадает в реестр пользователей через auth.
+func startSession(c *gin.Context, auth Authenticator) {
+	userID := uuid.New()
+	if _, _, err := SignIn(c, userID, ""); err != nil {
+		util.GetLogger().Errorf("failed to generate token: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
 	}
 
-	return tokenString, nil
+	if auth != nil {
+		auth.TouchUser(userID)
+	}
+	util.GetLogger().Infof("created new user with ID: %s", userID)
+	c.Next()
 }
 
-// isValidToken проверяет валидность JWT токена.
-// Принимает строку токена и секретный ключ.
-// Возвращает true, если токен валиден.
-func isValidToken(tokenString string, key []byte) bool {
-	_, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// renewToken продлевает токен, до истечения которого осталось меньше Auth.RenewBefore часов.
+// Роль продленного токена заново получается через auth, а не копируется из исходного токена,
+// поэтому отобранная роль не продлевается. Без auth продленный токен не содержит роли.
+// Новый токен передается в заголовке ответа, а если исходный токен получен из cookie, то и в cookie.
+func renewToken(c *gin.Context, auth Authenticator, userID uuid.UUID, claims *Claims, fromCookie bool) error {
+	hours := util.GetConfig().Auth.RenewBefore
+	if hours <= 0 || claims.ExpiresAt == nil {
+		return nil
+	}
+	if time.Until(claims.ExpiresAt.Time) >= time.Duration(hours)*time.Hour {
+		return nil
+	}
+
+	var role string
+	if auth != nil {
+		var err error
+		if role, err = auth.UserRole(c.Request.Context(), userID); err != nil {
+			return err
 		}
-		return key, nil
-	})
+	}
 
-	return err == nil
+	token, _, err := IssueToken(userID, role)
+	if err != nil {
+		return err
+	}
+	if fromCookie {
+		setToken(c, token)
+	} else {
+		setTokenHeader(c, token)
+	}
+	return nil
 }
 
-// extractUserIDFromToken извлекает идентификатор пользователя из JWT токена.
-// Принимает строку токена и секретный ключ.
-// Возвращает идентификатор пользователя и ошибку.
-func extractUserIDFromToken(tokenString string, key []byte) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return key, nil
-	})
+// bearerToken извлекает токен из значения заголовка Authorization со схемой Bearer.
+// Название схемы сравнивается без учета регистра.
+// Возвращает false, если заголовок пустой или содержит другую схему.
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, bearerScheme) {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// tokenTTL возвращает время жизни токена пользователя из конфигурации.
+func tokenTTL() time.Duration {
+	if hours := util.GetConfig().Auth.TokenTTL; hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultTokenTTL
+}
+
+// setToken передает клиенту токен пользователя в cookie и в заголовке ответа,
+// чтобы его могли сохранить и клиенты без поддержки cookie.
+func setToken(c *gin.Context, token string) {
+	SetCookie(c, util.GetConfig().Auth.CookieName, token, int(tokenTTL()/time.Second))
+	setTokenHeader(c, token)
+}
+
+// setTokenHeader передает клиенту токен пользователя в заголовке ответа, заданном в конфигурации.
+func setTokenHeader(c *gin.Context, token string) {
+	if header := util.GetConfig().Auth.TokenHeader; header != "" {
+		c.Header(header, bearerScheme+" "+token)
+	}
+}
+
+// IssueToken выпускает JWT токен для указанного пользователя с ролью role.
+// Токен принимается в заголовке "Authorization: Bearer <token>" и в cookie.
+// Возвращает строку токена, момент его истечения и ошибку.
+func IssueToken(userID uuid.UUID, role string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(tokenTTL()).Truncate(time.Second)
+	token, err := GetKeySet().Sign(userID.String(), role, expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
 
+// SignIn выпускает JWT токен для пользователя с ролью role и передает его клиенту
+// в cookie и в заголовке ответа. Последующие обработчики запроса получают этого пользователя и его роль.
+// Возвращает строку токена, момент его истечения и ошибку.
+func SignIn(c *gin.Context, userID uuid.UUID, role string) (string, time.Time, error) {
+	token, expiresAt, err := IssueToken(userID, role)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
+	setToken(c, token)
+	c.Set(util.GetConfig().Auth.CookieName, userID)
+	c.Set(roleKey, role)
+	return token, expiresAt, nil
+}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UserID, nil
+// SignOut удаляет cookie с токеном пользователя и отменяет токены, выпущенные
+// при обработке текущего запроса. Отзыв самого токена выполняется вызывающей стороной.
+func SignOut(c *gin.Context) {
+	cfg := util.GetConfig().Auth
+	ClearCookie(c, cfg.CookieName)
+	if cfg.TokenHeader != "" {
+		c.Writer.Header().Del(cfg.TokenHeader)
 	}
+}
 
-	return "", errors.New("invalid token claims")
+// GetClaims возвращает данные JWT токена, которым аутентифицирован запрос.
+// Принимает контекст gin.
+// Возвращает false, если запрос аутентифицирован API-ключом или токен выпущен при обработке запроса.
+func GetClaims(c *gin.Context) (*Claims, bool) {
+	claims, exists := c.Get(claimsKey)
+	if !exists {
+		return nil, false
+	}
+	res, ok := claims.(*Claims)
+	return res, ok
 }
 
 // RequireAuth создает middleware для проверки аутентификации пользователя.
 // Проверяет наличие и валидность идентификатора пользователя в контексте.
+// Запрос, аутентифицированный API-ключом, должен иметь все указанные области доступа,
+// иначе отклоняется со статусом 403. Токен и cookie пользователя дают полный доступ.
 // Возвращает gin.HandlerFunc.
-func RequireAuth() gin.HandlerFunc {
+func RequireAuth(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		cookieName := util.GetConfig().Auth.CookieName
 		userID, exists := c.Get(cookieName)
@@ -197,10 +334,86 @@ func RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		if granted, ok := GetAPIKeyScopes(c); ok {
+			for _, scope := range scopes {
+				if !slices.Contains(granted, scope) {
+					c.AbortWithStatus(http.StatusForbidden)
+					return
+				}
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession создает middleware, отклоняющий запросы, аутентифицированные API-ключом.
+// Используется для операций, которые нельзя делегировать ключу, например выпуска токенов и ключей.
+// Возвращает gin.HandlerFunc.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := GetAPIKeyScopes(c); ok {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole создает middleware, пропускающий только пользователей с указанной ролью.
+// Роль проверяется по учетной записи через auth, а не по токену, поэтому отобранная роль
+// перестает действовать сразу, даже если токен выпущен, когда она еще была назначена.
+// Запросы, аутентифицированные API-ключом, отклоняются, так как ключ не передает роль.
+// Возвращает gin.HandlerFunc, отклоняющий остальные запросы со статусом 403.
+func RequireRole(auth Authenticator, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := GetAPIKeyScopes(c); ok || auth == nil {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		userID, err := GetUserID(c)
+		if err != nil {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		stored, err := auth.UserRole(c.Request.Context(), userID)
+		if err != nil {
+			util.GetLogger().Errorf("failed to get user role: %v", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if stored != role {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		c.Set(roleKey, stored)
 		c.Next()
 	}
 }
 
+// GetRole возвращает роль пользователя из токена, которым аутентифицирован запрос,
+// или роль из учетной записи, если ее уже проверил RequireRole.
+// Принимает контекст gin.
+// Возвращает пустую строку для обычных и анонимных пользователей.
+func GetRole(c *gin.Context) string {
+	return c.GetString(roleKey)
+}
+
+// GetAPIKeyScopes возвращает области доступа API-ключа, которым аутентифицирован запрос.
+// Принимает контекст gin.
+// Возвращает false, если запрос аутентифицирован токеном или cookie.
+func GetAPIKeyScopes(c *gin.Context) ([]string, bool) {
+	scopes, exists := c.Get(apiKeyScopesKey)
+	if !exists {
+		return nil, false
+	}
+	res, ok := scopes.([]string)
+	return res, ok
+}
+
 // GetUserID извлекает идентификатор пользователя из контекста.
 // Принимает контекст gin.
 // Возвращает UUID пользователя и ошибку.
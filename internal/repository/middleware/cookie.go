@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// SetCookie устанавливает cookie с атрибутами из конфигурации: доменом, флагом Secure и политикой SameSite.
+// Cookie всегда недоступна из JavaScript. Флаг Secure включается автоматически, если сервер работает по HTTPS
+// или задана политика SameSite=None, без которого браузеры такую cookie отклоняют.
+// Отрицательный maxAge удаляет cookie.
+func SetCookie(c *gin.Context, name, value string, maxAge int) {
+	setCookie(c, name, value, maxAge, cookieSameSite(util.GetConfig().Auth.Cookie.SameSite))
+}
+
+// SetRedirectCookie устанавливает cookie, которую браузер должен передать при возврате на сервис
+// с другого сайта, например от провайдера учетных записей. Политика SameSite всегда Lax,
+// так как со Strict cookie не передается при таком переходе. Остальные атрибуты берутся из конфигурации.
+func SetRedirectCookie(c *gin.Context, name, value string, maxAge int) {
+	setCookie(c, name, value, maxAge, http.SameSiteLaxMode)
+}
+
+// setCookie устанавливает cookie с политикой sameSite и остальными атрибутами из конфигурации.
+func setCookie(c *gin.Context, name, value string, maxAge int, sameSite http.SameSite) {
+	cfg := util.GetConfig()
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   cfg.Auth.Cookie.Domain,
+		MaxAge:   maxAge,
+		Secure:   cfg.Auth.Cookie.Secure || cfg.Server.EnableHTTPS || sameSite == http.SameSiteNoneMode,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+// ClearCookie удаляет cookie у клиента и отменяет значения этой cookie,
+// уже установленные в ответе на текущий запрос.
+func ClearCookie(c *gin.Context, name string) {
+	header := c.Writer.Header()
+	cookies := header.Values("Set-Cookie")
+	header.Del("Set-Cookie")
+	for _, cookie := range cookies {
+		if !strings.HasPrefix(cookie, name+"=") {
+			header.Add("Set-Cookie", cookie)
+		}
+	}
+	SetCookie(c, name, "", -1)
+}
+
+// cookieSameSite преобразует политику SameSite из конфигурации.
+// Пустое или неизвестное значение трактуется как Lax.
+func cookieSameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -1,7 +1,6 @@
 package middleware
 
 import (
-	"bytes"
 	"compress/gzip"
 	"io"
 	"net/http"
@@ -12,16 +11,29 @@ import (
 )
 
 // responseWriter представляет обертку над gin.ResponseWriter для сжатия ответов.
-// Позволяет буферизировать ответ перед его отправкой.
+// Решение о сжатии принимается при первой записи по заголовку Content-Type,
+// после чего данные сжимаются и отправляются по мере записи.
 type responseWriter struct {
 	gin.ResponseWriter
-	buf *bytes.Buffer
+	acceptsGzip bool
+	decided     bool
+	gz          *gzip.Writer
 }
 
-// Write записывает данные в буфер.
+// Write записывает данные в ответ, сжимая их, если это необходимо.
 // Реализует интерфейс io.Writer.
 func (w *responseWriter) Write(b []byte) (int, error) {
-	return w.buf.Write(b)
+	w.decide()
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// WriteString записывает строку в ответ, сжимая ее, если это необходимо.
+// Реализует интерфейс io.StringWriter.
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
 }
 
 // WriteHeader устанавливает HTTP статус код ответа.
@@ -30,6 +42,32 @@ func (w *responseWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+// Flush отправляет клиенту накопленные сжатые данные.
+// Реализует интерфейс http.Flusher.
+func (w *responseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide определяет при первой записи, нужно ли сжимать ответ.
+// Сжимаются ответы в формате JSON и HTML, если клиент поддерживает gzip.
+func (w *responseWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	contentType := w.Header().Get("Content-Type")
+	if w.acceptsGzip &&
+		(strings.Contains(contentType, "application/json") || strings.Contains(contentType, "text/html")) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+}
+
 // GzipMiddleware создает middleware для сжатия HTTP запросов и ответов.
 // Поддерживает сжатие запросов с Content-Encoding: gzip.
 // Сжимает ответы, если клиент поддерживает gzip и тип контента подходит.
@@ -42,27 +80,32 @@ func GzipMiddleware() gin.HandlerFunc {
 
 		wr := &responseWriter{
 			ResponseWriter: c.Writer,
-			buf:            &bytes.Buffer{},
+			acceptsGzip:    strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip"),
 		}
 		c.Writer = wr
 
 		c.Next()
 
-		acceptsGzip := strings.Contains(c.Request.Header.Get("Accept-Encoding"), "gzip")
-		contentType := c.Writer.Header().Get("Content-Type")
-		if (strings.Contains(contentType, "application/json") ||
-			strings.Contains(contentType, "text/html")) &&
-			acceptsGzip {
-			wr.handleGzipResponse()
-			return
+		if wr.gz != nil {
+			wr.gz.Close()
 		}
-
-		wr.ResponseWriter.Write(wr.buf.Bytes())
 	}
 }
 
+// gzipBody представляет тело запроса, распаковываемое по мере чтения.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close закрывает распаковщик и исходное тело запроса.
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
 // handleGzipRequest обрабатывает входящий запрос, сжатый с помощью gzip.
-// Распаковывает тело запроса и обновляет его содержимое.
+// Заменяет тело запроса распаковщиком, читающим исходное тело по мере необходимости.
 // В случае ошибки прерывает обработку запроса.
 func handleGzipRequest(c *gin.Context) {
 	gz, err := gzip.NewReader(c.Request.Body)
@@ -70,26 +113,7 @@ func handleGzipRequest(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusBadRequest, errors.New("invalid gzip body"))
 		return
 	}
-	defer gz.Close()
-
-	body, err := io.ReadAll(gz)
-	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, errors.New("failed to read gzip body"))
-		return
-	}
-
-	c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
-	c.Request.ContentLength = int64(len(body))
-}
-
-// handleGzipResponse сжимает ответ с помощью gzip.
-// Устанавливает соответствующие заголовки и записывает сжатые данные.
-func (w *responseWriter) handleGzipResponse() {
-	w.Header().Set("Content-Encoding", "gzip")
-	w.Header().Del("Content-Length")
-
-	gz := gzip.NewWriter(w.ResponseWriter)
-	defer gz.Close()
 
-	gz.Write(w.buf.Bytes())
+	c.Request.Body = &gzipBody{Reader: gz, body: c.Request.Body}
+	c.Request.ContentLength = -1
 }
@@ -0,0 +1,255 @@
+package middleware
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// Алгоритмы подписи JWT токенов, поддерживаемые набором ключей.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+var (
+	// onceKeySet используется для загрузки ключей из конфигурации только один раз
+	onceKeySet sync.Once
+	// keySet хранит глобальный набор ключей
+	keySet *KeySet
+)
+
+// jwtKey представляет один ключ набора.
+type jwtKey struct {
+	id        string            // Идентификатор ключа, пустой для SecretKey
+	method    jwt.SigningMethod // Алгоритм подписи
+	signKey   interface{}       // Ключ подписи, nil для ключей только для проверки
+	verifyKey interface{}       // Ключ проверки подписи
+}
+
+// KeySet представляет набор ключей подписи и проверки JWT токенов.
+// Новые токены подписываются одним ключом, а проверяются любым ключом набора,
+// выбранным по заголовку kid. Это позволяет сменить ключ подписи, не делая
+// недействительными уже выпущенные токены.
+type KeySet struct {
+	signing *jwtKey            // Ключ подписи новых токенов
+	legacy  *jwtKey            // Ключ SecretKey для токенов без заголовка kid
+	keys    map[string]*jwtKey // Ключи проверки по идентификатору
+}
+
+// NewKeySet создает набор ключей по конфигурации аутентификации.
+// Ключи RS256 и EdDSA загружаются из PEM-файлов.
+// Возвращает ошибку, если ключ задан некорректно или ключ подписи не найден.
+func NewKeySet(cfg util.Auth) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*jwtKey, len(cfg.Keys))}
+	if cfg.SecretKey != "" {
+		secret := []byte(cfg.SecretKey)
+		ks.legacy = &jwtKey{method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		if keyCfg.ID == "" {
+			return nil, errors.New("signing key ID must not be empty")
+		}
+		if _, exists := ks.keys[keyCfg.ID]; exists {
+			return nil, errors.Errorf("duplicate signing key ID %q", keyCfg.ID)
+		}
+		key, err := loadKey(keyCfg)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "error occurred while loading signing key %q", keyCfg.ID)
+		}
+		ks.keys[key.id] = key
+	}
+
+	if cfg.SigningKeyID == "" {
+		ks.signing = ks.legacy
+	} else {
+		ks.signing = ks.keys[cfg.SigningKeyID]
+		if ks.signing == nil {
+			return nil, errors.Errorf("signing key %q not found", cfg.SigningKeyID)
+		}
+	}
+	if ks.signing == nil || ks.signing.signKey == nil {
+		return nil, errors.New("no key available for signing tokens")
+	}
+
+	return ks, nil
+}
+
+// loadKey создает ключ набора по его описанию в конфигурации.
+func loadKey(cfg util.SigningKey) (*jwtKey, error) {
+	key := &jwtKey{id: cfg.ID}
+
+	switch cfg.Algorithm {
+	case AlgorithmHS256:
+		if cfg.Secret == "" {
+			return nil, errors.New("secret must not be empty")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey, key.verifyKey = []byte(cfg.Secret), []byte(cfg.Secret)
+		return key, nil
+	case AlgorithmRS256:
+		key.method = jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, errors.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	var public crypto.PublicKey
+	switch {
+	case cfg.PrivateKeyPath != "":
+		signer, err := util.LoadSigningKey(cfg.PrivateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		key.signKey, public = signer, signer.Public()
+	case cfg.PublicKeyPath != "":
+		var err error
+		public, err = util.LoadPublicKey(cfg.PublicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("key path must not be empty")
+	}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		if key.method != jwt.SigningMethodRS256 {
+			return nil, errors.New("RSA key can be used only with RS256")
+		}
+		key.verifyKey = pub
+	case ed25519.PublicKey:
+		if key.method != jwt.SigningMethodEdDSA {
+			return nil, errors.New("Ed25519 key can be used only with EdDSA")
+		}
+		key.verifyKey = pub
+	default:
+		return nil, errors.Errorf("unsupported key type %T", public)
+	}
+
+	return key, nil
+}
+
+// GetKeySet возвращает глобальный набор ключей, загружая его из конфигурации при первом вызове.
+func GetKeySet() *KeySet {
+	onceKeySet.Do(func() {
+		ks, err := NewKeySet(util.GetConfig().Auth)
+		if err != nil {
+			log.Fatalf("error occurred while load jwt keys: %s", err)
+		}
+		keySet = ks
+	})
+
+	if keySet == nil {
+		log.Fatal("nil jwt keys")
+	}
+
+	return keySet
+}
+
+// Sign подписывает JWT токен пользователя с ролью role, действующий до expiresAt, текущим ключом подписи.
+// Токен содержит уникальный идентификатор jti, по которому его можно отозвать,
+// и заголовок kid, если ключ подписи задан в Keys.
+// Возвращает строку токена и ошибку.
+func (ks *KeySet) Sign(userID, role string, expiresAt time.Time) (string, error) {
+	claims := &Claims{
+		UserID: userID,
+		Role:   role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return ks.SignClaims(claims)
+}
+
+// SignClaims подписывает JWT токен с произвольными данными текущим ключом подписи.
+// Токен содержит заголовок kid, если ключ подписи задан в Keys.
+// Возвращает строку токена и ошибку.
+func (ks *KeySet) SignClaims(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	if ks.signing.id != "" {
+		token.Header["kid"] = ks.signing.id
+	}
+
+	return token.SignedString(ks.signing.signKey)
+}
+
+// Parse проверяет подпись и срок действия JWT токена пользователя.
+// Возвращает данные токена и ошибку.
+func (ks *KeySet) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := ks.ParseClaims(tokenString, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ParseClaims проверяет подпись и срок действия JWT токена и заполняет claims его данными.
+// Ключ проверки выбирается по заголовку kid, токены без него проверяются ключом SecretKey.
+// Алгоритм токена должен совпадать с алгоритмом выбранного ключа.
+// Возвращает ошибку, если токен недействителен.
+func (ks *KeySet) ParseClaims(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		key := ks.legacy
+		if kid, ok := token.Header["kid"]; ok {
+			id, _ := kid.(string)
+			key = ks.keys[id]
+		}
+		if key == nil {
+			return nil, errors.New("unknown signing key")
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, errors.New("unexpected signing method")
+		}
+		return key.verifyKey, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !token.Valid {
+		return errors.New("invalid token claims")
+	}
+	return nil
+}
+
+// JWKS возвращает публичные ключи набора в формате JSON Web Key Set.
+// Ключи HS256 не публикуются. Ключи упорядочены по идентификатору.
+func (ks *KeySet) JWKS() model.JWKS {
+	res := model.JWKS{Keys: make([]model.JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := model.JWK{Use: "sig", Alg: key.method.Alg(), Kid: key.id}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		res.Keys = append(res.Keys, jwk)
+	}
+	sort.Slice(res.Keys, func(i, j int) bool { return res.Keys[i].Kid < res.Keys[j].Kid })
+	return res
+}
@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// touchBatchSize максимальное количество пользователей в одном запросе сохранения активности
+const touchBatchSize = 1000
+
+// TouchUsers сохраняет время последней активности пользователей в реестре PostgreSQL.
+// Пользователи сохраняются пачками не больше touchBatchSize.
+// Возвращает ошибку, если операция не удалась.
+func (p *Postgres) TouchUsers(ctx context.Context, seen map[uuid.UUID]time.Time) error {
+	values := make([]string, 0, touchBatchSize)
+	args := make([]interface{}, 0, 2*touchBatchSize)
+
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		_, err := p.db.NewRaw(`
+			INSERT INTO shortener.user_activity (user_id, time_created, time_last_seen)
+			VALUES `+strings.Join(values, ", ")+`
+			ON CONFLICT (user_id) DO UPDATE
+			SET time_last_seen = GREATEST(user_activity.time_last_seen, EXCLUDED.time_last_seen)`, args...).
+			Exec(ctx)
+		values, args = values[:0], args[:0]
+		return err
+	}
+
+	for userID, at := range seen {
+		values = append(values, "(?, ?, ?)")
+		args = append(args, userID, at, at)
+		if len(values) == touchBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// CountUsers возвращает количество учетных записей и анонимных пользователей из PostgreSQL.
+// Возвращает ошибку, если операция не удалась.
+func (p *Postgres) CountUsers(ctx context.Context, activeSince time.Time) (*model.UserCount, error) {
+	var count model.UserCount
+
+	err := p.db.NewRaw(`
+		SELECT
+			r.registered + a.anonymous AS total,
+			r.registered,
+			a.anonymous,
+			a.active
+		FROM (SELECT count(*) AS registered FROM shortener.users) r,
+		(
+			SELECT
+				count(*) FILTER (WHERE u.id IS NULL) AS anonymous,
+				count(*) FILTER (WHERE ua.time_last_seen >= ?) AS active
+			FROM shortener.user_activity ua
+			LEFT JOIN shortener.users u ON u.id = ua.user_id
+		) a`, activeSince).
+		Scan(ctx, &count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &count, nil
+}
+
+// FindInactiveUsers возвращает анонимных пользователей из PostgreSQL, неактивных с seenBefore.
+// Возвращает не больше limit идентификаторов и ошибку, если операция не удалась.
+func (p *Postgres) FindInactiveUsers(ctx context.Context, seenBefore time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+
+	err := p.db.NewRaw(`
+		SELECT ua.user_id
+		FROM shortener.user_activity ua
+		WHERE ua.time_last_seen < ?
+			AND NOT EXISTS (SELECT 1 FROM shortener.users u WHERE u.id = ua.user_id)
+		ORDER BY ua.time_last_seen
+		LIMIT ?`, seenBefore, limit).
+		Scan(ctx, &ids)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// PurgeInactiveUser удаляет ссылки неактивного анонимного пользователя из PostgreSQL,
+// отзывает его API-ключи и удаляет его из реестра в одной транзакции.
+// Возвращает количество удаленных ссылок и repository.ErrNotFound, если пользователь
+// стал активен, зарегистрировался или отсутствует в реестре.
+func (p *Postgres) PurgeInactiveUser(ctx context.Context, userID uuid.UUID, seenBefore time.Time) (int, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.NewRaw(`
+		DELETE FROM shortener.user_activity ua
+		WHERE ua.user_id = ? AND ua.time_last_seen < ?
+			AND NOT EXISTS (SELECT 1 FROM shortener.users u WHERE u.id = ua.user_id)`, userID, seenBefore).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		err = repository.ErrNotFound
+		return 0, err
+	}
+
+	result, err = tx.NewDelete().
+		Table("shortener.links").
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.NewUpdate().
+		Table("shortener.api_keys").
+		Set("time_revoked = now()").
+		Where("user_id = ? AND time_revoked IS NULL", userID).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// likeEscaper экранирует специальные символы шаблона LIKE.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchLinks находит ссылки любых пользователей в PostgreSQL, включая удаленные, от новых к старым.
+// Оригинальный URL сравнивается с подстрокой без учета регистра.
+// Возвращает не больше filter.Limit ссылок и ошибку, если операция не удалась.
+func (p *Postgres) SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.Link, error) {
+	var (
+		links []model.Link
+		query = `
+				SELECT ` + linkColumns + `
+				FROM shortener.links
+				WHERE (?::text = '' OR link ILIKE ?)
+					AND (?::uuid IS NULL OR user_id = ?)
+				ORDER BY time_created DESC, id
+				LIMIT ?;
+			`
+	)
+
+	pattern := "%" + likeEscaper.Replace(filter.URL) + "%"
+	err := p.db.NewRaw(query, filter.URL, pattern, filter.UserID, filter.UserID, filter.Limit).Scan(ctx, &links)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// SetLinkDisabled отключает или включает ссылку в PostgreSQL.
+// Возвращает repository.ErrNotFound, если ссылка не найдена.
+func (p *Postgres) SetLinkDisabled(ctx context.Context, id string, disabledAt *time.Time, reason string) error {
+	result, err := p.db.NewUpdate().
+		Table("shortener.links").
+		Set("time_disabled = ?", disabledAt).
+		Set("disabled_reason = ?", reason).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+// FindUserStats возвращает количество ссылок и API-ключей пользователей из PostgreSQL.
+// Возвращает не больше limit записей и ошибку, если операция не удалась.
+func (p *Postgres) FindUserStats(ctx context.Context, userID *uuid.UUID, limit int) ([]model.UserStats, error) {
+	var stats []model.UserStats
+
+	query := `
+		SELECT l.user_id,
+			COALESCE(u.email, '') AS email,
+			count(*) AS links,
+			count(*) FILTER (WHERE l.is_deleted = false AND l.time_disabled IS NULL) AS active_links,
+			count(*) FILTER (WHERE l.is_deleted = true) AS deleted_links,
+			count(*) FILTER (WHERE l.time_disabled IS NOT NULL) AS disabled_links,
+			(SELECT count(*) FROM shortener.api_keys k
+				WHERE k.user_id = l.user_id AND k.time_revoked IS NULL) AS api_keys
+		FROM shortener.links l
+		LEFT JOIN shortener.users u ON u.id = l.user_id
+		WHERE ?::uuid IS NULL OR l.user_id = ?
+		GROUP BY l.user_id, u.email
+		ORDER BY links DESC, l.user_id
+		LIMIT ?;
+	`
+	err := p.db.NewRaw(query, userID, userID, limit).Scan(ctx, &stats)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// PurgeUser удаляет ссылки пользователя из PostgreSQL и отзывает его API-ключи в одной транзакции.
+// Метки, история и статистика вариантов удаляются вместе со ссылками.
+// Возвращает количество удаленных ссылок и ошибку, если операция не удалась.
+func (p *Postgres) PurgeUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result, err := tx.NewDelete().
+		Table("shortener.links").
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.NewUpdate().
+		Table("shortener.api_keys").
+		Set("time_revoked = now()").
+		Where("user_id = ? AND time_revoked IS NULL", userID).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(deleted), nil
+}
+
+// RecordAdminAction добавляет запись в журнал действий администраторов в PostgreSQL.
+// Возвращает ошибку, если операция не удалась.
+func (p *Postgres) RecordAdminAction(ctx context.Context, action *model.AdminAction) error {
+	_, err := p.db.NewInsert().
+		Model(action).
+		Returning("id, time_created").
+		Exec(ctx)
+	return err
+}
+
+// FindAdminActions возвращает последние записи журнала действий администраторов из PostgreSQL.
+// Возвращает не больше limit записей и ошибку, если операция не удалась.
+func (p *Postgres) FindAdminActions(ctx context.Context, limit int) ([]model.AdminAction, error) {
+	var actions []model.AdminAction
+
+	err := p.db.NewSelect().
+		Model(&actions).
+		Order("id DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return actions, nil
+}
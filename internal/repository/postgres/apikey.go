@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// CreateAPIKey сохраняет новый API-ключ пользователя в PostgreSQL.
+// Возвращает ошибку, если операция не удалась.
+func (p *Postgres) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	_, err := p.db.NewInsert().
+		Model(key).
+		Returning("time_created").
+		Exec(ctx)
+	return err
+}
+
+// FindAPIKeys возвращает API-ключи пользователя из PostgreSQL, включая отозванные, от новых к старым.
+// Возвращает список ключей и ошибку, если операция не удалась.
+func (p *Postgres) FindAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	var keys []model.APIKey
+
+	err := p.db.NewSelect().
+		Model(&keys).
+		Where("user_id = ?", userID).
+		Order("time_created DESC", "id").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// FindAPIKeyByHash находит API-ключ по хэшу в PostgreSQL.
+// Возвращает найденный ключ и repository.ErrNotFound, если ключ не найден.
+func (p *Postgres) FindAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	var key model.APIKey
+
+	err := p.db.NewSelect().
+		Model(&key).
+		Where("key_hash = ?", hash).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// RevokeAPIKey отзывает API-ключ пользователя в PostgreSQL.
+// Возвращает repository.ErrNotFound, если ключ не найден, уже отозван или принадлежит другому пользователю.
+func (p *Postgres) RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	result, err := p.db.NewUpdate().
+		Table("shortener.api_keys").
+		Set("time_revoked = now()").
+		Where("id = ? AND user_id = ? AND time_revoked IS NULL", id, userID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
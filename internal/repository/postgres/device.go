@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// CreateDeviceCode сохраняет код привязки устройства в PostgreSQL.
+// Ранее выданные пользователю коды и истекшие коды удаляются в той же транзакции.
+// Возвращает repository.ErrCodeExists, если такой код уже выдан.
+func (p *Postgres) CreateDeviceCode(ctx context.Context, code *model.DeviceCode) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.NewDelete().
+		Table("shortener.device_codes").
+		Where("user_id = ? OR time_expires < now()", code.UserID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.NewInsert().
+		Model(code).
+		Returning("time_created").
+		Exec(ctx)
+	if isUniqueViolation(err) {
+		err = repository.ErrCodeExists
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClaimDeviceCode удаляет действующий код привязки устройства из PostgreSQL и возвращает его.
+// Возвращает repository.ErrNotFound, если код не найден или истек.
+func (p *Postgres) ClaimDeviceCode(ctx context.Context, hash string) (*model.DeviceCode, error) {
+	var code model.DeviceCode
+
+	err := p.db.NewRaw(`
+		DELETE FROM shortener.device_codes
+		WHERE code_hash = ? AND time_expires > now()
+		RETURNING code_hash, user_id, time_created, time_expires`, hash).
+		Scan(ctx, &code)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
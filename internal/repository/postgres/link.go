@@ -2,40 +2,132 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/pgdriver"
 	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
 )
 
+// linkColumns перечисляет колонки таблицы ссылок, читаемые в model.Link.
+// Метки ссылки собираются из shortener.link_tags в массив.
+const linkColumns = `id, link, user_id, is_deleted, time_deleted, clicks_left, active_from, active_until, folder, title,
+	redirect_code, cache_max_age, passthrough, utm, targets, rules, entries, time_disabled, disabled_reason, time_created,
+	ARRAY(SELECT lt.tag FROM shortener.link_tags lt WHERE lt.link_id = links.id ORDER BY lt.tag) AS tags`
+
 // CreateLink создает новую запись сокращенного URL в PostgreSQL.
-// Использует UPSERT для обработки дубликатов.
+// Если оригинальный URL уже сокращен неудаленной ссылкой в области дедупликации,
+// возвращает существующую запись вместо создания новой.
 // Возвращает созданную запись и ошибку, если операция не удалась.
-func (p *Postgres) CreateLink(ctx context.Context, id, link string, userID uuid.UUID) (*model.Link, error) {
+func (p *Postgres) CreateLink(ctx context.Context, id, link string, userID uuid.UUID, opts model.LinkOptions) (*model.Link, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	existing, err := findDuplicate(ctx, tx, id, link, userID, repository.DedupeScope())
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
 	var newLink model.Link
 
+	data := model.Link{ID: id, Link: link, UserID: userID}
+	opts.Apply(&data)
+
 	query := `
-		INSERT INTO shortener.links (id, link, user_id, is_deleted)
-        VALUES (?, ?, ?, false)
-        ON CONFLICT (link) DO UPDATE SET link = EXCLUDED.link
-        RETURNING id, link, user_id, is_deleted;
+		INSERT INTO shortener.links (id, link, user_id, is_deleted, clicks_left, active_from, active_until, title,
+			redirect_code, cache_max_age, passthrough, utm, targets, rules, entries)
+        VALUES (?, ?, ?, false, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        RETURNING ` + linkColumns + `;
 	`
 
-	err := p.db.NewRaw(query, id, link, userID).Scan(ctx, &newLink)
+	err = tx.NewRaw(query, data.ID, data.Link, data.UserID, data.ClicksLeft, data.ActiveFrom, data.ActiveUntil, data.Title,
+		data.RedirectCode, data.CacheMaxAge, data.Passthrough, data.UTM,
+		data.Targets, data.Rules, data.Entries).Scan(ctx, &newLink)
 	if err != nil {
 		return nil, err
 	}
 
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &newLink, nil
 }
 
+// findDuplicate ищет неудаленную ссылку, отличную от id, на оригинальный URL url в области дедупликации scope.
+// Блокирует URL до конца транзакции, чтобы конкурентные запросы не создали дубликат.
+// Подборки не имеют оригинального URL и не дедуплицируются.
+// Возвращает nil, если дубликат не найден или дедупликация отключена.
+func findDuplicate(ctx context.Context, tx bun.Tx, id, url string, userID uuid.UUID, scope string) (*model.Link, error) {
+	if scope == model.DedupeNone || url == "" {
+		return nil, nil
+	}
+
+	if err := lockURLs(ctx, tx, []string{url}); err != nil {
+		return nil, err
+	}
+
+	var link model.Link
+	err := tx.NewRaw(`
+		SELECT `+linkColumns+`
+		FROM shortener.links
+		WHERE link = ? AND id <> ? AND is_deleted = false AND time_disabled IS NULL AND (? OR user_id = ?)
+		ORDER BY time_created, id
+		LIMIT 1;
+	`, url, id, scope == model.DedupeGlobal, userID).Scan(ctx, &link)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+// lockURLs блокирует оригинальные URL urls до конца транзакции.
+// Любая проверка дубликатов выполняется под этой блокировкой, иначе конкурентные запросы
+// с одним URL не видят изменений друг друга и создают дубликат.
+// URL блокируются по порядку, чтобы транзакции с несколькими URL не ждали друг друга взаимно.
+func lockURLs(ctx context.Context, tx bun.Tx, urls []string) error {
+	urls = slices.Compact(slices.Sorted(slices.Values(urls)))
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		if _, err := tx.NewRaw(`SELECT pg_advisory_xact_lock(hashtext(?));`, url).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // FindLink находит запись сокращенного URL по его идентификатору в PostgreSQL.
-// Возвращает найденную запись и ошибку, если URL не найден.
+// Возвращает найденную запись и repository.ErrNotFound, если URL не найден.
 func (p *Postgres) FindLink(ctx context.Context, id string) (*model.Link, error) {
 	var (
 		link  model.Link
 		query = `
-				SELECT id, link, user_id, is_deleted
+				SELECT ` + linkColumns + `
 				FROM shortener.links
 				WHERE id = ?
 				LIMIT 1;
@@ -44,26 +136,224 @@ func (p *Postgres) FindLink(ctx context.Context, id string) (*model.Link, error)
 
 	err := p.db.NewRaw(query, id).Scan(ctx, &link)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
 		return nil, err
 	}
 
 	return &link, err
 }
 
-// FindUserLinks возвращает все URL, созданные указанным пользователем в PostgreSQL.
-// Возвращает массив URL и ошибку, если операция не удалась.
-func (p *Postgres) FindUserLinks(ctx context.Context, userID uuid.UUID) ([]model.Link, error) {
+// UpdateLink сохраняет изменяемые владельцем параметры ссылки в PostgreSQL.
+// При смене оригинального URL в той же транзакции сохраняет предыдущее значение в shortener.link_history.
+// Возвращает repository.ErrNotFound, если ссылка не найдена или принадлежит другому пользователю,
+// и repository.ErrLinkExists, если новый URL уже сокращен.
+func (p *Postgres) UpdateLink(ctx context.Context, link *model.Link) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var changed bool
+	err = tx.NewRaw(`
+		SELECT link <> ?
+		FROM shortener.links
+		WHERE id = ? AND user_id = ?
+		FOR UPDATE;
+	`, link.Link, link.ID, link.UserID).Scan(ctx, &changed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = repository.ErrNotFound
+		}
+		return err
+	}
+
+	if changed {
+		var duplicate *model.Link
+		duplicate, err = findDuplicate(ctx, tx, link.ID, link.Link, link.UserID, repository.DedupeScope())
+		if err != nil {
+			return err
+		}
+		if duplicate != nil {
+			err = repository.ErrLinkExists
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO shortener.link_history (link_id, link)
+		SELECT id, link
+		FROM shortener.links
+		WHERE id = ? AND user_id = ? AND link <> ?
+		FOR UPDATE;
+	`
+	_, err = tx.NewRaw(query, link.ID, link.UserID, link.Link).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.NewUpdate().
+		Table("shortener.links").
+		Set("link = ?", link.Link).
+		Set("active_from = ?", link.ActiveFrom).
+		Set("active_until = ?", link.ActiveUntil).
+		Set("folder = ?", link.Folder).
+		Set("title = ?", link.Title).
+		Set("redirect_code = ?", link.RedirectCode).
+		Set("cache_max_age = ?", link.CacheMaxAge).
+		Set("passthrough = ?", link.Passthrough).
+		Set("utm = ?", link.UTM).
+		Set("targets = ?", link.Targets).
+		Set("rules = ?", link.Rules).
+		Set("entries = ?", link.Entries).
+		Where("id = ? AND user_id = ?", link.ID, link.UserID).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		err = repository.ErrNotFound
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FindLinkHistory возвращает предыдущие значения оригинального URL ссылки из PostgreSQL.
+// Версии нумеруются в порядке замены, начиная с 1.
+func (p *Postgres) FindLinkHistory(ctx context.Context, id string) ([]model.LinkVersion, error) {
 	var (
-		links []model.Link
-		query = `
-				SELECT id, link, user_id, is_deleted
-				FROM shortener.links
-				WHERE user_id = ? AND is_deleted = false
+		history []model.LinkVersion
+		query   = `
+				SELECT ROW_NUMBER() OVER (ORDER BY id) AS version, link, time_replaced
+				FROM shortener.link_history
+				WHERE link_id = ?
 				ORDER BY id;
 			`
 	)
 
-	err := p.db.NewRaw(query, userID).Scan(ctx, &links)
+	err := p.db.NewRaw(query, id).Scan(ctx, &history)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// isUniqueViolation проверяет, вызвана ли ошибка нарушением ограничения уникальности.
+func isUniqueViolation(err error) bool {
+	var pgErr pgdriver.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == "23505"
+}
+
+// ConsumeClick атомарно уменьшает оставшееся количество переходов по ссылке в PostgreSQL.
+// Использует условный UPDATE, поэтому конкурентные запросы не могут превысить лимит.
+// Возвращает false, если лимит переходов исчерпан, и ошибку, если операция не удалась.
+func (p *Postgres) ConsumeClick(ctx context.Context, id string) (bool, error) {
+	result, err := p.db.NewUpdate().
+		Table("shortener.links").
+		Set("clicks_left = clicks_left - 1").
+		Where("id = ? AND is_deleted = false AND (clicks_left IS NULL OR clicks_left > 0)", id).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// RecordVariants увеличивает счетчики переходов на варианты адресов ссылок в shortener.link_variant_clicks.
+// Счетчики сохраняются пачками не больше touchBatchSize, переходы по удаленным из таблицы ссылкам пропускаются.
+// Возвращает ошибку, если операция не удалась.
+func (p *Postgres) RecordVariants(ctx context.Context, clicks map[string]map[string]int64) error {
+	values := make([]string, 0, touchBatchSize)
+	args := make([]interface{}, 0, 3*touchBatchSize)
+
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		_, err := p.db.NewRaw(`
+			INSERT INTO shortener.link_variant_clicks (link_id, variant, clicks)
+			SELECT v.link_id, v.variant, v.clicks
+			FROM (VALUES `+strings.Join(values, ", ")+`) AS v (link_id, variant, clicks)
+			JOIN shortener.links l ON l.id = v.link_id
+			ON CONFLICT (link_id, variant) DO UPDATE
+			SET clicks = link_variant_clicks.clicks + EXCLUDED.clicks`, args...).
+			Exec(ctx)
+		values, args = values[:0], args[:0]
+		return err
+	}
+
+	for id, variants := range clicks {
+		for variant, count := range variants {
+			values = append(values, "(?::varchar, ?::varchar, ?::bigint)")
+			args = append(args, id, variant, count)
+			if len(values) == touchBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return flush()
+}
+
+// FindVariantClicks возвращает количество переходов по вариантам адреса ссылки из PostgreSQL.
+// Возвращает массив счетчиков и ошибку, если операция не удалась.
+func (p *Postgres) FindVariantClicks(ctx context.Context, id string) ([]model.VariantStats, error) {
+	var (
+		stats []model.VariantStats
+		query = `
+				SELECT variant, clicks
+				FROM shortener.link_variant_clicks
+				WHERE link_id = ?
+				ORDER BY variant;
+			`
+	)
+
+	err := p.db.NewRaw(query, id).Scan(ctx, &stats)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// userLinksQuery запрос неудаленных ссылок пользователя, подходящих под фильтр по метке и папке
+const userLinksQuery = `
+	SELECT ` + linkColumns + `
+	FROM shortener.links
+	WHERE user_id = ? AND is_deleted = false
+		AND (?::text = '' OR EXISTS (
+			SELECT 1 FROM shortener.link_tags lt WHERE lt.link_id = links.id AND lt.tag = ?
+		))
+		AND (?::text IS NULL OR folder = ?)
+	ORDER BY id;
+`
+
+// FindUserLinks возвращает все URL, созданные указанным пользователем в PostgreSQL и подходящие под фильтр.
+// Возвращает массив URL и ошибку, если операция не удалась.
+func (p *Postgres) FindUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter) ([]model.Link, error) {
+	var links []model.Link
+
+	err := p.db.NewRaw(userLinksQuery, userID, filter.Tag, filter.Tag, filter.Folder, filter.Folder).Scan(ctx, &links)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +361,193 @@ func (p *Postgres) FindUserLinks(ctx context.Context, userID uuid.UUID) ([]model
 	return links, nil
 }
 
+// WalkUserLinks передает функции fn по одной все URL, созданные указанным пользователем в PostgreSQL
+// и подходящие под фильтр. Строки читаются из результата запроса по мере обработки.
+// Возвращает первую ошибку fn или ошибку, если операция не удалась.
+func (p *Postgres) WalkUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter, fn func(*model.Link) error) error {
+	rows, err := p.db.QueryContext(ctx, userLinksQuery, userID, filter.Tag, filter.Tag, filter.Folder, filter.Folder)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link model.Link
+		if err = p.db.ScanRow(ctx, rows, &link); err != nil {
+			return err
+		}
+		if err = fn(&link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// AssignLabels назначает и снимает метки, а также меняет папку у ссылок пользователя в PostgreSQL.
+// Все изменения выполняются в одной транзакции.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (p *Postgres) AssignLabels(ctx context.Context, userID uuid.UUID, req model.LabelRequest) (int, error) {
+	if len(req.IDs) == 0 {
+		return 0, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var ids []string
+	err = tx.NewRaw(`
+		SELECT id
+		FROM shortener.links
+		WHERE id IN (?) AND user_id = ? AND is_deleted = false
+		FOR UPDATE;
+	`, bun.In(req.IDs), userID).Scan(ctx, &ids)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		tx.Rollback()
+		return 0, nil
+	}
+
+	if len(req.AddTags) > 0 {
+		_, err = tx.NewRaw(`
+			INSERT INTO shortener.link_tags (link_id, user_id, tag)
+			SELECT l.id, ?, t.tag
+			FROM unnest(?::text[]) AS l(id), unnest(?::text[]) AS t(tag)
+			ON CONFLICT (link_id, tag) DO NOTHING;
+		`, userID, pgdialect.Array(ids), pgdialect.Array(req.AddTags)).Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(req.RemoveTags) > 0 {
+		_, err = tx.NewDelete().
+			Table("shortener.link_tags").
+			Where("link_id IN (?) AND tag IN (?)", bun.In(ids), bun.In(req.RemoveTags)).
+			Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if req.Folder.Set {
+		folder := ""
+		if req.Folder.Value != nil {
+			folder = *req.Folder.Value
+		}
+		_, err = tx.NewUpdate().
+			Table("shortener.links").
+			Set("folder = ?", folder).
+			Where("id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
+}
+
+// FindUserTags возвращает метки пользователя с количеством неудаленных ссылок для каждой из PostgreSQL.
+// Возвращает массив меток и ошибку, если операция не удалась.
+func (p *Postgres) FindUserTags(ctx context.Context, userID uuid.UUID) ([]model.TagInfo, error) {
+	var (
+		tags  []model.TagInfo
+		query = `
+				SELECT lt.tag, COUNT(*) AS links
+				FROM shortener.link_tags lt
+				JOIN shortener.links l ON l.id = lt.link_id
+				WHERE lt.user_id = ? AND l.is_deleted = false
+				GROUP BY lt.tag
+				ORDER BY lt.tag;
+			`
+	)
+
+	err := p.db.NewRaw(query, userID).Scan(ctx, &tags)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// RenameTag переименовывает метку на всех ссылках пользователя в PostgreSQL.
+// Если у ссылки уже есть метка с новым названием, метки объединяются.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (p *Postgres) RenameTag(ctx context.Context, userID uuid.UUID, from, to string) (int, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	_, err = tx.NewRaw(`
+		INSERT INTO shortener.link_tags (link_id, user_id, tag)
+		SELECT link_id, user_id, ?
+		FROM shortener.link_tags
+		WHERE user_id = ? AND tag = ?
+		ON CONFLICT (link_id, tag) DO NOTHING;
+	`, to, userID, from).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := p.deleteTag(ctx, tx, userID, from)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// DeleteTag снимает метку со всех ссылок пользователя в PostgreSQL.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (p *Postgres) DeleteTag(ctx context.Context, userID uuid.UUID, tag string) (int, error) {
+	return p.deleteTag(ctx, p.db, userID, tag)
+}
+
+// deleteTag удаляет метку пользователя в рамках переданного соединения или транзакции.
+func (p *Postgres) deleteTag(ctx context.Context, db bun.IDB, userID uuid.UUID, tag string) (int, error) {
+	result, err := db.NewDelete().
+		Table("shortener.link_tags").
+		Where("user_id = ? AND tag = ?", userID, tag).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
 // BatchCreate создает несколько записей сокращенных URL в PostgreSQL в рамках транзакции.
+// Если оригинальный URL уже сокращен неудаленной ссылкой в области дедупликации,
+// заменяет элемент links существующей записью вместо создания новой.
 // Возвращает ошибку, если операция не удалась.
 func (p *Postgres) BatchCreate(ctx context.Context, links []model.Link) error {
 	tx, err := p.db.BeginTx(ctx, nil)
@@ -84,14 +560,254 @@ func (p *Postgres) BatchCreate(ctx context.Context, links []model.Link) error {
 		}
 	}()
 
-	_, err = tx.NewInsert().
-		Model(&links).
+	scope := repository.DedupeScope()
+	for i := range links {
+		var existing *model.Link
+		existing, err = findDuplicate(ctx, tx, links[i].ID, links[i].Link, links[i].UserID, scope)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			links[i] = *existing
+			continue
+		}
+
+		_, err = tx.NewInsert().
+			Model(&links[i]).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RestoreURLs снимает пометку удаления с указанных URL пользователя в PostgreSQL.
+// Восстанавливает только URL, удаленные не раньше deletedAfter, оригинальный адрес которых
+// не сокращен другой неудаленной ссылкой в области дедупликации. Из нескольких восстанавливаемых ссылок
+// с одним оригинальным URL восстанавливается только первая по идентификатору. Поиск конфликтов
+// и восстановление выполняются в одной транзакции под блокировкой оригинальных URL.
+// Возвращает идентификаторы восстановленных URL, идентификаторы URL с конфликтом и ошибку,
+// если операция не удалась.
+func (p *Postgres) RestoreURLs(ctx context.Context, ids []string, userID uuid.UUID, deletedAfter time.Time) ([]string, []string, error) {
+	if len(ids) == 0 {
+		return nil, nil, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var candidates []model.Link
+	err = tx.NewRaw(`
+		SELECT id, link, time_disabled
+		FROM shortener.links
+		WHERE id IN (?) AND user_id = ? AND is_deleted = true AND time_deleted >= ?
+		ORDER BY id
+		FOR UPDATE;
+	`, bun.In(ids), userID, deletedAfter).Scan(ctx, &candidates)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, err
+	}
+	if len(candidates) == 0 {
+		tx.Rollback()
+		return nil, nil, nil
+	}
+
+	var (
+		conflicts []string
+		scope     = repository.DedupeScope()
+	)
+	if scope != model.DedupeNone {
+		urls := make([]string, 0, len(candidates))
+		for _, link := range candidates {
+			urls = append(urls, link.Link)
+		}
+		if err = lockURLs(ctx, tx, urls); err != nil {
+			return nil, nil, err
+		}
+
+		candidateIDs := make([]string, 0, len(candidates))
+		for _, link := range candidates {
+			candidateIDs = append(candidateIDs, link.ID)
+		}
+		err = tx.NewRaw(`
+			SELECT l.id
+			FROM shortener.links l
+			WHERE l.id IN (?) AND l.link <> '' AND EXISTS (
+				SELECT 1 FROM shortener.links o
+				WHERE o.link = l.link AND o.id <> l.id AND o.is_deleted = false AND o.time_disabled IS NULL
+					AND (? OR o.user_id = l.user_id)
+			)
+			ORDER BY l.id;
+		`, bun.In(candidateIDs), scope == model.DedupeGlobal).Scan(ctx, &conflicts)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+	}
+
+	// Восстанавливаемые ссылки сравниваются и между собой: из нескольких ссылок
+	// с одним оригинальным URL восстанавливается только первая.
+	var (
+		restored []string
+		seen     = make(map[string]bool)
+	)
+	for _, link := range candidates {
+		if slices.Contains(conflicts, link.ID) {
+			continue
+		}
+		if scope != model.DedupeNone && link.Link != "" && link.DisabledAt == nil {
+			if seen[link.Link] {
+				conflicts = append(conflicts, link.ID)
+				continue
+			}
+			seen[link.Link] = true
+		}
+		restored = append(restored, link.ID)
+	}
+	slices.Sort(conflicts)
+
+	if len(restored) > 0 {
+		_, err = tx.NewUpdate().
+			Table("shortener.links").
+			Set("is_deleted = false").
+			Set("time_deleted = NULL").
+			Where("id IN (?)", bun.In(restored)).
+			Exec(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return restored, conflicts, nil
+}
+
+// TransferLinks передает неудаленные ссылки from пользователю to в PostgreSQL.
+// При дедупликации в пределах пользователя пропускает ссылки, оригинальный URL которых у to уже сокращен
+// или встречается среди передаваемых ссылок раньше, проверяя дубликаты под блокировкой оригинальных URL.
+// Владелец ссылок и их меток меняется в одной транзакции с записью в shortener.link_transfers.
+// Возвращает идентификаторы переданных ссылок и repository.ErrTokenUsed, если токен уже был использован.
+func (p *Postgres) TransferLinks(ctx context.Context, tokenID string, ids []string, from, to uuid.UUID) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var used bool
+	err = tx.NewRaw(`SELECT EXISTS (SELECT 1 FROM shortener.link_transfers WHERE token_id = ?);`, tokenID).
+		Scan(ctx, &used)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		err = repository.ErrTokenUsed
+		return nil, err
+	}
+
+	dedupe := repository.DedupeScope() == model.DedupeUser
+	if dedupe {
+		var urls []string
+		err = tx.NewRaw(`
+			SELECT DISTINCT link
+			FROM shortener.links
+			WHERE id IN (?) AND user_id = ? AND is_deleted = false AND link <> '';
+		`, bun.In(ids), from).Scan(ctx, &urls)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		if err = lockURLs(ctx, tx, urls); err != nil {
+			return nil, err
+		}
+	}
+
+	// Из нескольких передаваемых ссылок с одним оригинальным URL передается только первая по идентификатору.
+	var moved []string
+	err = tx.NewRaw(`
+		UPDATE shortener.links l
+		SET user_id = ?
+		WHERE l.id IN (?) AND l.user_id = ? AND l.is_deleted = false
+			AND (NOT ? OR l.link = '' OR l.time_disabled IS NOT NULL OR (NOT EXISTS (
+				SELECT 1 FROM shortener.links o
+				WHERE o.link = l.link AND o.id <> l.id AND o.user_id = ? AND o.is_deleted = false AND o.time_disabled IS NULL
+			) AND NOT EXISTS (
+				SELECT 1 FROM shortener.links d
+				WHERE d.id IN (?) AND d.link = l.link AND d.id < l.id AND d.user_id = ?
+					AND d.is_deleted = false AND d.time_disabled IS NULL
+			)))
+		RETURNING l.id;
+	`, to, bun.In(ids), from, dedupe, to, bun.In(ids), from).Scan(ctx, &moved)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if len(moved) == 0 {
+		tx.Rollback()
+		return nil, nil
+	}
+
+	_, err = tx.NewUpdate().
+		Table("shortener.link_tags").
+		Set("user_id = ?", to).
+		Where("link_id IN (?)", bun.In(moved)).
 		Exec(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return tx.Commit()
+	_, err = tx.NewRaw(`
+		INSERT INTO shortener.link_transfers (token_id, link_id, from_user, to_user)
+		SELECT ?, l.id, ?, ?
+		FROM unnest(?::text[]) AS l(id);
+	`, tokenID, from, to, pgdialect.Array(moved)).Exec(ctx)
+	if err != nil {
+		if isUniqueViolation(err) {
+			err = repository.ErrTokenUsed
+		}
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+// FindTransfers возвращает записи журнала передачи ссылок, в которых пользователь
+// был отправителем или получателем, от новых к старым.
+// Возвращает список записей и ошибку, если операция не удалась.
+func (p *Postgres) FindTransfers(ctx context.Context, userID uuid.UUID) ([]model.LinkTransfer, error) {
+	var transfers []model.LinkTransfer
+
+	query := `
+		SELECT token_id, link_id, from_user, to_user, time_transferred
+		FROM shortener.link_transfers
+		WHERE from_user = ? OR to_user = ?
+		ORDER BY time_transferred DESC, link_id;
+	`
+	err := p.db.NewRaw(query, userID, userID).Scan(ctx, &transfers)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return transfers, nil
 }
 
 // MarkDeletedURLs помечает указанные URL как удаленные в PostgreSQL.
@@ -105,6 +821,7 @@ func (p *Postgres) MarkDeletedURLs(ctx context.Context, ids []string, userID uui
 	result, err := p.db.NewUpdate().
 		Table("shortener.links").
 		Set("is_deleted = true").
+		Set("time_deleted = now()").
 		Where("id IN (?) AND user_id = ? AND is_deleted = false", bun.In(ids), userID).
 		Exec(ctx)
 
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// connectTest подключается к базе из DATABASE_DSN и применяет миграции.
+// Пропускает тест, если база не задана.
+func connectTest(t *testing.T) *Postgres {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	if cfg.Postgres.ConnString == "" {
+		t.Skip("DATABASE_DSN is not set")
+	}
+
+	ctx := context.Background()
+	p, err := Connect(ctx)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { p.Close() })
+	if !assert.NoError(t, goose.UpContext(ctx, p.db.DB, "../../../migration")) {
+		t.FailNow()
+	}
+	return p
+}
+
+// newTestID возвращает идентификатор ссылки, не занятый предыдущими запусками теста.
+func newTestID() string {
+	return uuid.NewString()[:8]
+}
+
+// countActive возвращает количество неудаленных включенных ссылок пользователя на url.
+func countActive(t *testing.T, p *Postgres, url string, userID uuid.UUID) int {
+	count, err := p.db.NewSelect().
+		Table("shortener.links").
+		Where("link = ? AND user_id = ? AND is_deleted = false AND time_disabled IS NULL", url, userID).
+		Count(context.Background())
+	assert.NoError(t, err)
+	return count
+}
+
+func TestDedupeOnRestoreAndOwnerChange(t *testing.T) {
+	p := connectTest(t)
+	ctx := context.Background()
+	cfg := util.GetConfig()
+
+	prevScope := cfg.Links.DedupeScope
+	t.Cleanup(func() { cfg.Links.DedupeScope = prevScope })
+
+	t.Run("restore deduplicates candidates", func(t *testing.T) {
+		cfg.Links.DedupeScope = model.DedupeUser
+		userID := uuid.New()
+		url := "https://example.com/" + uuid.NewString()
+
+		first, second := newTestID(), newTestID()
+		_, err := p.CreateLink(ctx, first, url, userID, model.LinkOptions{})
+		assert.NoError(t, err)
+		_, err = p.MarkDeletedURLs(ctx, []string{first}, userID)
+		assert.NoError(t, err)
+		_, err = p.CreateLink(ctx, second, url, userID, model.LinkOptions{})
+		assert.NoError(t, err)
+		_, err = p.MarkDeletedURLs(ctx, []string{second}, userID)
+		assert.NoError(t, err)
+
+		restored, conflicts, err := p.RestoreURLs(ctx, []string{first, second}, userID, time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Len(t, restored, 1)
+		assert.Len(t, conflicts, 1)
+		assert.Equal(t, 1, countActive(t, p, url, userID))
+	})
+
+	t.Run("restore races with create", func(t *testing.T) {
+		cfg.Links.DedupeScope = model.DedupeUser
+		userID := uuid.New()
+
+		for range 10 {
+			url := "https://example.com/" + uuid.NewString()
+			deleted := newTestID()
+			_, err := p.CreateLink(ctx, deleted, url, userID, model.LinkOptions{})
+			assert.NoError(t, err)
+			_, err = p.MarkDeletedURLs(ctx, []string{deleted}, userID)
+			assert.NoError(t, err)
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, _, err := p.RestoreURLs(ctx, []string{deleted}, userID, time.Now().Add(-time.Hour))
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := p.CreateLink(ctx, newTestID(), url, userID, model.LinkOptions{})
+				assert.NoError(t, err)
+			}()
+			wg.Wait()
+
+			assert.Equal(t, 1, countActive(t, p, url, userID))
+		}
+	})
+
+	t.Run("transfer and merge deduplicate candidates", func(t *testing.T) {
+		from, to := uuid.New(), uuid.New()
+		url := "https://example.com/" + uuid.NewString()
+
+		// Дубликаты у отправителя появились, пока дедупликация была отключена.
+		cfg.Links.DedupeScope = model.DedupeNone
+		ids := []string{newTestID(), newTestID()}
+		for _, id := range ids {
+			_, err := p.CreateLink(ctx, id, url, from, model.LinkOptions{})
+			assert.NoError(t, err)
+		}
+
+		cfg.Links.DedupeScope = model.DedupeUser
+		moved, err := p.TransferLinks(ctx, uuid.NewString(), ids, from, to)
+		assert.NoError(t, err)
+		assert.Len(t, moved, 1)
+		assert.Equal(t, 1, countActive(t, p, url, to))
+
+		other, fresh := uuid.New(), "https://example.com/"+uuid.NewString()
+		cfg.Links.DedupeScope = model.DedupeNone
+		for _, u := range []string{url, url, fresh, fresh} {
+			_, err = p.CreateLink(ctx, newTestID(), u, other, model.LinkOptions{})
+			assert.NoError(t, err)
+		}
+
+		cfg.Links.DedupeScope = model.DedupeUser
+		merged, err := p.MergeUser(ctx, other, to)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, merged)
+		assert.Equal(t, 1, countActive(t, p, url, to))
+		assert.Equal(t, 1, countActive(t, p, fresh, to))
+	})
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevokeToken добавляет токен в список отозванных в PostgreSQL и удаляет записи об истекших токенах.
+// Возвращает ошибку, если операция не удалась.
+func (p *Postgres) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := p.db.NewRaw(`
+		INSERT INTO shortener.revoked_tokens (jti, time_expires)
+		VALUES (?, ?)
+		ON CONFLICT (jti) DO NOTHING`, jti, expiresAt).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.db.NewDelete().
+		Table("shortener.revoked_tokens").
+		Where("time_expires < now()").
+		Exec(ctx)
+	return err
+}
+
+// RevokeUserTokens сохраняет в PostgreSQL момент, раньше которого выпущенные токены пользователя отозваны.
+// Возвращает ошибку, если операция не удалась.
+func (p *Postgres) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	_, err := p.db.NewRaw(`
+		INSERT INTO shortener.revoked_user_tokens (user_id, time_revoked)
+		VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE
+		SET time_revoked = GREATEST(revoked_user_tokens.time_revoked, EXCLUDED.time_revoked)`, userID, before).
+		Exec(ctx)
+	return err
+}
+
+// IsTokenRevoked проверяет, находится ли токен в списке отозванных в PostgreSQL
+// или выпущен раньше отзыва всех токенов пользователя.
+// Возвращает true, если токен отозван, и ошибку, если операция не удалась.
+func (p *Postgres) IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := p.db.NewRaw(`
+		SELECT EXISTS (SELECT 1 FROM shortener.revoked_tokens WHERE jti = ?)
+			OR EXISTS (SELECT 1 FROM shortener.revoked_user_tokens WHERE user_id = ? AND time_revoked > ?)`,
+		jti, userID, issuedAt).
+		Scan(ctx, &revoked)
+	return revoked, err
+}
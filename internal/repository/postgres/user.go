@@ -0,0 +1,155 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// CreateUser сохраняет новую учетную запись в PostgreSQL.
+// Возвращает repository.ErrUserExists, если адрес электронной почты уже зарегистрирован.
+func (p *Postgres) CreateUser(ctx context.Context, user *model.User) error {
+	_, err := p.db.NewInsert().
+		Model(user).
+		Returning("time_created").
+		Exec(ctx)
+	if isUniqueViolation(err) {
+		return repository.ErrUserExists
+	}
+	return err
+}
+
+// FindUserByEmail находит учетную запись по адресу электронной почты в PostgreSQL.
+// Возвращает найденную учетную запись и repository.ErrNotFound, если она не найдена.
+func (p *Postgres) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return p.findUser(ctx, "email = ?", email)
+}
+
+// FindUserByID находит учетную запись по идентификатору пользователя в PostgreSQL.
+// Возвращает найденную учетную запись и repository.ErrNotFound, если пользователь не зарегистрирован.
+func (p *Postgres) FindUserByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	return p.findUser(ctx, "id = ?", id)
+}
+
+// findUser находит учетную запись по условию.
+func (p *Postgres) findUser(ctx context.Context, where string, args ...interface{}) (*model.User, error) {
+	var user model.User
+
+	err := p.db.NewSelect().
+		Model(&user).
+		Where(where, args...).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repository.ErrNotFound
+		}
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// FindUserByIdentity находит учетную запись по привязанному пользователю провайдера в PostgreSQL.
+// Возвращает найденную учетную запись и repository.ErrNotFound, если привязки нет.
+func (p *Postgres) FindUserByIdentity(ctx context.Context, issuer, subject string) (*model.User, error) {
+	return p.findUser(ctx, "id = (SELECT user_id FROM shortener.user_identities WHERE issuer = ? AND subject = ?)", issuer, subject)
+}
+
+// LinkIdentity привязывает пользователя провайдера к учетной записи в PostgreSQL.
+// Возвращает repository.ErrUserExists, если пользователь провайдера уже привязан.
+func (p *Postgres) LinkIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	_, err := p.db.NewInsert().
+		Model(identity).
+		Returning("time_created").
+		Exec(ctx)
+	if isUniqueViolation(err) {
+		return repository.ErrUserExists
+	}
+	return err
+}
+
+// MergeUser передает ссылки, метки и API-ключи пользователя from пользователю to в PostgreSQL.
+// При дедупликации в пределах пользователя неудаленные ссылки, оригинальный URL которых у to уже сокращен,
+// остаются у from вместе с метками, а из нескольких ссылок from с одним URL переносится одна.
+// Проверка дубликатов выполняется под блокировкой оригинальных URL. Все изменения выполняются в одной транзакции.
+// Возвращает количество переданных ссылок и ошибку, если операция не удалась.
+func (p *Postgres) MergeUser(ctx context.Context, from, to uuid.UUID) (int, error) {
+	if from == to {
+		return 0, nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	dedupe := repository.DedupeScope() == model.DedupeUser
+	if dedupe {
+		var urls []string
+		err = tx.NewRaw(`
+			SELECT DISTINCT link
+			FROM shortener.links
+			WHERE user_id = ? AND is_deleted = false AND link <> '';
+		`, from).Scan(ctx, &urls)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+		if err = lockURLs(ctx, tx, urls); err != nil {
+			return 0, err
+		}
+	}
+
+	// Из нескольких ссылок from с одним оригинальным URL переносится только первая по идентификатору.
+	result, err := tx.NewRaw(`
+		UPDATE shortener.links l
+		SET user_id = ?
+		WHERE l.user_id = ?
+			AND (NOT ? OR l.is_deleted = true OR l.link = '' OR l.time_disabled IS NOT NULL OR (NOT EXISTS (
+				SELECT 1 FROM shortener.links o
+				WHERE o.link = l.link AND o.user_id = ? AND o.is_deleted = false AND o.time_disabled IS NULL
+			) AND NOT EXISTS (
+				SELECT 1 FROM shortener.links d
+				WHERE d.link = l.link AND d.id < l.id AND d.user_id = ? AND d.is_deleted = false AND d.time_disabled IS NULL
+			)));
+	`, to, from, dedupe, to, from).Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.NewUpdate().
+		Table("shortener.link_tags").
+		Set("user_id = ?", to).
+		Where("user_id = ? AND link_id IN (SELECT id FROM shortener.links WHERE user_id = ?)", from, to).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.NewUpdate().
+		Table("shortener.api_keys").
+		Set("user_id = ?", to).
+		Where("user_id = ?", from).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(moved), nil
+}
@@ -2,27 +2,103 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/util"
 )
 
+// ErrNotFound ошибка, возникающая при отсутствии ссылки в хранилище
+// ErrLinkExists ошибка, возникающая при нарушении уникальности оригинального URL
+// ErrTokenUsed ошибка, возникающая при повторном использовании одноразового токена
+// ErrUserExists ошибка, возникающая при регистрации уже занятого адреса электронной почты
+// ErrCodeExists ошибка, возникающая при выдаче кода привязки, совпадающего с действующим
+var (
+	ErrNotFound   = errors.New("link not found")
+	ErrLinkExists = errors.New("link already exists")
+	ErrTokenUsed  = errors.New("token already used")
+	ErrUserExists = errors.New("user already exists")
+	ErrCodeExists = errors.New("code already exists")
+)
+
+// DedupeScope возвращает область дедупликации оригинальных URL из конфигурации.
+// Пустое или неизвестное значение трактуется как model.DedupeGlobal.
+func DedupeScope() string {
+	switch scope := util.GetConfig().Links.DedupeScope; scope {
+	case model.DedupeUser, model.DedupeNone:
+		return scope
+	default:
+		return model.DedupeGlobal
+	}
+}
+
 // LinkRepository определяет интерфейс для работы с хранилищем сокращенных URL.
 // Предоставляет методы для создания, поиска и управления URL в базе данных.
 type LinkRepository interface {
 	// CreateLink создает новую запись сокращенного URL в хранилище.
+	// Принимает необязательные параметры ссылки. Если URL уже сокращен неудаленной ссылкой
+	// в области дедупликации DedupeScope, возвращает существующую запись.
 	// Возвращает созданную запись и ошибку, если операция не удалась.
-	CreateLink(ctx context.Context, id, url string, userID uuid.UUID) (*model.Link, error)
+	CreateLink(ctx context.Context, id, url string, userID uuid.UUID, opts model.LinkOptions) (*model.Link, error)
 
 	// FindLink находит запись сокращенного URL по его идентификатору.
-	// Возвращает найденную запись и ошибку, если URL не найден.
+	// Возвращает найденную запись и ErrNotFound, если URL не найден.
 	FindLink(ctx context.Context, id string) (*model.Link, error)
 
-	// FindUserLinks возвращает все URL, созданные указанным пользователем.
+	// UpdateLink сохраняет изменяемые владельцем параметры ссылки.
+	// Изменяет только ссылку, принадлежащую link.UserID.
+	// При смене оригинального URL сохраняет предыдущее значение в истории ссылки.
+	// Возвращает ErrNotFound, если ссылка не найдена, и ErrLinkExists при конфликте URL.
+	UpdateLink(ctx context.Context, link *model.Link) error
+
+	// FindLinkHistory возвращает предыдущие значения оригинального URL ссылки.
+	// Версии упорядочены от старых к новым.
+	FindLinkHistory(ctx context.Context, id string) ([]model.LinkVersion, error)
+
+	// ConsumeClick атомарно уменьшает оставшееся количество переходов по ссылке.
+	// Возвращает false, если лимит переходов исчерпан, и ошибку, если операция не удалась.
+	ConsumeClick(ctx context.Context, id string) (bool, error)
+
+	// RecordVariants увеличивает счетчики переходов на варианты адресов ссылок.
+	// Принимает количество переходов по идентификаторам ссылок и названиям вариантов.
+	// Переходы по отсутствующим ссылкам не учитываются.
+	// Возвращает ошибку, если операция не удалась.
+	RecordVariants(ctx context.Context, clicks map[string]map[string]int64) error
+
+	// FindVariantClicks возвращает количество переходов по вариантам адреса ссылки.
+	// Варианты без переходов в результат не попадают.
+	FindVariantClicks(ctx context.Context, id string) ([]model.VariantStats, error)
+
+	// FindUserLinks возвращает все URL, созданные указанным пользователем и подходящие под фильтр.
 	// Возвращает массив URL и ошибку, если операция не удалась.
-	FindUserLinks(ctx context.Context, userID uuid.UUID) ([]model.Link, error)
+	FindUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter) ([]model.Link, error)
+
+	// WalkUserLinks передает функции fn по одной все URL, созданные указанным пользователем
+	// и подходящие под фильтр, не загружая их в память целиком. Обход прекращается при первой ошибке fn.
+	// Возвращает ошибку fn или ошибку, если операция не удалась.
+	WalkUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter, fn func(*model.Link) error) error
+
+	// AssignLabels назначает и снимает метки, а также меняет папку у ссылок пользователя.
+	// Изменяет только неудаленные ссылки, принадлежащие пользователю.
+	// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+	AssignLabels(ctx context.Context, userID uuid.UUID, req model.LabelRequest) (int, error)
+
+	// FindUserTags возвращает метки пользователя с количеством ссылок для каждой.
+	// Возвращает массив меток и ошибку, если операция не удалась.
+	FindUserTags(ctx context.Context, userID uuid.UUID) ([]model.TagInfo, error)
+
+	// RenameTag переименовывает метку на всех ссылках пользователя.
+	// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+	RenameTag(ctx context.Context, userID uuid.UUID, from, to string) (int, error)
+
+	// DeleteTag снимает метку со всех ссылок пользователя.
+	// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+	DeleteTag(ctx context.Context, userID uuid.UUID, tag string) (int, error)
 
 	// BatchCreate создает несколько записей сокращенных URL в хранилище.
+	// Элементы, URL которых уже сокращен в области дедупликации, заменяются существующими записями.
 	// Возвращает ошибку, если операция не удалась.
 	BatchCreate(ctx context.Context, links []model.Link) error
 
@@ -30,6 +106,138 @@ type LinkRepository interface {
 	// Возвращает количество удаленных URL и ошибку, если операция не удалась.
 	MarkDeletedURLs(ctx context.Context, ids []string, userID uuid.UUID) (int, error)
 
+	// RestoreURLs снимает пометку удаления с указанных URL пользователя, удаленных не раньше deletedAfter.
+	// Не восстанавливает URL, оригинальный адрес которых уже сокращен другой неудаленной ссылкой.
+	// Возвращает идентификаторы восстановленных URL, идентификаторы URL с конфликтом и ошибку,
+	// если операция не удалась.
+	RestoreURLs(ctx context.Context, ids []string, userID uuid.UUID, deletedAfter time.Time) ([]string, []string, error)
+
+	// TransferLinks передает неудаленные ссылки from пользователю to и записывает передачу в журнал.
+	// При дедупликации в пределах пользователя ссылки, оригинальный URL которых у to уже сокращен
+	// другой неудаленной ссылкой, не передаются. Изменение владельца и запись в журнал выполняются атомарно.
+	// Возвращает идентификаторы переданных ссылок и ErrTokenUsed, если токен уже был использован.
+	TransferLinks(ctx context.Context, tokenID string, ids []string, from, to uuid.UUID) ([]string, error)
+
+	// FindTransfers возвращает записи журнала передачи, в которых пользователь был отправителем или получателем.
+	// Записи упорядочены от новых к старым.
+	// Возвращает список записей и ошибку, если операция не удалась.
+	FindTransfers(ctx context.Context, userID uuid.UUID) ([]model.LinkTransfer, error)
+
+	// CreateAPIKey сохраняет новый API-ключ пользователя.
+	// Возвращает ошибку, если операция не удалась.
+	CreateAPIKey(ctx context.Context, key *model.APIKey) error
+
+	// FindAPIKeys возвращает API-ключи пользователя, включая отозванные, от новых к старым.
+	// Возвращает список ключей и ошибку, если операция не удалась.
+	FindAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error)
+
+	// FindAPIKeyByHash находит API-ключ по хэшу.
+	// Возвращает найденный ключ и ErrNotFound, если ключ не найден.
+	FindAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error)
+
+	// RevokeAPIKey отзывает API-ключ пользователя.
+	// Возвращает ErrNotFound, если ключ не найден, уже отозван или принадлежит другому пользователю.
+	RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) error
+
+	// CreateUser сохраняет новую учетную запись.
+	// Возвращает ErrUserExists, если адрес электронной почты уже зарегистрирован.
+	CreateUser(ctx context.Context, user *model.User) error
+
+	// FindUserByEmail находит учетную запись по адресу электронной почты.
+	// Возвращает найденную учетную запись и ErrNotFound, если она не найдена.
+	FindUserByEmail(ctx context.Context, email string) (*model.User, error)
+
+	// FindUserByID находит учетную запись по идентификатору пользователя.
+	// Возвращает найденную учетную запись и ErrNotFound, если пользователь не зарегистрирован.
+	FindUserByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+
+	// FindUserByIdentity находит учетную запись, к которой привязан пользователь subject провайдера issuer.
+	// Возвращает найденную учетную запись и ErrNotFound, если привязки нет.
+	FindUserByIdentity(ctx context.Context, issuer, subject string) (*model.User, error)
+
+	// LinkIdentity привязывает пользователя провайдера OpenID Connect к учетной записи.
+	// Возвращает ErrUserExists, если пользователь провайдера уже привязан.
+	LinkIdentity(ctx context.Context, identity *model.UserIdentity) error
+
+	// MergeUser передает все ссылки, включая удаленные, их метки и API-ключи пользователя from пользователю to.
+	// При дедупликации в пределах пользователя неудаленные ссылки, оригинальный URL которых у to уже сокращен
+	// другой неудаленной ссылкой, остаются у from. Изменения выполняются атомарно.
+	// Возвращает количество переданных ссылок и ошибку, если операция не удалась.
+	MergeUser(ctx context.Context, from, to uuid.UUID) (int, error)
+
+	// SearchLinks находит ссылки любых пользователей, включая удаленные, от новых к старым.
+	// Возвращает не больше filter.Limit ссылок и ошибку, если операция не удалась.
+	SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.Link, error)
+
+	// SetLinkDisabled отключает ссылку с указанной причиной или, если disabledAt равен nil, включает ее.
+	// Возвращает ErrNotFound, если ссылка не найдена.
+	SetLinkDisabled(ctx context.Context, id string, disabledAt *time.Time, reason string) error
+
+	// FindUserStats возвращает количество ссылок и API-ключей пользователей, у которых есть ссылки,
+	// от пользователей с наибольшим количеством ссылок. Если userID задан, возвращает только его.
+	// Возвращает не больше limit записей и ошибку, если операция не удалась.
+	FindUserStats(ctx context.Context, userID *uuid.UUID, limit int) ([]model.UserStats, error)
+
+	// PurgeUser безвозвратно удаляет все ссылки пользователя и отзывает его API-ключи.
+	// Возвращает количество удаленных ссылок и ошибку, если операция не удалась.
+	PurgeUser(ctx context.Context, userID uuid.UUID) (int, error)
+
+	// RecordAdminAction добавляет запись в журнал действий администраторов.
+	// Возвращает ошибку, если операция не удалась.
+	RecordAdminAction(ctx context.Context, action *model.AdminAction) error
+
+	// FindAdminActions возвращает последние записи журнала действий администраторов, от новых к старым.
+	// Возвращает не больше limit записей и ошибку, если операция не удалась.
+	FindAdminActions(ctx context.Context, limit int) ([]model.AdminAction, error)
+
+	// TouchUsers сохраняет время последней активности пользователей в реестре.
+	// Пользователи, которых нет в реестре, добавляются с моментом первого обращения, равным времени активности.
+	// Более раннее время активности не заменяет сохраненное.
+	// Возвращает ошибку, если операция не удалась.
+	TouchUsers(ctx context.Context, seen map[uuid.UUID]time.Time) error
+
+	// CountUsers возвращает количество учетных записей и анонимных пользователей из реестра,
+	// а также пользователей, активных не раньше activeSince.
+	// Возвращает ошибку, если операция не удалась.
+	CountUsers(ctx context.Context, activeSince time.Time) (*model.UserCount, error)
+
+	// FindInactiveUsers возвращает анонимных пользователей, последняя активность которых раньше seenBefore,
+	// от давно неактивных к недавно активным.
+	// Возвращает не больше limit идентификаторов и ошибку, если операция не удалась.
+	FindInactiveUsers(ctx context.Context, seenBefore time.Time, limit int) ([]uuid.UUID, error)
+
+	// PurgeInactiveUser безвозвратно удаляет ссылки анонимного пользователя, отзывает его API-ключи
+	// и удаляет его из реестра, если его последняя активность по-прежнему раньше seenBefore.
+	// Изменения выполняются атомарно.
+	// Возвращает количество удаленных ссылок и ErrNotFound, если пользователь стал активен,
+	// зарегистрировался или отсутствует в реестре.
+	PurgeInactiveUser(ctx context.Context, userID uuid.UUID, seenBefore time.Time) (int, error)
+
+	// CreateDeviceCode сохраняет код привязки устройства.
+	// Ранее выданные пользователю коды и коды с истекшим сроком действия удаляются.
+	// Возвращает ErrCodeExists, если такой код уже выдан, и ошибку, если операция не удалась.
+	CreateDeviceCode(ctx context.Context, code *model.DeviceCode) error
+
+	// ClaimDeviceCode находит действующий код привязки устройства по хэшу и удаляет его,
+	// чтобы код нельзя было использовать повторно.
+	// Возвращает код и ErrNotFound, если код не найден или истек.
+	ClaimDeviceCode(ctx context.Context, hash string) (*model.DeviceCode, error)
+
+	// RevokeToken добавляет токен с идентификатором jti в список отозванных до момента его истечения expiresAt.
+	// Повторный отзыв токена не является ошибкой. Записи об истекших токенах удаляются.
+	// Возвращает ошибку, если операция не удалась.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+
+	// RevokeUserTokens отзывает все токены пользователя, выпущенные раньше before.
+	// Сохраняется только самый поздний момент отзыва.
+	// Возвращает ошибку, если операция не удалась.
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error
+
+	// IsTokenRevoked проверяет, находится ли токен пользователя userID с идентификатором jti
+	// в списке отозванных или выпущен в issuedAt раньше отзыва всех токенов пользователя.
+	// Возвращает true, если токен отозван, и ошибку, если операция не удалась.
+	IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error)
+
 	// Status проверяет доступность хранилища.
 	// Возвращает true, если хранилище доступно, и ошибку в противном случае.
 	Status(ctx context.Context) (bool, error)
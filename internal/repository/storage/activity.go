@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+)
+
+// activityFilePath возвращает путь к файлу реестра пользователей.
+func (s *LocalStorage) activityFilePath() string {
+	return s.filePath + ".activity"
+}
+
+// readActivityFile загружает реестр пользователей из файла.
+func (s *LocalStorage) readActivityFile() error {
+	var activity []model.UserActivity
+	if err := readJSONFile(s.activityFilePath(), &activity); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range activity {
+		s.activity[a.UserID] = a
+	}
+	return nil
+}
+
+// writeActivityFile сохраняет реестр пользователей в файл, если хранилище использует файловую систему.
+// Вызывается под блокировкой хранилища.
+func (s *LocalStorage) writeActivityFile() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	activity := make([]model.UserActivity, 0, len(s.activity))
+	for _, a := range s.activity {
+		activity = append(activity, a)
+	}
+	slices.SortFunc(activity, func(a, b model.UserActivity) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return writeJSONFile(s.activityFilePath(), activity)
+}
+
+// TouchUsers сохраняет время последней активности пользователей в реестре.
+// При ошибке записи в файл реестр не изменяется.
+func (s *LocalStorage) TouchUsers(ctx context.Context, seen map[uuid.UUID]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := make(map[uuid.UUID]*model.UserActivity, len(seen))
+	for userID, at := range seen {
+		a, exists := s.activity[userID]
+		if exists {
+			prev[userID] = &a
+			if !at.After(a.LastSeenAt) {
+				continue
+			}
+		} else {
+			prev[userID] = nil
+			a = model.UserActivity{UserID: userID, CreatedAt: at}
+		}
+		a.LastSeenAt = at
+		s.activity[userID] = a
+	}
+
+	if err := s.writeActivityFile(); err != nil {
+		for userID, a := range prev {
+			if a == nil {
+				delete(s.activity, userID)
+			} else {
+				s.activity[userID] = *a
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// CountUsers возвращает количество учетных записей и анонимных пользователей из реестра.
+func (s *LocalStorage) CountUsers(ctx context.Context, activeSince time.Time) (*model.UserCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := model.UserCount{Registered: len(s.users)}
+	for userID, a := range s.activity {
+		if _, registered := s.users[userID]; !registered {
+			count.Anonymous++
+		}
+		if !a.LastSeenAt.Before(activeSince) {
+			count.Active++
+		}
+	}
+	count.Total = count.Registered + count.Anonymous
+
+	return &count, nil
+}
+
+// FindInactiveUsers возвращает анонимных пользователей, неактивных с seenBefore.
+func (s *LocalStorage) FindInactiveUsers(ctx context.Context, seenBefore time.Time, limit int) ([]uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var inactive []model.UserActivity
+	for userID, a := range s.activity {
+		if _, registered := s.users[userID]; !registered && a.LastSeenAt.Before(seenBefore) {
+			inactive = append(inactive, a)
+		}
+	}
+	slices.SortFunc(inactive, func(a, b model.UserActivity) int { return a.LastSeenAt.Compare(b.LastSeenAt) })
+
+	ids := make([]uuid.UUID, 0, min(limit, len(inactive)))
+	for _, a := range inactive[:min(limit, len(inactive))] {
+		ids = append(ids, a.UserID)
+	}
+	return ids, nil
+}
+
+// PurgeInactiveUser удаляет ссылки неактивного анонимного пользователя, отзывает его API-ключи
+// и удаляет его из реестра.
+// При ошибке записи в файлы изменения отменяются.
+// Возвращает количество удаленных ссылок и ErrNotFound, если пользователь не подлежит удалению.
+func (s *LocalStorage) PurgeInactiveUser(ctx context.Context, userID uuid.UUID, seenBefore time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, exists := s.activity[userID]
+	if _, registered := s.users[userID]; !exists || registered || !a.LastSeenAt.Before(seenBefore) {
+		return 0, ErrNotFound
+	}
+
+	delete(s.activity, userID)
+	if err := s.writeActivityFile(); err != nil {
+		s.activity[userID] = a
+		return 0, err
+	}
+
+	deleted, err := s.purgeUser(userID)
+	if err != nil {
+		s.activity[userID] = a
+		s.writeActivityFile()
+		return 0, err
+	}
+	return deleted, nil
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+)
+
+// actionsFilePath возвращает путь к файлу журнала действий администраторов.
+func (s *LocalStorage) actionsFilePath() string {
+	return s.filePath + ".audit"
+}
+
+// readActionsFile загружает журнал действий администраторов из файла.
+func (s *LocalStorage) readActionsFile() error {
+	var actions []model.AdminAction
+	if err := readJSONFile(s.actionsFilePath(), &actions); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = actions
+	return nil
+}
+
+// SearchLinks находит ссылки любых пользователей, включая удаленные, от новых к старым.
+// Оригинальный URL сравнивается с подстрокой без учета регистра.
+func (s *LocalStorage) SearchLinks(ctx context.Context, filter model.AdminLinkFilter) ([]model.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	needle := strings.ToLower(filter.URL)
+	var links []model.Link
+	for id, data := range s.links {
+		if filter.UserID != nil && data.UserID != *filter.UserID {
+			continue
+		}
+		if needle != "" && !strings.Contains(strings.ToLower(data.URL), needle) {
+			continue
+		}
+		links = append(links, *data.toModel(id))
+	}
+
+	slices.SortFunc(links, func(a, b model.Link) int {
+		if c := b.TimeCreated.Compare(a.TimeCreated); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(links) > filter.Limit {
+		links = links[:filter.Limit]
+	}
+	return links, nil
+}
+
+// SetLinkDisabled отключает или включает ссылку.
+// Возвращает ErrNotFound, если ссылка не найдена. При ошибке записи в файл изменение отменяется.
+func (s *LocalStorage) SetLinkDisabled(ctx context.Context, id string, disabledAt *time.Time, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.links[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	prev := data
+	data.DisabledAt, data.DisabledReason = disabledAt, reason
+	s.links[id] = data
+
+	if s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			s.links[id] = prev
+			return err
+		}
+	}
+	return nil
+}
+
+// FindUserStats возвращает количество ссылок и API-ключей пользователей, у которых есть ссылки.
+func (s *LocalStorage) FindUserStats(ctx context.Context, userID *uuid.UUID, limit int) ([]model.UserStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byUser := make(map[uuid.UUID]*model.UserStats)
+	for _, data := range s.links {
+		if userID != nil && data.UserID != *userID {
+			continue
+		}
+		st, exists := byUser[data.UserID]
+		if !exists {
+			st = &model.UserStats{UserID: data.UserID, Email: s.users[data.UserID].Email}
+			byUser[data.UserID] = st
+		}
+		st.Links++
+		switch {
+		case data.IsDeleted:
+			st.DeletedLinks++
+		case data.DisabledAt == nil:
+			st.ActiveLinks++
+		}
+		if data.DisabledAt != nil {
+			st.DisabledLinks++
+		}
+	}
+	for _, key := range s.keys {
+		if st, exists := byUser[key.UserID]; exists && key.RevokedAt == nil {
+			st.APIKeys++
+		}
+	}
+
+	stats := make([]model.UserStats, 0, len(byUser))
+	for _, st := range byUser {
+		stats = append(stats, *st)
+	}
+	slices.SortFunc(stats, func(a, b model.UserStats) int {
+		if a.Links != b.Links {
+			return b.Links - a.Links
+		}
+		return strings.Compare(a.UserID.String(), b.UserID.String())
+	})
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats, nil
+}
+
+// PurgeUser безвозвратно удаляет ссылки пользователя и отзывает его API-ключи.
+// При ошибке записи в файлы изменения отменяются.
+// Возвращает количество удаленных ссылок.
+func (s *LocalStorage) PurgeUser(ctx context.Context, userID uuid.UUID) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.purgeUser(userID)
+}
+
+// purgeUser удаляет ссылки пользователя и отзывает его API-ключи.
+// Вызывается под блокировкой хранилища.
+func (s *LocalStorage) purgeUser(userID uuid.UUID) (int, error) {
+	var (
+		prevLinks = make(map[string]linkData)
+		prevKeys  = make(map[uuid.UUID]model.APIKey)
+		now       = time.Now()
+	)
+	for id, data := range s.links {
+		if data.UserID == userID {
+			prevLinks[id] = data
+			delete(s.links, id)
+		}
+	}
+	for id, key := range s.keys {
+		if key.UserID == userID && key.RevokedAt == nil {
+			prevKeys[id] = key
+			key.RevokedAt = &now
+			s.keys[id] = key
+		}
+	}
+
+	rollback := func() {
+		for id, data := range prevLinks {
+			s.links[id] = data
+		}
+		for id, key := range prevKeys {
+			s.keys[id] = key
+		}
+	}
+	if len(prevLinks) > 0 && s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			rollback()
+			return 0, err
+		}
+	}
+	if len(prevKeys) > 0 {
+		if err := s.writeKeysFile(); err != nil {
+			rollback()
+			if len(prevLinks) > 0 && s.filePath != "" {
+				s.writeToFile()
+			}
+			return 0, err
+		}
+	}
+
+	return len(prevLinks), nil
+}
+
+// RecordAdminAction добавляет запись в журнал действий администраторов.
+// При ошибке записи в файл запись не сохраняется.
+func (s *LocalStorage) RecordAdminAction(ctx context.Context, action *model.AdminAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	action.ID = int64(len(s.actions)) + 1
+	if action.CreatedAt.IsZero() {
+		action.CreatedAt = time.Now()
+	}
+	s.actions = append(s.actions, *action)
+
+	if s.filePath != "" {
+		if err := writeJSONFile(s.actionsFilePath(), s.actions); err != nil {
+			s.actions = s.actions[:len(s.actions)-1]
+			return err
+		}
+	}
+	return nil
+}
+
+// FindAdminActions возвращает последние записи журнала действий администраторов, от новых к старым.
+func (s *LocalStorage) FindAdminActions(ctx context.Context, limit int) ([]model.AdminAction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	actions := slices.Clone(s.actions)
+	slices.Reverse(actions)
+	if len(actions) > limit {
+		actions = actions[:limit]
+	}
+	return actions, nil
+}
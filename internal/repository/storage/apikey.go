@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+)
+
+// fileAPIKey представляет структуру для сериализации API-ключа в JSON.
+// Содержит поля, которые не передаются клиенту.
+type fileAPIKey struct {
+	model.APIKey
+	UserID uuid.UUID `json:"user_id"`  // Идентификатор владельца ключа
+	Hash   string    `json:"key_hash"` // Хэш ключа
+}
+
+// keysFilePath возвращает путь к файлу API-ключей, хранящемуся рядом с файлом ссылок.
+func (s *LocalStorage) keysFilePath() string {
+	return s.filePath + ".keys"
+}
+
+// readKeysFile загружает API-ключи из файла.
+func (s *LocalStorage) readKeysFile() error {
+	var keys []fileAPIKey
+	if err := readJSONFile(s.keysFilePath(), &keys); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		key.APIKey.UserID, key.APIKey.Hash = key.UserID, key.Hash
+		s.keys[key.ID] = key.APIKey
+	}
+	return nil
+}
+
+// writeKeysFile сохраняет API-ключи в файл, если хранилище использует файловую систему.
+// Вызывается под блокировкой хранилища.
+func (s *LocalStorage) writeKeysFile() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	keys := make([]fileAPIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, fileAPIKey{APIKey: key, UserID: key.UserID, Hash: key.Hash})
+	}
+	slices.SortFunc(keys, func(a, b fileAPIKey) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return writeJSONFile(s.keysFilePath(), keys)
+}
+
+// CreateAPIKey сохраняет новый API-ключ пользователя.
+// При ошибке записи в файл ключ не сохраняется.
+func (s *LocalStorage) CreateAPIKey(ctx context.Context, key *model.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	stored := *key
+	stored.Scopes = slices.Clone(key.Scopes)
+	s.keys[key.ID] = stored
+
+	if err := s.writeKeysFile(); err != nil {
+		delete(s.keys, key.ID)
+		return err
+	}
+	return nil
+}
+
+// FindAPIKeys возвращает API-ключи пользователя, включая отозванные, от новых к старым.
+func (s *LocalStorage) FindAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []model.APIKey
+	for _, key := range s.keys {
+		if key.UserID == userID {
+			key.Scopes = slices.Clone(key.Scopes)
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b model.APIKey) int { return b.CreatedAt.Compare(a.CreatedAt) })
+
+	return keys, nil
+}
+
+// FindAPIKeyByHash находит API-ключ по хэшу.
+// Возвращает ErrNotFound, если ключ не найден.
+func (s *LocalStorage) FindAPIKeyByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			key.Scopes = slices.Clone(key.Scopes)
+			return &key, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// RevokeAPIKey отзывает API-ключ пользователя.
+// Возвращает ErrNotFound, если ключ не найден, уже отозван или принадлежит другому пользователю.
+func (s *LocalStorage) RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, exists := s.keys[id]
+	if !exists || key.UserID != userID || key.RevokedAt != nil {
+		return ErrNotFound
+	}
+
+	prev := key
+	now := time.Now()
+	key.RevokedAt = &now
+	s.keys[id] = key
+
+	if err := s.writeKeysFile(); err != nil {
+		s.keys[id] = prev
+		return err
+	}
+	return nil
+}
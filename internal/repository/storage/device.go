@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+)
+
+// fileDeviceCode представляет структуру для сериализации кода привязки устройства в JSON.
+// Содержит поля, которые не передаются клиенту.
+type fileDeviceCode struct {
+	model.DeviceCode
+	Hash      string    `json:"code_hash"`  // Хэш кода
+	UserID    uuid.UUID `json:"user_id"`    // Идентификатор пользователя, выдавшего код
+	CreatedAt time.Time `json:"created_at"` // Момент выдачи кода
+}
+
+// devicesFilePath возвращает путь к файлу кодов привязки устройств.
+func (s *LocalStorage) devicesFilePath() string {
+	return s.filePath + ".devices"
+}
+
+// readDevicesFile загружает коды привязки устройств из файла.
+func (s *LocalStorage) readDevicesFile() error {
+	var codes []fileDeviceCode
+	if err := readJSONFile(s.devicesFilePath(), &codes); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, code := range codes {
+		code.DeviceCode.Hash = code.Hash
+		code.DeviceCode.UserID = code.UserID
+		code.DeviceCode.CreatedAt = code.CreatedAt
+		s.devices[code.Hash] = code.DeviceCode
+	}
+	return nil
+}
+
+// writeDevicesFile сохраняет коды привязки устройств в файл, если хранилище использует файловую систему.
+// Вызывается под блокировкой хранилища.
+func (s *LocalStorage) writeDevicesFile() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	codes := make([]fileDeviceCode, 0, len(s.devices))
+	for _, code := range s.devices {
+		codes = append(codes, fileDeviceCode{DeviceCode: code, Hash: code.Hash, UserID: code.UserID, CreatedAt: code.CreatedAt})
+	}
+	slices.SortFunc(codes, func(a, b fileDeviceCode) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return writeJSONFile(s.devicesFilePath(), codes)
+}
+
+// CreateDeviceCode сохраняет код привязки устройства.
+// Ранее выданные пользователю коды и истекшие коды удаляются.
+// При ошибке записи в файл коды не изменяются.
+func (s *LocalStorage) CreateDeviceCode(ctx context.Context, code *model.DeviceCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	devices := make(map[string]model.DeviceCode, len(s.devices)+1)
+	for hash, c := range s.devices {
+		if c.UserID != code.UserID && c.ExpiresAt.After(now) {
+			devices[hash] = c
+		}
+	}
+	if _, exists := devices[code.Hash]; exists {
+		return ErrCodeExists
+	}
+	code.CreatedAt = now
+	devices[code.Hash] = *code
+
+	prev := s.devices
+	s.devices = devices
+	if err := s.writeDevicesFile(); err != nil {
+		s.devices = prev
+		return err
+	}
+	return nil
+}
+
+// ClaimDeviceCode удаляет действующий код привязки устройства и возвращает его.
+// При ошибке записи в файл код не удаляется.
+// Возвращает ErrNotFound, если код не найден или истек.
+func (s *LocalStorage) ClaimDeviceCode(ctx context.Context, hash string) (*model.DeviceCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	code, exists := s.devices[hash]
+	if !exists || !code.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+
+	delete(s.devices, hash)
+	if err := s.writeDevicesFile(); err != nil {
+		s.devices[hash] = code
+		return nil, err
+	}
+	return &code, nil
+}
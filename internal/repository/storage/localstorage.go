@@ -5,27 +5,45 @@ import (
 	"encoding/json"
 	"errors"
 	"os"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
 )
 
 // ErrIDExists ошибка, возникающая при попытке создать ссылку с уже существующим ID
 // ErrNotFound ошибка, возникающая при попытке найти несуществующую ссылку
+// ErrLinkExists ошибка, возникающая при смене URL на уже сокращенный
+// ErrUserExists ошибка, возникающая при регистрации уже занятого адреса электронной почты
+// ErrCodeExists ошибка, возникающая при выдаче кода привязки, совпадающего с действующим
 // ErrStorageAccess ошибка, возникающая при проблемах с доступом к хранилищу
 var (
 	ErrIDExists      = errors.New("ID already exists")
-	ErrNotFound      = errors.New("link not found")
+	ErrNotFound      = repository.ErrNotFound
+	ErrLinkExists    = repository.ErrLinkExists
+	ErrUserExists    = repository.ErrUserExists
+	ErrCodeExists    = repository.ErrCodeExists
 	ErrStorageAccess = errors.New("storage access error")
 )
 
 // LocalStorage представляет локальное хранилище для сокращенных URL.
 // Использует файловую систему для персистентного хранения данных.
 type LocalStorage struct {
-	links    map[string]linkData
-	filePath string
-	mu       sync.RWMutex
+	links        map[string]linkData
+	keys         map[uuid.UUID]model.APIKey
+	users        map[uuid.UUID]model.User
+	idents       map[identityKey]model.UserIdentity
+	actions      []model.AdminAction
+	revoked      map[string]time.Time
+	revokedUsers map[uuid.UUID]time.Time
+	activity     map[uuid.UUID]model.UserActivity
+	devices      map[string]model.DeviceCode
+	filePath     string
+	mu           sync.RWMutex
 }
 
 // linkData представляет структуру данных для хранения информации об URL.
@@ -33,6 +51,87 @@ type linkData struct {
 	URL       string    // Оригинальный URL
 	UserID    uuid.UUID // Идентификатор пользователя
 	IsDeleted bool      // Флаг удаления
+	linkAttrs
+}
+
+// linkAttrs представляет дополнительные параметры ссылки.
+// Хранятся в памяти и в файле в одинаковом виде.
+type linkAttrs struct {
+	ClicksLeft     *int64               `json:"clicks_left,omitempty"`     // Оставшееся количество переходов
+	ActiveFrom     *time.Time           `json:"active_from,omitempty"`     // Начало окна активности
+	ActiveUntil    *time.Time           `json:"active_until,omitempty"`    // Окончание окна активности
+	History        []model.LinkVersion  `json:"history,omitempty"`         // Предыдущие значения оригинального URL
+	Folder         string               `json:"folder,omitempty"`          // Папка ссылки
+	Tags           []string             `json:"tags,omitempty"`            // Метки ссылки
+	Title          string               `json:"title,omitempty"`           // Название ссылки
+	RedirectCode   *int                 `json:"redirect_code,omitempty"`   // HTTP-статус редиректа
+	CacheMaxAge    *int                 `json:"cache_max_age,omitempty"`   // Время кэширования редиректа
+	Passthrough    string               `json:"passthrough,omitempty"`     // Режим передачи пути и параметров запроса
+	UTM            *model.UTMParams     `json:"utm,omitempty"`             // UTM-метки ссылки
+	Targets        []model.LinkTarget   `json:"targets,omitempty"`         // Варианты адреса с весами
+	Rules          []model.TargetRule   `json:"rules,omitempty"`           // Правила выбора адреса
+	Entries        []model.BundleEntry  `json:"entries,omitempty"`         // Ссылки страницы-подборки
+	VariantClicks  map[string]int64     `json:"variant_clicks,omitempty"`  // Количество переходов по вариантам
+	TimeCreated    time.Time            `json:"time_created"`              // Момент создания ссылки
+	DeletedAt      *time.Time           `json:"deleted_at,omitempty"`      // Момент пометки ссылки как удаленной
+	Transfers      []model.LinkTransfer `json:"transfers,omitempty"`       // Журнал передачи ссылки между пользователями
+	DisabledAt     *time.Time           `json:"disabled_at,omitempty"`     // Момент отключения ссылки администратором
+	DisabledReason string               `json:"disabled_reason,omitempty"` // Причина отключения ссылки
+}
+
+// newLinkData создает запись хранилища из модели ссылки.
+func newLinkData(link *model.Link) linkData {
+	return linkData{
+		URL:       link.Link,
+		UserID:    link.UserID,
+		IsDeleted: link.IsDeleted,
+		linkAttrs: linkAttrs{
+			ClicksLeft:     link.ClicksLeft,
+			ActiveFrom:     link.ActiveFrom,
+			ActiveUntil:    link.ActiveUntil,
+			Folder:         link.Folder,
+			Tags:           slices.Clone(link.Tags),
+			Title:          link.Title,
+			RedirectCode:   link.RedirectCode,
+			CacheMaxAge:    link.CacheMaxAge,
+			Passthrough:    link.Passthrough,
+			UTM:            link.UTM,
+			Targets:        slices.Clone(link.Targets),
+			Rules:          slices.Clone(link.Rules),
+			Entries:        slices.Clone(link.Entries),
+			TimeCreated:    link.TimeCreated,
+			DeletedAt:      link.DeletedAt,
+			DisabledAt:     link.DisabledAt,
+			DisabledReason: link.DisabledReason,
+		},
+	}
+}
+
+// toModel преобразует запись хранилища в модель ссылки.
+func (d linkData) toModel(id string) *model.Link {
+	return &model.Link{
+		ID:             id,
+		Link:           d.URL,
+		UserID:         d.UserID,
+		IsDeleted:      d.IsDeleted,
+		ClicksLeft:     d.ClicksLeft,
+		ActiveFrom:     d.ActiveFrom,
+		ActiveUntil:    d.ActiveUntil,
+		Folder:         d.Folder,
+		Tags:           slices.Clone(d.Tags),
+		Title:          d.Title,
+		RedirectCode:   d.RedirectCode,
+		CacheMaxAge:    d.CacheMaxAge,
+		Passthrough:    d.Passthrough,
+		UTM:            d.UTM,
+		Targets:        slices.Clone(d.Targets),
+		Rules:          slices.Clone(d.Rules),
+		Entries:        slices.Clone(d.Entries),
+		TimeCreated:    d.TimeCreated,
+		DeletedAt:      d.DeletedAt,
+		DisabledAt:     d.DisabledAt,
+		DisabledReason: d.DisabledReason,
+	}
 }
 
 // fileLinks представляет структуру для сериализации данных в JSON.
@@ -42,6 +141,7 @@ type fileLinks struct {
 	OriginalURL string    `json:"original_url"` // Оригинальный URL
 	UserID      uuid.UUID `json:"user_id"`      // Идентификатор пользователя
 	IsDeleted   bool      `json:"is_deleted"`   // Флаг удаления
+	linkAttrs
 }
 
 // InitStorage создает и инициализирует новое локальное хранилище.
@@ -49,35 +149,69 @@ type fileLinks struct {
 // Возвращает инициализированное хранилище и ошибку, если инициализация не удалась.
 func InitStorage(filePath string) (*LocalStorage, error) {
 	s := &LocalStorage{
-		links:    make(map[string]linkData),
-		filePath: filePath,
+		links:        make(map[string]linkData),
+		keys:         make(map[uuid.UUID]model.APIKey),
+		users:        make(map[uuid.UUID]model.User),
+		idents:       make(map[identityKey]model.UserIdentity),
+		revoked:      make(map[string]time.Time),
+		revokedUsers: make(map[uuid.UUID]time.Time),
+		activity:     make(map[uuid.UUID]model.UserActivity),
+		devices:      make(map[string]model.DeviceCode),
+		filePath:     filePath,
 	}
 
 	if filePath != "" {
 		if err := s.readFromFile(); err != nil {
 			return nil, err
 		}
+		if err := s.readKeysFile(); err != nil {
+			return nil, err
+		}
+		if err := s.readUsersFile(); err != nil {
+			return nil, err
+		}
+		if err := s.readIdentitiesFile(); err != nil {
+			return nil, err
+		}
+		if err := s.readActionsFile(); err != nil {
+			return nil, err
+		}
+		if err := s.readRevokedFile(); err != nil {
+			return nil, err
+		}
+		if err := s.readRevokedUsersFile(); err != nil {
+			return nil, err
+		}
+		if err := s.readActivityFile(); err != nil {
+			return nil, err
+		}
+		if err := s.readDevicesFile(); err != nil {
+			return nil, err
+		}
 	}
 
 	return s, nil
 }
 
 // CreateLink создает новую запись сокращенного URL в хранилище.
+// Если оригинальный URL уже сокращен неудаленной ссылкой в области дедупликации,
+// возвращает существующую запись вместо создания новой.
 // Возвращает созданную запись и ошибку, если операция не удалась.
-func (s *LocalStorage) CreateLink(ctx context.Context, id, url string, userID uuid.UUID) (*model.Link, error) {
+func (s *LocalStorage) CreateLink(ctx context.Context, id, url string, userID uuid.UUID, opts model.LinkOptions) (*model.Link, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if _, exists := s.links[id]; exists {
 		return nil, ErrIDExists
 	}
-
-	s.links[id] = linkData{
-		URL:       url,
-		UserID:    userID,
-		IsDeleted: false,
+	if dupID, ok := s.findDuplicate(id, url, userID, repository.DedupeScope()); ok {
+		return s.links[dupID].toModel(dupID), nil
 	}
 
+	link := model.Link{ID: id, Link: url, UserID: userID, TimeCreated: time.Now()}
+	opts.Apply(&link)
+	s.links[id] = newLinkData(&link)
+
 	if s.filePath != "" {
 		if err := s.writeToFile(); err != nil {
 			delete(s.links, id)
@@ -85,7 +219,7 @@ func (s *LocalStorage) CreateLink(ctx context.Context, id, url string, userID uu
 		}
 	}
 
-	return &model.Link{ID: id, Link: url, UserID: userID, IsDeleted: false}, nil
+	return &link, nil
 }
 
 // FindLink находит запись сокращенного URL по его идентификатору.
@@ -99,48 +233,377 @@ func (s *LocalStorage) FindLink(ctx context.Context, id string) (*model.Link, er
 		return nil, ErrNotFound
 	}
 
-	return &model.Link{ID: id, Link: data.URL, UserID: data.UserID, IsDeleted: data.IsDeleted}, nil
+	return data.toModel(id), nil
+}
+
+// UpdateLink сохраняет изменяемые владельцем параметры ссылки.
+// При смене оригинального URL добавляет предыдущее значение в историю ссылки.
+// Возвращает ErrNotFound, если ссылка не найдена или принадлежит другому пользователю,
+// и ErrLinkExists, если новый URL уже сокращен в области дедупликации.
+func (s *LocalStorage) UpdateLink(ctx context.Context, link *model.Link) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.links[link.ID]
+	if !exists || data.UserID != link.UserID {
+		return ErrNotFound
+	}
+
+	prev := data
+	if data.URL != link.Link {
+		if _, ok := s.findDuplicate(link.ID, link.Link, link.UserID, repository.DedupeScope()); ok {
+			return ErrLinkExists
+		}
+		data.History = append(data.History, model.LinkVersion{
+			Version:     len(data.History) + 1,
+			OriginalURL: data.URL,
+			ReplacedAt:  time.Now(),
+		})
+		data.URL = link.Link
+	}
+	data.ActiveFrom = link.ActiveFrom
+	data.ActiveUntil = link.ActiveUntil
+	data.Folder = link.Folder
+	data.Title = link.Title
+	data.RedirectCode = link.RedirectCode
+	data.CacheMaxAge = link.CacheMaxAge
+	data.Passthrough = link.Passthrough
+	data.UTM = link.UTM
+	data.Targets = slices.Clone(link.Targets)
+	data.Rules = slices.Clone(link.Rules)
+	data.Entries = slices.Clone(link.Entries)
+	s.links[link.ID] = data
+
+	if s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			s.links[link.ID] = prev
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindLinkHistory возвращает предыдущие значения оригинального URL ссылки.
+// Возвращает ErrNotFound, если ссылка не найдена.
+func (s *LocalStorage) FindLinkHistory(ctx context.Context, id string) ([]model.LinkVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, exists := s.links[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	history := make([]model.LinkVersion, len(data.History))
+	copy(history, data.History)
+	return history, nil
+}
+
+// ConsumeClick атомарно уменьшает оставшееся количество переходов по ссылке.
+// Проверка и уменьшение счетчика выполняются под блокировкой хранилища.
+// Возвращает false, если лимит переходов исчерпан, и ошибку, если операция не удалась.
+func (s *LocalStorage) ConsumeClick(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.links[id]
+	if !exists {
+		return false, ErrNotFound
+	}
+	if data.IsDeleted {
+		return false, nil
+	}
+	if data.ClicksLeft == nil {
+		return true, nil
+	}
+	if *data.ClicksLeft <= 0 {
+		return false, nil
+	}
+
+	left := *data.ClicksLeft - 1
+	data.ClicksLeft = &left
+	s.links[id] = data
+
+	if s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// RecordVariants увеличивает счетчики переходов на варианты адресов ссылок
+// и сохраняет хранилище в файл одной записью. Переходы по отсутствующим ссылкам пропускаются.
+// При ошибке записи в файл счетчики не изменяются.
+func (s *LocalStorage) RecordVariants(ctx context.Context, clicks map[string]map[string]int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := make(map[string]map[string]int64, len(clicks))
+	for id, variants := range clicks {
+		data, exists := s.links[id]
+		if !exists {
+			continue
+		}
+
+		prev[id] = data.VariantClicks
+		updated := make(map[string]int64, len(data.VariantClicks)+len(variants))
+		for name, count := range data.VariantClicks {
+			updated[name] = count
+		}
+		for name, count := range variants {
+			updated[name] += count
+		}
+		data.VariantClicks = updated
+		s.links[id] = data
+	}
+
+	if len(prev) > 0 && s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			for id, variants := range prev {
+				data := s.links[id]
+				data.VariantClicks = variants
+				s.links[id] = data
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FindVariantClicks возвращает количество переходов по вариантам адреса ссылки.
+// Возвращает ErrNotFound, если ссылка не найдена.
+func (s *LocalStorage) FindVariantClicks(ctx context.Context, id string) ([]model.VariantStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, exists := s.links[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	stats := make([]model.VariantStats, 0, len(data.VariantClicks))
+	for name, count := range data.VariantClicks {
+		stats = append(stats, model.VariantStats{Name: name, Clicks: count})
+	}
+	slices.SortFunc(stats, func(a, b model.VariantStats) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return stats, nil
 }
 
-// FindUserLinks возвращает все URL, созданные указанным пользователем.
+// FindUserLinks возвращает все URL, созданные указанным пользователем и подходящие под фильтр.
 // Возвращает массив URL и ошибку, если операция не удалась.
-func (s *LocalStorage) FindUserLinks(ctx context.Context, userID uuid.UUID) ([]model.Link, error) {
+func (s *LocalStorage) FindUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter) ([]model.Link, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var result []model.Link
 	for id, data := range s.links {
 		if data.UserID == userID && !data.IsDeleted {
-			result = append(result, model.Link{
-				ID:        id,
-				Link:      data.URL,
-				UserID:    userID,
-				IsDeleted: data.IsDeleted,
-			})
+			link := data.toModel(id)
+			if filter.Match(link) {
+				result = append(result, *link)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// WalkUserLinks передает функции fn по одной все URL, созданные указанным пользователем
+// и подходящие под фильтр, в порядке идентификаторов.
+// Набор ссылок фиксируется при вызове, а блокировка хранилища не удерживается во время вызова fn.
+// Ссылки, удаленные во время обхода, пропускаются.
+// Возвращает первую ошибку fn.
+func (s *LocalStorage) WalkUserLinks(ctx context.Context, userID uuid.UUID, filter model.LinkFilter, fn func(*model.Link) error) error {
+	s.mu.RLock()
+	var ids []string
+	for id, data := range s.links {
+		if data.UserID == userID && !data.IsDeleted && filter.Match(data.toModel(id)) {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.RUnlock()
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mu.RLock()
+		data, exists := s.links[id]
+		s.mu.RUnlock()
+		if !exists || data.UserID != userID || data.IsDeleted {
+			continue
+		}
+		if err := fn(data.toModel(id)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AssignLabels назначает и снимает метки, а также меняет папку у ссылок пользователя.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (s *LocalStorage) AssignLabels(ctx context.Context, userID uuid.UUID, req model.LabelRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := make(map[string]linkData)
+	for _, id := range req.IDs {
+		data, exists := s.links[id]
+		if !exists || data.UserID != userID || data.IsDeleted {
+			continue
 		}
+		if _, seen := prev[id]; seen {
+			continue
+		}
+		prev[id] = data
+
+		tags := slices.Clone(data.Tags)
+		for _, tag := range req.AddTags {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		tags = slices.DeleteFunc(tags, func(tag string) bool {
+			return slices.Contains(req.RemoveTags, tag)
+		})
+		slices.Sort(tags)
+		data.Tags = tags
+
+		if req.Folder.Set {
+			data.Folder = ""
+			if req.Folder.Value != nil {
+				data.Folder = *req.Folder.Value
+			}
+		}
+		s.links[id] = data
+	}
+
+	if len(prev) > 0 && s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			for id, data := range prev {
+				s.links[id] = data
+			}
+			return 0, err
+		}
+	}
+
+	return len(prev), nil
+}
+
+// FindUserTags возвращает метки пользователя с количеством ссылок для каждой.
+// Метки упорядочены по названию.
+func (s *LocalStorage) FindUserTags(ctx context.Context, userID uuid.UUID) ([]model.TagInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int)
+	for _, data := range s.links {
+		if data.UserID != userID || data.IsDeleted {
+			continue
+		}
+		for _, tag := range data.Tags {
+			counts[tag]++
+		}
+	}
+
+	result := make([]model.TagInfo, 0, len(counts))
+	for tag, count := range counts {
+		result = append(result, model.TagInfo{Name: tag, Links: count})
 	}
+	slices.SortFunc(result, func(a, b model.TagInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
 
 	return result, nil
 }
 
+// RenameTag переименовывает метку на всех ссылках пользователя.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (s *LocalStorage) RenameTag(ctx context.Context, userID uuid.UUID, from, to string) (int, error) {
+	return s.replaceTag(userID, from, to)
+}
+
+// DeleteTag снимает метку со всех ссылок пользователя.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (s *LocalStorage) DeleteTag(ctx context.Context, userID uuid.UUID, tag string) (int, error) {
+	return s.replaceTag(userID, tag, "")
+}
+
+// replaceTag заменяет метку на ссылках пользователя, пустая замена снимает метку.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (s *LocalStorage) replaceTag(userID uuid.UUID, from, to string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev := make(map[string]linkData)
+	for id, data := range s.links {
+		if data.UserID != userID || !slices.Contains(data.Tags, from) {
+			continue
+		}
+		prev[id] = data
+
+		tags := slices.DeleteFunc(slices.Clone(data.Tags), func(tag string) bool {
+			return tag == from
+		})
+		if to != "" && !slices.Contains(tags, to) {
+			tags = append(tags, to)
+			slices.Sort(tags)
+		}
+		data.Tags = tags
+		s.links[id] = data
+	}
+
+	if len(prev) > 0 && s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			for id, data := range prev {
+				s.links[id] = data
+			}
+			return 0, err
+		}
+	}
+
+	return len(prev), nil
+}
+
 // BatchCreate создает несколько записей сокращенных URL в хранилище.
+// Если оригинальный URL уже сокращен неудаленной ссылкой в области дедупликации,
+// заменяет элемент links существующей записью вместо создания новой.
 // Возвращает ошибку, если операция не удалась.
 func (s *LocalStorage) BatchCreate(ctx context.Context, links []model.Link) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for _, link := range links {
-		s.links[link.ID] = linkData{
-			URL:       link.Link,
-			UserID:    link.UserID,
-			IsDeleted: link.IsDeleted,
+	var (
+		now     = time.Now()
+		scope   = repository.DedupeScope()
+		created []string
+	)
+	for i := range links {
+		if dupID, ok := s.findDuplicate(links[i].ID, links[i].Link, links[i].UserID, scope); ok {
+			links[i] = *s.links[dupID].toModel(dupID)
+			continue
+		}
+		if links[i].TimeCreated.IsZero() {
+			links[i].TimeCreated = now
 		}
+		s.links[links[i].ID] = newLinkData(&links[i])
+		created = append(created, links[i].ID)
 	}
 
 	if s.filePath != "" {
 		if err := s.writeToFile(); err != nil {
-			for _, link := range links {
-				delete(s.links, link.ID)
+			for _, id := range created {
+				delete(s.links, id)
 			}
 			return err
 		}
@@ -155,10 +618,12 @@ func (s *LocalStorage) MarkDeletedURLs(ctx context.Context, ids []string, userID
 	defer s.mu.Unlock()
 
 	count := 0
+	now := time.Now()
 	for _, id := range ids {
 		data, exists := s.links[id]
 		if exists && data.UserID == userID && !data.IsDeleted {
 			data.IsDeleted = true
+			data.DeletedAt = &now
 			s.links[id] = data
 			count++
 		}
@@ -173,6 +638,178 @@ func (s *LocalStorage) MarkDeletedURLs(ctx context.Context, ids []string, userID
 	return count, nil
 }
 
+// RestoreURLs снимает пометку удаления с указанных URL пользователя.
+// Восстанавливает только URL, удаленные не раньше deletedAfter, оригинальный адрес которых
+// не сокращен другой неудаленной ссылкой в области дедупликации. Ссылки, удаленные до появления отметки времени удаления,
+// считаются удаленными в пределах срока хранения.
+// Возвращает идентификаторы восстановленных URL, идентификаторы URL с конфликтом и ошибку,
+// если операция не удалась.
+func (s *LocalStorage) RestoreURLs(ctx context.Context, ids []string, userID uuid.UUID, deletedAfter time.Time) ([]string, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		restored, conflicts []string
+		prev                = make(map[string]linkData)
+		scope               = repository.DedupeScope()
+	)
+	for _, id := range ids {
+		data, exists := s.links[id]
+		if !exists || data.UserID != userID || !data.IsDeleted {
+			continue
+		}
+		if data.DeletedAt != nil && data.DeletedAt.Before(deletedAfter) {
+			continue
+		}
+		if _, ok := s.findDuplicate(id, data.URL, userID, scope); ok {
+			conflicts = append(conflicts, id)
+			continue
+		}
+
+		prev[id] = data
+		data.IsDeleted = false
+		data.DeletedAt = nil
+		s.links[id] = data
+		restored = append(restored, id)
+	}
+
+	if len(restored) > 0 && s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			for id, data := range prev {
+				s.links[id] = data
+			}
+			return nil, nil, err
+		}
+	}
+
+	return restored, conflicts, nil
+}
+
+// findDuplicate ищет неудаленную ссылку, отличную от id, на оригинальный URL url в области дедупликации scope.
+// Из нескольких подходящих ссылок выбирает созданную раньше остальных.
+// Подборки не имеют оригинального URL и не дедуплицируются. Вызывается под блокировкой хранилища.
+// Возвращает идентификатор найденной ссылки и false, если дубликат не найден или дедупликация отключена.
+func (s *LocalStorage) findDuplicate(id, url string, userID uuid.UUID, scope string) (string, bool) {
+	if scope == model.DedupeNone || url == "" {
+		return "", false
+	}
+
+	var (
+		found   string
+		created time.Time
+	)
+	for otherID, other := range s.links {
+		if otherID == id || other.URL != url || other.IsDeleted || other.DisabledAt != nil {
+			continue
+		}
+		if scope == model.DedupeUser && other.UserID != userID {
+			continue
+		}
+		if found == "" || other.TimeCreated.Before(created) ||
+			(other.TimeCreated.Equal(created) && otherID < found) {
+			found, created = otherID, other.TimeCreated
+		}
+	}
+	return found, found != ""
+}
+
+// hasUserDuplicate проверяет, что при дедупликации в пределах пользователя оригинальный URL
+// неудаленной и неотключенной ссылки уже сокращен другой ссылкой пользователя userID.
+// Вызывается под блокировкой хранилища.
+func (s *LocalStorage) hasUserDuplicate(id string, data linkData, userID uuid.UUID) bool {
+	if data.IsDeleted || data.DisabledAt != nil {
+		return false
+	}
+	scope := repository.DedupeScope()
+	if scope != model.DedupeUser {
+		return false
+	}
+	_, ok := s.findDuplicate(id, data.URL, userID, scope)
+	return ok
+}
+
+// TransferLinks передает неудаленные ссылки from пользователю to.
+// При дедупликации в пределах пользователя пропускает ссылки, оригинальный URL которых у to уже сокращен.
+// Журнал передачи хранится вместе со ссылкой. При ошибке записи в файл изменения отменяются.
+// Возвращает идентификаторы переданных ссылок и ErrTokenUsed, если токен уже был использован.
+func (s *LocalStorage) TransferLinks(ctx context.Context, tokenID string, ids []string, from, to uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, data := range s.links {
+		for _, t := range data.Transfers {
+			if t.TokenID == tokenID {
+				return nil, repository.ErrTokenUsed
+			}
+		}
+	}
+
+	var (
+		moved []string
+		prev  = make(map[string]linkData)
+		now   = time.Now()
+	)
+	for _, id := range ids {
+		data, exists := s.links[id]
+		if !exists || data.UserID != from || data.IsDeleted {
+			continue
+		}
+		if _, seen := prev[id]; seen {
+			continue
+		}
+		if s.hasUserDuplicate(id, data, to) {
+			continue
+		}
+
+		prev[id] = data
+		data.UserID = to
+		data.Transfers = append(slices.Clone(data.Transfers), model.LinkTransfer{
+			TokenID:       tokenID,
+			LinkID:        id,
+			FromUser:      from,
+			ToUser:        to,
+			TransferredAt: now,
+		})
+		s.links[id] = data
+		moved = append(moved, id)
+	}
+
+	if len(moved) > 0 && s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			for id, data := range prev {
+				s.links[id] = data
+			}
+			return nil, err
+		}
+	}
+
+	return moved, nil
+}
+
+// FindTransfers возвращает записи журнала передачи ссылок, в которых пользователь
+// был отправителем или получателем, от новых к старым.
+func (s *LocalStorage) FindTransfers(ctx context.Context, userID uuid.UUID) ([]model.LinkTransfer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var transfers []model.LinkTransfer
+	for _, data := range s.links {
+		for _, t := range data.Transfers {
+			if t.FromUser == userID || t.ToUser == userID {
+				transfers = append(transfers, t)
+			}
+		}
+	}
+
+	slices.SortFunc(transfers, func(a, b model.LinkTransfer) int {
+		if c := b.TransferredAt.Compare(a.TransferredAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.LinkID, b.LinkID)
+	})
+	return transfers, nil
+}
+
 // Close закрывает хранилище и сохраняет данные в файл.
 // Возвращает ошибку, если операция не удалась.
 func (s *LocalStorage) Close() error {
@@ -224,12 +861,46 @@ func (s *LocalStorage) readFromFile() error {
 			URL:       link.OriginalURL,
 			UserID:    link.UserID,
 			IsDeleted: link.IsDeleted,
+			linkAttrs: link.linkAttrs,
 		}
 	}
 
 	return nil
 }
 
+// readJSONFile читает значение v из JSON-файла path.
+// Отсутствующий или пустой файл не считается ошибкой.
+func readJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return ErrStorageAccess
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// writeJSONFile сохраняет значение v в JSON-файл path.
+// Данные записываются во временный файл, который затем заменяет path.
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return ErrStorageAccess
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return ErrStorageAccess
+	}
+	return nil
+}
+
 // writeToFile сохраняет данные из хранилища в файл.
 // Возвращает ошибку, если операция не удалась.
 func (s *LocalStorage) writeToFile() error {
@@ -247,6 +918,7 @@ func (s *LocalStorage) writeToFile() error {
 			OriginalURL: data.URL,
 			UserID:      data.UserID,
 			IsDeleted:   data.IsDeleted,
+			linkAttrs:   data.linkAttrs,
 		})
 	}
 
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// revokedFilePath возвращает путь к файлу списка отозванных токенов.
+func (s *LocalStorage) revokedFilePath() string {
+	return s.filePath + ".revoked"
+}
+
+// readRevokedFile загружает список отозванных токенов из файла.
+func (s *LocalStorage) readRevokedFile() error {
+	revoked := make(map[string]time.Time)
+	if err := readJSONFile(s.revokedFilePath(), &revoked); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked = revoked
+	return nil
+}
+
+// revokedUsersFilePath возвращает путь к файлу моментов отзыва всех токенов пользователей.
+func (s *LocalStorage) revokedUsersFilePath() string {
+	return s.filePath + ".revoked_users"
+}
+
+// readRevokedUsersFile загружает моменты отзыва всех токенов пользователей из файла.
+func (s *LocalStorage) readRevokedUsersFile() error {
+	revokedUsers := make(map[uuid.UUID]time.Time)
+	if err := readJSONFile(s.revokedUsersFilePath(), &revokedUsers); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revokedUsers = revokedUsers
+	return nil
+}
+
+// RevokeToken добавляет токен в список отозванных и удаляет записи об истекших токенах.
+// При ошибке записи в файл список не изменяется.
+func (s *LocalStorage) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	revoked := make(map[string]time.Time, len(s.revoked)+1)
+	for id, exp := range s.revoked {
+		if exp.After(now) {
+			revoked[id] = exp
+		}
+	}
+	if _, exists := revoked[jti]; !exists {
+		revoked[jti] = expiresAt
+	}
+
+	if s.filePath != "" {
+		if err := writeJSONFile(s.revokedFilePath(), revoked); err != nil {
+			return err
+		}
+	}
+	s.revoked = revoked
+	return nil
+}
+
+// RevokeUserTokens сохраняет момент, раньше которого выпущенные токены пользователя отозваны.
+// При ошибке записи в файл момент отзыва не изменяется.
+func (s *LocalStorage) RevokeUserTokens(ctx context.Context, userID uuid.UUID, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, exists := s.revokedUsers[userID]
+	if exists && !before.After(prev) {
+		return nil
+	}
+
+	s.revokedUsers[userID] = before
+	if s.filePath != "" {
+		if err := writeJSONFile(s.revokedUsersFilePath(), s.revokedUsers); err != nil {
+			if exists {
+				s.revokedUsers[userID] = prev
+			} else {
+				delete(s.revokedUsers, userID)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// IsTokenRevoked проверяет, находится ли токен в списке отозванных
+// или выпущен раньше отзыва всех токенов пользователя.
+func (s *LocalStorage) IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, revoked := s.revoked[jti]; revoked {
+		return true, nil
+	}
+	before, exists := s.revokedUsers[userID]
+	return exists && issuedAt.Before(before), nil
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+)
+
+// fileUser представляет структуру для сериализации учетной записи в JSON.
+// Содержит поля, которые не передаются клиенту.
+type fileUser struct {
+	model.User
+	PasswordHash string `json:"password_hash"` // Хэш пароля
+}
+
+// usersFilePath возвращает путь к файлу учетных записей, хранящемуся рядом с файлом ссылок.
+func (s *LocalStorage) usersFilePath() string {
+	return s.filePath + ".users"
+}
+
+// readUsersFile загружает учетные записи из файла.
+func (s *LocalStorage) readUsersFile() error {
+	var users []fileUser
+	if err := readJSONFile(s.usersFilePath(), &users); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, user := range users {
+		user.User.PasswordHash = user.PasswordHash
+		s.users[user.ID] = user.User
+	}
+	return nil
+}
+
+// writeUsersFile сохраняет учетные записи в файл, если хранилище использует файловую систему.
+// Вызывается под блокировкой хранилища.
+func (s *LocalStorage) writeUsersFile() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	users := make([]fileUser, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, fileUser{User: user, PasswordHash: user.PasswordHash})
+	}
+	slices.SortFunc(users, func(a, b fileUser) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return writeJSONFile(s.usersFilePath(), users)
+}
+
+// CreateUser сохраняет новую учетную запись.
+// Возвращает ErrUserExists, если адрес электронной почты уже зарегистрирован.
+// При ошибке записи в файл учетная запись не сохраняется.
+func (s *LocalStorage) CreateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.users {
+		if user.Email != "" && other.Email == user.Email {
+			return ErrUserExists
+		}
+	}
+	if _, exists := s.users[user.ID]; exists {
+		return ErrUserExists
+	}
+
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = time.Now()
+	}
+	s.users[user.ID] = *user
+
+	if err := s.writeUsersFile(); err != nil {
+		delete(s.users, user.ID)
+		return err
+	}
+	return nil
+}
+
+// FindUserByEmail находит учетную запись по адресу электронной почты.
+// Возвращает ErrNotFound, если учетная запись не найдена.
+func (s *LocalStorage) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// FindUserByID находит учетную запись по идентификатору пользователя.
+// Возвращает ErrNotFound, если пользователь не зарегистрирован.
+func (s *LocalStorage) FindUserByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// identityKey представляет ключ привязки пользователя провайдера OpenID Connect.
+type identityKey struct {
+	issuer  string
+	subject string
+}
+
+// identitiesFilePath возвращает путь к файлу привязок пользователей провайдеров.
+func (s *LocalStorage) identitiesFilePath() string {
+	return s.filePath + ".identities"
+}
+
+// readIdentitiesFile загружает привязки пользователей провайдеров из файла.
+func (s *LocalStorage) readIdentitiesFile() error {
+	var idents []model.UserIdentity
+	if err := readJSONFile(s.identitiesFilePath(), &idents); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ident := range idents {
+		s.idents[identityKey{ident.Issuer, ident.Subject}] = ident
+	}
+	return nil
+}
+
+// writeIdentitiesFile сохраняет привязки пользователей провайдеров в файл,
+// если хранилище использует файловую систему. Вызывается под блокировкой хранилища.
+func (s *LocalStorage) writeIdentitiesFile() error {
+	if s.filePath == "" {
+		return nil
+	}
+
+	idents := make([]model.UserIdentity, 0, len(s.idents))
+	for _, ident := range s.idents {
+		idents = append(idents, ident)
+	}
+	slices.SortFunc(idents, func(a, b model.UserIdentity) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	return writeJSONFile(s.identitiesFilePath(), idents)
+}
+
+// FindUserByIdentity находит учетную запись по привязанному пользователю провайдера.
+// Возвращает ErrNotFound, если привязки нет.
+func (s *LocalStorage) FindUserByIdentity(ctx context.Context, issuer, subject string) (*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ident, exists := s.idents[identityKey{issuer, subject}]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	user, exists := s.users[ident.UserID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// LinkIdentity привязывает пользователя провайдера к учетной записи.
+// Возвращает ErrUserExists, если пользователь провайдера уже привязан.
+// При ошибке записи в файл привязка не сохраняется.
+func (s *LocalStorage) LinkIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := identityKey{identity.Issuer, identity.Subject}
+	if _, exists := s.idents[key]; exists {
+		return ErrUserExists
+	}
+
+	if identity.CreatedAt.IsZero() {
+		identity.CreatedAt = time.Now()
+	}
+	s.idents[key] = *identity
+
+	if err := s.writeIdentitiesFile(); err != nil {
+		delete(s.idents, key)
+		return err
+	}
+	return nil
+}
+
+// MergeUser передает ссылки, включая удаленные, и API-ключи пользователя from пользователю to.
+// При дедупликации в пределах пользователя неудаленные ссылки, оригинальный URL которых у to уже сокращен,
+// остаются у from. При ошибке записи в файлы изменения отменяются.
+// Возвращает количество переданных ссылок.
+func (s *LocalStorage) MergeUser(ctx context.Context, from, to uuid.UUID) (int, error) {
+	if from == to {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		prevLinks = make(map[string]linkData)
+		prevKeys  = make(map[uuid.UUID]model.APIKey)
+	)
+	for id, data := range s.links {
+		if data.UserID == from && !s.hasUserDuplicate(id, data, to) {
+			prevLinks[id] = data
+		}
+	}
+	for id, data := range prevLinks {
+		data.UserID = to
+		s.links[id] = data
+	}
+	for id, key := range s.keys {
+		if key.UserID == from {
+			prevKeys[id] = key
+			key.UserID = to
+			s.keys[id] = key
+		}
+	}
+
+	rollback := func() {
+		for id, data := range prevLinks {
+			s.links[id] = data
+		}
+		for id, key := range prevKeys {
+			s.keys[id] = key
+		}
+	}
+	if len(prevLinks) > 0 && s.filePath != "" {
+		if err := s.writeToFile(); err != nil {
+			rollback()
+			return 0, err
+		}
+	}
+	if len(prevKeys) > 0 {
+		if err := s.writeKeysFile(); err != nil {
+			rollback()
+			if len(prevLinks) > 0 && s.filePath != "" {
+				s.writeToFile()
+			}
+			return 0, err
+		}
+	}
+
+	return len(prevLinks), nil
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+const (
+	// defaultSeenInterval период сохранения времени активности пользователей по умолчанию
+	defaultSeenInterval = time.Minute
+	// defaultCleanupInterval период очистки неактивных пользователей по умолчанию
+	defaultCleanupInterval = time.Hour
+	// cleanupBatchSize количество неактивных пользователей, обрабатываемых за один запрос к хранилищу
+	cleanupBatchSize = 100
+	// activeWindow период, за который пользователь считается активным в подсчете пользователей
+	activeWindow = 24 * time.Hour
+)
+
+// TouchUser отмечает обращение пользователя к сервису.
+// Время активности накапливается в памяти и сохраняется в хранилище методом FlushActivity,
+// поэтому частые запросы одного пользователя не приводят к записи в хранилище на каждый запрос.
+func (s *Service) TouchUser(userID uuid.UUID) {
+	if userID == uuid.Nil {
+		return
+	}
+
+	s.seenMu.Lock()
+	defer s.seenMu.Unlock()
+	if s.seen == nil {
+		s.seen = make(map[uuid.UUID]time.Time)
+	}
+	s.seen[userID] = time.Now()
+}
+
+// FlushActivity сохраняет накопленное время активности пользователей в хранилище.
+// Если сохранить не удалось, время активности возвращается в очередь и будет сохранено при следующем вызове.
+func (s *Service) FlushActivity(ctx context.Context) error {
+	s.seenMu.Lock()
+	seen := s.seen
+	s.seen = nil
+	s.seenMu.Unlock()
+
+	if len(seen) == 0 {
+		return nil
+	}
+
+	err := s.repo.TouchUsers(ctx, seen)
+	if err != nil {
+		s.seenMu.Lock()
+		if s.seen == nil {
+			s.seen = seen
+		} else {
+			for userID, at := range seen {
+				if at.After(s.seen[userID]) {
+					s.seen[userID] = at
+				}
+			}
+		}
+		s.seenMu.Unlock()
+		return errors.WithMessage(err, "error occurred while saving user activity")
+	}
+	return nil
+}
+
+// CleanupInactiveUsers безвозвратно удаляет ссылки анонимных пользователей, которые не обращались
+// к сервису дольше inactiveFor, отзывает их API-ключи и удаляет их из реестра.
+// Учетные записи не затрагиваются.
+// Возвращает количество удаленных пользователей и ссылок.
+func (s *Service) CleanupInactiveUsers(ctx context.Context, inactiveFor time.Duration) (int, int, error) {
+	if inactiveFor <= 0 {
+		return 0, 0, errors.WithMessage(ErrInvalidOptions, "inactivity period must be positive")
+	}
+	// Время активности из памяти сохраняется заранее, чтобы не удалить недавно активного пользователя.
+	if err := s.FlushActivity(ctx); err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		seenBefore   = time.Now().Add(-inactiveFor)
+		users, links int
+	)
+	for {
+		ids, err := s.repo.FindInactiveUsers(ctx, seenBefore, cleanupBatchSize)
+		if err != nil {
+			return users, links, err
+		}
+		if len(ids) == 0 {
+			return users, links, nil
+		}
+
+		purged := 0
+		for _, userID := range ids {
+			deleted, err := s.repo.PurgeInactiveUser(ctx, userID, seenBefore)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return users, links, err
+			}
+			purged++
+			links += deleted
+		}
+		users += purged
+		if purged == 0 || len(ids) < cleanupBatchSize {
+			return users, links, nil
+		}
+	}
+}
+
+// RunJobs выполняет фоновые задачи сервиса до отмены контекста:
+// периодически сохраняет время активности пользователей и переходы по вариантам адресов ссылок
+// и, если задан Users.InactiveTTL, удаляет неактивных анонимных пользователей.
+// Перед завершением сохраняет накопленные время активности и переходы.
+func (s *Service) RunJobs(ctx context.Context) {
+	var (
+		cfg    = util.GetConfig().Users
+		logger = util.GetLogger()
+	)
+
+	seenInterval := defaultSeenInterval
+	if cfg.SeenInterval > 0 {
+		seenInterval = time.Duration(cfg.SeenInterval) * time.Second
+	}
+	flushTicker := time.NewTicker(seenInterval)
+	defer flushTicker.Stop()
+
+	var cleanup <-chan time.Time
+	if cfg.InactiveTTL > 0 {
+		cleanupInterval := defaultCleanupInterval
+		if cfg.CleanupInterval > 0 {
+			cleanupInterval = time.Duration(cfg.CleanupInterval) * time.Minute
+		}
+		cleanupTicker := time.NewTicker(cleanupInterval)
+		defer cleanupTicker.Stop()
+		cleanup = cleanupTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.FlushActivity(flushCtx); err != nil {
+				logger.Error(err)
+			}
+			if err := s.FlushVariants(flushCtx); err != nil {
+				logger.Error(err)
+			}
+			cancel()
+			return
+		case <-flushTicker.C:
+			if err := s.FlushActivity(ctx); err != nil {
+				logger.Error(err)
+			}
+			if err := s.FlushVariants(ctx); err != nil {
+				logger.Error(err)
+			}
+		case <-cleanup:
+			users, links, err := s.CleanupInactiveUsers(ctx, time.Duration(cfg.InactiveTTL)*time.Hour)
+			if err != nil {
+				logger.Errorf("error occurred while cleaning up inactive users: %v", err)
+			}
+			if users > 0 {
+				logger.Infof("removed %d inactive users with %d links", users, links)
+			}
+		}
+	}
+}
+
+// CountUsers возвращает количество учетных записей и анонимных пользователей,
+// а также пользователей, обращавшихся к сервису за последние сутки.
+// Принимает контекст и идентификатор администратора.
+// Возвращает количество пользователей и ошибку, если операция не удалась.
+func (s *Service) CountUsers(ctx context.Context, adminID uuid.UUID) (*model.UserCount, error) {
+	if err := s.recordAdminAction(ctx, adminID, model.AdminActionViewUsers, "count", ""); err != nil {
+		return nil, err
+	}
+	if err := s.FlushActivity(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.repo.CountUsers(ctx, time.Now().Add(-activeWindow))
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// ErrURLDisabled ошибка, возникающая при переходе по ссылке, отключенной администратором
+var ErrURLDisabled = errors.New("url is disabled")
+
+const (
+	// defaultAdminLimit количество записей в ответах администратору по умолчанию
+	defaultAdminLimit = 100
+	// maxAdminLimit максимальное количество записей в ответах администратору
+	maxAdminLimit = 1000
+	// maxReasonLength максимальная длина причины действия администратора
+	maxReasonLength = 1000
+)
+
+// adminLimit приводит запрошенное количество записей к допустимому диапазону.
+func adminLimit(limit int) int {
+	if limit <= 0 {
+		return defaultAdminLimit
+	}
+	return min(limit, maxAdminLimit)
+}
+
+// normalizeReason проверяет причину действия администратора.
+// Возвращает ErrInvalidOptions, если причина пустая или слишком длинная.
+func normalizeReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || len(reason) > maxReasonLength {
+		return "", errors.WithMessagef(ErrInvalidOptions, "reason must contain from 1 to %d characters", maxReasonLength)
+	}
+	return reason, nil
+}
+
+// recordAdminAction записывает действие администратора в журнал.
+// Запись выполняется до самого действия, поэтому действие, которое не удалось записать, не выполняется.
+func (s *Service) recordAdminAction(ctx context.Context, adminID uuid.UUID, action, target, reason string) error {
+	err := s.repo.RecordAdminAction(ctx, &model.AdminAction{
+		AdminID: adminID,
+		Action:  action,
+		Target:  target,
+		Reason:  reason,
+	})
+	if err != nil {
+		return errors.WithMessage(err, "error occurred while recording admin action")
+	}
+	return nil
+}
+
+// SearchLinks находит ссылки любых пользователей по подстроке оригинального URL и владельцу.
+// Удаленные и отключенные ссылки также попадают в результат.
+// Принимает контекст, идентификатор администратора и условия поиска.
+// Возвращает найденные ссылки от новых к старым и ошибку, если операция не удалась.
+func (s *Service) SearchLinks(ctx context.Context, adminID uuid.UUID, filter model.AdminLinkFilter) ([]model.AdminLink, error) {
+	filter.URL = strings.TrimSpace(filter.URL)
+	filter.Limit = adminLimit(filter.Limit)
+
+	target := fmt.Sprintf("url=%q", filter.URL)
+	if filter.UserID != nil {
+		target += " user=" + filter.UserID.String()
+	}
+	if err := s.recordAdminAction(ctx, adminID, model.AdminActionSearchLinks, target, ""); err != nil {
+		return nil, err
+	}
+
+	links, err := s.repo.SearchLinks(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.AdminLink, len(links))
+	for i := range links {
+		result[i] = model.AdminLink{Link: links[i], ShortURL: buildShortURL(links[i].ID)}
+	}
+	return result, nil
+}
+
+// DisableLink отключает ссылку любого пользователя. Переходы по отключенной ссылке возвращают ErrURLDisabled,
+// владелец видит причину отключения в списке своих ссылок.
+// Принимает контекст, идентификатор администратора, идентификатор ссылки и причину.
+// Возвращает ErrInvalidOptions, если причина не указана, и ErrURLNotFound, если ссылка не найдена.
+func (s *Service) DisableLink(ctx context.Context, adminID uuid.UUID, id, reason string) error {
+	reason, err := normalizeReason(reason)
+	if err != nil {
+		return err
+	}
+	if err = s.recordAdminAction(ctx, adminID, model.AdminActionDisableLink, id, reason); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.repo.SetLinkDisabled(ctx, normalizeQuery(id), &now, reason)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrURLNotFound
+	}
+	return err
+}
+
+// EnableLink снимает отключение со ссылки любого пользователя.
+// Принимает контекст, идентификатор администратора, идентификатор ссылки и причину.
+// Возвращает ErrInvalidOptions, если причина не указана, и ErrURLNotFound, если ссылка не найдена.
+func (s *Service) EnableLink(ctx context.Context, adminID uuid.UUID, id, reason string) error {
+	reason, err := normalizeReason(reason)
+	if err != nil {
+		return err
+	}
+	if err = s.recordAdminAction(ctx, adminID, model.AdminActionEnableLink, id, reason); err != nil {
+		return err
+	}
+
+	err = s.repo.SetLinkDisabled(ctx, normalizeQuery(id), nil, "")
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrURLNotFound
+	}
+	return err
+}
+
+// GetUserStats возвращает количество ссылок и API-ключей пользователей,
+// начиная с пользователей с наибольшим количеством ссылок.
+// Если userID задан, возвращает только его, в том числе пользователя без ссылок.
+// Принимает контекст, идентификатор администратора, идентификатор пользователя и количество записей.
+// Возвращает список записей и ошибку, если операция не удалась.
+func (s *Service) GetUserStats(ctx context.Context, adminID uuid.UUID, userID *uuid.UUID, limit int) ([]model.UserStats, error) {
+	target := ""
+	if userID != nil {
+		target = userID.String()
+	}
+	if err := s.recordAdminAction(ctx, adminID, model.AdminActionViewUsers, target, ""); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.repo.FindUserStats(ctx, userID, adminLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 && userID != nil {
+		st := model.UserStats{UserID: *userID}
+		if user, err := s.repo.FindUserByID(ctx, *userID); err == nil {
+			st.Email = user.Email
+		}
+		return []model.UserStats{st}, nil
+	}
+	if stats == nil {
+		return []model.UserStats{}, nil
+	}
+	return stats, nil
+}
+
+// PurgeUser безвозвратно удаляет все ссылки пользователя и отзывает его API-ключи.
+// Учетная запись пользователя сохраняется.
+// Принимает контекст, идентификатор администратора, идентификатор пользователя и причину.
+// Возвращает количество удаленных ссылок и ErrInvalidOptions, если причина не указана.
+func (s *Service) PurgeUser(ctx context.Context, adminID, userID uuid.UUID, reason string) (int, error) {
+	reason, err := normalizeReason(reason)
+	if err != nil {
+		return 0, err
+	}
+	if err = s.recordAdminAction(ctx, adminID, model.AdminActionPurgeUser, userID.String(), reason); err != nil {
+		return 0, err
+	}
+
+	return s.repo.PurgeUser(ctx, userID)
+}
+
+// GetAdminActions возвращает последние записи журнала действий администраторов, от новых к старым.
+// Просмотр журнала также записывается в журнал.
+// Принимает контекст, идентификатор администратора и количество записей.
+// Возвращает список записей и ошибку, если операция не удалась.
+func (s *Service) GetAdminActions(ctx context.Context, adminID uuid.UUID, limit int) ([]model.AdminAction, error) {
+	if err := s.recordAdminAction(ctx, adminID, model.AdminActionViewActions, "", ""); err != nil {
+		return nil, err
+	}
+
+	actions, err := s.repo.FindAdminActions(ctx, adminLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+	if actions == nil {
+		return []model.AdminAction{}, nil
+	}
+	return actions, nil
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// ErrAPIKeyInvalid ошибка, возникающая при предъявлении неизвестного, отозванного или просроченного API-ключа
+// ErrAPIKeyNotFound ошибка, возникающая при обращении к чужому, несуществующему или уже отозванному API-ключу
+var (
+	ErrAPIKeyInvalid  = errors.New("api key is invalid")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+)
+
+const (
+	// apiKeySecretSize количество случайных байт API-ключа
+	apiKeySecretSize = 32
+	// apiKeyPrefixLength количество символов ключа, сохраняемых для его узнавания владельцем
+	apiKeyPrefixLength = 12
+	// maxAPIKeyNameLength максимальная длина названия API-ключа
+	maxAPIKeyNameLength = 255
+)
+
+// apiKeyScopes допустимые области доступа API-ключей
+var apiKeyScopes = []string{model.ScopeLinksRead, model.ScopeLinksWrite, model.ScopeLinksDelete}
+
+// hashAPIKey вычисляет хэш API-ключа для хранения и поиска.
+// Ключ содержит достаточно случайных байт, поэтому медленная функция хэширования не требуется.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeScopes приводит области доступа к нижнему регистру, удаляет повторы и сортирует их.
+// Возвращает ErrInvalidOptions, если области не заданы или среди них есть неизвестная.
+func normalizeScopes(scopes []string) ([]string, error) {
+	res := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !slices.Contains(apiKeyScopes, scope) {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "unsupported scope %q", scope)
+		}
+		res = append(res, scope)
+	}
+	if len(res) == 0 {
+		return nil, errors.WithMessage(ErrInvalidOptions, "scopes must not be empty")
+	}
+	slices.Sort(res)
+	return slices.Compact(res), nil
+}
+
+// CreateAPIKey создает API-ключ пользователя с указанными областями доступа.
+// Сохраняется только хэш ключа, сам ключ возвращается один раз.
+// Принимает контекст, идентификатор пользователя и параметры ключа.
+// Возвращает созданный ключ и ErrInvalidOptions, если параметры некорректны.
+func (s *Service) CreateAPIKey(ctx context.Context, userID uuid.UUID, req model.APIKeyRequest) (*model.CreatedAPIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if len(name) > maxAPIKeyNameLength {
+		return nil, errors.WithMessage(ErrInvalidOptions, "name is too long")
+	}
+	scopes, err := normalizeScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.WithMessage(ErrInvalidOptions, "expires_at must be in the future")
+	}
+
+	secret := make([]byte, apiKeySecretSize)
+	if _, err = rand.Read(secret); err != nil {
+		return nil, errors.WithMessage(err, "error occurred while reading rand")
+	}
+	key := model.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey := model.APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:apiKeyPrefixLength],
+		Hash:      hashAPIKey(key),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err = s.repo.CreateAPIKey(ctx, &apiKey); err != nil {
+		return nil, err
+	}
+
+	return &model.CreatedAPIKey{APIKey: apiKey, Key: key}, nil
+}
+
+// GetAPIKeys возвращает API-ключи пользователя, включая отозванные, от новых к старым.
+// Принимает контекст и идентификатор пользователя.
+// Возвращает список ключей и ошибку, если операция не удалась.
+func (s *Service) GetAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error) {
+	keys, err := s.repo.FindAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if keys == nil {
+		return []model.APIKey{}, nil
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает API-ключ пользователя.
+// Принимает контекст, идентификатор ключа и идентификатор пользователя.
+// Возвращает ErrAPIKeyNotFound, если ключ не найден, уже отозван или принадлежит другому пользователю.
+func (s *Service) RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	err := s.repo.RevokeAPIKey(ctx, id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// ResolveAPIKey находит владельца и области доступа действующего API-ключа.
+// Принимает контекст и API-ключ.
+// Возвращает идентификатор владельца, области доступа ключа и ErrAPIKeyInvalid,
+// если ключ неизвестен, отозван или просрочен.
+func (s *Service) ResolveAPIKey(ctx context.Context, key string) (uuid.UUID, []string, error) {
+	if !strings.HasPrefix(key, model.APIKeyPrefix) {
+		return uuid.Nil, nil, ErrAPIKeyInvalid
+	}
+
+	apiKey, err := s.repo.FindAPIKeyByHash(ctx, hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return uuid.Nil, nil, ErrAPIKeyInvalid
+		}
+		return uuid.Nil, nil, err
+	}
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && !time.Now().Before(*apiKey.ExpiresAt)) {
+		return uuid.Nil, nil, ErrAPIKeyInvalid
+	}
+
+	return apiKey.UserID, apiKey.Scopes, nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+)
+
+// maxEntries максимальное количество ссылок страницы-подборки
+const maxEntries = 50
+
+// normalizeEntries проверяет ссылки страницы-подборки и присваивает названия ссылкам без них.
+// Безымянные ссылки получают названия по порядку: 1, 2, 3 и т.д.
+// Возвращает ErrInvalidOptions, если ссылок нет или слишком много, текст или адрес ссылки
+// некорректен или названия повторяются.
+func normalizeEntries(entries []model.BundleEntry) ([]model.BundleEntry, error) {
+	if len(entries) == 0 || len(entries) > maxEntries {
+		return nil, errors.WithMessagef(ErrInvalidOptions, "entries must contain from 1 to %d items", maxEntries)
+	}
+
+	res := make([]model.BundleEntry, len(entries))
+	names := make(map[string]struct{}, len(entries))
+	for i, entry := range entries {
+		entry.Name = strings.TrimSpace(entry.Name)
+		if entry.Name == "" {
+			entry.Name = strconv.Itoa(i + 1)
+		}
+		if len(entry.Name) > maxVariantLength {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "entry name %q is too long", entry.Name)
+		}
+		if _, exists := names[entry.Name]; exists {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "duplicate entry name %q", entry.Name)
+		}
+		entry.Title = strings.TrimSpace(entry.Title)
+		if entry.Title == "" || len(entry.Title) > maxTitleLength {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "invalid title of entry %q", entry.Name)
+		}
+		if entry.URL == "" {
+			return nil, errors.WithMessage(ErrInvalidOptions, "entry url must not be empty")
+		}
+		names[entry.Name] = struct{}{}
+		entry.URL = normalizeQuery(entry.URL)
+		res[i] = entry
+	}
+
+	return res, nil
+}
+
+// validateBundle проверяет, что для подборки не заданы параметры, имеющие смысл только для редиректа:
+// передача пути и параметров запроса, варианты адреса и правила.
+// Возвращает ErrInvalidOptions, если такие параметры заданы.
+func validateBundle(passthrough string, targets []model.LinkTarget, rules []model.TargetRule) error {
+	if passthrough != "" && passthrough != model.PassthroughOff {
+		return errors.WithMessage(ErrInvalidOptions, "passthrough is not supported for bundles")
+	}
+	if len(targets) > 0 || len(rules) > 0 {
+		return errors.WithMessage(ErrInvalidOptions, "targets and rules are not supported for bundles")
+	}
+	return nil
+}
+
+// buildBundlePage формирует страницу-подборку ссылки.
+// Ссылки страницы ведут на сокращенный URL подборки с параметром model.BundleEntryParam,
+// чтобы переход по каждой из них был учтен.
+func buildBundlePage(link *model.Link) *model.BundlePage {
+	shortURL := buildShortURL(link.ID)
+	page := &model.BundlePage{
+		ShortURL: shortURL,
+		Title:    link.Title,
+		Entries:  make([]model.BundlePageEntry, len(link.Entries)),
+	}
+	for i, entry := range link.Entries {
+		page.Entries[i] = model.BundlePageEntry{
+			Name:  entry.Name,
+			Title: entry.Title,
+			URL:   shortURL + "?" + url.Values{model.BundleEntryParam: {entry.Name}}.Encode(),
+		}
+	}
+	return page
+}
+
+// CreateBundle создает страницу-подборку из нескольких ссылок с собственным сокращенным URL.
+// Подборка разделяет пространство идентификаторов с обычными ссылками и управляется так же, как они.
+// Принимает контекст, идентификатор пользователя и параметры подборки, включая ее ссылки.
+// Возвращает сокращенный URL подборки и ErrInvalidOptions, если параметры некорректны.
+func (s *Service) CreateBundle(ctx context.Context, userID uuid.UUID, opts model.LinkOptions) (string, error) {
+	entries := opts.Entries
+	opts.Entries = nil
+	if err := validateOptions(opts); err != nil {
+		return "", err
+	}
+	if err := validateBundle(opts.Passthrough, opts.Targets, opts.Rules); err != nil {
+		return "", err
+	}
+
+	var err error
+	if opts.Entries, err = normalizeEntries(entries); err != nil {
+		return "", err
+	}
+	opts.Title = strings.TrimSpace(opts.Title)
+
+	id, err := s.generateShortID()
+	if err != nil {
+		return "", err
+	}
+	link, err := s.repo.CreateLink(ctx, id, "", userID, opts)
+	if err != nil {
+		return "", err
+	}
+
+	return buildShortURL(link.ID), nil
+}
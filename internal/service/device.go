@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// ErrDeviceCodeInvalid ошибка, возникающая при вводе неизвестного, истекшего или уже использованного кода привязки
+// ErrTooManyAttempts ошибка, возникающая при превышении количества попыток ввода кода привязки
+var (
+	ErrDeviceCodeInvalid = errors.New("device code is invalid or expired")
+	ErrTooManyAttempts   = errors.New("too many attempts")
+)
+
+const (
+	// defaultDeviceCodeTTL время жизни кода привязки устройства по умолчанию
+	defaultDeviceCodeTTL = 10 * time.Minute
+	// defaultDeviceCodeLength количество цифр в коде привязки по умолчанию
+	defaultDeviceCodeLength = 8
+	// minDeviceCodeLength минимальное количество цифр в коде привязки
+	minDeviceCodeLength = 6
+	// maxDeviceCodeLength максимальное количество цифр в коде привязки
+	maxDeviceCodeLength = 12
+	// defaultRedeemAttempts количество попыток ввода кода привязки за период по умолчанию
+	defaultRedeemAttempts = 5
+	// defaultRedeemWindow период ограничения попыток ввода кода привязки по умолчанию
+	defaultRedeemWindow = 15 * time.Minute
+	// defaultRedeemFailures количество неудачных попыток ввода кода привязки со всех адресов за период,
+	// после которого попытки замедляются, по умолчанию
+	defaultRedeemFailures = 100
+	// defaultRedeemDelay интервал между попытками ввода кода привязки со всех адресов после превышения
+	// количества неудачных попыток по умолчанию
+	defaultRedeemDelay = time.Second
+	// maxTrackedClients количество адресов, после которого из учета попыток удаляются истекшие записи
+	maxTrackedClients = 10000
+	// deviceCodeRetries количество попыток выдать код, не совпадающий с действующими
+	deviceCodeRetries = 3
+)
+
+// redeemAttempts представляет учет попыток ввода кода привязки с одного адреса
+// или неудачных попыток со всех адресов.
+type redeemAttempts struct {
+	count int
+	reset time.Time
+}
+
+// deviceCodeLength возвращает количество цифр в коде привязки из конфигурации.
+func deviceCodeLength() int {
+	length := util.GetConfig().Devices.CodeLength
+	if length < minDeviceCodeLength || length > maxDeviceCodeLength {
+		return defaultDeviceCodeLength
+	}
+	return length
+}
+
+// hashDeviceCode возвращает хэш кода привязки, под которым код хранится в репозитории.
+func hashDeviceCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeDeviceCode удаляет из кода привязки пробелы и дефисы, которыми код разделяют при вводе.
+// Возвращает код и ErrDeviceCodeInvalid, если код не состоит из нужного количества цифр.
+func normalizeDeviceCode(code string) (string, error) {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	if len(code) != deviceCodeLength() {
+		return "", ErrDeviceCodeInvalid
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", ErrDeviceCodeInvalid
+		}
+	}
+	return code, nil
+}
+
+// newDeviceCode возвращает случайный числовой код привязки.
+func newDeviceCode() (string, error) {
+	length := deviceCodeLength()
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", errors.WithMessage(err, "error occurred while reading rand")
+		}
+		b[i] = byte('0' + n.Int64())
+	}
+	return string(b), nil
+}
+
+// CreateDeviceCode выдает пользователю одноразовый числовой код привязки другого устройства.
+// Ранее выданный пользователю код перестает действовать.
+// Принимает контекст и идентификатор пользователя.
+// Возвращает код с моментом его истечения и ошибку, если операция не удалась.
+func (s *Service) CreateDeviceCode(ctx context.Context, userID uuid.UUID) (*model.CreatedDeviceCode, error) {
+	ttl := defaultDeviceCodeTTL
+	if seconds := util.GetConfig().Devices.CodeTTL; seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+
+	for range deviceCodeRetries {
+		code, err := newDeviceCode()
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.repo.CreateDeviceCode(ctx, &model.DeviceCode{
+			Hash:      hashDeviceCode(code),
+			UserID:    userID,
+			ExpiresAt: expiresAt,
+		})
+		if errors.Is(err, repository.ErrCodeExists) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &model.CreatedDeviceCode{Code: code, ExpiresAt: expiresAt}, nil
+	}
+	return nil, errors.New("failed to generate unique device code")
+}
+
+// ClaimDeviceCode привязывает устройство к пользователю, выдавшему код привязки.
+// Устройство получает идентификатор выдавшего код пользователя, а ссылки и API-ключи
+// текущего анонимного пользователя userID переносятся к нему, если в запросе нет отказа от переноса.
+// Данные учетной записи не переносятся. Код можно использовать только один раз,
+// а количество попыток ввода кода с одного адреса client за период ограничено.
+// Если неудачных попыток со всех адресов за период стало больше допустимого, попытки
+// выполняются по очереди с интервалом, чтобы коды нельзя было перебирать с множества адресов.
+// Принимает контекст, идентификатор текущего пользователя, адрес клиента и запрос с кодом.
+// Возвращает пользователя, выдавшего код, количество перенесенных ссылок, ErrDeviceCodeInvalid,
+// если код неверен или истек, и ErrTooManyAttempts, если попытки ввода исчерпаны.
+func (s *Service) ClaimDeviceCode(ctx context.Context, userID uuid.UUID, client string, req model.DeviceClaimRequest) (*model.User, int, error) {
+	if !s.allowRedeem(client) {
+		return nil, 0, ErrTooManyAttempts
+	}
+	if delay := s.redeemDelay(); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	code, err := normalizeDeviceCode(req.Code)
+	if err != nil {
+		s.failRedeem()
+		return nil, 0, err
+	}
+	claimed, err := s.repo.ClaimDeviceCode(ctx, hashDeviceCode(code))
+	if errors.Is(err, repository.ErrNotFound) {
+		s.failRedeem()
+		return nil, 0, ErrDeviceCodeInvalid
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	user, err := s.repo.FindUserByID(ctx, claimed.UserID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		user = &model.User{ID: claimed.UserID}
+	case err != nil:
+		return nil, 0, err
+	}
+
+	merged := 0
+	if !req.RefuseMerge {
+		if merged, err = s.absorbAnonymous(ctx, userID, user.ID); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	util.GetLogger().Infof("linked device of user %s to user %s, merged %d links", userID, user.ID, merged)
+	return user, merged, nil
+}
+
+// RedeemWindow возвращает период ограничения попыток ввода кода привязки из конфигурации.
+func RedeemWindow() time.Duration {
+	if minutes := util.GetConfig().Devices.RedeemWindow; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultRedeemWindow
+}
+
+// allowRedeem учитывает попытку ввода кода привязки с адреса client.
+// Возвращает false, если попытки с адреса на текущий период исчерпаны.
+func (s *Service) allowRedeem(client string) bool {
+	limit := defaultRedeemAttempts
+	if attempts := util.GetConfig().Devices.RedeemAttempts; attempts > 0 {
+		limit = attempts
+	}
+	window := RedeemWindow()
+
+	s.attemptsMu.Lock()
+	defer s.attemptsMu.Unlock()
+
+	now := time.Now()
+	if s.attempts == nil {
+		s.attempts = make(map[string]redeemAttempts)
+	}
+	if len(s.attempts) >= maxTrackedClients {
+		for key, a := range s.attempts {
+			if !now.Before(a.reset) {
+				delete(s.attempts, key)
+			}
+		}
+	}
+
+	a := s.attempts[client]
+	if !now.Before(a.reset) {
+		a = redeemAttempts{reset: now.Add(window)}
+	}
+	if a.count >= limit {
+		return false
+	}
+	a.count++
+	s.attempts[client] = a
+	return true
+}
+
+// redeemDelay возвращает время, через которое можно проверить код привязки.
+// Пока неудачных попыток со всех адресов за период не больше допустимого, попытки не ждут.
+// После этого каждой попытке отводится очередь с интервалом из конфигурации,
+// поэтому скорость перебора кодов не зависит от количества адресов и параллельных запросов.
+func (s *Service) redeemDelay() time.Duration {
+	cfg := util.GetConfig().Devices
+	failures := defaultRedeemFailures
+	if cfg.RedeemFailures > 0 {
+		failures = cfg.RedeemFailures
+	}
+	interval := defaultRedeemDelay
+	if cfg.RedeemDelay > 0 {
+		interval = time.Duration(cfg.RedeemDelay) * time.Millisecond
+	}
+
+	s.attemptsMu.Lock()
+	defer s.attemptsMu.Unlock()
+
+	now := time.Now()
+	if !now.Before(s.failures.reset) || s.failures.count < failures {
+		return 0
+	}
+	slot := now
+	if s.nextRedeem.After(slot) {
+		slot = s.nextRedeem
+	}
+	s.nextRedeem = slot.Add(interval)
+	return s.nextRedeem.Sub(now)
+}
+
+// failRedeem учитывает неудачную попытку ввода кода привязки в общем ограничении для всех адресов,
+// которое не позволяет перебирать коды с множества адресов.
+func (s *Service) failRedeem() {
+	s.attemptsMu.Lock()
+	defer s.attemptsMu.Unlock()
+
+	now := time.Now()
+	if !now.Before(s.failures.reset) {
+		s.failures = redeemAttempts{reset: now.Add(RedeemWindow())}
+	}
+	s.failures.count++
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// maxAliasLength максимальная длина идентификатора ссылки
+const maxAliasLength = 8
+
+// validAlias проверяет, что идентификатор ссылки непустой, не длиннее maxAliasLength
+// и состоит из символов, используемых в сгенерированных идентификаторах.
+func validAlias(id string) bool {
+	if id == "" || len(id) > maxAliasLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// toLinkRecord преобразует ссылку в запись экспорта.
+func toLinkRecord(link *model.Link) model.LinkRecord {
+	created := link.TimeCreated
+	rec := model.LinkRecord{
+		ID:          link.ID,
+		ShortURL:    buildShortURL(link.ID),
+		OriginalURL: link.Link,
+		Folder:      link.Folder,
+		Tags:        link.Tags,
+		LinkOptions: model.LinkOptions{
+			MaxClicks:    link.ClicksLeft,
+			ActiveFrom:   link.ActiveFrom,
+			ActiveUntil:  link.ActiveUntil,
+			Title:        link.Title,
+			RedirectCode: link.RedirectCode,
+			CacheMaxAge:  link.CacheMaxAge,
+			Passthrough:  link.Passthrough,
+			UTM:          link.UTM,
+			Targets:      link.Targets,
+			Rules:        link.Rules,
+			Entries:      link.Entries,
+		},
+	}
+	if !created.IsZero() {
+		rec.CreatedAt = &created
+	}
+	return rec
+}
+
+// ExportLinks передает функции fn по одной все неудаленные ссылки пользователя вместе с их параметрами.
+// Ссылки читаются из хранилища по мере обработки и не загружаются в память целиком.
+// Принимает контекст, идентификатор пользователя и функцию обработки записи экспорта.
+// Возвращает первую ошибку fn или ошибку, если операция не удалась.
+func (s *Service) ExportLinks(ctx context.Context, userID uuid.UUID, fn func(*model.LinkRecord) error) error {
+	return s.repo.WalkUserLinks(ctx, userID, model.LinkFilter{}, func(link *model.Link) error {
+		rec := toLinkRecord(link)
+		return fn(&rec)
+	})
+}
+
+// ImportLink создает ссылку пользователя по записи импорта.
+// Идентификатор из записи сохраняется, если он корректен и свободен, иначе генерируется новый.
+// Повторный импорт собственной ссылки и уже сокращенный оригинальный URL дают статус exists.
+// Принимает контекст, идентификатор пользователя и запись импорта.
+// Возвращает итог импорта записи; номер строки заполняет вызывающая сторона.
+func (s *Service) ImportLink(ctx context.Context, userID uuid.UUID, rec model.LinkRecord) model.ImportResult {
+	res, err := s.importLink(ctx, userID, rec)
+	if err != nil {
+		res.Status = model.ImportFailed
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// importLink выполняет импорт записи и возвращает ошибку, если запись не удалось сохранить.
+func (s *Service) importLink(ctx context.Context, userID uuid.UUID, rec model.LinkRecord) (model.ImportResult, error) {
+	var res model.ImportResult
+
+	original := normalizeQuery(strings.TrimSpace(rec.OriginalURL))
+	opts := rec.LinkOptions
+	entries := opts.Entries
+	opts.Entries = nil
+	switch {
+	case len(entries) > 0 && original != "":
+		return res, errors.WithMessage(ErrInvalidOptions, "bundle must not have original_url")
+	case len(entries) == 0 && original == "":
+		return res, errors.WithMessage(ErrInvalidOptions, "original_url must not be empty")
+	}
+
+	if err := validateOptions(opts); err != nil {
+		return res, err
+	}
+	var err error
+	if opts.Targets, err = normalizeTargets(opts.Targets); err != nil {
+		return res, err
+	}
+	if opts.Rules, err = normalizeRules(opts.Rules); err != nil {
+		return res, err
+	}
+	if len(entries) > 0 {
+		if err = validateBundle(opts.Passthrough, opts.Targets, opts.Rules); err != nil {
+			return res, err
+		}
+		if opts.Entries, err = normalizeEntries(entries); err != nil {
+			return res, err
+		}
+	}
+	tags, err := normalizeTags(rec.Tags)
+	if err != nil {
+		return res, err
+	}
+	folder, err := normalizeFolder(rec.Folder)
+	if err != nil {
+		return res, err
+	}
+
+	id := ""
+	if validAlias(rec.ID) {
+		existing, err := s.repo.FindLink(ctx, rec.ID)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			id = rec.ID
+		case err != nil:
+			return res, err
+		case existing.UserID == userID && existing.Link == original && !existing.IsDeleted:
+			res.Status, res.ID, res.ShortURL = model.ImportExists, existing.ID, buildShortURL(existing.ID)
+			return res, nil
+		}
+	}
+	if id == "" {
+		if id, err = s.generateShortID(); err != nil {
+			return res, err
+		}
+		res.AliasChanged = rec.ID != ""
+	}
+
+	link, err := s.repo.CreateLink(ctx, id, original, userID, opts)
+	if err != nil {
+		return res, err
+	}
+	res.ID, res.ShortURL = link.ID, buildShortURL(link.ID)
+	if link.ID != id {
+		res.Status, res.AliasChanged = model.ImportExists, false
+		return res, nil
+	}
+
+	if len(tags) > 0 || folder != "" {
+		_, err = s.repo.AssignLabels(ctx, userID, model.LabelRequest{
+			IDs:     []string{id},
+			AddTags: tags,
+			Folder:  model.Optional[string]{Value: &folder, Set: folder != ""},
+		})
+		if err != nil {
+			return res, errors.WithMessage(err, "link created without labels")
+		}
+	}
+
+	res.Status = model.ImportCreated
+	return res, nil
+}
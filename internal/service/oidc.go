@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+)
+
+// ErrOIDCDisabled ошибка, возникающая при входе через OpenID Connect, если провайдер не настроен
+// ErrOIDCRejected ошибка, возникающая, если вход через провайдер не подтвержден
+var (
+	ErrOIDCDisabled = errors.New("oidc login is disabled")
+	ErrOIDCRejected = errors.New("oidc login rejected")
+)
+
+// oidcSecretSize размер случайных значений state, nonce и секрета PKCE в байтах
+const oidcSecretSize = 32
+
+// IdentityProvider определяет провайдер OpenID Connect, через который выполняется вход.
+type IdentityProvider interface {
+	// AuthCodeURL возвращает адрес страницы входа провайдера с указанными state, nonce и кодом PKCE.
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+
+	// Exchange обменивает код авторизации на ID токен, проверяет его и nonce
+	// и возвращает подтвержденного провайдером пользователя.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*model.OIDCIdentity, error)
+}
+
+// SetIdentityProvider включает вход через провайдер OpenID Connect.
+// Значение nil отключает вход.
+func (s *Service) SetIdentityProvider(idp IdentityProvider) {
+	s.idp = idp
+}
+
+// StartOIDCLogin начинает вход через провайдер OpenID Connect.
+// Создает случайные state, nonce и секрет PKCE, которые клиент должен сохранить до возврата от провайдера.
+// Возвращает адрес страницы входа провайдера, параметры входа и ErrOIDCDisabled, если провайдер не настроен.
+func (s *Service) StartOIDCLogin(ctx context.Context) (string, *model.OIDCFlow, error) {
+	if s.idp == nil {
+		return "", nil, ErrOIDCDisabled
+	}
+
+	var (
+		flow model.OIDCFlow
+		err  error
+	)
+	for _, v := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		if *v, err = oidcSecret(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	challenge := sha256.Sum256([]byte(flow.Verifier))
+	authURL, err := s.idp.AuthCodeURL(ctx, flow.State, flow.Nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return "", nil, err
+	}
+	return authURL, &flow, nil
+}
+
+// CompleteOIDCLogin завершает вход через провайдер OpenID Connect.
+// Проверяет, что state совпадает с сохраненным в flow, и обменивает код авторизации у провайдера.
+// Пользователь провайдера входит в привязанную к нему учетную запись. При первом входе
+// для него создается новая учетная запись без пароля: к существующей учетной записи с тем же
+// адресом электронной почты он не привязывается, так как адрес при регистрации не подтверждается.
+// Ссылки и API-ключи анонимного пользователя anonID переносятся в учетную запись.
+// Возвращает учетную запись, количество перенесенных ссылок, ErrOIDCDisabled, если провайдер
+// не настроен, и ErrOIDCRejected, если вход не подтвержден.
+func (s *Service) CompleteOIDCLogin(ctx context.Context, anonID uuid.UUID, flow model.OIDCFlow, state, code string) (*model.User, int, error) {
+	if s.idp == nil {
+		return nil, 0, ErrOIDCDisabled
+	}
+	if flow.State == "" || code == "" || subtle.ConstantTimeCompare([]byte(state), []byte(flow.State)) != 1 {
+		return nil, 0, errors.WithMessage(ErrOIDCRejected, "state mismatch")
+	}
+
+	identity, err := s.idp.Exchange(ctx, code, flow.Verifier, flow.Nonce)
+	if err != nil {
+		return nil, 0, errors.WithMessage(ErrOIDCRejected, err.Error())
+	}
+
+	user, err := s.identityUser(ctx, identity)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	merged, err := s.absorbAnonymous(ctx, anonID, user.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return user, merged, nil
+}
+
+// identityUser находит учетную запись пользователя провайдера или создает для него новую.
+// Подтвержденный провайдером адрес электронной почты сохраняется в новой учетной записи,
+// если он не занят другой учетной записью.
+func (s *Service) identityUser(ctx context.Context, identity *model.OIDCIdentity) (*model.User, error) {
+	user, err := s.repo.FindUserByIdentity(ctx, identity.Issuer, identity.Subject)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return user, err
+	}
+
+	var email string
+	if identity.EmailVerified {
+		email, _ = normalizeEmail(identity.Email)
+	}
+	user = &model.User{ID: uuid.New(), Email: email}
+	err = s.repo.CreateUser(ctx, user)
+	if email != "" && errors.Is(err, repository.ErrUserExists) {
+		// Адрес занят другой учетной записью, новая учетная запись создается без него.
+		user.Email = ""
+		err = s.repo.CreateUser(ctx, user)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.LinkIdentity(ctx, &model.UserIdentity{Issuer: identity.Issuer, Subject: identity.Subject, UserID: user.ID})
+	if errors.Is(err, repository.ErrUserExists) {
+		// Пользователь провайдера привязан параллельным входом.
+		return s.repo.FindUserByIdentity(ctx, identity.Issuer, identity.Subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// oidcSecret возвращает случайное значение для state, nonce или секрета PKCE.
+func oidcSecret() (string, error) {
+	b := make([]byte, oidcSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.WithMessage(err, "error occurred while reading rand")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
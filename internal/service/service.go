@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ypxd99/yandex-practicm/internal/model"
@@ -12,20 +14,57 @@ import (
 // Обеспечивает бизнес-логику для операций с URL и взаимодействует с репозиторием.
 type Service struct {
 	repo repository.LinkRepository
+	idp  IdentityProvider
+
+	seenMu sync.Mutex
+	seen   map[uuid.UUID]time.Time
+
+	variantsMu sync.Mutex
+	variants   map[string]map[string]int64
+
+	attemptsMu sync.Mutex
+	attempts   map[string]redeemAttempts
+	failures   redeemAttempts
+	nextRedeem time.Time
 }
 
 // LinkService определяет интерфейс для работы с сокращенными URL.
 // Предоставляет методы для создания, поиска и управления URL.
 type LinkService interface {
 	// ShorterLink создает сокращенный URL для заданного длинного URL.
-	// Принимает контекст, оригинальный URL и идентификатор пользователя.
+	// Принимает контекст, оригинальный URL, идентификатор пользователя и параметры ссылки.
 	// Возвращает сокращенный URL и ошибку, если операция не удалась.
-	ShorterLink(ctx context.Context, url string, userID uuid.UUID) (string, error)
+	ShorterLink(ctx context.Context, url string, userID uuid.UUID, opts model.LinkOptions) (string, error)
+
+	// CreateBundle создает страницу-подборку из нескольких ссылок с собственным сокращенным URL.
+	// Принимает контекст, идентификатор пользователя и параметры подборки, включая ее ссылки.
+	// Возвращает сокращенный URL подборки и ошибку, если операция не удалась.
+	CreateBundle(ctx context.Context, userID uuid.UUID, opts model.LinkOptions) (string, error)
 
-	// FindLink находит оригинальный URL по его сокращенному идентификатору.
+	// FindLink находит оригинальный URL по его сокращенному идентификатору и учитывает переход.
+	// Принимает контекст, идентификатор сокращенного URL и сведения о запросе перехода.
+	// Возвращает параметры редиректа и ошибку, если URL не найден.
+	FindLink(ctx context.Context, id string, visit model.Visit) (*model.Redirect, error)
+
+	// LookupLink возвращает параметры редиректа по сокращенному идентификатору без учета перехода.
+	// Принимает контекст, идентификатор сокращенного URL и сведения о запросе перехода.
+	// Возвращает параметры редиректа и ошибку, если URL не найден.
+	LookupLink(ctx context.Context, id string, visit model.Visit) (*model.Redirect, error)
+
+	// PreviewLink возвращает сведения о ссылке без перехода по ней.
 	// Принимает контекст и идентификатор сокращенного URL.
-	// Возвращает оригинальный URL и ошибку, если URL не найден.
-	FindLink(ctx context.Context, id string) (string, error)
+	// Возвращает описание ссылки и ошибку, если URL не найден, удален, неактивен или исчерпан.
+	PreviewLink(ctx context.Context, id string) (*model.LinkPreview, error)
+
+	// GetShortURL возвращает полный сокращенный URL ссылки пользователя.
+	// Принимает контекст, идентификатор ссылки и идентификатор пользователя.
+	// Возвращает сокращенный URL и ошибку, если ссылка не найдена или принадлежит другому пользователю.
+	GetShortURL(ctx context.Context, id string, userID uuid.UUID) (string, error)
+
+	// GetPublicShortURL возвращает полный сокращенный URL существующей неудаленной ссылки.
+	// Принимает контекст и идентификатор ссылки.
+	// Возвращает сокращенный URL и ошибку, если ссылка не найдена или удалена.
+	GetPublicShortURL(ctx context.Context, id string) (string, error)
 
 	// StorageStatus проверяет доступность хранилища.
 	// Принимает контекст.
@@ -37,15 +76,198 @@ type LinkService interface {
 	// Возвращает массив сокращенных URL и ошибку, если операция не удалась.
 	BatchShorten(ctx context.Context, batch []model.BatchRequest, userID uuid.UUID) ([]model.BatchResponse, error)
 
-	// GetUserURLs возвращает список всех URL, созданных пользователем.
-	// Принимает контекст и идентификатор пользователя.
+	// GetUserURLs возвращает список URL, созданных пользователем и подходящих под фильтр.
+	// Принимает контекст, идентификатор пользователя и фильтр.
 	// Возвращает массив URL и ошибку, если операция не удалась.
-	GetUserURLs(ctx context.Context, userID uuid.UUID) ([]model.UserURLResponse, error)
+	GetUserURLs(ctx context.Context, userID uuid.UUID, filter model.LinkFilter) ([]model.UserURLResponse, error)
+
+	// AssignLabels назначает и снимает метки, а также меняет папку у ссылок пользователя.
+	// Принимает контекст, идентификатор пользователя и запрос на изменение.
+	// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+	AssignLabels(ctx context.Context, userID uuid.UUID, req model.LabelRequest) (int, error)
+
+	// GetUserTags возвращает метки пользователя с количеством ссылок для каждой.
+	// Принимает контекст и идентификатор пользователя.
+	// Возвращает массив меток и ошибку, если операция не удалась.
+	GetUserTags(ctx context.Context, userID uuid.UUID) ([]model.TagInfo, error)
+
+	// RenameTag переименовывает метку на всех ссылках пользователя.
+	// Принимает контекст, идентификатор пользователя, текущее и новое название метки.
+	// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+	RenameTag(ctx context.Context, userID uuid.UUID, from, to string) (int, error)
+
+	// DeleteTag снимает метку со всех ссылок пользователя.
+	// Принимает контекст, идентификатор пользователя и название метки.
+	// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+	DeleteTag(ctx context.Context, userID uuid.UUID, tag string) (int, error)
+
+	// UpdateLink изменяет параметры ссылки по запросу ее владельца.
+	// Принимает контекст, идентификатор ссылки, идентификатор пользователя и запрос на изменение.
+	// Возвращает обновленное описание ссылки и ошибку, если операция не удалась.
+	UpdateLink(ctx context.Context, id string, userID uuid.UUID, req model.UpdateLinkRequest) (*model.UserURLResponse, error)
+
+	// GetLinkHistory возвращает предыдущие значения оригинального URL ссылки пользователя.
+	// Принимает контекст, идентификатор ссылки и идентификатор пользователя.
+	// Возвращает список версий и ошибку, если операция не удалась.
+	GetLinkHistory(ctx context.Context, id string, userID uuid.UUID) ([]model.LinkVersion, error)
+
+	// GetVariantStats возвращает количество переходов по вариантам адреса ссылки пользователя.
+	// Принимает контекст, идентификатор ссылки и идентификатор пользователя.
+	// Возвращает список счетчиков и ошибку, если операция не удалась.
+	GetVariantStats(ctx context.Context, id string, userID uuid.UUID) ([]model.VariantStats, error)
+
+	// RollbackLink восстанавливает оригинальный URL ссылки из указанной версии истории.
+	// Принимает контекст, идентификатор ссылки, идентификатор пользователя и номер версии.
+	// Возвращает обновленное описание ссылки и ошибку, если операция не удалась.
+	RollbackLink(ctx context.Context, id string, userID uuid.UUID, version int) (*model.UserURLResponse, error)
 
 	// DeleteURLs помечает указанные URL как удаленные.
 	// Принимает контекст, массив идентификаторов URL и идентификатор пользователя.
 	// Возвращает количество удаленных URL и ошибку, если операция не удалась.
 	DeleteURLs(ctx context.Context, ids []string, userID uuid.UUID) (int, error)
+
+	// RestoreURLs снимает пометку удаления с указанных URL пользователя.
+	// Принимает контекст, массив идентификаторов URL и идентификатор пользователя.
+	// Возвращает итог восстановления и ошибку, если операция не удалась.
+	RestoreURLs(ctx context.Context, ids []string, userID uuid.UUID) (*model.RestoreResult, error)
+
+	// CreateTransfer выпускает подписанный токен передачи ссылок пользователя.
+	// Принимает контекст, идентификаторы ссылок и идентификатор пользователя.
+	// Возвращает токен передачи и ошибку, если операция не удалась.
+	CreateTransfer(ctx context.Context, ids []string, userID uuid.UUID) (*model.TransferToken, error)
+
+	// RedeemTransfer принимает ссылки по токену передачи и делает пользователя их владельцем.
+	// Принимает контекст, токен передачи и идентификатор получателя.
+	// Возвращает итог передачи и ошибку, если операция не удалась.
+	RedeemTransfer(ctx context.Context, token string, userID uuid.UUID) (*model.TransferResult, error)
+
+	// GetTransfers возвращает журнал передачи ссылок пользователя.
+	// Принимает контекст и идентификатор пользователя.
+	// Возвращает список записей журнала и ошибку, если операция не удалась.
+	GetTransfers(ctx context.Context, userID uuid.UUID) ([]model.LinkTransfer, error)
+
+	// ExportLinks передает функции fn по одной все неудаленные ссылки пользователя вместе с их параметрами.
+	// Принимает контекст, идентификатор пользователя и функцию обработки записи экспорта.
+	// Возвращает первую ошибку fn или ошибку, если операция не удалась.
+	ExportLinks(ctx context.Context, userID uuid.UUID, fn func(*model.LinkRecord) error) error
+
+	// ImportLink создает ссылку пользователя по записи импорта.
+	// Принимает контекст, идентификатор пользователя и запись импорта.
+	// Возвращает итог импорта записи.
+	ImportLink(ctx context.Context, userID uuid.UUID, rec model.LinkRecord) model.ImportResult
+
+	// CreateAPIKey создает API-ключ пользователя с указанными областями доступа.
+	// Принимает контекст, идентификатор пользователя и параметры ключа.
+	// Возвращает созданный ключ, показываемый один раз, и ошибку, если операция не удалась.
+	CreateAPIKey(ctx context.Context, userID uuid.UUID, req model.APIKeyRequest) (*model.CreatedAPIKey, error)
+
+	// GetAPIKeys возвращает API-ключи пользователя без самих ключей.
+	// Принимает контекст и идентификатор пользователя.
+	// Возвращает список ключей и ошибку, если операция не удалась.
+	GetAPIKeys(ctx context.Context, userID uuid.UUID) ([]model.APIKey, error)
+
+	// RevokeAPIKey отзывает API-ключ пользователя.
+	// Принимает контекст, идентификатор ключа и идентификатор пользователя.
+	// Возвращает ошибку, если ключ не найден или операция не удалась.
+	RevokeAPIKey(ctx context.Context, id, userID uuid.UUID) error
+
+	// ResolveAPIKey находит владельца и области доступа действующего API-ключа.
+	// Принимает контекст и API-ключ.
+	// Возвращает идентификатор владельца, области доступа и ошибку, если ключ недействителен.
+	ResolveAPIKey(ctx context.Context, key string) (uuid.UUID, []string, error)
+
+	// SignUp регистрирует учетную запись и переносит в нее данные анонимного пользователя.
+	// Принимает контекст, идентификатор текущего пользователя и данные для регистрации.
+	// Возвращает учетную запись, количество перенесенных ссылок и ошибку, если операция не удалась.
+	SignUp(ctx context.Context, anonID uuid.UUID, creds model.Credentials) (*model.User, int, error)
+
+	// Login выполняет вход в учетную запись и переносит в нее данные анонимного пользователя.
+	// Принимает контекст, идентификатор текущего пользователя и данные для входа.
+	// Возвращает учетную запись, количество перенесенных ссылок и ошибку, если операция не удалась.
+	Login(ctx context.Context, anonID uuid.UUID, creds model.Credentials) (*model.User, int, error)
+
+	// StartOIDCLogin начинает вход через провайдер OpenID Connect.
+	// Принимает контекст.
+	// Возвращает адрес страницы входа провайдера, параметры входа, которые нужно сохранить
+	// до возврата от провайдера, и ошибку, если вход через провайдер недоступен.
+	StartOIDCLogin(ctx context.Context) (string, *model.OIDCFlow, error)
+
+	// CompleteOIDCLogin завершает вход через провайдер OpenID Connect и переносит в учетную запись
+	// данные анонимного пользователя.
+	// Принимает контекст, идентификатор текущего пользователя, сохраненные параметры входа,
+	// а также state и код авторизации, полученные от провайдера.
+	// Возвращает учетную запись, количество перенесенных ссылок и ошибку, если вход не подтвержден.
+	CompleteOIDCLogin(ctx context.Context, anonID uuid.UUID, flow model.OIDCFlow, state, code string) (*model.User, int, error)
+
+	// RevokeToken отзывает JWT токен при выходе пользователя.
+	// Принимает контекст, идентификатор токена jti и момент его истечения.
+	// Возвращает ошибку, если операция не удалась.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+
+	// RevokeUserTokens отзывает все JWT токены пользователя, выпущенные до текущего момента.
+	// Принимает контекст и идентификатор пользователя.
+	// Возвращает ошибку, если операция не удалась.
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) error
+
+	// IsTokenRevoked проверяет, отозван ли JWT токен.
+	// Принимает контекст, идентификатор пользователя, идентификатор токена jti и момент его выпуска.
+	// Возвращает true, если токен отозван, и ошибку, если операция не удалась.
+	IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error)
+
+	// UserRole возвращает сохраненную роль учетной записи.
+	// Принимает контекст и идентификатор пользователя.
+	// Возвращает роль, пустую для анонимных пользователей, и ошибку, если операция не удалась.
+	UserRole(ctx context.Context, userID uuid.UUID) (string, error)
+
+	// CreateDeviceCode выдает пользователю одноразовый код привязки другого устройства.
+	// Принимает контекст и идентификатор пользователя.
+	// Возвращает код и ошибку, если операция не удалась.
+	CreateDeviceCode(ctx context.Context, userID uuid.UUID) (*model.CreatedDeviceCode, error)
+
+	// ClaimDeviceCode привязывает устройство к пользователю, выдавшему код привязки,
+	// и переносит к нему ссылки текущего анонимного пользователя, если от переноса не отказались.
+	// Принимает контекст, идентификатор текущего пользователя, адрес клиента и запрос с кодом.
+	// Возвращает пользователя, выдавшего код, количество перенесенных ссылок и ошибку, если код не принят.
+	ClaimDeviceCode(ctx context.Context, userID uuid.UUID, client string, req model.DeviceClaimRequest) (*model.User, int, error)
+
+	// TouchUser отмечает обращение пользователя к сервису.
+	// Принимает идентификатор пользователя.
+	TouchUser(userID uuid.UUID)
+
+	// SearchLinks находит ссылки любых пользователей по подстроке оригинального URL и владельцу.
+	// Принимает контекст, идентификатор администратора и условия поиска.
+	// Возвращает найденные ссылки и ошибку, если операция не удалась.
+	SearchLinks(ctx context.Context, adminID uuid.UUID, filter model.AdminLinkFilter) ([]model.AdminLink, error)
+
+	// DisableLink отключает ссылку любого пользователя с указанной причиной.
+	// Принимает контекст, идентификатор администратора, идентификатор ссылки и причину.
+	// Возвращает ошибку, если ссылка не найдена или причина не указана.
+	DisableLink(ctx context.Context, adminID uuid.UUID, id, reason string) error
+
+	// EnableLink снимает отключение со ссылки любого пользователя.
+	// Принимает контекст, идентификатор администратора, идентификатор ссылки и причину.
+	// Возвращает ошибку, если ссылка не найдена или причина не указана.
+	EnableLink(ctx context.Context, adminID uuid.UUID, id, reason string) error
+
+	// GetUserStats возвращает количество ссылок и API-ключей пользователей.
+	// Принимает контекст, идентификатор администратора, идентификатор пользователя и количество записей.
+	// Возвращает список записей и ошибку, если операция не удалась.
+	GetUserStats(ctx context.Context, adminID uuid.UUID, userID *uuid.UUID, limit int) ([]model.UserStats, error)
+
+	// PurgeUser безвозвратно удаляет ссылки пользователя и отзывает его API-ключи.
+	// Принимает контекст, идентификатор администратора, идентификатор пользователя и причину.
+	// Возвращает количество удаленных ссылок и ошибку, если операция не удалась.
+	PurgeUser(ctx context.Context, adminID, userID uuid.UUID, reason string) (int, error)
+
+	// GetAdminActions возвращает последние записи журнала действий администраторов.
+	// Принимает контекст, идентификатор администратора и количество записей.
+	// Возвращает список записей и ошибку, если операция не удалась.
+	GetAdminActions(ctx context.Context, adminID uuid.UUID, limit int) ([]model.AdminAction, error)
+
+	// CountUsers возвращает количество учетных записей, анонимных и недавно активных пользователей.
+	// Принимает контекст и идентификатор администратора.
+	// Возвращает количество пользователей и ошибку, если операция не удалась.
+	CountUsers(ctx context.Context, adminID uuid.UUID) (*model.UserCount, error)
 }
 
 // InitService создает и возвращает новый экземпляр Service с предоставленным репозиторием.
@@ -4,21 +4,69 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"hash/fnv"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
 	"github.com/ypxd99/yandex-practicm/util"
 )
 
 // ErrURLExist ошибка, возникающая при попытке создать уже существующий URL
 // ErrURLDeleted ошибка, возникающая при попытке получить доступ к удаленному URL
+// ErrURLExhausted ошибка, возникающая при исчерпании лимита переходов по URL
+// ErrURLNotActive ошибка, возникающая при переходе по URL до начала окна активности
+// ErrURLExpired ошибка, возникающая при переходе по URL после окончания окна активности
+// ErrURLNotFound ошибка, возникающая при обращении к чужому или несуществующему URL
+// ErrVersionNotFound ошибка, возникающая при обращении к несуществующей версии URL
+// ErrInvalidOptions ошибка, возникающая при некорректных параметрах ссылки
+// ErrURLCached ошибка, возникающая при смене адреса ссылки, редирект которой кэшируется клиентами
 var (
-	ErrURLExist   = errors.New("url already exists")
-	ErrURLDeleted = errors.New("url is deleted")
+	ErrURLExist        = errors.New("url already exists")
+	ErrURLDeleted      = errors.New("url is deleted")
+	ErrURLExhausted    = errors.New("url click limit reached")
+	ErrURLNotActive    = errors.New("url is not active yet")
+	ErrURLExpired      = errors.New("url is expired")
+	ErrURLNotFound     = errors.New("url not found")
+	ErrVersionNotFound = errors.New("url version not found")
+	ErrInvalidOptions  = errors.New("invalid link options")
+	ErrURLCached       = errors.New("url redirect is cached by clients")
 )
 
+const (
+	// maxTagLength максимальная длина названия метки
+	maxTagLength = 64
+	// maxFolderLength максимальная длина названия папки
+	maxFolderLength = 255
+	// maxTitleLength максимальная длина названия ссылки
+	maxTitleLength = 255
+	// maxCacheMaxAge максимальное время кэширования редиректа в секундах
+	maxCacheMaxAge = 365 * 24 * 60 * 60
+	// defaultRedirectCode HTTP-статус редиректа, если он не задан в конфигурации
+	defaultRedirectCode = 307
+	// maxUTMLength максимальная длина значения UTM-метки
+	maxUTMLength = 255
+	// maxTargets максимальное количество вариантов адреса ссылки
+	maxTargets = 10
+	// maxTargetWeight максимальный вес варианта адреса
+	maxTargetWeight = 1000
+	// maxVariantLength максимальная длина названия варианта адреса
+	maxVariantLength = 32
+	// maxRules максимальное количество правил выбора адреса ссылки
+	maxRules = 20
+	// maxLanguageLength максимальная длина языка в правиле выбора адреса
+	maxLanguageLength = 35
+)
+
+// redirectCodes допустимые HTTP-статусы редиректа
+var redirectCodes = []int{301, 302, 303, 307, 308}
+
 // normalizeQuery нормализует запрос, удаляя SQL-ключевые слова.
 // Принимает строку запроса и возвращает нормализованную строку.
 func normalizeQuery(data string) string {
@@ -56,49 +104,586 @@ func (s *Service) generateShortID() (string, error) {
 	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "="), nil
 }
 
+// validateOptions проверяет корректность параметров создания ссылки.
+// Возвращает ErrInvalidOptions, если параметры некорректны.
+func validateOptions(opts model.LinkOptions) error {
+	if opts.MaxClicks != nil && *opts.MaxClicks <= 0 {
+		return errors.WithMessage(ErrInvalidOptions, "max_clicks must be positive")
+	}
+	if len(opts.Title) > maxTitleLength {
+		return errors.WithMessage(ErrInvalidOptions, "title is too long")
+	}
+	if len(opts.Entries) > 0 {
+		return errors.WithMessage(ErrInvalidOptions, "entries are supported only for bundles")
+	}
+	if err := validateRedirect(opts.RedirectCode, opts.CacheMaxAge); err != nil {
+		return err
+	}
+	if err := validatePassthrough(opts.Passthrough, opts.UTM); err != nil {
+		return err
+	}
+
+	return validateWindow(opts.ActiveFrom, opts.ActiveUntil)
+}
+
+// validatePassthrough проверяет режим передачи пути и параметров запроса и UTM-метки ссылки.
+// Возвращает ErrInvalidOptions, если режим неизвестен или значение метки слишком длинное.
+func validatePassthrough(mode string, utm *model.UTMParams) error {
+	switch mode {
+	case "", model.PassthroughOff, model.PassthroughPath, model.PassthroughQuery:
+	default:
+		return errors.WithMessagef(ErrInvalidOptions, "unsupported passthrough %q", mode)
+	}
+
+	for _, values := range utm.Values() {
+		if len(values[0]) > maxUTMLength {
+			return errors.WithMessage(ErrInvalidOptions, "utm value is too long")
+		}
+	}
+
+	return nil
+}
+
+// normalizeTargets проверяет варианты адреса ссылки и присваивает названия вариантам без них.
+// Безымянные варианты получают названия по порядку: a, b, c и т.д.
+// Возвращает ErrInvalidOptions, если вариантов меньше двух, вес или адрес некорректен
+// или названия повторяются.
+func normalizeTargets(targets []model.LinkTarget) ([]model.LinkTarget, error) {
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	if len(targets) < 2 || len(targets) > maxTargets {
+		return nil, errors.WithMessagef(ErrInvalidOptions, "targets must contain from 2 to %d items", maxTargets)
+	}
+
+	res := make([]model.LinkTarget, len(targets))
+	names := make(map[string]struct{}, len(targets))
+	for i, target := range targets {
+		target.Name = strings.TrimSpace(target.Name)
+		if target.Name == "" {
+			target.Name = string(rune('a' + i))
+		}
+		if len(target.Name) > maxVariantLength {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "target name %q is too long", target.Name)
+		}
+		if _, exists := names[target.Name]; exists {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "duplicate target name %q", target.Name)
+		}
+		if target.URL == "" {
+			return nil, errors.WithMessage(ErrInvalidOptions, "target url must not be empty")
+		}
+		if target.Weight <= 0 || target.Weight > maxTargetWeight {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "target weight must be between 1 and %d", maxTargetWeight)
+		}
+		names[target.Name] = struct{}{}
+		target.URL = normalizeQuery(target.URL)
+		res[i] = target
+	}
+
+	return res, nil
+}
+
+// normalizeRules проверяет правила выбора адреса ссылки и приводит их значения к нижнему регистру.
+// Возвращает ErrInvalidOptions, если правил слишком много, правило не содержит условий или адреса,
+// либо платформа, язык или интервал часов некорректны.
+func normalizeRules(rules []model.TargetRule) ([]model.TargetRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	if len(rules) > maxRules {
+		return nil, errors.WithMessagef(ErrInvalidOptions, "rules must contain at most %d items", maxRules)
+	}
+
+	res := make([]model.TargetRule, len(rules))
+	for i, rule := range rules {
+		rule.Platform = strings.ToLower(strings.TrimSpace(rule.Platform))
+		rule.Language = strings.ToLower(strings.TrimSpace(rule.Language))
+		if rule.Platform == "" && rule.Language == "" && rule.Hours == nil {
+			return nil, errors.WithMessage(ErrInvalidOptions, "rule must contain at least one condition")
+		}
+		switch rule.Platform {
+		case "", model.PlatformIOS, model.PlatformAndroid, model.PlatformDesktop:
+		default:
+			return nil, errors.WithMessagef(ErrInvalidOptions, "unsupported platform %q", rule.Platform)
+		}
+		if len(rule.Language) > maxLanguageLength || strings.Trim(rule.Language, "abcdefghijklmnopqrstuvwxyz-") != "" {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "invalid language %q", rule.Language)
+		}
+		if rule.Hours != nil {
+			if rule.Hours.From < 0 || rule.Hours.From > 23 || rule.Hours.To < 0 || rule.Hours.To > 23 ||
+				rule.Hours.From == rule.Hours.To {
+				return nil, errors.WithMessage(ErrInvalidOptions, "hours must be distinct values between 0 and 23")
+			}
+			hours := *rule.Hours
+			rule.Hours = &hours
+		}
+		if rule.URL == "" {
+			return nil, errors.WithMessage(ErrInvalidOptions, "rule url must not be empty")
+		}
+		rule.URL = normalizeQuery(rule.URL)
+		res[i] = rule
+	}
+
+	return res, nil
+}
+
+// detectPlatform определяет платформу посетителя по заголовку User-Agent.
+func detectPlatform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "android"):
+		return model.PlatformAndroid
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return model.PlatformIOS
+	default:
+		return model.PlatformDesktop
+	}
+}
+
+// acceptsLanguage сообщает, принимает ли посетитель язык lang согласно заголовку Accept-Language.
+// Язык "de" совпадает как с "de", так и с региональными вариантами вида "de-AT".
+// Языки с нулевым весом q не учитываются.
+func acceptsLanguage(header, lang string) bool {
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+		if tag == lang || strings.HasPrefix(tag, lang+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// selectRule возвращает первое правило, условия которого выполнены для запроса перехода,
+// или nil, если ни одно правило не сработало.
+func selectRule(rules []model.TargetRule, visit model.Visit, now time.Time) *model.TargetRule {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	platform := detectPlatform(visit.UserAgent)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Platform != "" && rule.Platform != platform {
+			continue
+		}
+		if rule.Language != "" && !acceptsLanguage(visit.AcceptLanguage, rule.Language) {
+			continue
+		}
+		if rule.Hours != nil && !rule.Hours.Contains(now) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// pickTarget выбирает вариант адреса пропорционально весам.
+// Выбор детерминирован для пары ссылки и посетителя, поэтому посетитель
+// получает один и тот же вариант, пока не изменятся варианты ссылки.
+func pickTarget(targets []model.LinkTarget, linkID, visitorID string) model.LinkTarget {
+	total := 0
+	for _, target := range targets {
+		total += target.Weight
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(linkID))
+	h.Write([]byte{0})
+	h.Write([]byte(visitorID))
+	point := int(h.Sum64() % uint64(total))
+
+	for _, target := range targets {
+		if point < target.Weight {
+			return target
+		}
+		point -= target.Weight
+	}
+	return targets[len(targets)-1]
+}
+
+// buildDestination формирует адрес редиректа с учетом режима передачи и UTM-меток ссылки.
+// Параметры исходного адреса имеют приоритет над параметрами запроса перехода,
+// а UTM-метки ссылки заменяют одноименные параметры.
+// Возвращает ErrURLNotFound, если передан дополнительный путь, а ссылка его не принимает.
+func buildDestination(link *model.Link, base string, visit model.Visit) (string, error) {
+	extra := strings.Trim(visit.Path, "/")
+	passQuery := (link.Passthrough == model.PassthroughPath || link.Passthrough == model.PassthroughQuery) &&
+		len(visit.Query) > 0
+	if extra != "" && link.Passthrough != model.PassthroughPath {
+		return "", ErrURLNotFound
+	}
+	if extra == "" && !passQuery && link.UTM == nil {
+		return base, nil
+	}
+
+	dest, err := url.Parse(base)
+	if err != nil {
+		return "", errors.WithMessage(err, "error occurred while parsing destination")
+	}
+
+	if extra != "" {
+		for _, segment := range strings.Split(extra, "/") {
+			if segment == "." || segment == ".." {
+				return "", ErrURLNotFound
+			}
+		}
+		dest = dest.JoinPath(extra)
+	}
+
+	query := dest.Query()
+	if passQuery {
+		for key, values := range visit.Query {
+			if !query.Has(key) {
+				query[key] = values
+			}
+		}
+	}
+	for key, values := range link.UTM.Values() {
+		query[key] = values
+	}
+	dest.RawQuery = query.Encode()
+
+	return dest.String(), nil
+}
+
+// validateRedirect проверяет HTTP-статус и время кэширования редиректа.
+// Возвращает ErrInvalidOptions, если статус не является редиректом или время кэширования вне допустимых границ.
+func validateRedirect(code, maxAge *int) error {
+	if code != nil && !slices.Contains(redirectCodes, *code) {
+		return errors.WithMessagef(ErrInvalidOptions, "unsupported redirect_code %d", *code)
+	}
+	if maxAge != nil && (*maxAge < 0 || *maxAge > maxCacheMaxAge) {
+		return errors.WithMessagef(ErrInvalidOptions, "cache_max_age must be between 0 and %d", maxCacheMaxAge)
+	}
+
+	return nil
+}
+
+// buildRedirect формирует параметры редиректа по ссылке и запросу перехода.
+// Адрес выбирается первым сработавшим правилом ссылки, а если ни одно не сработало,
+// одним из вариантов адреса или оригинальным URL. Для подборки без выбранной ссылки
+// вместо адреса возвращается страница подборки.
+// Незаданные для ссылки значения берутся из конфигурации. Ссылки с ограничением переходов,
+// правилами и вариантами адреса, а также переходы по ссылкам подборки не кэшируются,
+// а время кэширования ссылок с окном активности не выходит за его окончание.
+// Возвращает ErrURLNotFound, если ссылка подборки не найдена.
+func buildRedirect(link *model.Link, visit model.Visit, now time.Time) (*model.Redirect, error) {
+	var (
+		base, variant = link.Link, ""
+		bundle        *model.BundlePage
+	)
+	switch entryName := visit.Query.Get(model.BundleEntryParam); {
+	case link.IsBundle() && entryName == "":
+		bundle = buildBundlePage(link)
+	case link.IsBundle():
+		i := slices.IndexFunc(link.Entries, func(e model.BundleEntry) bool { return e.Name == entryName })
+		if i < 0 {
+			return nil, ErrURLNotFound
+		}
+		base, variant = link.Entries[i].URL, entryName
+	default:
+		if rule := selectRule(link.Rules, visit, now); rule != nil {
+			base = rule.URL
+		} else if len(link.Targets) > 0 {
+			target := pickTarget(link.Targets, link.ID, visit.VisitorID)
+			base, variant = target.URL, target.Name
+		}
+	}
+
+	dest := ""
+	if bundle == nil {
+		var err error
+		if dest, err = buildDestination(link, base, visit); err != nil {
+			return nil, err
+		}
+	} else if strings.Trim(visit.Path, "/") != "" {
+		return nil, ErrURLNotFound
+	}
+
+	res := &model.Redirect{URL: dest, Variant: variant, Bundle: bundle}
+	res.Code, res.MaxAge = redirectPolicy(link)
+	if variant != "" {
+		res.MaxAge = 0
+	}
+	if link.ActiveUntil != nil {
+		res.MaxAge = min(res.MaxAge, int(link.ActiveUntil.Sub(now)/time.Second))
+	}
+	res.MaxAge = max(res.MaxAge, 0)
+
+	return res, nil
+}
+
+// redirectPolicy возвращает HTTP-статус редиректа и время кэширования ссылки
+// с учетом значений по умолчанию из конфигурации.
+// Недопустимый статус из конфигурации заменяется на defaultRedirectCode.
+// Ссылки с лимитом переходов, правилами или вариантами адреса не кэшируются.
+func redirectPolicy(link *model.Link) (int, int) {
+	cfg := util.GetConfig().Links
+	code, maxAge := cfg.RedirectCode, cfg.CacheMaxAge
+	if !slices.Contains(redirectCodes, code) {
+		code = defaultRedirectCode
+	}
+	if link.RedirectCode != nil {
+		code = *link.RedirectCode
+	}
+	if link.CacheMaxAge != nil {
+		maxAge = *link.CacheMaxAge
+	}
+
+	if link.ClicksLeft != nil || len(link.Rules) > 0 || len(link.Targets) > 0 {
+		maxAge = 0
+	}
+	return code, maxAge
+}
+
+// checkDestinationChange проверяет, что адрес ссылки можно изменить.
+// Постоянный редирект и редирект с публичным кэшированием браузеры и CDN продолжают
+// выполнять по старому адресу, поэтому адрес такой ссылки не меняется, пока владелец
+// не переведет ее на временный редирект без кэширования.
+// Возвращает ErrURLCached, если редирект ссылки кэшируется клиентами.
+func checkDestinationChange(link *model.Link) error {
+	code, maxAge := redirectPolicy(link)
+	if code == 301 || code == 308 || maxAge > 0 {
+		return errors.WithMessage(ErrURLCached, "switch the link to a temporary uncached redirect before changing its destination")
+	}
+	return nil
+}
+
+// validateWindow проверяет, что окно активности ссылки не пустое.
+// Возвращает ErrInvalidOptions, если окончание окна не позже его начала.
+func validateWindow(from, until *time.Time) error {
+	if from != nil && until != nil && !until.After(*from) {
+		return errors.WithMessage(ErrInvalidOptions, "active_until must be after active_from")
+	}
+
+	return nil
+}
+
+// buildShortURL формирует полный сокращенный URL по идентификатору ссылки.
+func buildShortURL(id string) string {
+	var res strings.Builder
+	res.WriteString(util.GetConfig().Server.BaseURL)
+	res.WriteString("/")
+	res.WriteString(id)
+	return res.String()
+}
+
+// toUserURL формирует описание ссылки для ее владельца.
+func toUserURL(link *model.Link) model.UserURLResponse {
+	return model.UserURLResponse{
+		ShortURL:       buildShortURL(link.ID),
+		OriginalURL:    link.Link,
+		ActiveFrom:     link.ActiveFrom,
+		ActiveUntil:    link.ActiveUntil,
+		Folder:         link.Folder,
+		Tags:           link.Tags,
+		Title:          link.Title,
+		RedirectCode:   link.RedirectCode,
+		CacheMaxAge:    link.CacheMaxAge,
+		Passthrough:    link.Passthrough,
+		UTM:            link.UTM,
+		Targets:        link.Targets,
+		Rules:          link.Rules,
+		Entries:        link.Entries,
+		DisabledAt:     link.DisabledAt,
+		DisabledReason: link.DisabledReason,
+	}
+}
+
+// normalizeTags приводит названия меток к нижнему регистру и удаляет повторы.
+// Возвращает ErrInvalidOptions, если название метки пустое или слишком длинное.
+func normalizeTags(tags []string) ([]string, error) {
+	res := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, errors.WithMessagef(ErrInvalidOptions, "invalid tag %q", tag)
+		}
+		if !slices.Contains(res, tag) {
+			res = append(res, tag)
+		}
+	}
+	return res, nil
+}
+
+// normalizeFolder удаляет лишние пробелы и разделители из названия папки.
+// Возвращает ErrInvalidOptions, если название папки слишком длинное.
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.Trim(strings.TrimSpace(folder), "/")
+	if len(folder) > maxFolderLength {
+		return "", errors.WithMessage(ErrInvalidOptions, "folder name is too long")
+	}
+	return folder, nil
+}
+
 // ShorterLink создает сокращенную версию URL.
-// Принимает контекст, оригинальный URL и идентификатор пользователя.
+// Принимает контекст, оригинальный URL, идентификатор пользователя и параметры ссылки.
 // Возвращает сокращенный URL и ошибку, если операция не удалась.
-func (s *Service) ShorterLink(ctx context.Context, req string, userID uuid.UUID) (string, error) {
+func (s *Service) ShorterLink(ctx context.Context, req string, userID uuid.UUID, opts model.LinkOptions) (string, error) {
+	if err := validateOptions(opts); err != nil {
+		return "", err
+	}
+	targets, err := normalizeTargets(opts.Targets)
+	if err != nil {
+		return "", err
+	}
+	opts.Targets = targets
+	if opts.Rules, err = normalizeRules(opts.Rules); err != nil {
+		return "", err
+	}
 	id, err := s.generateShortID()
 	if err != nil {
 		return "", err
 	}
-	link, err := s.repo.CreateLink(ctx, id, normalizeQuery(req), userID)
+	link, err := s.repo.CreateLink(ctx, id, normalizeQuery(req), userID, opts)
 	if err != nil {
 		return "", err
 	}
 
-	baseURL := util.GetConfig().Server.BaseURL
 	if link.ID != id {
-		var res strings.Builder
-		res.WriteString(baseURL)
-		res.WriteString("/")
-		res.WriteString(link.ID)
-		return res.String(), ErrURLExist
+		return buildShortURL(link.ID), ErrURLExist
 	}
-	var res strings.Builder
-	res.WriteString(baseURL)
-	res.WriteString("/")
-	res.WriteString(link.ID)
-	return res.String(), nil
+	return buildShortURL(link.ID), nil
+}
+
+// resolveLink находит ссылку по ее сокращенной версии и проверяет, что по ней можно перейти.
+// Возвращает ошибку, если ссылка не найдена, удалена, отключена, неактивна или исчерпана.
+func (s *Service) resolveLink(ctx context.Context, req string) (*model.Link, error) {
+	link, err := s.repo.FindLink(ctx, normalizeQuery(req))
+	if err != nil {
+		return nil, err
+	}
+
+	if link.IsDeleted {
+		return nil, ErrURLDeleted
+	}
+	if link.DisabledAt != nil {
+		return nil, ErrURLDisabled
+	}
+
+	notYet, expired := link.IsActiveAt(time.Now())
+	if notYet {
+		return nil, ErrURLNotActive
+	}
+	if expired {
+		return nil, ErrURLExpired
+	}
+
+	if link.ClicksLeft != nil && *link.ClicksLeft <= 0 {
+		return nil, ErrURLExhausted
+	}
+
+	return link, nil
 }
 
 // FindLink находит оригинальный URL по его сокращенной версии.
+// Принимает контекст, сокращенный URL и сведения о запросе перехода.
+// Проверяет окно активности ссылки, для ссылок с ограничением переходов атомарно списывает один переход.
+// У подборок переход списывается только при открытии страницы, а переходы по ее ссылкам
+// учитываются как переходы по вариантам.
+// Возвращает параметры редиректа и ошибку, если URL не найден, удален, неактивен или исчерпан.
+func (s *Service) FindLink(ctx context.Context, req string, visit model.Visit) (*model.Redirect, error) {
+	link, err := s.resolveLink(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	redirect, err := buildRedirect(link, visit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if link.ClicksLeft != nil && (!link.IsBundle() || redirect.Bundle != nil) {
+		ok, err := s.repo.ConsumeClick(ctx, link.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrURLExhausted
+		}
+	}
+
+	if redirect.Variant != "" {
+		s.recordVariant(link.ID, redirect.Variant)
+	}
+
+	return redirect, nil
+}
+
+// LookupLink возвращает параметры редиректа по сокращенной версии URL без перехода по ней.
+// Проверяет состояние ссылки так же, как FindLink, но не списывает переход.
+// Принимает контекст, сокращенный URL и сведения о запросе перехода.
+// Возвращает параметры редиректа и ошибку, если URL не найден, удален, неактивен или исчерпан.
+func (s *Service) LookupLink(ctx context.Context, req string, visit model.Visit) (*model.Redirect, error) {
+	link, err := s.resolveLink(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildRedirect(link, visit, time.Now())
+}
+
+// PreviewLink возвращает сведения о ссылке без перехода по ней.
+// Проверяет состояние ссылки так же, как FindLink, но не списывает переход.
 // Принимает контекст и сокращенный URL.
-// Возвращает оригинальный URL и ошибку, если URL не найден или удален.
-func (s *Service) FindLink(ctx context.Context, req string) (string, error) {
-	str := normalizeQuery(req)
-	link, err := s.repo.FindLink(ctx, str)
+// Возвращает описание ссылки и ошибку, если URL не найден, удален, неактивен или исчерпан.
+func (s *Service) PreviewLink(ctx context.Context, req string) (*model.LinkPreview, error) {
+	link, err := s.resolveLink(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &model.LinkPreview{
+		ShortURL:    buildShortURL(link.ID),
+		OriginalURL: link.Link,
+		Title:       link.Title,
+	}
+	if !link.TimeCreated.IsZero() {
+		created := link.TimeCreated
+		preview.CreatedAt = &created
+	}
+
+	return preview, nil
+}
+
+// GetShortURL возвращает полный сокращенный URL ссылки пользователя.
+// Принимает контекст, идентификатор ссылки и идентификатор пользователя.
+// Возвращает сокращенный URL и ErrURLNotFound, если ссылка не найдена или принадлежит другому пользователю.
+func (s *Service) GetShortURL(ctx context.Context, id string, userID uuid.UUID) (string, error) {
+	link, err := s.findUserLink(ctx, id, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return buildShortURL(link.ID), nil
+}
+
+// GetPublicShortURL возвращает полный сокращенный URL существующей неудаленной и неотключенной ссылки.
+// Окно активности и лимит переходов не проверяются, переход по ссылке не списывается.
+// Принимает контекст и идентификатор ссылки.
+// Возвращает сокращенный URL и ошибку, если ссылка не найдена, удалена или отключена.
+func (s *Service) GetPublicShortURL(ctx context.Context, id string) (string, error) {
+	link, err := s.repo.FindLink(ctx, normalizeQuery(id))
 	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrURLNotFound
+		}
 		return "", err
 	}
 
 	if link.IsDeleted {
 		return "", ErrURLDeleted
 	}
+	if link.DisabledAt != nil {
+		return "", ErrURLDisabled
+	}
 
-	return link.Link, nil
+	return buildShortURL(link.ID), nil
 }
 
 // StorageStatus проверяет доступность хранилища.
@@ -114,9 +699,19 @@ func (s *Service) StorageStatus(ctx context.Context) (bool, error) {
 func (s *Service) BatchShorten(ctx context.Context, batch []model.BatchRequest, userID uuid.UUID) ([]model.BatchResponse, error) {
 	resp := make([]model.BatchResponse, len(batch))
 	links := make([]model.Link, len(batch))
-	baseURL := util.GetConfig().Server.BaseURL
 
 	for i, item := range batch {
+		if err := validateOptions(item.LinkOptions); err != nil {
+			return nil, err
+		}
+		targets, err := normalizeTargets(item.Targets)
+		if err != nil {
+			return nil, err
+		}
+		item.Targets = targets
+		if item.Rules, err = normalizeRules(item.Rules); err != nil {
+			return nil, err
+		}
 		shortURL, err := s.generateShortID()
 		if err != nil {
 			return nil, err
@@ -126,14 +721,8 @@ func (s *Service) BatchShorten(ctx context.Context, batch []model.BatchRequest,
 			Link:   item.OriginalURL,
 			UserID: userID,
 		}
-		var res strings.Builder
-		res.WriteString(baseURL)
-		res.WriteString("/")
-		res.WriteString(shortURL)
-		resp[i] = model.BatchResponse{
-			CorrelationID: item.CorrelationID,
-			ShortURL:      res.String(),
-		}
+		item.Apply(&links[i])
+		resp[i] = model.BatchResponse{CorrelationID: item.CorrelationID}
 	}
 
 	err := s.repo.BatchCreate(ctx, links)
@@ -141,14 +730,28 @@ func (s *Service) BatchShorten(ctx context.Context, batch []model.BatchRequest,
 		return nil, err
 	}
 
+	// Хранилище заменяет дубликаты существующими ссылками, поэтому адреса строятся после сохранения.
+	for i := range links {
+		resp[i].ShortURL = buildShortURL(links[i].ID)
+	}
 	return resp, nil
 }
 
-// GetUserURLs возвращает все URL, созданные указанным пользователем.
-// Принимает контекст и идентификатор пользователя.
+// GetUserURLs возвращает все URL, созданные указанным пользователем и подходящие под фильтр.
+// Принимает контекст, идентификатор пользователя и фильтр.
 // Возвращает массив URL и ошибку, если операция не удалась.
-func (s *Service) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]model.UserURLResponse, error) {
-	links, err := s.repo.FindUserLinks(ctx, userID)
+func (s *Service) GetUserURLs(ctx context.Context, userID uuid.UUID, filter model.LinkFilter) ([]model.UserURLResponse, error) {
+	if filter.Tag != "" {
+		filter.Tag = strings.ToLower(strings.TrimSpace(filter.Tag))
+	}
+	if filter.Folder != nil {
+		folder, err := normalizeFolder(*filter.Folder)
+		if err != nil {
+			return nil, err
+		}
+		filter.Folder = &folder
+	}
+	links, err := s.repo.FindUserLinks(ctx, userID, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -158,21 +761,291 @@ func (s *Service) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]model.Us
 	}
 
 	result := make([]model.UserURLResponse, len(links))
-	baseURL := util.GetConfig().Server.BaseURL
-	for i, link := range links {
-		var res strings.Builder
-		res.WriteString(baseURL)
-		res.WriteString("/")
-		res.WriteString(link.ID)
-		result[i] = model.UserURLResponse{
-			ShortURL:    res.String(),
-			OriginalURL: link.Link,
-		}
+	for i := range links {
+		result[i] = toUserURL(&links[i])
 	}
 
 	return result, nil
 }
 
+// findUserLink находит неудаленную ссылку, принадлежащую пользователю.
+// Возвращает ErrURLNotFound, если ссылка не найдена или принадлежит другому пользователю.
+func (s *Service) findUserLink(ctx context.Context, id string, userID uuid.UUID) (*model.Link, error) {
+	link, err := s.repo.FindLink(ctx, normalizeQuery(id))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrURLNotFound
+		}
+		return nil, err
+	}
+
+	if link.UserID != userID || link.IsDeleted {
+		return nil, ErrURLNotFound
+	}
+
+	return link, nil
+}
+
+// UpdateLink изменяет параметры ссылки по запросу ее владельца.
+// Принимает контекст, идентификатор ссылки, идентификатор пользователя и запрос на изменение.
+// Возвращает обновленное описание ссылки и ошибку, если операция не удалась.
+func (s *Service) UpdateLink(ctx context.Context, id string, userID uuid.UUID, req model.UpdateLinkRequest) (*model.UserURLResponse, error) {
+	link, err := s.findUserLink(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.OriginalURL.Set || req.Targets.Set || req.Rules.Set {
+		if err = checkDestinationChange(link); err != nil {
+			return nil, err
+		}
+	}
+	if req.OriginalURL.Set {
+		if link.IsBundle() {
+			return nil, errors.WithMessage(ErrInvalidOptions, "original_url is not supported for bundles")
+		}
+		if req.OriginalURL.Value == nil || *req.OriginalURL.Value == "" {
+			return nil, errors.WithMessage(ErrInvalidOptions, "original_url must not be empty")
+		}
+		link.Link = normalizeQuery(*req.OriginalURL.Value)
+	}
+	if req.Folder.Set {
+		link.Folder = ""
+		if req.Folder.Value != nil {
+			if link.Folder, err = normalizeFolder(*req.Folder.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req.Title.Set {
+		link.Title = ""
+		if req.Title.Value != nil {
+			link.Title = strings.TrimSpace(*req.Title.Value)
+		}
+		if len(link.Title) > maxTitleLength {
+			return nil, errors.WithMessage(ErrInvalidOptions, "title is too long")
+		}
+	}
+	if req.RedirectCode.Set {
+		link.RedirectCode = req.RedirectCode.Value
+	}
+	if req.CacheMaxAge.Set {
+		link.CacheMaxAge = req.CacheMaxAge.Value
+	}
+	if err = validateRedirect(link.RedirectCode, link.CacheMaxAge); err != nil {
+		return nil, err
+	}
+	if req.Passthrough.Set {
+		link.Passthrough = ""
+		if req.Passthrough.Value != nil {
+			link.Passthrough = *req.Passthrough.Value
+		}
+	}
+	if req.UTM.Set {
+		link.UTM = req.UTM.Value
+	}
+	if err = validatePassthrough(link.Passthrough, link.UTM); err != nil {
+		return nil, err
+	}
+	if req.Targets.Set {
+		link.Targets = nil
+		if req.Targets.Value != nil {
+			if link.Targets, err = normalizeTargets(*req.Targets.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req.Rules.Set {
+		link.Rules = nil
+		if req.Rules.Value != nil {
+			if link.Rules, err = normalizeRules(*req.Rules.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req.Entries.Set {
+		if !link.IsBundle() {
+			return nil, errors.WithMessage(ErrInvalidOptions, "entries are supported only for bundles")
+		}
+		var entries []model.BundleEntry
+		if req.Entries.Value != nil {
+			entries = *req.Entries.Value
+		}
+		if link.Entries, err = normalizeEntries(entries); err != nil {
+			return nil, err
+		}
+	}
+	if link.IsBundle() {
+		if err = validateBundle(link.Passthrough, link.Targets, link.Rules); err != nil {
+			return nil, err
+		}
+	}
+	if req.ActiveFrom.Set {
+		link.ActiveFrom = req.ActiveFrom.Value
+	}
+	if req.ActiveUntil.Set {
+		link.ActiveUntil = req.ActiveUntil.Value
+	}
+	if err = validateWindow(link.ActiveFrom, link.ActiveUntil); err != nil {
+		return nil, err
+	}
+
+	return s.saveLink(ctx, link)
+}
+
+// saveLink сохраняет измененную ссылку и формирует ее описание для владельца.
+// Возвращает ErrURLNotFound, если ссылка не найдена, и ErrURLExist, если новый URL уже сокращен.
+func (s *Service) saveLink(ctx context.Context, link *model.Link) (*model.UserURLResponse, error) {
+	err := s.repo.UpdateLink(ctx, link)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, ErrURLNotFound
+		case errors.Is(err, repository.ErrLinkExists):
+			return nil, ErrURLExist
+		}
+		return nil, err
+	}
+
+	resp := toUserURL(link)
+	return &resp, nil
+}
+
+// GetLinkHistory возвращает предыдущие значения оригинального URL ссылки пользователя.
+// Принимает контекст, идентификатор ссылки и идентификатор пользователя.
+// Возвращает список версий и ошибку, если операция не удалась.
+func (s *Service) GetLinkHistory(ctx context.Context, id string, userID uuid.UUID) ([]model.LinkVersion, error) {
+	link, err := s.findUserLink(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.repo.FindLinkHistory(ctx, link.ID)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		return []model.LinkVersion{}, nil
+	}
+
+	return history, nil
+}
+
+// recordVariant учитывает переход на вариант адреса ссылки.
+// Переходы накапливаются в памяти и сохраняются в хранилище методом FlushVariants,
+// поэтому переходы по ссылке не приводят к записи в хранилище на каждый запрос.
+func (s *Service) recordVariant(id, variant string) {
+	s.variantsMu.Lock()
+	defer s.variantsMu.Unlock()
+	if s.variants == nil {
+		s.variants = make(map[string]map[string]int64)
+	}
+	if s.variants[id] == nil {
+		s.variants[id] = make(map[string]int64)
+	}
+	s.variants[id][variant]++
+}
+
+// FlushVariants сохраняет накопленные переходы по вариантам адресов ссылок в хранилище.
+// Если сохранить не удалось, переходы возвращаются в очередь и будут сохранены при следующем вызове.
+func (s *Service) FlushVariants(ctx context.Context) error {
+	s.variantsMu.Lock()
+	variants := s.variants
+	s.variants = nil
+	s.variantsMu.Unlock()
+
+	if len(variants) == 0 {
+		return nil
+	}
+
+	err := s.repo.RecordVariants(ctx, variants)
+	if err != nil {
+		s.variantsMu.Lock()
+		if s.variants == nil {
+			s.variants = variants
+		} else {
+			for id, clicks := range variants {
+				if s.variants[id] == nil {
+					s.variants[id] = clicks
+					continue
+				}
+				for variant, count := range clicks {
+					s.variants[id][variant] += count
+				}
+			}
+		}
+		s.variantsMu.Unlock()
+		return errors.WithMessage(err, "error occurred while saving variant clicks")
+	}
+	return nil
+}
+
+// GetVariantStats возвращает количество переходов по вариантам адреса ссылки пользователя.
+// Для подборки вариантами считаются ее ссылки.
+// Сначала перечисляются текущие варианты ссылки, затем удаленные варианты, по которым были переходы.
+// Принимает контекст, идентификатор ссылки и идентификатор пользователя.
+// Возвращает список счетчиков и ошибку, если операция не удалась.
+func (s *Service) GetVariantStats(ctx context.Context, id string, userID uuid.UUID) ([]model.VariantStats, error) {
+	link, err := s.findUserLink(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.FlushVariants(ctx); err != nil {
+		return nil, err
+	}
+	clicks, err := s.repo.FindVariantClicks(ctx, link.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]model.VariantStats, 0, len(link.Targets)+len(link.Entries)+len(clicks))
+	for _, target := range link.Targets {
+		res = append(res, model.VariantStats{Name: target.Name, URL: target.URL, Weight: target.Weight})
+	}
+	for _, entry := range link.Entries {
+		res = append(res, model.VariantStats{Name: entry.Name, URL: entry.URL})
+	}
+	for _, c := range clicks {
+		i := slices.IndexFunc(res, func(v model.VariantStats) bool { return v.Name == c.Name })
+		if i < 0 {
+			res = append(res, model.VariantStats{Name: c.Name})
+			i = len(res) - 1
+		}
+		res[i].Clicks = c.Clicks
+	}
+
+	return res, nil
+}
+
+// RollbackLink восстанавливает оригинальный URL ссылки из указанной версии истории.
+// Текущее значение URL при этом также сохраняется в истории.
+// Принимает контекст, идентификатор ссылки, идентификатор пользователя и номер версии.
+// Возвращает обновленное описание ссылки и ошибку, если операция не удалась.
+func (s *Service) RollbackLink(ctx context.Context, id string, userID uuid.UUID, version int) (*model.UserURLResponse, error) {
+	link, err := s.findUserLink(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkDestinationChange(link); err != nil {
+		return nil, err
+	}
+	history, err := s.repo.FindLinkHistory(ctx, link.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range history {
+		if v.Version == version {
+			link.Link = v.OriginalURL
+			return s.saveLink(ctx, link)
+		}
+	}
+
+	return nil, ErrVersionNotFound
+}
+
 // DeleteURLs помечает указанные URL как удаленные.
 // Принимает контекст, массив идентификаторов URL и идентификатор пользователя.
 // Возвращает количество удаленных URL и ошибку, если операция не удалась.
@@ -186,3 +1059,104 @@ func (s *Service) DeleteURLs(ctx context.Context, ids []string, userID uuid.UUID
 	util.GetLogger().Infof("marked %d URLs as deleted", count)
 	return count, nil
 }
+
+// RestoreURLs снимает пометку удаления с указанных URL пользователя.
+// Восстанавливаются только ссылки, удаленные в пределах срока хранения из конфигурации,
+// оригинальный URL которых не был с тех пор сокращен другой ссылкой.
+// Принимает контекст, массив идентификаторов URL и идентификатор пользователя.
+// Возвращает итог восстановления и ошибку, если операция не удалась.
+func (s *Service) RestoreURLs(ctx context.Context, ids []string, userID uuid.UUID) (*model.RestoreResult, error) {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	var deletedAfter time.Time
+	if window := util.GetConfig().Links.RestoreWindow; window > 0 {
+		deletedAfter = time.Now().Add(-time.Duration(window) * time.Hour)
+	}
+
+	// Ссылки, оригинальный URL которых уже сокращен, репозиторий возвращает в conflicts,
+	// а не ошибкой, поэтому восстановление остальных ссылок не прерывается.
+	restored, conflicts, err := s.repo.RestoreURLs(ctx, ids, userID, deletedAfter)
+	if err != nil {
+		util.GetLogger().Errorf("failed to restore URLs: %v", err)
+		return nil, err
+	}
+
+	res := &model.RestoreResult{Restored: restored, Conflicts: conflicts}
+	if res.Restored == nil {
+		res.Restored = []string{}
+	}
+	for _, id := range ids {
+		if !slices.Contains(restored, id) && !slices.Contains(conflicts, id) {
+			res.Skipped = append(res.Skipped, id)
+		}
+	}
+
+	util.GetLogger().Infof("restored %d URLs", len(restored))
+	return res, nil
+}
+
+// AssignLabels назначает и снимает метки, а также меняет папку у ссылок пользователя.
+// Принимает контекст, идентификатор пользователя и запрос на изменение.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (s *Service) AssignLabels(ctx context.Context, userID uuid.UUID, req model.LabelRequest) (int, error) {
+	var err error
+	if req.AddTags, err = normalizeTags(req.AddTags); err != nil {
+		return 0, err
+	}
+	if req.RemoveTags, err = normalizeTags(req.RemoveTags); err != nil {
+		return 0, err
+	}
+	if req.Folder.Set && req.Folder.Value != nil {
+		folder, err := normalizeFolder(*req.Folder.Value)
+		if err != nil {
+			return 0, err
+		}
+		req.Folder.Value = &folder
+	}
+
+	return s.repo.AssignLabels(ctx, userID, req)
+}
+
+// GetUserTags возвращает метки пользователя с количеством ссылок для каждой.
+// Принимает контекст и идентификатор пользователя.
+// Возвращает массив меток и ошибку, если операция не удалась.
+func (s *Service) GetUserTags(ctx context.Context, userID uuid.UUID) ([]model.TagInfo, error) {
+	tags, err := s.repo.FindUserTags(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tags == nil {
+		return []model.TagInfo{}, nil
+	}
+
+	return tags, nil
+}
+
+// RenameTag переименовывает метку на всех ссылках пользователя.
+// Принимает контекст, идентификатор пользователя, текущее и новое название метки.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (s *Service) RenameTag(ctx context.Context, userID uuid.UUID, from, to string) (int, error) {
+	tags, err := normalizeTags([]string{from, to})
+	if err != nil {
+		return 0, err
+	}
+	if len(tags) == 1 {
+		return 0, nil
+	}
+
+	return s.repo.RenameTag(ctx, userID, tags[0], tags[1])
+}
+
+// DeleteTag снимает метку со всех ссылок пользователя.
+// Принимает контекст, идентификатор пользователя и название метки.
+// Возвращает количество затронутых ссылок и ошибку, если операция не удалась.
+func (s *Service) DeleteTag(ctx context.Context, userID uuid.UUID, tag string) (int, error) {
+	tags, err := normalizeTags([]string{tag})
+	if err != nil {
+		return 0, err
+	}
+
+	return s.repo.DeleteTag(ctx, userID, tags[0])
+}
@@ -2,14 +2,19 @@ package service_test
 
 import (
 	"context"
+	"net/http"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/ypxd99/yandex-practicm/internal/mocks"
 	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
 	"github.com/ypxd99/yandex-practicm/internal/service"
 	"github.com/ypxd99/yandex-practicm/util"
 )
@@ -25,11 +30,11 @@ func TestShorterLink(t *testing.T) {
 		svc := service.InitService(mockRepo)
 
 		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, IsDeleted: false}
-		mockRepo.On("CreateLink", ctx, mock.Anything, "https://example.com", testUserID).
+		mockRepo.On("CreateLink", ctx, mock.Anything, "https://example.com", testUserID, model.LinkOptions{}).
 			Return(expected, nil).
 			Once()
 
-		id, err := svc.ShorterLink(ctx, "https://example.com", testUserID)
+		id, err := svc.ShorterLink(ctx, "https://example.com", testUserID, model.LinkOptions{})
 		if err != nil {
 			if !errors.Is(err, service.ErrURLExist) {
 				assert.NoError(t, err)
@@ -44,11 +49,11 @@ func TestShorterLink(t *testing.T) {
 		mockRepo := new(mocks.MockLinkRepository)
 		svc := service.InitService(mockRepo)
 
-		mockRepo.On("CreateLink", ctx, mock.Anything, "https://error.com", testUserID).
+		mockRepo.On("CreateLink", ctx, mock.Anything, "https://error.com", testUserID, model.LinkOptions{}).
 			Return((*model.Link)(nil), errors.New("db error")).
 			Once()
 
-		_, err := svc.ShorterLink(ctx, "https://error.com", testUserID)
+		_, err := svc.ShorterLink(ctx, "https://error.com", testUserID, model.LinkOptions{})
 
 		assert.Error(t, err)
 		mockRepo.AssertExpectations(t)
@@ -70,10 +75,10 @@ func TestFindLink(t *testing.T) {
 			Return(expected, nil).
 			Once()
 
-		url, err := svc.FindLink(ctx, "abc123")
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{})
 
 		assert.NoError(t, err)
-		assert.Equal(t, "https://example.com", url)
+		assert.Equal(t, "https://example.com", redirect.URL)
 		mockRepo.AssertExpectations(t)
 	})
 
@@ -85,13 +90,385 @@ func TestFindLink(t *testing.T) {
 			Return((*model.Link)(nil), errors.New("not found")).
 			Once()
 
-		_, err := svc.FindLink(ctx, "notfound")
+		_, err := svc.FindLink(ctx, "notfound", model.Visit{})
 
 		assert.Error(t, err)
 		mockRepo.AssertExpectations(t)
 	})
 }
 
+func TestGetPublicShortURL(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+
+	t.Run("existing link", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		clicks := int64(0)
+		mockRepo.On("FindLink", ctx, "abc123").
+			Return(&model.Link{ID: "abc123", Link: "https://example.com", ClicksLeft: &clicks}, nil).
+			Once()
+
+		url, err := svc.GetPublicShortURL(ctx, "abc123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, cfg.Server.BaseURL+"/abc123", url)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("deleted link", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		mockRepo.On("FindLink", ctx, "abc123").
+			Return(&model.Link{ID: "abc123", Link: "https://example.com", IsDeleted: true}, nil).
+			Once()
+
+		_, err := svc.GetPublicShortURL(ctx, "abc123")
+
+		assert.ErrorIs(t, err, service.ErrURLDeleted)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestFindLinkMaxClicks(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	testUserID := uuid.New()
+	left := int64(1)
+
+	t.Run("click consumed", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, ClicksLeft: &left}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+		mockRepo.On("ConsumeClick", ctx, "abc123").Return(true, nil).Once()
+
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", redirect.URL)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("limit reached", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, ClicksLeft: &left}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+		mockRepo.On("ConsumeClick", ctx, "abc123").Return(false, nil).Once()
+
+		_, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.ErrorIs(t, err, service.ErrURLExhausted)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid max clicks", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+		zero := int64(0)
+
+		_, err := svc.ShorterLink(ctx, "https://example.com", testUserID, model.LinkOptions{MaxClicks: &zero})
+
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestFindLinkRedirectPolicy(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	t.Run("server defaults", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, cfg.Links.RedirectCode, redirect.Code)
+		assert.Equal(t, cfg.Links.CacheMaxAge, redirect.MaxAge)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("per link policy capped by window", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		code, maxAge := 308, 86400
+		until := time.Now().Add(time.Minute)
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID,
+			RedirectCode: &code, CacheMaxAge: &maxAge, ActiveUntil: &until}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 308, redirect.Code)
+		assert.LessOrEqual(t, redirect.MaxAge, 60)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid redirect code", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		code := 200
+		_, err := svc.ShorterLink(ctx, "https://example.com", testUserID, model.LinkOptions{RedirectCode: &code})
+
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("invalid server default code", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		prev := cfg.Links.RedirectCode
+		cfg.Links.RedirectCode = 200
+		defer func() { cfg.Links.RedirectCode = prev }()
+
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTemporaryRedirect, redirect.Code)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestFindLinkPassthrough(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	visit := model.Visit{
+		Path:  "/docs/intro",
+		Query: url.Values{"x": {"1"}, "ref": {"visitor"}},
+	}
+	utm := &model.UTMParams{Source: "print", Campaign: "launch"}
+
+	tests := []struct {
+		name     string
+		link     model.Link
+		visit    model.Visit
+		expected string
+		err      error
+	}{
+		{
+			name:     "path and query",
+			link:     model.Link{Link: "https://example.com/base?ref=owner", Passthrough: model.PassthroughPath},
+			visit:    visit,
+			expected: "https://example.com/base/docs/intro?ref=owner&x=1",
+		},
+		{
+			name:     "query only",
+			link:     model.Link{Link: "https://example.com/base", Passthrough: model.PassthroughQuery},
+			visit:    model.Visit{Query: visit.Query},
+			expected: "https://example.com/base?ref=visitor&x=1",
+		},
+		{
+			name:     "utm without passthrough",
+			link:     model.Link{Link: "https://example.com/base?utm_source=old", UTM: utm},
+			visit:    model.Visit{Query: visit.Query},
+			expected: "https://example.com/base?utm_campaign=launch&utm_source=print",
+		},
+		{
+			name:  "path not allowed",
+			link:  model.Link{Link: "https://example.com/base", Passthrough: model.PassthroughQuery},
+			visit: visit,
+			err:   service.ErrURLNotFound,
+		},
+		{
+			name:  "path traversal",
+			link:  model.Link{Link: "https://example.com/base", Passthrough: model.PassthroughPath},
+			visit: model.Visit{Path: "/../admin"},
+			err:   service.ErrURLNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockLinkRepository)
+			svc := service.InitService(mockRepo)
+
+			link := tt.link
+			link.ID = "abc123"
+			link.UserID = testUserID
+			mockRepo.On("FindLink", ctx, "abc123").Return(&link, nil).Once()
+
+			redirect, err := svc.FindLink(ctx, "abc123", tt.visit)
+
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expected, redirect.URL)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPreviewLink(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	t.Run("click not consumed", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		left := int64(1)
+		created := time.Now().Add(-time.Hour)
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID,
+			ClicksLeft: &left, Title: "Docs", TimeCreated: created}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		preview, err := svc.PreviewLink(ctx, "abc123")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", preview.OriginalURL)
+		assert.Equal(t, "Docs", preview.Title)
+		assert.Equal(t, created, *preview.CreatedAt)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("exhausted link", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		left := int64(0)
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, ClicksLeft: &left}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		_, err := svc.PreviewLink(ctx, "abc123")
+
+		assert.ErrorIs(t, err, service.ErrURLExhausted)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestFindLinkActiveWindow(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	testUserID := uuid.New()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	t.Run("not active yet", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, ActiveFrom: &future}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		_, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.ErrorIs(t, err, service.ErrURLNotActive)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, ActiveUntil: &past}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		_, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.ErrorIs(t, err, service.ErrURLExpired)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("inside window", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		expected := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, ActiveFrom: &past, ActiveUntil: &future}
+		mockRepo.On("FindLink", ctx, "abc123").Return(expected, nil).Once()
+
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com", redirect.URL)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestUpdateLink(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	testUserID := uuid.New()
+	from := time.Now().Add(time.Hour).UTC()
+
+	t.Run("update window", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		stored := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID}
+		mockRepo.On("FindLink", ctx, "abc123").Return(stored, nil).Once()
+		mockRepo.On("UpdateLink", ctx, mock.MatchedBy(func(l *model.Link) bool {
+			return l.ActiveFrom != nil && l.ActiveFrom.Equal(from) && l.ActiveUntil == nil
+		})).Return(nil).Once()
+
+		resp, err := svc.UpdateLink(ctx, "abc123", testUserID, model.UpdateLinkRequest{
+			ActiveFrom: model.Optional[time.Time]{Value: &from, Set: true},
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, &from, resp.ActiveFrom)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("foreign link", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		stored := &model.Link{ID: "abc123", Link: "https://example.com", UserID: uuid.New()}
+		mockRepo.On("FindLink", ctx, "abc123").Return(stored, nil).Once()
+
+		_, err := svc.UpdateLink(ctx, "abc123", testUserID, model.UpdateLinkRequest{})
+
+		assert.ErrorIs(t, err, service.ErrURLNotFound)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("empty window", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		until := from.Add(-time.Minute)
+		stored := &model.Link{ID: "abc123", Link: "https://example.com", UserID: testUserID, ActiveFrom: &from}
+		mockRepo.On("FindLink", ctx, "abc123").Return(stored, nil).Once()
+
+		_, err := svc.UpdateLink(ctx, "abc123", testUserID, model.UpdateLinkRequest{
+			ActiveUntil: model.Optional[time.Time]{Value: &until, Set: true},
+		})
+
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
 func TestBatchShorten(t *testing.T) {
 	cfg := util.GetConfig()
 	util.InitLogger(cfg.Logger)
@@ -134,11 +511,11 @@ func TestGetUserURLs(t *testing.T) {
 			{ID: "def456", Link: "https://yandex.ru", UserID: testUserID, IsDeleted: false},
 		}
 
-		mockRepo.On("FindUserLinks", ctx, testUserID).
+		mockRepo.On("FindUserLinks", ctx, testUserID, model.LinkFilter{}).
 			Return(links, nil).
 			Once()
 
-		result, err := svc.GetUserURLs(ctx, testUserID)
+		result, err := svc.GetUserURLs(ctx, testUserID, model.LinkFilter{})
 
 		assert.NoError(t, err)
 		assert.Len(t, result, 2)
@@ -152,11 +529,11 @@ func TestGetUserURLs(t *testing.T) {
 
 		var emptyLinks []model.Link
 
-		mockRepo.On("FindUserLinks", ctx, testUserID).
+		mockRepo.On("FindUserLinks", ctx, testUserID, model.LinkFilter{}).
 			Return(emptyLinks, nil).
 			Once()
 
-		result, err := svc.GetUserURLs(ctx, testUserID)
+		result, err := svc.GetUserURLs(ctx, testUserID, model.LinkFilter{})
 
 		assert.NoError(t, err)
 		assert.Empty(t, result)
@@ -221,3 +598,390 @@ func TestDeleteURLs(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 }
+
+func TestFindLinkTargets(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+
+	link := &model.Link{
+		ID:     "abc123",
+		Link:   "https://example.com",
+		UserID: uuid.New(),
+		Targets: []model.LinkTarget{
+			{Name: "a", URL: "https://a.example.com", Weight: 3},
+			{Name: "b", URL: "https://b.example.com", Weight: 1},
+		},
+	}
+
+	t.Run("sticky visitor", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		mockRepo.On("FindLink", ctx, "abc123").Return(link, nil).Twice()
+
+		first, err := svc.FindLink(ctx, "abc123", model.Visit{VisitorID: "visitor"})
+		assert.NoError(t, err)
+		second, err := svc.FindLink(ctx, "abc123", model.Visit{VisitorID: "visitor"})
+		assert.NoError(t, err)
+
+		mockRepo.On("RecordVariants", ctx, map[string]map[string]int64{
+			"abc123": {first.Variant: 2},
+		}).Return(nil).Once()
+		assert.NoError(t, svc.FlushVariants(ctx))
+		assert.NoError(t, svc.FlushVariants(ctx))
+
+		assert.NotEmpty(t, first.Variant)
+		assert.Equal(t, first.Variant, second.Variant)
+		assert.Equal(t, first.URL, second.URL)
+		assert.Equal(t, 0, first.MaxAge)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("weighted distribution", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		mockRepo.On("FindLink", ctx, "abc123").Return(link, nil)
+
+		counts := map[string]int{}
+		for i := 0; i < 4000; i++ {
+			redirect, err := svc.LookupLink(ctx, "abc123", model.Visit{VisitorID: uuid.New().String()})
+			assert.NoError(t, err)
+			counts[redirect.Variant]++
+		}
+
+		assert.InDelta(t, 3000, counts["a"], 200)
+		assert.InDelta(t, 1000, counts["b"], 200)
+		assert.NoError(t, svc.FlushVariants(ctx))
+		mockRepo.AssertNotCalled(t, "RecordVariants", mock.Anything, mock.Anything)
+	})
+
+	t.Run("single target rejected", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		_, err := svc.ShorterLink(ctx, "https://example.com", uuid.New(), model.LinkOptions{
+			Targets: []model.LinkTarget{{URL: "https://a.example.com", Weight: 1}},
+		})
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+		mockRepo.AssertNotCalled(t, "CreateLink")
+	})
+}
+
+func TestFindLinkRules(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+
+	const (
+		iPhone  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+		android = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+		desktop = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+	)
+
+	hour := time.Now().UTC().Hour()
+	rules := []model.TargetRule{
+		{Platform: model.PlatformIOS, URL: "https://apps.apple.com/app/id1"},
+		{Platform: model.PlatformAndroid, URL: "https://play.google.com/store/apps/details?id=app"},
+		{Language: "de", URL: "https://example.com/de"},
+		{Language: "fr", Hours: &model.HourRange{From: hour, To: (hour + 2) % 24}, URL: "https://example.com/fr-now"},
+		{Language: "fr", Hours: &model.HourRange{From: (hour + 2) % 24, To: hour}, URL: "https://example.com/fr-later"},
+	}
+
+	tests := []struct {
+		name     string
+		visit    model.Visit
+		expected string
+	}{
+		{
+			name:     "ios",
+			visit:    model.Visit{UserAgent: iPhone},
+			expected: "https://apps.apple.com/app/id1",
+		},
+		{
+			name:     "android",
+			visit:    model.Visit{UserAgent: android, AcceptLanguage: "de-DE"},
+			expected: "https://play.google.com/store/apps/details?id=app",
+		},
+		{
+			name:     "desktop language",
+			visit:    model.Visit{UserAgent: desktop, AcceptLanguage: "fr;q=0.9, de-AT;q=0.8"},
+			expected: "https://example.com/de",
+		},
+		{
+			name:     "time of day",
+			visit:    model.Visit{UserAgent: desktop, AcceptLanguage: "fr-CA"},
+			expected: "https://example.com/fr-now",
+		},
+		{
+			name:     "rejected language",
+			visit:    model.Visit{UserAgent: desktop, AcceptLanguage: "en, de;q=0"},
+			expected: "https://example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mocks.MockLinkRepository)
+			svc := service.InitService(mockRepo)
+
+			link := &model.Link{ID: "abc123", Link: "https://example.com", UserID: uuid.New(), Rules: rules}
+			mockRepo.On("FindLink", ctx, "abc123").Return(link, nil).Once()
+
+			redirect, err := svc.FindLink(ctx, "abc123", tt.visit)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, redirect.URL)
+			assert.Equal(t, 0, redirect.MaxAge)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("invalid rule", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		_, err := svc.ShorterLink(ctx, "https://example.com", uuid.New(), model.LinkOptions{
+			Rules: []model.TargetRule{{Platform: "windows-phone", URL: "https://example.com/wp"}},
+		})
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+
+		_, err = svc.ShorterLink(ctx, "https://example.com", uuid.New(), model.LinkOptions{
+			Rules: []model.TargetRule{{URL: "https://example.com/any"}},
+		})
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+		mockRepo.AssertNotCalled(t, "CreateLink")
+	})
+}
+
+func TestHourRange(t *testing.T) {
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+
+	night := model.HourRange{From: 22, To: 6}
+	assert.True(t, night.Contains(day.Add(23*time.Hour)))
+	assert.True(t, night.Contains(day.Add(5*time.Hour)))
+	assert.False(t, night.Contains(day.Add(6*time.Hour)))
+
+	work := model.HourRange{From: 9, To: 18}
+	assert.True(t, work.Contains(day.Add(9*time.Hour)))
+	assert.False(t, work.Contains(day.Add(18*time.Hour)))
+}
+
+func TestRestoreURLs(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	testUserID := uuid.New()
+
+	t.Run("retention window", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		window := time.Duration(cfg.Links.RestoreWindow) * time.Hour
+		mockRepo.On("RestoreURLs", ctx, []string{"a", "b", "c"}, testUserID, mock.MatchedBy(func(after time.Time) bool {
+			return time.Since(after) >= window && time.Since(after) < window+time.Minute
+		})).
+			Return([]string{"a"}, []string{"b"}, nil).
+			Once()
+
+		res, err := svc.RestoreURLs(ctx, []string{"c", "a", "b", "a"}, testUserID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, &model.RestoreResult{Restored: []string{"a"}, Conflicts: []string{"b"}, Skipped: []string{"c"}}, res)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("repository error", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		mockRepo.On("RestoreURLs", ctx, []string{"a"}, testUserID, mock.Anything).
+			Return([]string(nil), []string(nil), errors.New("db error")).
+			Once()
+
+		res, err := svc.RestoreURLs(ctx, []string{"a"}, testUserID)
+
+		assert.Error(t, err)
+		assert.Nil(t, res)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestRedeemTransfer(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+	owner, recipient := uuid.New(), uuid.New()
+
+	newToken := func(t *testing.T, svc *service.Service, mockRepo *mocks.MockLinkRepository) string {
+		mockRepo.On("FindLink", ctx, "abc123").
+			Return(&model.Link{ID: "abc123", Link: "https://example.com", UserID: owner}, nil).
+			Once()
+		token, err := svc.CreateTransfer(ctx, []string{"abc123"}, owner)
+		assert.NoError(t, err)
+		return token.Token
+	}
+
+	t.Run("successful redeem", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+		token := newToken(t, svc, mockRepo)
+
+		mockRepo.On("TransferLinks", ctx, mock.AnythingOfType("string"), []string{"abc123"}, owner, recipient).
+			Return([]string{"abc123"}, nil).
+			Once()
+
+		res, err := svc.RedeemTransfer(ctx, token, recipient)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"abc123"}, res.Transferred)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("tampered token", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+		token := newToken(t, svc, mockRepo)
+
+		_, err := svc.RedeemTransfer(ctx, token[:len(token)-2]+"xx", recipient)
+
+		assert.ErrorIs(t, err, service.ErrTransferInvalid)
+		mockRepo.AssertNotCalled(t, "TransferLinks")
+	})
+
+	t.Run("token already used", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+		token := newToken(t, svc, mockRepo)
+
+		mockRepo.On("TransferLinks", ctx, mock.AnythingOfType("string"), []string{"abc123"}, owner, recipient).
+			Return([]string(nil), repository.ErrTokenUsed).
+			Once()
+
+		_, err := svc.RedeemTransfer(ctx, token, recipient)
+
+		assert.ErrorIs(t, err, service.ErrTransferUsed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("empty secret key", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+		token := newToken(t, svc, mockRepo)
+
+		secret := cfg.Auth.SecretKey
+		cfg.Auth.SecretKey = ""
+		defer func() { cfg.Auth.SecretKey = secret }()
+
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"ids": []string{"abc123"},
+			"sub": owner.String(),
+			"aud": "link-transfer",
+			"jti": uuid.NewString(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte(""))
+		assert.NoError(t, err)
+
+		_, err = svc.RedeemTransfer(ctx, forged, recipient)
+		assert.ErrorIs(t, err, service.ErrTransferInvalid)
+
+		mockRepo.On("TransferLinks", ctx, mock.AnythingOfType("string"), []string{"abc123"}, owner, recipient).
+			Return([]string{"abc123"}, nil).
+			Once()
+
+		res, err := svc.RedeemTransfer(ctx, token, recipient)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"abc123"}, res.Transferred)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestFindLinkBundle(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+	ctx := context.Background()
+
+	maxClicks := int64(5)
+	link := &model.Link{
+		ID:         "abc123",
+		UserID:     uuid.New(),
+		Title:      "My links",
+		ClicksLeft: &maxClicks,
+		Entries: []model.BundleEntry{
+			{Name: "1", Title: "Blog", URL: "https://blog.example.com"},
+			{Name: "shop", Title: "Shop", URL: "https://shop.example.com"},
+		},
+	}
+
+	t.Run("page", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		mockRepo.On("FindLink", ctx, "abc123").Return(link, nil).Once()
+		mockRepo.On("ConsumeClick", ctx, "abc123").Return(true, nil).Once()
+
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{})
+		assert.NoError(t, err)
+		if assert.NotNil(t, redirect.Bundle) {
+			assert.Equal(t, "My links", redirect.Bundle.Title)
+			assert.Len(t, redirect.Bundle.Entries, 2)
+			assert.Equal(t, cfg.Server.BaseURL+"/abc123?entry=shop", redirect.Bundle.Entries[1].URL)
+		}
+		assert.Empty(t, redirect.URL)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("entry click", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		mockRepo.On("FindLink", ctx, "abc123").Return(link, nil).Once()
+		mockRepo.On("RecordVariants", ctx, map[string]map[string]int64{"abc123": {"shop": 1}}).Return(nil).Once()
+
+		redirect, err := svc.FindLink(ctx, "abc123", model.Visit{Query: url.Values{"entry": {"shop"}}})
+		assert.NoError(t, err)
+		assert.NoError(t, svc.FlushVariants(ctx))
+		assert.Nil(t, redirect.Bundle)
+		assert.Equal(t, "https://shop.example.com", redirect.URL)
+		assert.Equal(t, "shop", redirect.Variant)
+		assert.Equal(t, 0, redirect.MaxAge)
+		mockRepo.AssertNotCalled(t, "ConsumeClick", mock.Anything, mock.Anything)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("unknown entry", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		mockRepo.On("FindLink", ctx, "abc123").Return(link, nil).Once()
+
+		_, err := svc.FindLink(ctx, "abc123", model.Visit{Query: url.Values{"entry": {"missing"}}})
+		assert.ErrorIs(t, err, service.ErrURLNotFound)
+	})
+
+	t.Run("invalid bundle", func(t *testing.T) {
+		mockRepo := new(mocks.MockLinkRepository)
+		svc := service.InitService(mockRepo)
+
+		_, err := svc.CreateBundle(ctx, uuid.New(), model.LinkOptions{})
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+
+		_, err = svc.CreateBundle(ctx, uuid.New(), model.LinkOptions{
+			Entries: []model.BundleEntry{{URL: "https://example.com"}},
+		})
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+
+		_, err = svc.CreateBundle(ctx, uuid.New(), model.LinkOptions{
+			Passthrough: model.PassthroughPath,
+			Entries:     []model.BundleEntry{{Title: "Home", URL: "https://example.com"}},
+		})
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+
+		_, err = svc.ShorterLink(ctx, "https://example.com", uuid.New(), model.LinkOptions{
+			Entries: []model.BundleEntry{{Title: "Home", URL: "https://example.com"}},
+		})
+		assert.ErrorIs(t, err, service.ErrInvalidOptions)
+		mockRepo.AssertNotCalled(t, "CreateLink")
+	})
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// ErrTransferInvalid ошибка, возникающая при предъявлении поддельного, просроченного или чужого токена передачи
+// ErrTransferUsed ошибка, возникающая при повторном предъявлении токена передачи
+var (
+	ErrTransferInvalid = errors.New("transfer token is invalid")
+	ErrTransferUsed    = errors.New("transfer token already redeemed")
+)
+
+const (
+	// transferAudience назначение токена передачи ссылок, отличающее его от токена авторизации
+	transferAudience = "link-transfer"
+	// defaultTransferTTL время жизни токена передачи, если оно не задано в конфигурации
+	defaultTransferTTL = 24 * time.Hour
+	// maxTransferLinks максимальное количество ссылок в одном токене передачи
+	maxTransferLinks = 1000
+)
+
+// transferClaims представляет содержимое токена передачи ссылок.
+// Отправитель хранится в поле sub, идентификатор токена в поле jti.
+type transferClaims struct {
+	// IDs идентификаторы передаваемых ссылок
+	IDs []string `json:"ids"`
+	jwt.RegisteredClaims
+}
+
+// CreateTransfer выпускает подписанный токен передачи ссылок пользователя.
+// Все ссылки должны принадлежать пользователю и не быть удаленными.
+// Принимает контекст, идентификаторы ссылок и идентификатор пользователя.
+// Возвращает токен передачи и ошибку, если операция не удалась.
+func (s *Service) CreateTransfer(ctx context.Context, ids []string, userID uuid.UUID) (*model.TransferToken, error) {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) == 0 || len(ids) > maxTransferLinks {
+		return nil, errors.WithMessagef(ErrInvalidOptions, "ids must contain from 1 to %d items", maxTransferLinks)
+	}
+
+	for i, id := range ids {
+		link, err := s.findUserLink(ctx, id, userID)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "link %s", id)
+		}
+		ids[i] = link.ID
+	}
+
+	ttl := defaultTransferTTL
+	if hours := util.GetConfig().Links.TransferTTL; hours > 0 {
+		ttl = time.Duration(hours) * time.Hour
+	}
+	now := time.Now()
+	expiresAt := now.Add(ttl).Truncate(time.Second)
+
+	claims := &transferClaims{
+		IDs: ids,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{transferAudience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := middleware.GetKeySet().SignClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TransferToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// RedeemTransfer принимает ссылки по токену передачи и делает пользователя их владельцем.
+// Ссылки, которые отправитель успел удалить или передать иначе, пропускаются.
+// Принимает контекст, токен передачи и идентификатор получателя.
+// Возвращает итог передачи и ошибку, если операция не удалась.
+func (s *Service) RedeemTransfer(ctx context.Context, token string, userID uuid.UUID) (*model.TransferResult, error) {
+	claims := &transferClaims{}
+	err := middleware.GetKeySet().ParseClaims(token, claims)
+	if err != nil {
+		return nil, errors.WithMessage(ErrTransferInvalid, err.Error())
+	}
+	if !claims.VerifyAudience(transferAudience, true) || claims.ID == "" {
+		return nil, ErrTransferInvalid
+	}
+
+	from, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, ErrTransferInvalid
+	}
+	if from == userID {
+		return nil, errors.WithMessage(ErrTransferInvalid, "links already belong to the user")
+	}
+
+	moved, err := s.repo.TransferLinks(ctx, claims.ID, claims.IDs, from, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenUsed) {
+			return nil, ErrTransferUsed
+		}
+		util.GetLogger().Errorf("failed to transfer links: %v", err)
+		return nil, err
+	}
+	if moved == nil {
+		moved = []string{}
+	}
+
+	util.GetLogger().Infof("transferred %d links from %s to %s by token %s", len(moved), from, userID, claims.ID)
+	return &model.TransferResult{Transferred: moved}, nil
+}
+
+// GetTransfers возвращает журнал передачи ссылок, в которых пользователь был отправителем или получателем.
+// Принимает контекст и идентификатор пользователя.
+// Возвращает список записей журнала и ошибку, если операция не удалась.
+func (s *Service) GetTransfers(ctx context.Context, userID uuid.UUID) ([]model.LinkTransfer, error) {
+	transfers, err := s.repo.FindTransfers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if transfers == nil {
+		transfers = []model.LinkTransfer{}
+	}
+
+	return transfers, nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrEmailTaken ошибка, возникающая при регистрации уже занятого адреса электронной почты
+// ErrInvalidCredentials ошибка, возникающая при входе с неизвестным адресом или неверным паролем
+var (
+	ErrEmailTaken         = errors.New("email already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+const (
+	// passwordCost стоимость хэширования паролей bcrypt
+	passwordCost = 12
+	// minPasswordLength минимальная длина пароля
+	minPasswordLength = 8
+	// maxPasswordLength максимальная длина пароля в байтах, больше bcrypt не учитывает
+	maxPasswordLength = 72
+	// maxEmailLength максимальная длина адреса электронной почты
+	maxEmailLength = 320
+)
+
+// dummyPasswordHash хэш, с которым сравнивается пароль при входе с неизвестным адресом,
+// чтобы время ответа не выдавало, зарегистрирован ли адрес.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost)
+	return hash
+})
+
+// normalizeEmail проверяет адрес электронной почты и приводит его к нижнему регистру.
+// Возвращает ErrInvalidOptions, если адрес некорректен.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxEmailLength {
+		return "", errors.WithMessage(ErrInvalidOptions, "invalid email")
+	}
+	return strings.ToLower(email), nil
+}
+
+// SignUp регистрирует учетную запись по адресу электронной почты и паролю.
+// Ссылки и API-ключи анонимного пользователя anonID переносятся в новую учетную запись.
+// Если anonID сам является учетной записью, перенос не выполняется.
+// Принимает контекст, идентификатор текущего пользователя и данные для регистрации.
+// Возвращает учетную запись, количество перенесенных ссылок, ErrInvalidOptions,
+// если адрес или пароль некорректны, и ErrEmailTaken, если адрес уже зарегистрирован.
+func (s *Service) SignUp(ctx context.Context, anonID uuid.UUID, creds model.Credentials) (*model.User, int, error) {
+	email, err := normalizeEmail(creds.Email)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(creds.Password) < minPasswordLength || len(creds.Password) > maxPasswordLength {
+		return nil, 0, errors.WithMessagef(ErrInvalidOptions,
+			"password must contain from %d to %d bytes", minPasswordLength, maxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), passwordCost)
+	if err != nil {
+		return nil, 0, errors.WithMessage(err, "error occurred while hashing password")
+	}
+
+	user := model.User{ID: uuid.New(), Email: email, PasswordHash: string(hash)}
+	if err = s.repo.CreateUser(ctx, &user); err != nil {
+		if errors.Is(err, repository.ErrUserExists) {
+			return nil, 0, ErrEmailTaken
+		}
+		return nil, 0, err
+	}
+
+	merged, err := s.absorbAnonymous(ctx, anonID, user.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &user, merged, nil
+}
+
+// Login выполняет вход в учетную запись по адресу электронной почты и паролю.
+// Ссылки и API-ключи анонимного пользователя anonID переносятся в учетную запись.
+// Если anonID сам является учетной записью, перенос не выполняется.
+// Принимает контекст, идентификатор текущего пользователя и данные для входа.
+// Возвращает учетную запись, количество перенесенных ссылок и ErrInvalidCredentials,
+// если адрес не зарегистрирован или пароль неверен.
+func (s *Service) Login(ctx context.Context, anonID uuid.UUID, creds model.Credentials) (*model.User, int, error) {
+	email, err := normalizeEmail(creds.Email)
+	if err != nil {
+		return nil, 0, ErrInvalidCredentials
+	}
+
+	user, err := s.repo.FindUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(creds.Password))
+			return nil, 0, ErrInvalidCredentials
+		}
+		return nil, 0, err
+	}
+	if err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(creds.Password)); err != nil {
+		return nil, 0, ErrInvalidCredentials
+	}
+
+	merged, err := s.absorbAnonymous(ctx, anonID, user.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return user, merged, nil
+}
+
+// UserRole возвращает сохраненную роль учетной записи, которая записывается в ее токены.
+// Роль назначается вне сервиса, у анонимных пользователей роли нет.
+// Возвращает роль и ошибку, если операция не удалась.
+func (s *Service) UserRole(ctx context.Context, userID uuid.UUID) (string, error) {
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return user.Role, nil
+}
+
+// absorbAnonymous переносит данные анонимного пользователя anonID в учетную запись userID.
+// Данные другой учетной записи не переносятся.
+// Возвращает количество перенесенных ссылок и ошибку, если операция не удалась.
+func (s *Service) absorbAnonymous(ctx context.Context, anonID, userID uuid.UUID) (int, error) {
+	if anonID == uuid.Nil || anonID == userID {
+		return 0, nil
+	}
+
+	_, err := s.repo.FindUserByID(ctx, anonID)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return 0, err
+	}
+
+	return s.repo.MergeUser(ctx, anonID, userID)
+}
+
+// RevokeToken отзывает JWT токен при выходе пользователя.
+// Токен остается в списке отозванных до момента своего истечения.
+// Возвращает ошибку, если операция не удалась.
+func (s *Service) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return ErrInvalidOptions
+	}
+	return s.repo.RevokeToken(ctx, jti, expiresAt)
+}
+
+// RevokeUserTokens отзывает все JWT токены пользователя, выпущенные до текущего момента,
+// включая токены, выданные на других устройствах и через выпуск токена для API.
+// Момент отзыва округляется вниз до секунды, с которой записывается время выпуска токена,
+// чтобы токен, выпущенный сразу после выхода, не был отозван. API-ключи не отзываются.
+// Возвращает ошибку, если операция не удалась.
+func (s *Service) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	if userID == uuid.Nil {
+		return ErrInvalidOptions
+	}
+	return s.repo.RevokeUserTokens(ctx, userID, time.Now().Truncate(time.Second))
+}
+
+// IsTokenRevoked проверяет, отозван ли JWT токен пользователя userID с идентификатором jti,
+// выпущенный в issuedAt.
+// Возвращает true, если токен отозван, и ошибку, если операция не удалась.
+func (s *Service) IsTokenRevoked(ctx context.Context, userID uuid.UUID, jti string, issuedAt time.Time) (bool, error) {
+	return s.repo.IsTokenRevoked(ctx, userID, jti, issuedAt)
+}
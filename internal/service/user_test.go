@@ -0,0 +1,545 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/ypxd99/yandex-practicm/internal/mocks"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/oidc"
+	"github.com/ypxd99/yandex-practicm/internal/repository/storage"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// openStorage открывает LocalStorage в файле filePath и создает сервис поверх него.
+// Повторный вызов с тем же файлом проверяет, что данные переживают перезапуск хранилища.
+func openStorage(t *testing.T, filePath string) (*storage.LocalStorage, *service.Service) {
+	repo, err := storage.InitStorage(filePath)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return repo, service.InitService(repo)
+}
+
+func TestUserAccounts(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "store")
+	anon := uuid.New()
+	creds := model.Credentials{Email: "Alice@Example.com", Password: "correct horse"}
+
+	repo, svc := openStorage(t, filePath)
+
+	_, err := svc.ShorterLink(ctx, "https://example.com/one", anon, model.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = svc.ShorterLink(ctx, "https://example.com/two", anon, model.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = svc.CreateAPIKey(ctx, anon, model.APIKeyRequest{Name: "ci", Scopes: []string{model.ScopeLinksRead}})
+	assert.NoError(t, err)
+
+	_, _, err = svc.SignUp(ctx, anon, model.Credentials{Email: "not-an-email", Password: creds.Password})
+	assert.ErrorIs(t, err, service.ErrInvalidOptions)
+	_, _, err = svc.SignUp(ctx, anon, model.Credentials{Email: creds.Email, Password: "short"})
+	assert.ErrorIs(t, err, service.ErrInvalidOptions)
+
+	user, merged, err := svc.SignUp(ctx, anon, creds)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, merged)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.NotEqual(t, anon, user.ID)
+	assert.NotContains(t, user.PasswordHash, creds.Password)
+
+	urls, err := svc.GetUserURLs(ctx, user.ID, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+	keys, err := svc.GetAPIKeys(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	urls, err = svc.GetUserURLs(ctx, anon, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+
+	_, _, err = svc.SignUp(ctx, uuid.New(), model.Credentials{Email: "alice@example.com", Password: "another password"})
+	assert.ErrorIs(t, err, service.ErrEmailTaken)
+	assert.NoError(t, repo.Close())
+
+	// Учетные записи переживают перезапуск хранилища.
+	repo, svc = openStorage(t, filePath)
+	defer repo.Close()
+
+	_, _, err = svc.Login(ctx, uuid.New(), model.Credentials{Email: creds.Email, Password: "wrong password"})
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+	_, _, err = svc.Login(ctx, uuid.New(), model.Credentials{Email: "bob@example.com", Password: creds.Password})
+	assert.ErrorIs(t, err, service.ErrInvalidCredentials)
+
+	// Вход с другого устройства переносит ссылки, созданные там анонимно.
+	device := uuid.New()
+	_, err = svc.ShorterLink(ctx, "https://example.com/three", device, model.LinkOptions{})
+	assert.NoError(t, err)
+	loggedIn, merged, err := svc.Login(ctx, device, creds)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, loggedIn.ID)
+	assert.Equal(t, 1, merged)
+
+	urls, err = svc.GetUserURLs(ctx, user.ID, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 3)
+
+	// Ссылки другой учетной записи при входе не переносятся.
+	other, _, err := svc.SignUp(ctx, uuid.New(), model.Credentials{Email: "bob@example.com", Password: "bobs password"})
+	assert.NoError(t, err)
+	_, err = svc.ShorterLink(ctx, "https://example.com/bob", other.ID, model.LinkOptions{})
+	assert.NoError(t, err)
+	_, merged, err = svc.Login(ctx, other.ID, creds)
+	assert.NoError(t, err)
+	assert.Zero(t, merged)
+	urls, err = svc.GetUserURLs(ctx, other.ID, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 1)
+}
+
+func TestAdminActions(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "store")
+	adminID := uuid.New()
+	owner := uuid.New()
+	other := uuid.New()
+
+	repo, svc := openStorage(t, filePath)
+
+	shortURL, err := svc.ShorterLink(ctx, "https://Phishing.example.com/login", owner, model.LinkOptions{})
+	assert.NoError(t, err)
+	id := shortURL[strings.LastIndex(shortURL, "/")+1:]
+	_, err = svc.ShorterLink(ctx, "https://docs.example.com", owner, model.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = svc.ShorterLink(ctx, "https://other.example.org", other, model.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = svc.CreateAPIKey(ctx, owner, model.APIKeyRequest{Name: "ci", Scopes: []string{model.ScopeLinksRead}})
+	assert.NoError(t, err)
+
+	links, err := svc.SearchLinks(ctx, adminID, model.AdminLinkFilter{URL: "phishing"})
+	assert.NoError(t, err)
+	assert.Len(t, links, 1)
+	assert.Equal(t, id, links[0].ID)
+	assert.Equal(t, shortURL, links[0].ShortURL)
+	links, err = svc.SearchLinks(ctx, adminID, model.AdminLinkFilter{UserID: &owner})
+	assert.NoError(t, err)
+	assert.Len(t, links, 2)
+
+	assert.ErrorIs(t, svc.DisableLink(ctx, adminID, id, " "), service.ErrInvalidOptions)
+	assert.ErrorIs(t, svc.DisableLink(ctx, adminID, "missing", "phishing"), service.ErrURLNotFound)
+	assert.NoError(t, svc.DisableLink(ctx, adminID, id, "phishing"))
+
+	_, err = svc.FindLink(ctx, id, model.Visit{})
+	assert.ErrorIs(t, err, service.ErrURLDisabled)
+	urls, err := svc.GetUserURLs(ctx, owner, model.LinkFilter{})
+	assert.NoError(t, err)
+	for _, u := range urls {
+		if u.ShortURL == shortURL {
+			assert.Equal(t, "phishing", u.DisabledReason)
+			assert.NotNil(t, u.DisabledAt)
+		}
+	}
+
+	// Отключенная ссылка не возвращается при повторном сокращении того же URL.
+	again, err := svc.ShorterLink(ctx, "https://Phishing.example.com/login", owner, model.LinkOptions{})
+	assert.NoError(t, err)
+	assert.NotEqual(t, shortURL, again)
+
+	assert.NoError(t, svc.EnableLink(ctx, adminID, id, "false positive"))
+	_, err = svc.FindLink(ctx, id, model.Visit{})
+	assert.NoError(t, err)
+
+	stats, err := svc.GetUserStats(ctx, adminID, nil, 0)
+	assert.NoError(t, err)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, owner, stats[0].UserID)
+	assert.Equal(t, 3, stats[0].Links)
+	assert.Equal(t, 1, stats[0].APIKeys)
+
+	deleted, err := svc.PurgeUser(ctx, adminID, owner, "abuse")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	_, err = svc.FindLink(ctx, id, model.Visit{})
+	assert.Error(t, err)
+
+	stranger := uuid.New()
+	stats, err = svc.GetUserStats(ctx, adminID, &stranger, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.UserStats{{UserID: stranger}}, stats)
+	assert.NoError(t, repo.Close())
+
+	// Журнал переживает перезапуск хранилища и содержит каждое действие, включая неудачные.
+	repo, svc = openStorage(t, filePath)
+	defer repo.Close()
+
+	actions, err := svc.GetAdminActions(ctx, adminID, 0)
+	assert.NoError(t, err)
+	assert.Len(t, actions, 9)
+	assert.Equal(t, model.AdminActionViewActions, actions[0].Action)
+	assert.Equal(t, model.AdminActionPurgeUser, actions[2].Action)
+	assert.Equal(t, owner.String(), actions[2].Target)
+	assert.Equal(t, "abuse", actions[2].Reason)
+	assert.Equal(t, model.AdminActionSearchLinks, actions[8].Action)
+}
+
+func TestTokenRevocation(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "store")
+
+	repo, svc := openStorage(t, filePath)
+
+	userID, other := uuid.New(), uuid.New()
+	issuedAt := time.Now().Add(-time.Minute)
+
+	assert.ErrorIs(t, svc.RevokeToken(ctx, "", time.Now().Add(time.Hour)), service.ErrInvalidOptions)
+	assert.NoError(t, svc.RevokeToken(ctx, "expired", time.Now().Add(-time.Hour)))
+	assert.NoError(t, svc.RevokeToken(ctx, "active", time.Now().Add(time.Hour)))
+	assert.NoError(t, svc.RevokeToken(ctx, "active", time.Now().Add(time.Hour)))
+
+	revoked, err := svc.IsTokenRevoked(ctx, userID, "active", issuedAt)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = svc.IsTokenRevoked(ctx, userID, "other", issuedAt)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	// Выход отзывает все токены пользователя, выпущенные раньше, но не токены других пользователей
+	// и не токены, выпущенные после выхода.
+	assert.ErrorIs(t, svc.RevokeUserTokens(ctx, uuid.Nil), service.ErrInvalidOptions)
+	assert.NoError(t, svc.RevokeUserTokens(ctx, userID))
+	revoked, err = svc.IsTokenRevoked(ctx, userID, "other", issuedAt)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = svc.IsTokenRevoked(ctx, other, "other", issuedAt)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = svc.IsTokenRevoked(ctx, userID, "fresh", time.Now().Add(time.Second))
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	assert.NoError(t, repo.Close())
+
+	// Список переживает перезапуск хранилища, а истекшие записи из него удаляются.
+	repo, svc = openStorage(t, filePath)
+	defer repo.Close()
+
+	revoked, err = svc.IsTokenRevoked(ctx, other, "active", issuedAt)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = svc.IsTokenRevoked(ctx, other, "expired", issuedAt)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	revoked, err = svc.IsTokenRevoked(ctx, userID, "other", issuedAt)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "store")
+
+	idp := mocks.NewFakeIdentityProvider("shortener", "client secret")
+	defer idp.Close()
+	provider := oidc.NewProvider(util.OIDC{
+		Issuer:       idp.URL,
+		ClientID:     "shortener",
+		ClientSecret: "client secret",
+		Scopes:       []string{"email"},
+	}, idp.Client())
+
+	repo, svc := openStorage(t, filePath)
+
+	_, _, err := svc.StartOIDCLogin(ctx)
+	assert.ErrorIs(t, err, service.ErrOIDCDisabled)
+	svc.SetIdentityProvider(provider)
+
+	authorize := func(t *testing.T) (model.OIDCFlow, string, string) {
+		authURL, flow, err := svc.StartOIDCLogin(ctx)
+		assert.NoError(t, err)
+		assert.Contains(t, authURL, "code_challenge_method=S256")
+		code, state, err := idp.Authorize(authURL)
+		assert.NoError(t, err)
+		assert.Equal(t, flow.State, state)
+		return *flow, state, code
+	}
+	login := func(t *testing.T, anonID uuid.UUID) (*model.User, int) {
+		flow, state, code := authorize(t)
+		user, merged, err := svc.CompleteOIDCLogin(ctx, anonID, flow, state, code)
+		assert.NoError(t, err)
+		return user, merged
+	}
+
+	anonID := uuid.New()
+	_, err = svc.ShorterLink(ctx, "https://example.com/sso", anonID, model.LinkOptions{})
+	assert.NoError(t, err)
+
+	idp.SetUser("employee-1", "Employee@Corp.example", true)
+	employee, merged := login(t, anonID)
+	assert.Equal(t, 1, merged)
+	assert.Equal(t, "employee@corp.example", employee.Email)
+	urls, err := svc.GetUserURLs(ctx, employee.ID, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	again, merged := login(t, uuid.New())
+	assert.Equal(t, employee.ID, again.ID)
+	assert.Equal(t, 0, merged)
+
+	t.Run("does not link to existing account by email", func(t *testing.T) {
+		account, _, err := svc.SignUp(ctx, uuid.Nil, model.Credentials{Email: "dev@corp.example", Password: "correct horse"})
+		assert.NoError(t, err)
+
+		idp.SetUser("employee-2", "DEV@corp.example", true)
+		user, _ := login(t, uuid.New())
+		assert.NotEqual(t, account.ID, user.ID)
+		assert.Empty(t, user.Email)
+
+		again, _ := login(t, uuid.New())
+		assert.Equal(t, user.ID, again.ID)
+
+		idp.SetUser("employee-3", "dev@corp.example", false)
+		other, _ := login(t, uuid.New())
+		assert.NotEqual(t, account.ID, other.ID)
+		assert.NotEqual(t, user.ID, other.ID)
+		assert.Empty(t, other.Email)
+	})
+
+	t.Run("rejects forged, expired or replayed callbacks", func(t *testing.T) {
+		idp.SetUser("employee-1", "employee@corp.example", true)
+
+		flow, _, code := authorize(t)
+		_, _, err := svc.CompleteOIDCLogin(ctx, uuid.New(), flow, "forged", code)
+		assert.ErrorIs(t, err, service.ErrOIDCRejected)
+
+		flow, state, code := authorize(t)
+		wrong := flow
+		wrong.Verifier = "wrong verifier"
+		_, _, err = svc.CompleteOIDCLogin(ctx, uuid.New(), wrong, state, code)
+		assert.ErrorIs(t, err, service.ErrOIDCRejected)
+
+		flow, state, code = authorize(t)
+		wrong = flow
+		wrong.Nonce = "wrong nonce"
+		_, _, err = svc.CompleteOIDCLogin(ctx, uuid.New(), wrong, state, code)
+		assert.ErrorIs(t, err, service.ErrOIDCRejected)
+
+		flow, state, code = authorize(t)
+		idp.ExpireCodes()
+		_, _, err = svc.CompleteOIDCLogin(ctx, uuid.New(), flow, state, code)
+		assert.ErrorIs(t, err, service.ErrOIDCRejected)
+
+		flow, state, code = authorize(t)
+		_, _, err = svc.CompleteOIDCLogin(ctx, uuid.New(), flow, state, code)
+		assert.NoError(t, err)
+		_, _, err = svc.CompleteOIDCLogin(ctx, uuid.New(), flow, state, code)
+		assert.ErrorIs(t, err, service.ErrOIDCRejected)
+	})
+	assert.NoError(t, repo.Close())
+
+	// Привязка пользователя провайдера переживает перезапуск хранилища.
+	repo, svc = openStorage(t, filePath)
+	defer repo.Close()
+	svc.SetIdentityProvider(provider)
+
+	user, _ := login(t, uuid.New())
+	assert.Equal(t, employee.ID, user.ID)
+}
+
+func TestUserRegistry(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "store")
+	adminID := uuid.New()
+	abandoned := uuid.New()
+	visitor := uuid.New()
+	longAgo := time.Now().Add(-100 * 24 * time.Hour)
+
+	repo, svc := openStorage(t, filePath)
+
+	_, err := svc.ShorterLink(ctx, "https://abandoned.example.com", abandoned, model.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = svc.CreateAPIKey(ctx, abandoned, model.APIKeyRequest{Name: "ci", Scopes: []string{model.ScopeLinksRead}})
+	assert.NoError(t, err)
+	_, err = svc.ShorterLink(ctx, "https://visitor.example.com", visitor, model.LinkOptions{})
+	assert.NoError(t, err)
+	user, _, err := svc.SignUp(ctx, uuid.New(), model.Credentials{Email: "owner@example.com", Password: "correct horse battery"})
+	assert.NoError(t, err)
+	_, err = svc.ShorterLink(ctx, "https://owner.example.com", user.ID, model.LinkOptions{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, repo.TouchUsers(ctx, map[uuid.UUID]time.Time{abandoned: longAgo, user.ID: longAgo}))
+	svc.TouchUser(visitor)
+	assert.NoError(t, svc.FlushActivity(ctx))
+	// Более раннее время активности не заменяет сохраненное.
+	assert.NoError(t, repo.TouchUsers(ctx, map[uuid.UUID]time.Time{visitor: longAgo}))
+
+	count, err := svc.CountUsers(ctx, adminID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.UserCount{Total: 3, Registered: 1, Anonymous: 2, Active: 1}, *count)
+
+	_, _, err = svc.CleanupInactiveUsers(ctx, 0)
+	assert.ErrorIs(t, err, service.ErrInvalidOptions)
+	users, links, err := svc.CleanupInactiveUsers(ctx, 90*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, users)
+	assert.Equal(t, 1, links)
+
+	urls, err := svc.GetUserURLs(ctx, abandoned, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+	keys, err := svc.GetAPIKeys(ctx, abandoned)
+	assert.NoError(t, err)
+	for _, key := range keys {
+		assert.NotNil(t, key.RevokedAt)
+	}
+	urls, err = svc.GetUserURLs(ctx, user.ID, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	// Повторная очистка ничего не удаляет, а реестр сохраняется в файле.
+	users, _, err = svc.CleanupInactiveUsers(ctx, 90*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Zero(t, users)
+	assert.NoError(t, repo.Close())
+
+	repo, svc = openStorage(t, filePath)
+	defer repo.Close()
+	count, err = svc.CountUsers(ctx, adminID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.UserCount{Total: 2, Registered: 1, Anonymous: 1, Active: 1}, *count)
+}
+
+func TestDeviceLinking(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), "store")
+	laptop := uuid.New()
+	phone := uuid.New()
+
+	repo, svc := openStorage(t, filePath)
+
+	_, err := svc.ShorterLink(ctx, "https://laptop.example.com", laptop, model.LinkOptions{})
+	assert.NoError(t, err)
+	_, err = svc.ShorterLink(ctx, "https://phone.example.com", phone, model.LinkOptions{})
+	assert.NoError(t, err)
+
+	first, err := svc.CreateDeviceCode(ctx, laptop)
+	assert.NoError(t, err)
+	assert.Len(t, first.Code, 8)
+	assert.True(t, first.ExpiresAt.After(time.Now()))
+
+	// Новый код отменяет ранее выданный, а выданный код сохраняется в файле.
+	code, err := svc.CreateDeviceCode(ctx, laptop)
+	assert.NoError(t, err)
+	assert.NoError(t, repo.Close())
+	repo, svc = openStorage(t, filePath)
+
+	if first.Code != code.Code {
+		_, _, err = svc.ClaimDeviceCode(ctx, phone, "198.51.100.1", model.DeviceClaimRequest{Code: first.Code})
+		assert.ErrorIs(t, err, service.ErrDeviceCodeInvalid)
+	}
+	_, _, err = svc.ClaimDeviceCode(ctx, phone, "198.51.100.1", model.DeviceClaimRequest{Code: "12ab"})
+	assert.ErrorIs(t, err, service.ErrDeviceCodeInvalid)
+
+	user, merged, err := svc.ClaimDeviceCode(ctx, phone, "198.51.100.1", model.DeviceClaimRequest{Code: code.Code[:4] + "-" + code.Code[4:]})
+	assert.NoError(t, err)
+	assert.Equal(t, laptop, user.ID)
+	assert.Equal(t, 1, merged)
+	urls, err := svc.GetUserURLs(ctx, laptop, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 2)
+
+	// Код одноразовый.
+	_, _, err = svc.ClaimDeviceCode(ctx, phone, "198.51.100.1", model.DeviceClaimRequest{Code: code.Code})
+	assert.ErrorIs(t, err, service.ErrDeviceCodeInvalid)
+
+	// При отказе от переноса устройство получает пользователя без своих ссылок.
+	tablet := uuid.New()
+	_, err = svc.ShorterLink(ctx, "https://tablet.example.com", tablet, model.LinkOptions{})
+	assert.NoError(t, err)
+	code, err = svc.CreateDeviceCode(ctx, laptop)
+	assert.NoError(t, err)
+	user, merged, err = svc.ClaimDeviceCode(ctx, tablet, "198.51.100.2", model.DeviceClaimRequest{Code: code.Code, RefuseMerge: true})
+	assert.NoError(t, err)
+	assert.Equal(t, laptop, user.ID)
+	assert.Zero(t, merged)
+	urls, err = svc.GetUserURLs(ctx, tablet, model.LinkFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, urls, 1)
+
+	// Количество попыток ввода кода с одного адреса ограничено.
+	code, err = svc.CreateDeviceCode(ctx, laptop)
+	assert.NoError(t, err)
+	for i := 0; i < cfg.Devices.RedeemAttempts; i++ {
+		_, _, err = svc.ClaimDeviceCode(ctx, tablet, "203.0.113.7", model.DeviceClaimRequest{Code: "00000000"})
+		assert.Error(t, err)
+	}
+	_, _, err = svc.ClaimDeviceCode(ctx, tablet, "203.0.113.7", model.DeviceClaimRequest{Code: code.Code})
+	assert.ErrorIs(t, err, service.ErrTooManyAttempts)
+	_, _, err = svc.ClaimDeviceCode(ctx, tablet, "203.0.113.8", model.DeviceClaimRequest{Code: code.Code})
+	assert.NoError(t, err)
+
+	// После превышения неудачных попыток со всех адресов попытки выполняются по очереди с интервалом,
+	// чтобы коды нельзя было перебирать с множества адресов, но верный код по-прежнему принимается.
+	prevFailures, prevDelay := cfg.Devices.RedeemFailures, cfg.Devices.RedeemDelay
+	cfg.Devices.RedeemFailures, cfg.Devices.RedeemDelay = 3, 50
+	t.Cleanup(func() { cfg.Devices.RedeemFailures, cfg.Devices.RedeemDelay = prevFailures, prevDelay })
+	interval := time.Duration(cfg.Devices.RedeemDelay) * time.Millisecond
+	svc = service.InitService(repo)
+	code, err = svc.CreateDeviceCode(ctx, laptop)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	for i := 0; i < cfg.Devices.RedeemFailures; i++ {
+		_, _, err = svc.ClaimDeviceCode(ctx, tablet, fmt.Sprintf("192.0.2.%d", i+1), model.DeviceClaimRequest{Code: "00000000"})
+		assert.ErrorIs(t, err, service.ErrDeviceCodeInvalid)
+	}
+	assert.Less(t, time.Since(start), interval)
+
+	start = time.Now()
+	var wg sync.WaitGroup
+	for i := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := svc.ClaimDeviceCode(ctx, tablet, fmt.Sprintf("192.0.2.%d", i+50), model.DeviceClaimRequest{Code: "00000000"})
+			assert.ErrorIs(t, err, service.ErrDeviceCodeInvalid)
+		}()
+	}
+	wg.Wait()
+	assert.GreaterOrEqual(t, time.Since(start), 2*interval)
+
+	start = time.Now()
+	user, _, err = svc.ClaimDeviceCode(ctx, tablet, "192.0.2.100", model.DeviceClaimRequest{Code: code.Code})
+	assert.NoError(t, err)
+	assert.Equal(t, laptop, user.ID)
+	assert.GreaterOrEqual(t, time.Since(start), interval)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = svc.ClaimDeviceCode(canceled, tablet, "192.0.2.101", model.DeviceClaimRequest{Code: "00000000"})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+// queryLimit извлекает необязательный параметр запроса "limit".
+// Возвращает 0, если параметр не задан.
+func queryLimit(c *gin.Context) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return 0, errors.New("invalid limit")
+	}
+	return limit, nil
+}
+
+// adminErrorStatus возвращает HTTP-статус для ошибки действия администратора.
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidOptions):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrURLNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// searchLinks обрабатывает GET-запрос администратора на поиск ссылок.
+// Принимает необязательные параметры запроса "url" (подстрока оригинального URL),
+// "user" (идентификатор владельца) и "limit".
+// Возвращает массив ссылок, включая удаленные и отключенные, от новых к старым.
+// Статусы ответа:
+// - 200: Поиск выполнен
+// - 400: Неверные параметры запроса
+// - 401: Пользователь не авторизован
+// - 403: Пользователь не является администратором
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) searchLinks(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	filter := model.AdminLinkFilter{URL: c.Query("url")}
+	if user := c.Query("user"); user != "" {
+		userID, err := uuid.Parse(user)
+		if err != nil {
+			response(c, http.StatusBadRequest, err, nil)
+			return
+		}
+		filter.UserID = &userID
+	}
+	if filter.Limit, err = queryLimit(c); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	links, err := h.service.SearchLinks(c.Request.Context(), adminID, filter)
+	if err != nil {
+		response(c, adminErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, links)
+}
+
+// setLinkDisabled обрабатывает POST-запросы администратора на отключение и включение ссылки.
+// Принимает идентификатор ссылки в параметре пути и JSON с полем "reason".
+// Статусы ответа:
+// - 204: Состояние ссылки изменено
+// - 400: Неверный формат запроса или не указана причина
+// - 401: Пользователь не авторизован
+// - 403: Пользователь не является администратором
+// - 404: Ссылка не найдена
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) setLinkDisabled(disabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, err := middleware.GetUserID(c)
+		if err != nil {
+			response(c, http.StatusUnauthorized, err, nil)
+			return
+		}
+
+		var req model.AdminRequest
+		if err = c.ShouldBindJSON(&req); err != nil {
+			response(c, http.StatusBadRequest, err, nil)
+			return
+		}
+
+		if disabled {
+			err = h.service.DisableLink(c.Request.Context(), adminID, c.Param("id"), req.Reason)
+		} else {
+			err = h.service.EnableLink(c.Request.Context(), adminID, c.Param("id"), req.Reason)
+		}
+		if err != nil {
+			response(c, adminErrorStatus(err), err, nil)
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// getUserStats обрабатывает GET-запросы администратора на получение количества ссылок пользователей.
+// Без параметра пути возвращает пользователей с наибольшим количеством ссылок,
+// количество задается параметром запроса "limit". С идентификатором пользователя
+// в параметре пути возвращает массив из одной записи.
+// Статусы ответа:
+// - 200: Данные успешно получены
+// - 400: Неверный идентификатор пользователя или параметры запроса
+// - 401: Пользователь не авторизован
+// - 403: Пользователь не является администратором
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getUserStats(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	var userID *uuid.UUID
+	if id := c.Param("id"); id != "" {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			response(c, http.StatusBadRequest, err, nil)
+			return
+		}
+		userID = &parsed
+	}
+	limit, err := queryLimit(c)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	stats, err := h.service.GetUserStats(c.Request.Context(), adminID, userID, limit)
+	if err != nil {
+		response(c, adminErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, stats)
+}
+
+// purgeUser обрабатывает DELETE-запрос администратора на удаление всех ссылок пользователя.
+// Принимает идентификатор пользователя в параметре пути и JSON с полем "reason".
+// Ссылки удаляются безвозвратно, API-ключи пользователя отзываются.
+// Возвращает JSON с полем "deleted" - количеством удаленных ссылок.
+// Статусы ответа:
+// - 200: Ссылки пользователя удалены
+// - 400: Неверный идентификатор пользователя, формат запроса или не указана причина
+// - 401: Пользователь не авторизован
+// - 403: Пользователь не является администратором
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) purgeUser(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	var req model.AdminRequest
+	if err = c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	deleted, err := h.service.PurgeUser(c.Request.Context(), adminID, userID, req.Reason)
+	if err != nil {
+		response(c, adminErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, model.PurgeResponse{Deleted: deleted})
+}
+
+// getAdminActions обрабатывает GET-запрос администратора на получение журнала действий администраторов.
+// Принимает необязательный параметр запроса "limit".
+// Возвращает массив записей от новых к старым.
+// Статусы ответа:
+// - 200: Журнал успешно получен
+// - 400: Неверные параметры запроса
+// - 401: Пользователь не авторизован
+// - 403: Пользователь не является администратором
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getAdminActions(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	limit, err := queryLimit(c)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	actions, err := h.service.GetAdminActions(c.Request.Context(), adminID, limit)
+	if err != nil {
+		response(c, adminErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, actions)
+}
+
+// countUsers обрабатывает GET-запрос администратора на получение количества пользователей.
+// Возвращает JSON с полями "total", "registered", "anonymous" и "active".
+// Статусы ответа:
+// - 200: Количество успешно получено
+// - 401: Пользователь не авторизован
+// - 403: Пользователь не является администратором
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) countUsers(c *gin.Context) {
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	count, err := h.service.CountUsers(c.Request.Context(), adminID)
+	if err != nil {
+		response(c, adminErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, count)
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+// createAPIKey обрабатывает POST-запрос на создание API-ключа пользователя.
+// Принимает JSON с полями "name", "scopes" и необязательным "expires_at".
+// Возвращает JSON с описанием ключа и полем "key"; ключ показывается только в этом ответе.
+// Статусы ответа:
+// - 201: Ключ успешно создан
+// - 400: Неверный формат запроса или параметры ключа
+// - 401: Пользователь не авторизован
+// - 403: Запрос аутентифицирован API-ключом
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) createAPIKey(c *gin.Context) {
+	var (
+		err error
+		req model.APIKeyRequest
+	)
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+	defer c.Request.Body.Close()
+
+	if err = json.Unmarshal(body, &req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(c.Request.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOptions) {
+			response(c, http.StatusBadRequest, err, nil)
+			return
+		}
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	response(c, http.StatusCreated, nil, key)
+}
+
+// getAPIKeys обрабатывает GET-запрос на получение API-ключей пользователя.
+// Возвращает массив JSON-объектов с полями "id", "name", "prefix", "scopes", "expires_at",
+// "created_at" и "revoked_at". Сами ключи не возвращаются.
+// Статусы ответа:
+// - 200: Список ключей успешно получен
+// - 401: Пользователь не авторизован
+// - 403: Запрос аутентифицирован API-ключом
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getAPIKeys(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	keys, err := h.service.GetAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, keys)
+}
+
+// revokeAPIKey обрабатывает DELETE-запрос на отзыв API-ключа пользователя.
+// Принимает идентификатор ключа в параметре пути.
+// Статусы ответа:
+// - 204: Ключ успешно отозван
+// - 400: Неверный идентификатор ключа
+// - 401: Пользователь не авторизован
+// - 403: Запрос аутентифицирован API-ключом
+// - 404: Ключ не найден или уже отозван
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	err = h.service.RevokeAPIKey(c.Request.Context(), id, userID)
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNotFound) {
+			response(c, http.StatusNotFound, err, nil)
+			return
+		}
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
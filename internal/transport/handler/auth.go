@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+// issueToken обрабатывает POST-запрос на выпуск токена для текущего пользователя.
+// Токен позволяет клиентам без поддержки cookie обращаться к API от имени того же пользователя
+// с заголовком "Authorization: Bearer <token>".
+// Возвращает JSON с полями "token", "token_type" и "expires_at".
+// Статусы ответа:
+// - 200: Токен успешно выпущен
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) issueToken(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	role, err := h.service.UserRole(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	token, expiresAt, err := middleware.IssueToken(userID, role)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	response(c, http.StatusOK, nil, model.AuthToken{Token: token, TokenType: "Bearer", ExpiresAt: expiresAt})
+}
+
+// getJWKS обрабатывает GET-запрос на получение публичных ключей проверки токенов.
+// Позволяет другим сервисам проверять токены пользователей без общего секрета.
+// Возвращает JSON Web Key Set с ключами RS256 и EdDSA, ключи HS256 не публикуются.
+// Статусы ответа:
+// - 200: Набор ключей успешно получен
+func (h *Handler) getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	response(c, http.StatusOK, nil, middleware.GetKeySet().JWKS())
+}
+
+// signUp обрабатывает POST-запрос на регистрацию учетной записи.
+// Принимает JSON с полями "email" и "password".
+// Ссылки и API-ключи текущего анонимного пользователя переносятся в новую учетную запись.
+// Токен учетной записи возвращается в cookie, в заголовке ответа и в теле.
+// Возвращает JSON с полями "user", "merged_links", "token", "token_type" и "expires_at".
+// Статусы ответа:
+// - 201: Учетная запись успешно создана
+// - 400: Неверный формат запроса, адрес или пароль
+// - 403: Запрос аутентифицирован API-ключом
+// - 409: Адрес электронной почты уже зарегистрирован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) signUp(c *gin.Context) {
+	var creds model.Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	anonID, _ := middleware.GetUserID(c)
+	user, merged, err := h.service.SignUp(c.Request.Context(), anonID, creds)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidOptions):
+			response(c, http.StatusBadRequest, err, nil)
+		case errors.Is(err, service.ErrEmailTaken):
+			response(c, http.StatusConflict, err, nil)
+		default:
+			response(c, http.StatusInternalServerError, err, nil)
+		}
+		return
+	}
+
+	h.signIn(c, http.StatusCreated, user, merged)
+}
+
+// login обрабатывает POST-запрос на вход в учетную запись.
+// Принимает JSON с полями "email" и "password".
+// Ссылки и API-ключи текущего анонимного пользователя переносятся в учетную запись.
+// Токен учетной записи возвращается в cookie, в заголовке ответа и в теле.
+// Возвращает JSON с полями "user", "merged_links", "token", "token_type" и "expires_at".
+// Статусы ответа:
+// - 200: Вход выполнен
+// - 400: Неверный формат запроса
+// - 401: Неверный адрес или пароль
+// - 403: Запрос аутентифицирован API-ключом
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) login(c *gin.Context) {
+	var creds model.Credentials
+	if err := c.ShouldBindJSON(&creds); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	anonID, _ := middleware.GetUserID(c)
+	user, merged, err := h.service.Login(c.Request.Context(), anonID, creds)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			response(c, http.StatusUnauthorized, err, nil)
+			return
+		}
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	h.signIn(c, http.StatusOK, user, merged)
+}
+
+// logout обрабатывает POST-запрос на выход.
+// Отзываются токен, которым аутентифицирован запрос, и все остальные JWT токены пользователя,
+// выпущенные до выхода: продленные, выпущенные для API и полученные на других устройствах,
+// в том числе привязанных кодом. API-ключи пользователя продолжают действовать.
+// Cookie с токеном удаляется. Следующий запрос без токена получает нового анонимного пользователя.
+// Статусы ответа:
+// - 204: Выход выполнен
+// - 403: Запрос аутентифицирован API-ключом
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) logout(c *gin.Context) {
+	if claims, ok := middleware.GetClaims(c); ok && claims.ID != "" && claims.ExpiresAt != nil {
+		if err := h.service.RevokeToken(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+			response(c, http.StatusInternalServerError, err, nil)
+			return
+		}
+	}
+	if userID, err := middleware.GetUserID(c); err == nil {
+		if err = h.service.RevokeUserTokens(c.Request.Context(), userID); err != nil {
+			response(c, http.StatusInternalServerError, err, nil)
+			return
+		}
+	}
+
+	middleware.SignOut(c)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusNoContent)
+}
+
+// signIn выпускает токен учетной записи и отправляет ответ на регистрацию или вход.
+func (h *Handler) signIn(c *gin.Context, status int, user *model.User, merged int) {
+	token, expiresAt, err := middleware.SignIn(c, user.ID, user.Role)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	response(c, status, nil, model.AccountResponse{
+		User:        *user,
+		MergedLinks: merged,
+		AuthToken:   model.AuthToken{Token: token, TokenType: "Bearer", ExpiresAt: expiresAt},
+	})
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+// bundleTemplate шаблон страницы-подборки
+var bundleTemplate = template.Must(template.New("bundle").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{if .Title}}{{.Title}}{{else}}Links{{end}}</title></head>
+<body>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+<ul>
+{{range .Entries}}<li><a href="{{.URL}}" rel="noopener noreferrer nofollow">{{.Title}}</a></li>
+{{end}}</ul>
+</body>
+</html>
+`))
+
+// shortenBundle обрабатывает POST-запрос на создание страницы-подборки.
+// Принимает JSON с массивом "entries" из объектов с полями "name", "title" и "url",
+// необязательным названием "title" и остальными параметрами ссылки.
+// Возвращает JSON с полем "result", содержащим сокращенный URL подборки.
+// Статусы ответа:
+// - 201: Подборка успешно создана
+// - 400: Неверный формат запроса или параметры подборки
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) shortenBundle(c *gin.Context) {
+	var (
+		err error
+		req model.LinkOptions
+	)
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, model.ShortenResponse{Result: ""})
+		return
+	}
+	defer c.Request.Body.Close()
+
+	if err = json.Unmarshal(body, &req); err != nil {
+		response(c, http.StatusBadRequest, err, model.ShortenResponse{Result: ""})
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, model.ShortenResponse{Result: ""})
+		return
+	}
+
+	resp, err := h.service.CreateBundle(c.Request.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOptions) {
+			response(c, http.StatusBadRequest, err, model.ShortenResponse{Result: ""})
+			return
+		}
+		response(c, http.StatusInternalServerError, err, model.ShortenResponse{Result: ""})
+		return
+	}
+
+	response(c, http.StatusCreated, nil, model.ShortenResponse{Result: resp})
+}
+
+// renderBundle отображает страницу-подборку.
+// Отдает JSON, если клиент предпочитает его, иначе HTML-страницу.
+func renderBundle(c *gin.Context, page *model.BundlePage) {
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, page)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := bundleTemplate.Execute(&buf, page); err != nil {
+		responseTextPlain(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+// createDeviceCode обрабатывает POST-запрос на выдачу кода привязки другого устройства.
+// Ранее выданный пользователю код перестает действовать.
+// Если в строке запроса задан параметр "format", ответ содержит QR-код с кодом привязки
+// в виде data URI, размер и уровень коррекции ошибок которого задаются параметрами "size" и "ecc".
+// Код не передается в URL, поэтому не попадает в журналы запросов.
+// Возвращает JSON с полями "code", "expires_at" и "qr".
+// Статусы ответа:
+// - 201: Код успешно выдан
+// - 400: Неверные параметры QR-кода
+// - 401: Пользователь не авторизован
+// - 403: Запрос аутентифицирован API-ключом
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) createDeviceCode(c *gin.Context) {
+	var req model.QRRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+	var params qrParams
+	if req.Format != "" {
+		var err error
+		if params, err = parseQRParams(req); err != nil {
+			response(c, http.StatusBadRequest, err, nil)
+			return
+		}
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	code, err := h.service.CreateDeviceCode(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	if req.Format != "" {
+		image, contentType, err := encodeQR(code.Code, params)
+		if err != nil {
+			response(c, http.StatusInternalServerError, err, nil)
+			return
+		}
+		code.QR = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(image)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	response(c, http.StatusCreated, nil, code)
+}
+
+// claimDeviceCode обрабатывает POST-запрос на привязку устройства по коду, выданному на другом устройстве.
+// Принимает JSON с полями "code" и "refuse_merge".
+// Устройство получает пользователя, выдавшего код, а ссылки текущего анонимного пользователя
+// переносятся к нему, если "refuse_merge" не задан.
+// Токен пользователя возвращается в cookie, в заголовке ответа и в теле.
+// Возвращает JSON с полями "user", "merged_links", "token", "token_type" и "expires_at".
+// Статусы ответа:
+// - 200: Устройство привязано
+// - 400: Неверный формат запроса, неизвестный или истекший код
+// - 403: Запрос аутентифицирован API-ключом
+// - 429: Превышено количество попыток ввода кода
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) claimDeviceCode(c *gin.Context) {
+	var req model.DeviceClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, _ := middleware.GetUserID(c)
+	user, merged, err := h.service.ClaimDeviceCode(c.Request.Context(), userID, c.ClientIP(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrDeviceCodeInvalid):
+			response(c, http.StatusBadRequest, err, nil)
+		case errors.Is(err, service.ErrTooManyAttempts):
+			c.Header("Retry-After", strconv.Itoa(int(service.RedeemWindow().Seconds())))
+			response(c, http.StatusTooManyRequests, err, nil)
+		default:
+			response(c, http.StatusInternalServerError, err, nil)
+		}
+		return
+	}
+
+	h.signIn(c, http.StatusOK, user, merged)
+}
@@ -101,7 +101,8 @@ func Example_shorten() {
 
 	// Инициализируем обработчик с мок-сервисом
 	mockService := &mocks.MockLinkService{}
-	mockService.On("ShorterLink", mock.Anything, "https://example.com/very/long/url", mock.Anything).
+	mockService.On("TouchUser", mock.Anything).Maybe()
+	mockService.On("ShorterLink", mock.Anything, "https://example.com/very/long/url", mock.Anything, mock.Anything).
 		Return("http://localhost:8080/abc123", nil)
 	h := handler.InitHandler(mockService)
 	h.InitRoutes(router)
@@ -137,6 +138,7 @@ func Example_batchShorten() {
 
 	// Инициализируем обработчик с мок-сервисом
 	mockService := &mocks.MockLinkService{}
+	mockService.On("TouchUser", mock.Anything).Maybe()
 	batchReq := []model.BatchRequest{
 		{
 			CorrelationID: "1",
@@ -190,6 +192,7 @@ func Example_getUserURLs() {
 
 	// Инициализируем обработчик с мок-сервисом
 	mockService := &mocks.MockLinkService{}
+	mockService.On("TouchUser", mock.Anything).Maybe()
 	urls := []model.UserURLResponse{
 		{
 			ShortURL:    "http://localhost:8080/abc123",
@@ -200,7 +203,7 @@ func Example_getUserURLs() {
 			OriginalURL: "https://example.com/url2",
 		},
 	}
-	mockService.On("GetUserURLs", mock.Anything, mock.Anything).
+	mockService.On("GetUserURLs", mock.Anything, mock.Anything, mock.Anything).
 		Return(urls, nil)
 	h := handler.InitHandler(mockService)
 	h.InitRoutes(router)
@@ -229,6 +232,7 @@ func Example_deleteURLs() {
 
 	// Инициализируем обработчик с мок-сервисом
 	mockService := &mocks.MockLinkService{}
+	mockService.On("TouchUser", mock.Anything).Maybe()
 	ids := []string{"abc123", "def456"}
 	mockService.On("DeleteURLs", mock.Anything, ids, mock.Anything).
 		Return(2, nil)
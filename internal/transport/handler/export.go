@@ -0,0 +1,548 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// csvColumns колонки CSV-файла экспорта и импорта ссылок
+var csvColumns = []string{
+	"id", "short_url", "original_url", "title", "folder", "tags", "max_clicks", "active_from", "active_until",
+	"redirect_code", "cache_max_age", "passthrough", "utm", "targets", "rules", "entries", "created_at",
+}
+
+// csvTagSeparator разделитель меток в ячейке CSV
+const csvTagSeparator = "|"
+
+// exportContentTypes типы содержимого ответа для форматов экспорта
+var exportContentTypes = map[string]string{
+	model.FormatCSV:    "text/csv; charset=utf-8",
+	model.FormatJSON:   "application/json; charset=utf-8",
+	model.FormatNDJSON: "application/x-ndjson",
+}
+
+// errRow ошибка разбора отдельной строки импорта, после которой чтение можно продолжить
+type errRow struct {
+	err error
+}
+
+func (e errRow) Error() string { return e.err.Error() }
+
+// recordReader последовательно читает записи импорта из тела запроса.
+// Next возвращает io.EOF после последней записи и errRow для строки, которую не удалось разобрать.
+type recordReader interface {
+	Next() (model.LinkRecord, error)
+}
+
+// jsonArrayWriter записывает элементы JSON-массива по одному, не накапливая их в памяти.
+type jsonArrayWriter struct {
+	w   io.Writer
+	enc *json.Encoder
+	n   int
+}
+
+// newJSONArrayWriter создает jsonArrayWriter, пишущий в w.
+func newJSONArrayWriter(w io.Writer) *jsonArrayWriter {
+	return &jsonArrayWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+// Write добавляет элемент в массив.
+func (a *jsonArrayWriter) Write(v interface{}) error {
+	sep := ","
+	if a.n == 0 {
+		sep = "["
+	}
+	if _, err := io.WriteString(a.w, sep); err != nil {
+		return err
+	}
+	a.n++
+	return a.enc.Encode(v)
+}
+
+// Close завершает массив.
+func (a *jsonArrayWriter) Close() error {
+	end := "]"
+	if a.n == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// recordWriter последовательно записывает записи экспорта в выбранном формате.
+// Close завершает вывод после последней записи.
+type recordWriter interface {
+	Write(rec *model.LinkRecord) error
+	Close() error
+}
+
+// newRecordWriter создает recordWriter для формата format, пишущий в w.
+func newRecordWriter(w io.Writer, format string) (recordWriter, error) {
+	switch format {
+	case model.FormatCSV:
+		cw := &csvRecordWriter{w: csv.NewWriter(w)}
+		if err := cw.w.Write(csvColumns); err != nil {
+			return nil, err
+		}
+		return cw, nil
+	case model.FormatNDJSON:
+		return &ndjsonRecordWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return &jsonRecordWriter{a: newJSONArrayWriter(w)}, nil
+	}
+}
+
+// csvRecordWriter записывает записи экспорта строками CSV-таблицы с заголовком.
+type csvRecordWriter struct {
+	w *csv.Writer
+}
+
+// Write добавляет строку таблицы.
+func (cw *csvRecordWriter) Write(rec *model.LinkRecord) error {
+	row, err := encodeCSVRecord(rec)
+	if err != nil {
+		return err
+	}
+	return cw.w.Write(row)
+}
+
+// Close сбрасывает буфер таблицы.
+func (cw *csvRecordWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// ndjsonRecordWriter записывает записи экспорта по одной в строке.
+type ndjsonRecordWriter struct {
+	enc *json.Encoder
+}
+
+// Write добавляет запись.
+func (nw *ndjsonRecordWriter) Write(rec *model.LinkRecord) error {
+	return nw.enc.Encode(rec)
+}
+
+// Close ничего не делает: у формата нет завершения.
+func (nw *ndjsonRecordWriter) Close() error {
+	return nil
+}
+
+// jsonRecordWriter записывает записи экспорта элементами JSON-массива.
+type jsonRecordWriter struct {
+	a *jsonArrayWriter
+}
+
+// Write добавляет элемент массива.
+func (jw *jsonRecordWriter) Write(rec *model.LinkRecord) error {
+	return jw.a.Write(rec)
+}
+
+// Close завершает массив.
+func (jw *jsonRecordWriter) Close() error {
+	return jw.a.Close()
+}
+
+// exportURLs обрабатывает GET-запрос на выгрузку всех ссылок пользователя.
+// Принимает формат в параметре "format": csv, json или ndjson, по умолчанию json.
+// Каждая запись кодируется и передается клиенту сразу после чтения из хранилища.
+// Если ошибка возникает после начала передачи, ответ обрывается и ошибка записывается в журнал.
+// Статусы ответа:
+// - 200: Ссылки успешно выгружены
+// - 400: Неизвестный формат
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) exportURLs(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", model.FormatJSON))
+	contentType, ok := exportContentTypes[format]
+	if !ok {
+		response(c, http.StatusBadRequest, fmt.Errorf("unsupported format %q", format), nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	// Заголовки отправляются с первой записью, чтобы ошибка чтения до нее вернула статус 500.
+	var writer recordWriter
+	start := func() error {
+		if writer != nil {
+			return nil
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, format))
+		c.Status(http.StatusOK)
+
+		var err error
+		writer, err = newRecordWriter(c.Writer, format)
+		return err
+	}
+
+	err = h.service.ExportLinks(c.Request.Context(), userID, func(rec *model.LinkRecord) error {
+		if err := start(); err != nil {
+			return err
+		}
+		return writer.Write(rec)
+	})
+	if err != nil && writer == nil && !c.Writer.Written() {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err == nil {
+		if err = start(); err == nil {
+			err = writer.Close()
+		}
+	}
+	if err != nil {
+		util.GetLogger().Errorf("failed to export links: %v", err)
+	}
+}
+
+// importURLs обрабатывает POST-запрос на загрузку ссылок пользователя.
+// Принимает записи в формате из параметра "format" или заголовка Content-Type: csv, json или ndjson.
+// Записи обрабатываются по мере чтения тела запроса.
+// Возвращает JSON-массив с результатом импорта каждой строки: "row", "status", "id", "short_url",
+// "alias_changed" и "error".
+// Статусы ответа:
+// - 200: Импорт выполнен, результат каждой строки указан в отчете
+// - 400: Неизвестный формат
+// - 401: Пользователь не авторизован
+func (h *Handler) importURLs(c *gin.Context) {
+	format := importFormat(c)
+	if _, ok := exportContentTypes[format]; !ok {
+		response(c, http.StatusBadRequest, fmt.Errorf("unsupported format %q", format), nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	var reader recordReader
+	switch format {
+	case model.FormatCSV:
+		reader = newCSVRecordReader(c.Request.Body)
+	case model.FormatNDJSON:
+		reader = &ndjsonRecordReader{dec: json.NewDecoder(c.Request.Body)}
+	default:
+		reader = &jsonRecordReader{dec: json.NewDecoder(c.Request.Body)}
+	}
+
+	c.Header("Content-Type", exportContentTypes[model.FormatJSON])
+	c.Status(http.StatusOK)
+
+	report := newJSONArrayWriter(c.Writer)
+	created := 0
+	for row := 1; ; row++ {
+		rec, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var res model.ImportResult
+		if err != nil {
+			res = model.ImportResult{Status: model.ImportFailed, Error: err.Error()}
+		} else {
+			res = h.service.ImportLink(c.Request.Context(), userID, rec)
+		}
+		res.Row = row
+		if res.Status == model.ImportCreated {
+			created++
+		}
+
+		if werr := report.Write(res); werr != nil {
+			util.GetLogger().Errorf("failed to write import report: %v", werr)
+			return
+		}
+		if err != nil && !errors.As(err, &errRow{}) {
+			break
+		}
+	}
+
+	if err = report.Close(); err != nil {
+		util.GetLogger().Errorf("failed to write import report: %v", err)
+	}
+	util.GetLogger().Infof("imported %d links for user %s", created, userID)
+}
+
+// importFormat определяет формат импорта по параметру "format" или заголовку Content-Type.
+func importFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return model.FormatCSV
+	case "application/x-ndjson":
+		return model.FormatNDJSON
+	default:
+		return model.FormatJSON
+	}
+}
+
+// jsonRecordReader читает записи импорта из JSON-массива.
+type jsonRecordReader struct {
+	dec     *json.Decoder
+	started bool
+}
+
+// Next возвращает очередную запись массива.
+func (r *jsonRecordReader) Next() (model.LinkRecord, error) {
+	var rec model.LinkRecord
+	if !r.started {
+		r.started = true
+		tok, err := r.dec.Token()
+		if err != nil {
+			return rec, err
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+			return rec, errors.New("expected JSON array")
+		}
+	}
+	if !r.dec.More() {
+		return rec, io.EOF
+	}
+
+	return rec, decodeRecord(r.dec, &rec)
+}
+
+// ndjsonRecordReader читает записи импорта, расположенные по одной в строке.
+type ndjsonRecordReader struct {
+	dec *json.Decoder
+}
+
+// Next возвращает очередную запись.
+func (r *ndjsonRecordReader) Next() (model.LinkRecord, error) {
+	var rec model.LinkRecord
+	return rec, decodeRecord(r.dec, &rec)
+}
+
+// decodeRecord читает JSON-запись. Ошибки типов значений не нарушают разбор потока
+// и возвращаются как errRow, остальные ошибки прерывают чтение.
+func decodeRecord(dec *json.Decoder, rec *model.LinkRecord) error {
+	err := dec.Decode(rec)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return errRow{err: err}
+	}
+	return err
+}
+
+// csvRecordReader читает записи импорта из CSV-таблицы с заголовком.
+// Колонки сопоставляются по названиям, неизвестные колонки пропускаются.
+type csvRecordReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+// newCSVRecordReader создает csvRecordReader, читающий из r.
+func newCSVRecordReader(r io.Reader) *csvRecordReader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	return &csvRecordReader{r: cr}
+}
+
+// Next возвращает очередную строку таблицы.
+func (r *csvRecordReader) Next() (model.LinkRecord, error) {
+	if r.columns == nil {
+		header, err := r.r.Read()
+		if err != nil {
+			return model.LinkRecord{}, err
+		}
+		r.columns = make(map[string]int, len(header))
+		for i, name := range header {
+			r.columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := r.columns["original_url"]; !ok {
+			return model.LinkRecord{}, errors.New("missing original_url column")
+		}
+	}
+
+	row, err := r.r.Read()
+	if err != nil {
+		return model.LinkRecord{}, err
+	}
+
+	rec, err := decodeCSVRecord(r.columns, row)
+	if err != nil {
+		return rec, errRow{err: err}
+	}
+	return rec, nil
+}
+
+// encodeCSVRecord преобразует запись экспорта в строку CSV в порядке csvColumns.
+func encodeCSVRecord(rec *model.LinkRecord) ([]string, error) {
+	utm, err := marshalCell(rec.UTM)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := marshalCell(rec.Targets)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := marshalCell(rec.Rules)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := marshalCell(rec.Entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		rec.ID,
+		rec.ShortURL,
+		rec.OriginalURL,
+		rec.Title,
+		rec.Folder,
+		strings.Join(rec.Tags, csvTagSeparator),
+		formatIntCell(rec.MaxClicks),
+		formatTimeCell(rec.ActiveFrom),
+		formatTimeCell(rec.ActiveUntil),
+		formatIntCell(rec.RedirectCode),
+		formatIntCell(rec.CacheMaxAge),
+		rec.Passthrough,
+		utm,
+		targets,
+		rules,
+		entries,
+		formatTimeCell(rec.CreatedAt),
+	}, nil
+}
+
+// decodeCSVRecord преобразует строку CSV в запись импорта.
+// Пустые ячейки оставляют соответствующие параметры незаданными.
+func decodeCSVRecord(columns map[string]int, row []string) (model.LinkRecord, error) {
+	cell := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	rec := model.LinkRecord{
+		ID:          cell("id"),
+		OriginalURL: cell("original_url"),
+		Folder:      cell("folder"),
+	}
+	rec.Title = cell("title")
+	rec.Passthrough = cell("passthrough")
+	if tags := cell("tags"); tags != "" {
+		rec.Tags = strings.Split(tags, csvTagSeparator)
+	}
+
+	var err error
+	if rec.MaxClicks, err = parseIntCell[int64](cell("max_clicks"), "max_clicks"); err != nil {
+		return rec, err
+	}
+	if rec.RedirectCode, err = parseIntCell[int](cell("redirect_code"), "redirect_code"); err != nil {
+		return rec, err
+	}
+	if rec.CacheMaxAge, err = parseIntCell[int](cell("cache_max_age"), "cache_max_age"); err != nil {
+		return rec, err
+	}
+	if rec.ActiveFrom, err = parseTimeCell(cell("active_from"), "active_from"); err != nil {
+		return rec, err
+	}
+	if rec.ActiveUntil, err = parseTimeCell(cell("active_until"), "active_until"); err != nil {
+		return rec, err
+	}
+	if err = unmarshalCell(cell("utm"), "utm", &rec.UTM); err != nil {
+		return rec, err
+	}
+	if err = unmarshalCell(cell("targets"), "targets", &rec.Targets); err != nil {
+		return rec, err
+	}
+	if err = unmarshalCell(cell("rules"), "rules", &rec.Rules); err != nil {
+		return rec, err
+	}
+	if err = unmarshalCell(cell("entries"), "entries", &rec.Entries); err != nil {
+		return rec, err
+	}
+
+	return rec, nil
+}
+
+// formatIntCell записывает необязательное число в ячейку CSV.
+func formatIntCell[T int | int64](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatInt(int64(*v), 10)
+}
+
+// parseIntCell читает необязательное число из ячейки CSV.
+func parseIntCell[T int | int64](s, name string) (*T, error) {
+	if s == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, s)
+	}
+	v := T(n)
+	return &v, nil
+}
+
+// formatTimeCell записывает необязательный момент времени в ячейку CSV в формате RFC 3339.
+func formatTimeCell(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseTimeCell читает необязательный момент времени в формате RFC 3339 из ячейки CSV.
+func parseTimeCell(s, name string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return &t, nil
+}
+
+// marshalCell записывает сложное значение в ячейку CSV в виде JSON, пустые значения дают пустую ячейку.
+func marshalCell[T any](v T) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	if s := string(data); s != "null" && s != "[]" {
+		return s, nil
+	}
+	return "", nil
+}
+
+// unmarshalCell читает сложное значение из JSON в ячейке CSV.
+func unmarshalCell(s, name string, v interface{}) error {
+	if s == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(s), v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
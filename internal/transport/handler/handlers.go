@@ -5,6 +5,7 @@ import (
 	_ "net/http/pprof"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
 	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
 	"github.com/ypxd99/yandex-practicm/internal/service"
 	"github.com/ypxd99/yandex-practicm/util"
@@ -31,6 +32,13 @@ func InitHandler(service service.LinkService) *Handler {
 // - Эндпоинты метрик и проверки работоспособности (/metrics, /health)
 // - Эндпоинты сокращения URL (/, /api/shorten)
 // - Эндпоинты управления URL пользователя (/api/user/urls)
+// - Эндпоинт выпуска токена для клиентов без cookie (/api/auth/token)
+// - Эндпоинт выхода с отзывом токена (/api/auth/logout)
+// - Эндпоинты входа через провайдер OpenID Connect (/api/auth/oidc/*)
+// - Эндпоинты управления API-ключами пользователя (/api/user/keys)
+// Переходы по ссылкам, предпросмотр и публичные QR-коды обрабатываются без аутентификации.
+// Запросы с API-ключом допускаются только к маршрутам, разрешенным его областями доступа.
+// Изменяющие запросы с cookie из чужих источников отклоняются.
 // Принимает экземпляр gin.Engine для настройки маршрутов.
 func (h *Handler) InitRoutes(r *gin.Engine) {
 	// Настройка эндпоинтов профилирования
@@ -46,24 +54,87 @@ func (h *Handler) InitRoutes(r *gin.Engine) {
 	util.GetHealthcheckRoute(r)
 	util.GetRouteList(r)
 
+	// Публичные ключи проверки токенов
+	r.GET("/.well-known/jwks.json", h.getJWKS)
+
 	// Настройка middleware
 	r.Use(middleware.LoggingMiddleware())
 	r.Use(middleware.GzipMiddleware())
-	r.Use(middleware.AuthMiddleware())
 
-	// Настройка основных эндпоинтов
-	r.POST("/", h.shorterLink)
+	// Переходы по ссылкам, их предпросмотр и QR-коды не зависят от пользователя, поэтому регистрируются
+	// до middleware аутентификации: посетители не получают токен и не попадают в реестр пользователей.
 	r.GET("/:id", h.getLinkByID)
+	r.HEAD("/:id", h.getLinkByID)
+	r.GET("/:id/*path", h.getLinkSubpath)
+	r.HEAD("/:id/*path", h.getLinkSubpath)
+
+	r.Use(middleware.AuthMiddleware(h.service))
+	r.Use(middleware.CSRFMiddleware())
+
+	// Настройка основных эндпоинтов
+	r.POST("/", middleware.RequireAuth(model.ScopeLinksWrite), h.shorterLink)
 	r.GET("/ping", h.getStorageStatus)
 
 	// Настройка API эндпоинтов
 	rAPI := r.Group("/api")
-	rAPI.POST("/shorten", h.shorten)
-	rAPI.POST("/shorten/batch", h.batchShorten)
+	shortenAPI := rAPI.Group("/shorten", middleware.RequireAuth(model.ScopeLinksWrite))
+	shortenAPI.POST("", h.shorten)
+	shortenAPI.POST("/batch", h.batchShorten)
+	shortenAPI.POST("/bundle", h.shortenBundle)
+	authAPI := rAPI.Group("/auth", middleware.RequireSession())
+	authAPI.POST("/token", h.issueToken)
+	authAPI.POST("/signup", h.signUp)
+	authAPI.POST("/login", h.login)
+	authAPI.POST("/logout", h.logout)
+	authAPI.GET("/oidc/login", h.startOIDCLogin)
+	authAPI.GET("/oidc/callback", h.completeOIDCLogin)
 
 	// Настройка эндпоинтов для работы с URL пользователя
 	userAPI := rAPI.Group("/user")
 	userAPI.Use(middleware.RequireAuth())
-	userAPI.GET("/urls", h.getUserURLs)
-	userAPI.DELETE("/urls", h.deleteURLs)
+
+	readAPI := userAPI.Group("", middleware.RequireAuth(model.ScopeLinksRead))
+	readAPI.GET("/urls", h.getUserURLs)
+	readAPI.GET("/urls/export", h.exportURLs)
+	readAPI.GET("/urls/:id/history", h.getURLHistory)
+	readAPI.GET("/urls/:id/qr", h.getURLQR)
+	readAPI.GET("/urls/:id/variants", h.getURLVariants)
+	readAPI.GET("/tags", h.getUserTags)
+	readAPI.GET("/transfers", h.getTransfers)
+
+	writeAPI := userAPI.Group("", middleware.RequireAuth(model.ScopeLinksWrite))
+	writeAPI.POST("/urls/restore", h.restoreURLs)
+	writeAPI.POST("/urls/import", h.importURLs)
+	writeAPI.PATCH("/urls/:id", h.updateURL)
+	writeAPI.POST("/urls/:id/rollback", h.rollbackURL)
+	writeAPI.POST("/urls/tags", h.assignLabels)
+	writeAPI.PUT("/tags/:tag", h.renameTag)
+	writeAPI.DELETE("/tags/:tag", h.deleteTag)
+	writeAPI.POST("/transfers", middleware.RequireAuth(model.ScopeLinksDelete), h.createTransfer)
+	writeAPI.POST("/transfers/redeem", h.redeemTransfer)
+
+	deleteAPI := userAPI.Group("", middleware.RequireAuth(model.ScopeLinksDelete))
+	deleteAPI.DELETE("/urls", h.deleteURLs)
+
+	// Управление API-ключами доступно только по токену или cookie пользователя
+	keysAPI := userAPI.Group("/keys", middleware.RequireSession())
+	keysAPI.GET("", h.getAPIKeys)
+	keysAPI.POST("", h.createAPIKey)
+	keysAPI.DELETE("/:id", h.revokeAPIKey)
+
+	// Привязка устройств по коду доступна только по токену или cookie пользователя
+	devicesAPI := userAPI.Group("/devices", middleware.RequireSession())
+	devicesAPI.POST("/code", h.createDeviceCode)
+	devicesAPI.POST("/claim", h.claimDeviceCode)
+
+	// Настройка эндпоинтов администратора
+	adminAPI := rAPI.Group("/admin", middleware.RequireAuth(), middleware.RequireRole(h.service, model.RoleAdmin))
+	adminAPI.GET("/links", h.searchLinks)
+	adminAPI.POST("/links/:id/disable", h.setLinkDisabled(true))
+	adminAPI.POST("/links/:id/enable", h.setLinkDisabled(false))
+	adminAPI.GET("/users", h.getUserStats)
+	adminAPI.GET("/users/count", h.countUsers)
+	adminAPI.GET("/users/:id", h.getUserStats)
+	adminAPI.DELETE("/users/:id", h.purgeUser)
+	adminAPI.GET("/actions", h.getAdminActions)
 }
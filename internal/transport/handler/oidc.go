@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+const (
+	// oidcFlowCookie имя cookie с параметрами незавершенного входа через провайдер OpenID Connect
+	oidcFlowCookie = "oidc_flow"
+	// oidcFlowMaxAge время в секундах, за которое нужно завершить вход у провайдера
+	oidcFlowMaxAge = 600
+)
+
+// errOIDCFlowMissing ошибка, возникающая при возврате от провайдера без начатого входа
+var errOIDCFlowMissing = errors.New("oidc login flow not found")
+
+// startOIDCLogin обрабатывает GET-запрос на вход через провайдер OpenID Connect.
+// Сохраняет параметры входа в cookie и перенаправляет на страницу входа провайдера.
+// Статусы ответа:
+// - 302: Перенаправление к провайдеру
+// - 403: Запрос аутентифицирован API-ключом
+// - 404: Вход через провайдер не настроен
+// - 502: Провайдер недоступен
+func (h *Handler) startOIDCLogin(c *gin.Context) {
+	authURL, flow, err := h.service.StartOIDCLogin(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, service.ErrOIDCDisabled) {
+			response(c, http.StatusNotFound, err, nil)
+			return
+		}
+		response(c, http.StatusBadGateway, err, nil)
+		return
+	}
+
+	data, err := json.Marshal(flow)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	middleware.SetRedirectCookie(c, oidcFlowCookie, base64.RawURLEncoding.EncodeToString(data), oidcFlowMaxAge)
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// completeOIDCLogin обрабатывает GET-запрос возврата от провайдера OpenID Connect.
+// Принимает параметры запроса "state" и "code" и параметры входа из cookie.
+// Ссылки и API-ключи текущего анонимного пользователя переносятся в учетную запись.
+// Токен учетной записи возвращается в cookie, в заголовке ответа и в теле.
+// Возвращает JSON с полями "user", "merged_links", "token", "token_type" и "expires_at".
+// Статусы ответа:
+// - 200: Вход выполнен
+// - 400: Вход не был начат или его время истекло
+// - 401: Провайдер отклонил вход или не подтвердил пользователя
+// - 403: Запрос аутентифицирован API-ключом
+// - 404: Вход через провайдер не настроен
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) completeOIDCLogin(c *gin.Context) {
+	flow, err := readOIDCFlow(c)
+	middleware.ClearCookie(c, oidcFlowCookie)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		response(c, http.StatusUnauthorized, errors.New("identity provider returned error: "+reason), nil)
+		return
+	}
+
+	anonID, _ := middleware.GetUserID(c)
+	user, merged, err := h.service.CompleteOIDCLogin(c.Request.Context(), anonID, flow, c.Query("state"), c.Query("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrOIDCDisabled):
+			response(c, http.StatusNotFound, err, nil)
+		case errors.Is(err, service.ErrOIDCRejected):
+			response(c, http.StatusUnauthorized, err, nil)
+		default:
+			response(c, http.StatusInternalServerError, err, nil)
+		}
+		return
+	}
+
+	h.signIn(c, http.StatusOK, user, merged)
+}
+
+// readOIDCFlow читает параметры незавершенного входа из cookie.
+// Возвращает errOIDCFlowMissing, если cookie отсутствует или повреждена.
+func readOIDCFlow(c *gin.Context) (model.OIDCFlow, error) {
+	var flow model.OIDCFlow
+
+	value, err := c.Cookie(oidcFlowCookie)
+	if err != nil {
+		return flow, errOIDCFlowMissing
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(data, &flow) != nil || flow.State == "" {
+		return flow, errOIDCFlowMissing
+	}
+	return flow, nil
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"os"
+	"sync"
+
+	"github.com/ypxd99/yandex-practicm/util"
+)
+
+// defaultNotActivePage страница, отображаемая для ссылки до начала окна активности,
+// если в конфигурации не задана собственная страница.
+const defaultNotActivePage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Link is not available yet</title></head>
+<body>
+<h1>Link is not available yet</h1>
+<p>This short link has not been activated. Please try again later.</p>
+</body>
+</html>
+`
+
+var (
+	// onceNotActivePage используется для однократной загрузки страницы
+	onceNotActivePage sync.Once
+	// notActivePage содержит загруженную страницу
+	notActivePage []byte
+)
+
+// getNotActivePage возвращает страницу для ссылки, окно активности которой еще не открылось.
+// Загружает страницу из файла, указанного в конфигурации, при первом вызове.
+func getNotActivePage() []byte {
+	onceNotActivePage.Do(func() {
+		notActivePage = []byte(defaultNotActivePage)
+
+		path := util.GetConfig().Links.NotActivePage
+		if path == "" {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			util.GetLogger().Errorf("failed to load not active page: %v", err)
+			return
+		}
+		notActivePage = data
+	})
+
+	return notActivePage
+}
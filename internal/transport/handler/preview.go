@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previewTemplate шаблон страницы предпросмотра ссылки
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="robots" content="noindex"><title>Link preview</title></head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+<p>The short link <code>{{.ShortURL}}</code> leads to:</p>
+<p><a href="{{.OriginalURL}}" rel="noopener noreferrer nofollow">{{.OriginalURL}}</a></p>
+{{if .CreatedAt}}<p>Created {{.CreatedAt.UTC.Format "2006-01-02 15:04 MST"}}</p>{{end}}
+</body>
+</html>
+`))
+
+// previewLink отображает предпросмотр ссылки без перехода по ней.
+// Отдает JSON, если клиент предпочитает его, иначе HTML-страницу.
+// Статусы ответа:
+// - 200: Предпросмотр ссылки
+// - 400: Неверный формат запроса
+// - 404: Окно активности URL еще не открылось
+// - 410: URL был удален, исчерпан лимит переходов или окно активности закрылось
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) previewLink(c *gin.Context, id string) {
+	preview, err := h.service.PreviewLink(c.Request.Context(), id)
+	if err != nil {
+		linkStateError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEJSON {
+		c.JSON(http.StatusOK, preview)
+		return
+	}
+
+	var page bytes.Buffer
+	if err = previewTemplate.Execute(&page, preview); err != nil {
+		responseTextPlain(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
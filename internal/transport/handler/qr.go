@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+const (
+	// qrDefaultSize размер QR-кода по умолчанию в пикселях
+	qrDefaultSize = 256
+	// qrMinSize минимальный размер QR-кода в пикселях
+	qrMinSize = 64
+	// qrMaxSize максимальный размер QR-кода в пикселях
+	qrMaxSize = 2048
+	// qrMaxAge время кэширования QR-кода в секундах
+	qrMaxAge = 86400
+)
+
+// qrLevels соответствие параметра ecc уровню коррекции ошибок
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"l": qrcode.Low,
+	"m": qrcode.Medium,
+	"q": qrcode.High,
+	"h": qrcode.Highest,
+}
+
+// getURLQR обрабатывает GET-запрос на получение QR-кода ссылки пользователя.
+// Принимает идентификатор ссылки в параметре пути и параметры "format", "size" и "ecc" в строке запроса.
+// Возвращает изображение QR-кода полного сокращенного URL.
+// Статусы ответа:
+// - 200: QR-код успешно сформирован
+// - 304: QR-код не изменился
+// - 400: Неверные параметры запроса
+// - 401: Пользователь не авторизован
+// - 404: Ссылка не найдена
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getURLQR(c *gin.Context) {
+	var req model.QRRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		responseTextPlain(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		responseTextPlain(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	shortURL, err := h.service.GetShortURL(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		responseTextPlain(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	renderQR(c, shortURL, req, "private")
+}
+
+// getPublicQR обрабатывает GET-запрос на получение QR-кода любой неудаленной ссылки.
+// Принимает идентификатор ссылки в параметре пути и параметры "format", "size" и "ecc" в строке запроса.
+// Возвращает изображение QR-кода полного сокращенного URL.
+// Статусы ответа:
+// - 200: QR-код успешно сформирован
+// - 304: QR-код не изменился
+// - 400: Неверные параметры запроса
+// - 404: Ссылка не найдена
+// - 410: Ссылка была удалена или отключена
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getPublicQR(c *gin.Context) {
+	var req model.QRRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		responseTextPlain(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	shortURL, err := h.service.GetPublicShortURL(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrURLDeleted) || errors.Is(err, service.ErrURLDisabled) {
+			responseTextPlain(c, http.StatusGone, err, nil)
+			return
+		}
+		responseTextPlain(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	renderQR(c, shortURL, req, "public")
+}
+
+// qrParams представляет проверенные параметры QR-кода.
+type qrParams struct {
+	format string
+	size   int
+	ecc    string
+	level  qrcode.RecoveryLevel
+}
+
+// parseQRParams проверяет параметры запроса QR-кода и подставляет значения по умолчанию.
+// Возвращает ошибку, если формат, размер или уровень коррекции ошибок не поддерживаются.
+func parseQRParams(req model.QRRequest) (qrParams, error) {
+	params := qrParams{
+		format: strings.ToLower(req.Format),
+		size:   req.Size,
+		ecc:    strings.ToLower(req.ECC),
+	}
+
+	if params.format == "" {
+		params.format = "png"
+	}
+	if params.format != "png" && params.format != "svg" {
+		return params, fmt.Errorf("unsupported format %q", req.Format)
+	}
+
+	if params.size == 0 {
+		params.size = qrDefaultSize
+	}
+	if params.size < qrMinSize || params.size > qrMaxSize {
+		return params, fmt.Errorf("size must be between %d and %d", qrMinSize, qrMaxSize)
+	}
+
+	if params.ecc == "" {
+		params.ecc = "m"
+	}
+	level, ok := qrLevels[params.ecc]
+	if !ok {
+		return params, fmt.Errorf("unsupported ecc %q", req.ECC)
+	}
+	params.level = level
+
+	return params, nil
+}
+
+// encodeQR формирует изображение QR-кода с содержимым content.
+// Возвращает изображение, его MIME-тип и ошибку, если сформировать изображение не удалось.
+func encodeQR(content string, params qrParams) ([]byte, string, error) {
+	qr, err := qrcode.New(content, params.level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if params.format == "svg" {
+		return qrSVG(qr.Bitmap(), params.size), "image/svg+xml", nil
+	}
+
+	png, err := qr.PNG(params.size)
+	if err != nil {
+		return nil, "", err
+	}
+	return png, "image/png", nil
+}
+
+// renderQR формирует QR-код с содержимым content и отправляет его клиенту.
+// Изображение однозначно определяется содержимым и параметрами запроса,
+// поэтому ответ кэшируется и сопровождается ETag.
+func renderQR(c *gin.Context, content string, req model.QRRequest, cacheScope string) {
+	params, err := parseQRParams(req)
+	if err != nil {
+		responseTextPlain(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join([]string{content, params.format, strconv.Itoa(params.size), params.ecc}, "|")))
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("Cache-Control", fmt.Sprintf("%s, max-age=%d", cacheScope, qrMaxAge))
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	image, contentType, err := encodeQR(content, params)
+	if err != nil {
+		responseTextPlain(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+	c.Data(http.StatusOK, contentType, image)
+}
+
+// qrSVG формирует SVG-изображение QR-кода по матрице модулей.
+// Каждый темный модуль рисуется квадратом единичного размера внутри общего пути.
+func qrSVG(bitmap [][]bool, size int) []byte {
+	var b strings.Builder
+	n := len(bitmap)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#ffffff"/><path fill="#000000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+	return []byte(b.String())
+}
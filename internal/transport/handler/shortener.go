@@ -3,16 +3,26 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/ypxd99/yandex-practicm/internal/model"
 	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
 	"github.com/ypxd99/yandex-practicm/internal/service"
 	"github.com/ypxd99/yandex-practicm/util"
 )
 
+const (
+	// visitorCookieMaxAge время жизни cookie посетителя в секундах
+	visitorCookieMaxAge = 3600 * 24 * 365
+	// defaultVisitorCookie имя cookie посетителя по умолчанию
+	defaultVisitorCookie = "visitor_id"
+)
+
 // shorterLink обрабатывает POST-запрос для сокращения URL.
 // Принимает URL в теле запроса в виде текста.
 // Возвращает сокращенный URL в теле ответа.
@@ -38,7 +48,7 @@ func (h *Handler) shorterLink(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.ShorterLink(c.Request.Context(), string(body), userID)
+	resp, err := h.service.ShorterLink(c.Request.Context(), string(body), userID, model.LinkOptions{})
 	if err != nil {
 		if errors.Is(err, service.ErrURLExist) {
 			responseTextPlain(c, http.StatusConflict, nil, []byte(resp))
@@ -53,11 +63,22 @@ func (h *Handler) shorterLink(c *gin.Context) {
 
 // getLinkByID обрабатывает GET-запрос для получения оригинального URL по его сокращенному идентификатору.
 // Принимает идентификатор в параметре пути.
-// Выполняет редирект на оригинальный URL.
+// Выполняет редирект на оригинальный URL со статусом и заголовком Cache-Control, заданными для ссылки.
+// Дополнительный путь после идентификатора и параметры запроса передаются в оригинальный URL,
+// если это разрешено для ссылки.
+// HEAD-запрос возвращает те же статус и заголовки, но не учитывается как переход.
+// Правила ссылки выбирают адрес по платформе из User-Agent, Accept-Language и времени суток.
+// Для ссылок с вариантами адреса выдает посетителю cookie, закрепляющую за ним вариант.
+// Для подборки отображает ее страницу, а с параметром "entry" выполняет редирект на выбранную ссылку.
+// Если идентификатор оканчивается на "+" или передан параметр "preview=1",
+// вместо редиректа отображает предпросмотр ссылки.
 // Статусы ответа:
-// - 307: Редирект на оригинальный URL
+// - 200: Предпросмотр ссылки или страница подборки
+// - 301, 302, 303, 307, 308: Редирект на оригинальный URL
 // - 400: Неверный формат запроса
-// - 410: URL был удален
+// - 404: Окно активности URL еще не открылось, ссылка не принимает дополнительный путь
+// или ссылка подборки не найдена
+// - 410: URL был удален, исчерпан лимит переходов или окно активности закрылось
 // - 500: Внутренняя ошибка сервера
 func (h *Handler) getLinkByID(c *gin.Context) {
 	req := c.Param("id")
@@ -65,22 +86,88 @@ func (h *Handler) getLinkByID(c *gin.Context) {
 		responseTextPlain(c, http.StatusBadRequest, errors.New("empty data"), nil)
 		return
 	}
+	if id, ok := strings.CutSuffix(req, "+"); ok || c.Query("preview") == "1" {
+		h.previewLink(c, id)
+		return
+	}
 
-	resp, err := h.service.FindLink(c.Request.Context(), req)
+	find := h.service.FindLink
+	if c.Request.Method == http.MethodHead {
+		find = h.service.LookupLink
+	}
+	cookieName := util.GetConfig().Links.VisitorCookie
+	if cookieName == "" {
+		cookieName = defaultVisitorCookie
+	}
+	visitorID, err := c.Cookie(cookieName)
+	if err != nil || visitorID == "" {
+		visitorID = uuid.New().String()
+	}
+	visit := model.Visit{
+		Path:           c.Param("path"),
+		Query:          c.Request.URL.Query(),
+		VisitorID:      visitorID,
+		UserAgent:      c.Request.UserAgent(),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+	}
+	resp, err := find(c.Request.Context(), req, visit)
 	if err != nil {
-		if errors.Is(err, service.ErrURLDeleted) {
-			responseTextPlain(c, http.StatusGone, err, nil)
-			return
-		}
-		responseTextPlain(c, http.StatusBadRequest, err, nil)
+		linkStateError(c, err)
 		return
 	}
 
-	c.Redirect(http.StatusTemporaryRedirect, resp)
+	if resp.Variant != "" {
+		middleware.SetCookie(c, cookieName, visitorID, visitorCookieMaxAge)
+	}
+
+	// Ответ с вариантом адреса зависит от cookie посетителя и не должен попадать в общие кэши.
+	if resp.MaxAge > 0 && resp.Variant == "" {
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", resp.MaxAge))
+	} else {
+		c.Header("Cache-Control", "private, no-cache, no-store, must-revalidate")
+	}
+	if resp.Bundle != nil {
+		renderBundle(c, resp.Bundle)
+		return
+	}
+	c.Redirect(resp.Code, resp.URL)
+}
+
+// linkStateError отправляет ответ для ошибки перехода по ссылке.
+// Удаленные, отключенные, исчерпанные и истекшие ссылки возвращают 410, неактивные - страницу с 404.
+func linkStateError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrURLDeleted) ||
+		errors.Is(err, service.ErrURLDisabled) ||
+		errors.Is(err, service.ErrURLExhausted) ||
+		errors.Is(err, service.ErrURLExpired) {
+		responseTextPlain(c, http.StatusGone, err, nil)
+		return
+	}
+	if errors.Is(err, service.ErrURLNotActive) {
+		c.Data(http.StatusNotFound, "text/html; charset=utf-8", getNotActivePage())
+		return
+	}
+	if errors.Is(err, service.ErrURLNotFound) {
+		responseTextPlain(c, http.StatusNotFound, err, nil)
+		return
+	}
+	responseTextPlain(c, http.StatusBadRequest, err, nil)
+}
+
+// getLinkSubpath обрабатывает запросы к путям вида /{id}/...
+// Путь /{id}/qr отдает QR-код ссылки, остальные пути обрабатываются как переход
+// с передачей дополнительного пути в оригинальный URL.
+func (h *Handler) getLinkSubpath(c *gin.Context) {
+	if c.Param("path") == "/qr" {
+		h.getPublicQR(c)
+		return
+	}
+
+	h.getLinkByID(c)
 }
 
 // shorten обрабатывает POST-запрос для сокращения URL через API.
-// Принимает JSON с полем "url".
+// Принимает JSON с полем "url" и необязательным полем "max_clicks".
 // Возвращает JSON с полем "result", содержащим сокращенный URL.
 // Статусы ответа:
 // - 201: URL успешно сокращен
@@ -114,12 +201,16 @@ func (h *Handler) shorten(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.ShorterLink(c.Request.Context(), req.URL, userID)
+	resp, err := h.service.ShorterLink(c.Request.Context(), req.URL, userID, req.LinkOptions)
 	if err != nil {
 		if errors.Is(err, service.ErrURLExist) {
 			response(c, http.StatusConflict, nil, model.ShortenResponse{Result: resp})
 			return
 		}
+		if errors.Is(err, service.ErrInvalidOptions) {
+			response(c, http.StatusBadRequest, err, model.ShortenResponse{Result: ""})
+			return
+		}
 		response(c, http.StatusInternalServerError, err, model.ShortenResponse{Result: ""})
 		return
 	}
@@ -178,6 +269,10 @@ func (h *Handler) batchShorten(c *gin.Context) {
 
 	resp, err := h.service.BatchShorten(c.Request.Context(), req, userID)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidOptions) {
+			response(c, http.StatusBadRequest, err, nil)
+			return
+		}
 		response(c, http.StatusInternalServerError, err, nil)
 		return
 	}
@@ -186,10 +281,12 @@ func (h *Handler) batchShorten(c *gin.Context) {
 }
 
 // getUserURLs обрабатывает GET-запрос для получения списка URL пользователя.
+// Принимает необязательные параметры запроса "tag" и "folder" для отбора ссылок.
 // Возвращает массив JSON-объектов с полями "short_url" и "original_url".
 // Статусы ответа:
 // - 200: Список URL успешно получен
 // - 204: У пользователя нет сохраненных URL
+// - 400: Неверное название папки
 // - 401: Пользователь не авторизован
 // - 500: Внутренняя ошибка сервера
 func (h *Handler) getUserURLs(c *gin.Context) {
@@ -199,8 +296,18 @@ func (h *Handler) getUserURLs(c *gin.Context) {
 		return
 	}
 
-	urls, err := h.service.GetUserURLs(c.Request.Context(), userID)
+	var filter model.LinkFilter
+	filter.Tag = c.Query("tag")
+	if folder, ok := c.GetQuery("folder"); ok {
+		filter.Folder = &folder
+	}
+
+	urls, err := h.service.GetUserURLs(c.Request.Context(), userID, filter)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidOptions) {
+			responseTextPlain(c, http.StatusBadRequest, err, nil)
+			return
+		}
 		responseTextPlain(c, http.StatusInternalServerError, err, nil)
 		return
 	}
@@ -213,6 +320,184 @@ func (h *Handler) getUserURLs(c *gin.Context) {
 	c.JSON(http.StatusOK, urls)
 }
 
+// updateURL обрабатывает PATCH-запрос на изменение параметров ссылки пользователя.
+// Принимает идентификатор ссылки в параметре пути и JSON с изменяемыми полями.
+// Возвращает JSON с обновленным описанием ссылки.
+// Статусы ответа:
+// - 200: Ссылка успешно изменена
+// - 400: Неверный формат запроса
+// - 401: Пользователь не авторизован
+// - 404: Ссылка не найдена
+// - 409: Новый URL уже сокращен или адрес ссылки с постоянным либо кэшируемым редиректом не может быть изменен
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) updateURL(c *gin.Context) {
+	var (
+		err error
+		req model.UpdateLinkRequest
+	)
+
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	resp, err := h.service.UpdateLink(c.Request.Context(), c.Param("id"), userID, req)
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, resp)
+}
+
+// getURLHistory обрабатывает GET-запрос на получение истории изменений ссылки пользователя.
+// Принимает идентификатор ссылки в параметре пути.
+// Возвращает массив JSON-объектов с полями "version", "original_url" и "replaced_at".
+// Статусы ответа:
+// - 200: История успешно получена
+// - 401: Пользователь не авторизован
+// - 404: Ссылка не найдена
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getURLHistory(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	history, err := h.service.GetLinkHistory(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, history)
+}
+
+// getURLVariants обрабатывает GET-запрос на получение статистики вариантов адреса ссылки пользователя.
+// Принимает идентификатор ссылки в параметре пути.
+// Возвращает массив JSON-объектов с полями "name", "url", "weight" и "clicks".
+// Статусы ответа:
+// - 200: Статистика успешно получена
+// - 401: Пользователь не авторизован
+// - 404: Ссылка не найдена
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getURLVariants(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	stats, err := h.service.GetVariantStats(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, stats)
+}
+
+// rollbackURL обрабатывает POST-запрос на восстановление версии ссылки из истории.
+// Принимает идентификатор ссылки в параметре пути и JSON с полем "version".
+// Возвращает JSON с обновленным описанием ссылки.
+// Статусы ответа:
+// - 200: Версия успешно восстановлена
+// - 400: Неверный формат запроса
+// - 401: Пользователь не авторизован
+// - 404: Ссылка или версия не найдена
+// - 409: URL версии уже сокращен другой ссылкой или редирект ссылки постоянный либо кэшируемый
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) rollbackURL(c *gin.Context) {
+	var (
+		err error
+		req model.RollbackRequest
+	)
+
+	err = c.ShouldBindJSON(&req)
+	if err != nil || req.Version <= 0 {
+		response(c, http.StatusBadRequest, errors.New("invalid version"), nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	resp, err := h.service.RollbackLink(c.Request.Context(), c.Param("id"), userID, req.Version)
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, resp)
+}
+
+// userLinkErrorStatus возвращает HTTP-статус для ошибки операции над ссылкой пользователя.
+func userLinkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrURLNotFound), errors.Is(err, service.ErrVersionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidOptions):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrURLExist), errors.Is(err, service.ErrURLCached):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// restoreURLs обрабатывает POST-запрос на восстановление удаленных URL пользователя.
+// Принимает массив идентификаторов URL в теле запроса.
+// Возвращает JSON с полями "restored", "conflicts" и "skipped": восстановленные ссылки,
+// ссылки, оригинальный URL которых уже сокращен другой ссылкой, и остальные ссылки,
+// которые не удалось восстановить.
+// Статусы ответа:
+// - 200: Запрос на восстановление обработан
+// - 400: Неверный формат запроса
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) restoreURLs(c *gin.Context) {
+	var (
+		err error
+		req model.RestoreRequest
+	)
+
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	if len(req) == 0 {
+		response(c, http.StatusBadRequest, errors.New("empty url list"), nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	resp, err := h.service.RestoreURLs(c.Request.Context(), req, userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, resp)
+}
+
 // deleteURLs обрабатывает DELETE-запрос для удаления URL пользователя.
 // Принимает массив идентификаторов URL в теле запроса.
 // Выполняет мягкое удаление (помечает URL как удаленные).
@@ -117,7 +117,7 @@ func BenchmarkShortenLink(b *testing.B) {
 	body, _ := json.Marshal(req)
 
 	// Настраиваем мок
-	mockRepo.On("CreateLink", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&model.Link{
+	mockRepo.On("CreateLink", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(&model.Link{
 		ID:     "test123",
 		Link:   req.URL,
 		UserID: userID,
@@ -180,7 +180,7 @@ func BenchmarkBatchShorten(b *testing.B) {
 
 func BenchmarkGetUserURLs(b *testing.B) {
 	router, mockRepo := setupTestRouter()
-	mockRepo.On("FindUserLinks", mock.Anything, mock.Anything).Return([]model.Link{
+	mockRepo.On("FindUserLinks", mock.Anything, mock.Anything, mock.Anything).Return([]model.Link{
 		{ID: "test1", Link: "http://test1.com", UserID: uuid.New(), IsDeleted: false},
 		{ID: "test2", Link: "http://test2.com", UserID: uuid.New(), IsDeleted: false},
 	}, nil)
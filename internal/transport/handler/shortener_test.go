@@ -2,11 +2,23 @@ package handler_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,6 +26,8 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/ypxd99/yandex-practicm/internal/mocks"
 	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
 	"github.com/ypxd99/yandex-practicm/internal/transport/handler"
 	"github.com/ypxd99/yandex-practicm/util"
 )
@@ -28,6 +42,11 @@ func setupRouter(service *mocks.MockLinkService) *gin.Engine {
 		c.Next()
 	})
 
+	// Токены по умолчанию не отозваны. Тесты отзыва задают ожидание до вызова setupRouter.
+	service.On("IsTokenRevoked", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(false, nil).Maybe()
+	service.On("TouchUser", mock.Anything).Maybe()
+	service.On("UserRole", mock.Anything, mock.Anything).Return("", nil).Maybe()
+
 	h := handler.InitHandler(service)
 	h.InitRoutes(r)
 	return r
@@ -42,7 +61,7 @@ func TestShorterLinkHandler(t *testing.T) {
 		router := setupRouter(mockService)
 
 		url := "https://yandex.ru"
-		mockService.On("ShorterLink", mock.Anything, url, mock.AnythingOfType("uuid.UUID")).
+		mockService.On("ShorterLink", mock.Anything, url, mock.AnythingOfType("uuid.UUID"), model.LinkOptions{}).
 			Return("abc123", nil).
 			Once()
 
@@ -61,7 +80,7 @@ func TestShorterLinkHandler(t *testing.T) {
 		router := setupRouter(mockService)
 
 		url := "https://error.com"
-		mockService.On("ShorterLink", mock.Anything, url, mock.AnythingOfType("uuid.UUID")).
+		mockService.On("ShorterLink", mock.Anything, url, mock.AnythingOfType("uuid.UUID"), model.LinkOptions{}).
 			Return("", errors.New("service error")).
 			Once()
 
@@ -85,8 +104,8 @@ func TestGetLinkByIDHandler(t *testing.T) {
 
 		id := "abc123"
 		target := "https://yandex.ru"
-		mockService.On("FindLink", mock.Anything, id).
-			Return(target, nil).
+		mockService.On("FindLink", mock.Anything, id, mock.Anything).
+			Return(&model.Redirect{URL: target, Code: http.StatusTemporaryRedirect}, nil).
 			Once()
 
 		req := httptest.NewRequest("GET", "/"+id, nil)
@@ -96,6 +115,11 @@ func TestGetLinkByIDHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
 		assert.Equal(t, target, resp.Header().Get("Location"))
+		// Посетитель ссылки не получает токен и не попадает в реестр пользователей.
+		for _, cookie := range resp.Result().Cookies() {
+			assert.NotEqual(t, cfg.Auth.CookieName, cookie.Name)
+		}
+		mockService.AssertNotCalled(t, "TouchUser", mock.Anything)
 		mockService.AssertExpectations(t)
 	})
 
@@ -104,8 +128,8 @@ func TestGetLinkByIDHandler(t *testing.T) {
 		router := setupRouter(mockService)
 
 		id := "invalid"
-		mockService.On("FindLink", mock.Anything, id).
-			Return("", errors.New("not found")).
+		mockService.On("FindLink", mock.Anything, id, mock.Anything).
+			Return(nil, errors.New("not found")).
 			Once()
 
 		req := httptest.NewRequest("GET", "/"+id, nil)
@@ -116,227 +140,2207 @@ func TestGetLinkByIDHandler(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, resp.Code)
 		mockService.AssertExpectations(t)
 	})
+
+	t.Run("click limit reached", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		id := "once"
+		mockService.On("FindLink", mock.Anything, id, mock.Anything).
+			Return(nil, service.ErrURLExhausted).
+			Once()
+
+		req := httptest.NewRequest("GET", "/"+id, nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusGone, resp.Code)
+		mockService.AssertExpectations(t)
+	})
 }
 
-func TestShortenHandler(t *testing.T) {
+func TestRedirectPolicyHandler(t *testing.T) {
 	cfg := util.GetConfig()
 	util.InitLogger(cfg.Logger)
 
-	t.Run("successful request", func(t *testing.T) {
+	t.Run("permanent cacheable redirect", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		input := model.ShortenRequest{
-			URL: "https://yandex.ru",
-		}
-		mockService.On("ShorterLink", mock.Anything, input.URL, mock.AnythingOfType("uuid.UUID")).
-			Return("abc123", nil).
+		mockService.On("FindLink", mock.Anything, "seo", mock.Anything).
+			Return(&model.Redirect{URL: "https://example.com", Code: http.StatusPermanentRedirect, MaxAge: 3600}, nil).
 			Once()
 
-		body, _ := json.Marshal(input)
-		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		req := httptest.NewRequest(http.MethodGet, "/seo", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusPermanentRedirect, resp.Code)
+		assert.Equal(t, "https://example.com", resp.Header().Get("Location"))
+		assert.Equal(t, "public, max-age=3600", resp.Header().Get("Cache-Control"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("uncacheable redirect", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
 
+		mockService.On("FindLink", mock.Anything, "stats", mock.Anything).
+			Return(&model.Redirect{URL: "https://example.com", Code: http.StatusFound}, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/stats", nil)
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusCreated, resp.Code)
+		assert.Equal(t, http.StatusFound, resp.Code)
+		assert.Contains(t, resp.Header().Get("Cache-Control"), "no-store")
+		mockService.AssertExpectations(t)
+	})
 
-		var response model.ShortenResponse
-		err := json.Unmarshal(resp.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		assert.Equal(t, "abc123", response.Result)
+	t.Run("head does not count click", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("LookupLink", mock.Anything, "seo", mock.Anything).
+			Return(&model.Redirect{URL: "https://example.com", Code: http.StatusMovedPermanently, MaxAge: 60}, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodHead, "/seo", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
 
+		assert.Equal(t, http.StatusMovedPermanently, resp.Code)
+		assert.Equal(t, "https://example.com", resp.Header().Get("Location"))
+		assert.Equal(t, "public, max-age=60", resp.Header().Get("Cache-Control"))
 		mockService.AssertExpectations(t)
 	})
+}
 
-	t.Run("invalid URL", func(t *testing.T) {
+func TestPassthroughHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("extra path and query", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		input := struct {
-			URL string `json:"url"`
-		}{
-			URL: "",
-		}
+		mockService.On("FindLink", mock.Anything, "abc123", mock.MatchedBy(func(v model.Visit) bool {
+			return v.Path == "/extra/path" && v.Query.Get("x") == "1"
+		})).
+			Return(&model.Redirect{URL: "https://example.com/extra/path?x=1", Code: http.StatusFound}, nil).
+			Once()
 
-		body, _ := json.Marshal(input)
-		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		req := httptest.NewRequest(http.MethodGet, "/abc123/extra/path?x=1", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
 
+		assert.Equal(t, http.StatusFound, resp.Code)
+		assert.Equal(t, "https://example.com/extra/path?x=1", resp.Header().Get("Location"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("passthrough disabled", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("FindLink", mock.Anything, "abc123", mock.Anything).
+			Return(nil, service.ErrURLNotFound).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123/extra", nil)
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
 		mockService.AssertExpectations(t)
 	})
+}
 
-	t.Run("service error", func(t *testing.T) {
+func TestPreviewHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	preview := &model.LinkPreview{
+		ShortURL:    "http://localhost:8080/abc123",
+		OriginalURL: "https://example.com/<docs>",
+		Title:       "Docs",
+	}
+
+	t.Run("html page", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		input := model.ShortenRequest{
-			URL: "https://yandex.ru",
-		}
-		mockService.On("ShorterLink", mock.Anything, input.URL, mock.AnythingOfType("uuid.UUID")).
-			Return("", errors.New("service error")).
+		mockService.On("PreviewLink", mock.Anything, "abc123").
+			Return(preview, nil).
 			Once()
 
-		body, _ := json.Marshal(input)
-		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		req := httptest.NewRequest(http.MethodGet, "/abc123+", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, resp.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, resp.Body.String(), "https://example.com/%3cdocs%3e")
+		assert.NotContains(t, resp.Body.String(), "<docs>")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("json by query", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("PreviewLink", mock.Anything, "abc123").
+			Return(preview, nil).
+			Once()
 
+		req := httptest.NewRequest(http.MethodGet, "/abc123?preview=1", nil)
+		req.Header.Set("Accept", "application/json")
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"short_url":"http://localhost:8080/abc123","original_url":"https://example.com/<docs>","title":"Docs"}`,
+			resp.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("deleted link", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("PreviewLink", mock.Anything, "gone").
+			Return(nil, service.ErrURLDeleted).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/gone+", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusGone, resp.Code)
 		mockService.AssertExpectations(t)
 	})
 }
 
-func TestBatchShortenHandler(t *testing.T) {
+func TestGetLinkByIDActiveWindowHandler(t *testing.T) {
 	cfg := util.GetConfig()
 	util.InitLogger(cfg.Logger)
 
-	t.Run("successful batch", func(t *testing.T) {
+	t.Run("not active yet", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		input := []model.BatchRequest{
-			{CorrelationID: "1", OriginalURL: "https://example.com"},
-			{CorrelationID: "2", OriginalURL: "https://yandex.ru"},
-		}
+		mockService.On("FindLink", mock.Anything, "soon", mock.Anything).
+			Return(nil, service.ErrURLNotActive).
+			Once()
 
-		output := []model.BatchResponse{
-			{CorrelationID: "1", ShortURL: "http://localhost:8080/abc123"},
-			{CorrelationID: "2", ShortURL: "http://localhost:8080/def456"},
-		}
+		req := httptest.NewRequest("GET", "/soon", nil)
+		resp := httptest.NewRecorder()
 
-		mockService.On("BatchShorten", mock.Anything, input, mock.AnythingOfType("uuid.UUID")).
-			Return(output, nil).
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		assert.Contains(t, resp.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, resp.Body.String(), "not available yet")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("FindLink", mock.Anything, "late", mock.Anything).
+			Return(nil, service.ErrURLExpired).
 			Once()
 
-		body, _ := json.Marshal(input)
-		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewBuffer(body))
+		req := httptest.NewRequest("GET", "/late", nil)
+		resp := httptest.NewRecorder()
+
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusGone, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestUpdateURLHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("successful update", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("UpdateLink", mock.Anything, "abc123", mock.AnythingOfType("uuid.UUID"),
+			mock.MatchedBy(func(r model.UpdateLinkRequest) bool {
+				return r.ActiveFrom.Set && r.ActiveFrom.Value != nil && r.ActiveUntil.Set && r.ActiveUntil.Value == nil
+			})).
+			Return(&model.UserURLResponse{ShortURL: "http://localhost:8080/abc123", OriginalURL: "https://example.com"}, nil).
+			Once()
+
+		body := `{"active_from":"2030-01-01T00:00:00Z","active_until":null}`
+		req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/abc123", bytes.NewBufferString(body))
 		req.Header.Set("Content-Type", "application/json")
 
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusCreated, resp.Code)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		mockService.AssertExpectations(t)
+	})
 
-		var response []model.BatchResponse
-		err := json.Unmarshal(resp.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		assert.Len(t, response, 2)
+	t.Run("not found", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("UpdateLink", mock.Anything, "missing", mock.AnythingOfType("uuid.UUID"), mock.Anything).
+			Return(nil, service.ErrURLNotFound).
+			Once()
+
+		req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/missing", bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
 
+		assert.Equal(t, http.StatusNotFound, resp.Code)
 		mockService.AssertExpectations(t)
 	})
 }
 
-func TestGetUserURLsHandler(t *testing.T) {
+func TestURLHistoryHandler(t *testing.T) {
 	cfg := util.GetConfig()
 	util.InitLogger(cfg.Logger)
 
-	t.Run("successful get user urls", func(t *testing.T) {
+	t.Run("get history", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		output := []model.UserURLResponse{
-			{ShortURL: "http://localhost:8080/abc123", OriginalURL: "https://example.com"},
-			{ShortURL: "http://localhost:8080/def456", OriginalURL: "https://yandex.ru"},
-		}
-
-		mockService.On("GetUserURLs", mock.Anything, mock.AnythingOfType("uuid.UUID")).
-			Return(output, nil).
+		history := []model.LinkVersion{{Version: 1, OriginalURL: "https://old.example.com"}}
+		mockService.On("GetLinkHistory", mock.Anything, "abc123", mock.AnythingOfType("uuid.UUID")).
+			Return(history, nil).
 			Once()
 
-		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
-
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/abc123/history", nil)
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
 		assert.Equal(t, http.StatusOK, resp.Code)
 
-		var response []model.UserURLResponse
+		var response []model.LinkVersion
 		err := json.Unmarshal(resp.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Len(t, response, 2)
-
+		assert.Equal(t, history[0].OriginalURL, response[0].OriginalURL)
 		mockService.AssertExpectations(t)
 	})
 
-	t.Run("no content", func(t *testing.T) {
+	t.Run("rollback unknown version", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		var emptyOutput []model.UserURLResponse
-
-		mockService.On("GetUserURLs", mock.Anything, mock.AnythingOfType("uuid.UUID")).
-			Return(emptyOutput, nil).
+		mockService.On("RollbackLink", mock.Anything, "abc123", mock.AnythingOfType("uuid.UUID"), 3).
+			Return(nil, service.ErrVersionNotFound).
 			Once()
 
-		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
-
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/abc123/rollback", bytes.NewBufferString(`{"version":3}`))
+		req.Header.Set("Content-Type", "application/json")
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusNoContent, resp.Code)
-
+		assert.Equal(t, http.StatusNotFound, resp.Code)
 		mockService.AssertExpectations(t)
 	})
 }
 
-func TestDeleteURLsHandler(t *testing.T) {
+func TestQRHandler(t *testing.T) {
 	cfg := util.GetConfig()
 	util.InitLogger(cfg.Logger)
 
-	t.Run("successful delete", func(t *testing.T) {
+	t.Run("user png", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		input := []string{"abc123", "def456"}
-
-		mockService.On("DeleteURLs", mock.Anything, input, mock.AnythingOfType("uuid.UUID")).
-			Return(2, nil).
+		mockService.On("GetShortURL", mock.Anything, "abc123", mock.AnythingOfType("uuid.UUID")).
+			Return("http://localhost:8080/abc123", nil).
 			Once()
 
-		body, _ := json.Marshal(input)
-		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/abc123/qr?size=128&ecc=h", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "image/png", resp.Header().Get("Content-Type"))
+		assert.Contains(t, resp.Header().Get("Cache-Control"), "private")
+		assert.True(t, bytes.HasPrefix(resp.Body.Bytes(), []byte("\x89PNG")))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("public svg with etag", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
 
+		mockService.On("GetPublicShortURL", mock.Anything, "abc123").
+			Return("http://localhost:8080/abc123", nil).
+			Twice()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123/qr?format=svg", nil)
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusAccepted, resp.Code)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "image/svg+xml", resp.Header().Get("Content-Type"))
+		assert.Contains(t, resp.Body.String(), "<svg")
+		etag := resp.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+
+		req = httptest.NewRequest(http.MethodGet, "/abc123/qr?format=svg", nil)
+		req.Header.Set("If-None-Match", etag)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
 
+		assert.Equal(t, http.StatusNotModified, resp.Code)
 		mockService.AssertExpectations(t)
 	})
 
-	t.Run("empty url list", func(t *testing.T) {
+	t.Run("public deleted", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		body, _ := json.Marshal([]string{})
-		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		mockService.On("GetPublicShortURL", mock.Anything, "gone").
+			Return("", service.ErrURLDeleted).
+			Once()
 
+		req := httptest.NewRequest(http.MethodGet, "/gone/qr", nil)
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, http.StatusGone, resp.Code)
+		mockService.AssertExpectations(t)
 	})
 
-	t.Run("invalid json", func(t *testing.T) {
+	t.Run("invalid params", func(t *testing.T) {
 		mockService := new(mocks.MockLinkService)
 		router := setupRouter(mockService)
 
-		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBufferString("invalid json"))
+		mockService.On("GetPublicShortURL", mock.Anything, "abc123").
+			Return("http://localhost:8080/abc123", nil).
+			Twice()
+
+		for _, query := range []string{"format=gif", "size=10"} {
+			req := httptest.NewRequest(http.MethodGet, "/abc123/qr?"+query, nil)
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		}
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestShortenHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("successful request", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		input := model.ShortenRequest{
+			URL: "https://yandex.ru",
+		}
+		mockService.On("ShorterLink", mock.Anything, input.URL, mock.AnythingOfType("uuid.UUID"), model.LinkOptions{}).
+			Return("abc123", nil).
+			Once()
+
+		body, _ := json.Marshal(input)
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 
 		resp := httptest.NewRecorder()
 		router.ServeHTTP(resp, req)
 
-		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+
+		var response model.ShortenResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, "abc123", response.Result)
+
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		input := struct {
+			URL string `json:"url"`
+		}{
+			URL: "",
+		}
+
+		body, _ := json.Marshal(input)
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		input := model.ShortenRequest{
+			URL: "https://yandex.ru",
+		}
+		mockService.On("ShorterLink", mock.Anything, input.URL, mock.AnythingOfType("uuid.UUID"), model.LinkOptions{}).
+			Return("", errors.New("service error")).
+			Once()
+
+		body, _ := json.Marshal(input)
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestBatchShortenHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("successful batch", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		input := []model.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "https://example.com"},
+			{CorrelationID: "2", OriginalURL: "https://yandex.ru"},
+		}
+
+		output := []model.BatchResponse{
+			{CorrelationID: "1", ShortURL: "http://localhost:8080/abc123"},
+			{CorrelationID: "2", ShortURL: "http://localhost:8080/def456"},
+		}
+
+		mockService.On("BatchShorten", mock.Anything, input, mock.AnythingOfType("uuid.UUID")).
+			Return(output, nil).
+			Once()
+
+		body, _ := json.Marshal(input)
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusCreated, resp.Code)
+
+		var response []model.BatchResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response, 2)
+
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestGetUserURLsHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("successful get user urls", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		output := []model.UserURLResponse{
+			{ShortURL: "http://localhost:8080/abc123", OriginalURL: "https://example.com"},
+			{ShortURL: "http://localhost:8080/def456", OriginalURL: "https://yandex.ru"},
+		}
+
+		mockService.On("GetUserURLs", mock.Anything, mock.AnythingOfType("uuid.UUID"), model.LinkFilter{}).
+			Return(output, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response []model.UserURLResponse
+		err := json.Unmarshal(resp.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response, 2)
+
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		var emptyOutput []model.UserURLResponse
+
+		mockService.On("GetUserURLs", mock.Anything, mock.AnythingOfType("uuid.UUID"), model.LinkFilter{}).
+			Return(emptyOutput, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestUserTagsHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("filter by tag and folder", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		output := []model.UserURLResponse{
+			{ShortURL: "http://localhost:8080/abc123", OriginalURL: "https://example.com", Tags: []string{"promo"}},
+		}
+		mockService.On("GetUserURLs", mock.Anything, mock.AnythingOfType("uuid.UUID"),
+			mock.MatchedBy(func(f model.LinkFilter) bool {
+				return f.Tag == "promo" && f.Folder != nil && *f.Folder == "events"
+			})).
+			Return(output, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls?tag=promo&folder=events", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("bulk assign", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("AssignLabels", mock.Anything, mock.AnythingOfType("uuid.UUID"),
+			mock.MatchedBy(func(r model.LabelRequest) bool {
+				return len(r.IDs) == 2 && r.AddTags[0] == "promo" && !r.Folder.Set
+			})).
+			Return(2, nil).
+			Once()
+
+		body := `{"ids":["abc123","def456"],"add_tags":["promo"]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/tags", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"updated":2}`, resp.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("list tags", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("GetUserTags", mock.Anything, mock.AnythingOfType("uuid.UUID")).
+			Return([]model.TagInfo{{Name: "promo", Links: 2}}, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/tags", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `[{"name":"promo","links":2}]`, resp.Body.String())
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestDeleteURLsHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("successful delete", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		input := []string{"abc123", "def456"}
+
+		mockService.On("DeleteURLs", mock.Anything, input, mock.AnythingOfType("uuid.UUID")).
+			Return(2, nil).
+			Once()
+
+		body, _ := json.Marshal(input)
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusAccepted, resp.Code)
+
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("empty url list", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		body, _ := json.Marshal([]string{})
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBufferString("invalid json"))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
+func TestVariantsHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("visitor cookie", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("FindLink", mock.Anything, "abc123", mock.MatchedBy(func(v model.Visit) bool {
+			return v.VisitorID == "visitor"
+		})).
+			Return(&model.Redirect{URL: "https://b.example.com", Code: http.StatusTemporaryRedirect, Variant: "b"}, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Links.VisitorCookie, Value: "visitor"})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+		assert.Equal(t, "https://b.example.com", resp.Header().Get("Location"))
+		var visitor *http.Cookie
+		for _, cookie := range resp.Result().Cookies() {
+			if cookie.Name == cfg.Links.VisitorCookie {
+				visitor = cookie
+			}
+		}
+		if assert.NotNil(t, visitor) {
+			assert.Equal(t, "visitor", visitor.Value)
+			assert.True(t, visitor.HttpOnly)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("default cookie name and private caching", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		name := cfg.Links.VisitorCookie
+		cfg.Links.VisitorCookie = ""
+		defer func() { cfg.Links.VisitorCookie = name }()
+
+		mockService.On("FindLink", mock.Anything, "abc123", mock.Anything).
+			Return(&model.Redirect{URL: "https://b.example.com", Code: http.StatusTemporaryRedirect, Variant: "b", MaxAge: 60}, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+		assert.Equal(t, "private, no-cache, no-store, must-revalidate", resp.Header().Get("Cache-Control"))
+		var visitor *http.Cookie
+		for _, cookie := range resp.Result().Cookies() {
+			if cookie.Name == "visitor_id" {
+				visitor = cookie
+			}
+		}
+		if assert.NotNil(t, visitor) {
+			assert.NotEmpty(t, visitor.Value)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("no cookie without variants", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("FindLink", mock.Anything, "abc123", mock.Anything).
+			Return(&model.Redirect{URL: "https://example.com", Code: http.StatusTemporaryRedirect}, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+		for _, cookie := range resp.Result().Cookies() {
+			assert.NotEqual(t, cfg.Links.VisitorCookie, cookie.Name)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("variant stats", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		stats := []model.VariantStats{{Name: "a", URL: "https://a.example.com", Weight: 1, Clicks: 7}}
+		mockService.On("GetVariantStats", mock.Anything, "abc123", mock.AnythingOfType("uuid.UUID")).
+			Return(stats, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/abc123/variants", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response []model.VariantStats
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Equal(t, stats, response)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestRulesHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	mockService := new(mocks.MockLinkService)
+	router := setupRouter(mockService)
+
+	mockService.On("FindLink", mock.Anything, "abc123", mock.MatchedBy(func(v model.Visit) bool {
+		return v.UserAgent == "Mozilla/5.0 (iPhone)" && v.AcceptLanguage == "de-DE"
+	})).
+		Return(&model.Redirect{URL: "https://apps.apple.com/app/id1", Code: http.StatusFound}, nil).
+		Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (iPhone)")
+	req.Header.Set("Accept-Language", "de-DE")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusFound, resp.Code)
+	assert.Equal(t, "https://apps.apple.com/app/id1", resp.Header().Get("Location"))
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreURLsHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("restore", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		result := &model.RestoreResult{Restored: []string{"abc123"}, Conflicts: []string{"def456"}}
+		mockService.On("RestoreURLs", mock.Anything, []string{"abc123", "def456"}, mock.AnythingOfType("uuid.UUID")).
+			Return(result, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", bytes.NewBufferString(`["abc123","def456"]`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response model.RestoreResult
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Equal(t, *result, response)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("empty list", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", bytes.NewBufferString(`[]`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertNotCalled(t, "RestoreURLs")
+	})
+}
+
+func TestTransferHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("create transfer", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		token := &model.TransferToken{Token: "signed", ExpiresAt: time.Now().Add(time.Hour).UTC().Truncate(time.Second)}
+		mockService.On("CreateTransfer", mock.Anything, []string{"abc123"}, mock.AnythingOfType("uuid.UUID")).
+			Return(token, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/transfers", bytes.NewBufferString(`{"ids":["abc123"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusCreated, resp.Code)
+
+		var response model.TransferToken
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Equal(t, token.Token, response.Token)
+		assert.True(t, token.ExpiresAt.Equal(response.ExpiresAt))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("redeem used token", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("RedeemTransfer", mock.Anything, "signed", mock.AnythingOfType("uuid.UUID")).
+			Return(nil, service.ErrTransferUsed).
+			Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/transfers/redeem", bytes.NewBufferString(`{"token":"signed"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("redeem invalid token", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("RedeemTransfer", mock.Anything, "forged", mock.AnythingOfType("uuid.UUID")).
+			Return(nil, service.ErrTransferInvalid).
+			Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/transfers/redeem", bytes.NewBufferString(`{"token":"forged"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestExportImportHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	limit := int64(5)
+	records := []model.LinkRecord{
+		{
+			ID:          "abc123",
+			ShortURL:    "http://localhost:8080/abc123",
+			OriginalURL: "https://example.com",
+			Tags:        []string{"a", "b"},
+			LinkOptions: model.LinkOptions{MaxClicks: &limit, UTM: &model.UTMParams{Source: "mail"}},
+		},
+	}
+
+	t.Run("export csv", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ExportLinks", mock.Anything, mock.AnythingOfType("uuid.UUID")).
+			Return(records, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export?format=csv", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", resp.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSpace(resp.Body.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.True(t, strings.HasPrefix(lines[0], "id,short_url,original_url,"))
+		assert.Contains(t, lines[1], `abc123,http://localhost:8080/abc123,https://example.com,,,a|b,5,`)
+		assert.Contains(t, lines[1], `"{""source"":""mail""}"`)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("export json", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ExportLinks", mock.Anything, mock.AnythingOfType("uuid.UUID")).
+			Return(records, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var response []model.LinkRecord
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response))
+		assert.Equal(t, records, response)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("export failure before first record", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ExportLinks", mock.Anything, mock.AnythingOfType("uuid.UUID")).
+			Return([]model.LinkRecord{}, errors.New("database error")).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export?format=ndjson", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.Empty(t, resp.Header().Get("Content-Disposition"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("export empty", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ExportLinks", mock.Anything, mock.AnythingOfType("uuid.UUID")).
+			Return([]model.LinkRecord{}, nil).
+			Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "[]", resp.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export?format=xml", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+
+	t.Run("import csv", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ImportLink", mock.Anything, mock.AnythingOfType("uuid.UUID"), mock.MatchedBy(func(rec model.LinkRecord) bool {
+			return rec.ID == "abc123" && rec.OriginalURL == "https://example.com" &&
+				*rec.MaxClicks == 5 && rec.UTM.Source == "mail" && len(rec.Tags) == 2
+		})).
+			Return(model.ImportResult{Status: model.ImportCreated, ID: "abc123"}).
+			Once()
+
+		body := "original_url,id,max_clicks,tags,utm\n" +
+			`https://example.com,abc123,5,a|b,"{""source"":""mail""}"` + "\n" +
+			"https://example.com/bad,,many,,\n"
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var report []model.ImportResult
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+		assert.Len(t, report, 2)
+		assert.Equal(t, model.ImportResult{Row: 1, Status: model.ImportCreated, ID: "abc123"}, report[0])
+		assert.Equal(t, 2, report[1].Row)
+		assert.Equal(t, model.ImportFailed, report[1].Status)
+		assert.Contains(t, report[1].Error, "max_clicks")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("import ndjson", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ImportLink", mock.Anything, mock.AnythingOfType("uuid.UUID"), mock.Anything).
+			Return(model.ImportResult{Status: model.ImportExists, ID: "abc123"}).
+			Twice()
+
+		body := `{"original_url":"https://example.com"}` + "\n" +
+			`{"original_url":"https://example.com/a","max_clicks":"x"}` + "\n" +
+			`{"original_url":"https://example.com/b"}` + "\n"
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/import?format=ndjson", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		var report []model.ImportResult
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &report))
+		assert.Len(t, report, 3)
+		assert.Equal(t, model.ImportExists, report[0].Status)
+		assert.Equal(t, model.ImportFailed, report[1].Status)
+		assert.Equal(t, model.ImportExists, report[2].Status)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestExportGzipHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	mockService := new(mocks.MockLinkService)
+	router := setupRouter(mockService)
+
+	records := []model.LinkRecord{
+		{ID: "abc123", OriginalURL: "https://example.com/a"},
+		{ID: "def456", OriginalURL: "https://example.com/b"},
+	}
+	mockService.On("ExportLinks", mock.Anything, mock.AnythingOfType("uuid.UUID")).
+		Return(records, nil).
+		Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls/export?format=json", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "gzip", resp.Header().Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(resp.Body)
+	assert.NoError(t, err)
+
+	var response []model.LinkRecord
+	assert.NoError(t, json.NewDecoder(gz).Decode(&response))
+	assert.Equal(t, records, response)
+	mockService.AssertExpectations(t)
+}
+
+func TestBundleHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	page := &model.BundlePage{
+		ShortURL: "http://localhost:8080/abc123",
+		Title:    "My <links>",
+		Entries: []model.BundlePageEntry{
+			{Name: "1", Title: "Blog", URL: "http://localhost:8080/abc123?entry=1"},
+		},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("CreateBundle", mock.Anything, mock.Anything, mock.MatchedBy(func(opts model.LinkOptions) bool {
+			return opts.Title == "My links" && len(opts.Entries) == 1 && opts.Entries[0].URL == "https://blog.example.com"
+		})).Return("http://localhost:8080/abc123", nil).Once()
+
+		body := `{"title":"My links","entries":[{"title":"Blog","url":"https://blog.example.com"}]}`
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/bundle", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusCreated, resp.Code)
+		assert.JSONEq(t, `{"result":"http://localhost:8080/abc123"}`, resp.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid bundle", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("CreateBundle", mock.Anything, mock.Anything, mock.Anything).
+			Return("", service.ErrInvalidOptions).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/bundle", strings.NewReader(`{"entries":[]}`))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("html page", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("FindLink", mock.Anything, "abc123", mock.Anything).
+			Return(&model.Redirect{Code: http.StatusTemporaryRedirect, Bundle: page}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, resp.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, resp.Body.String(), "<h1>My &lt;links&gt;</h1>")
+		assert.Contains(t, resp.Body.String(), `href="http://localhost:8080/abc123?entry=1"`)
+		assert.Contains(t, resp.Header().Get("Cache-Control"), "no-store")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("json page", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("FindLink", mock.Anything, "abc123", mock.Anything).
+			Return(&model.Redirect{Code: http.StatusTemporaryRedirect, Bundle: page}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.Header.Set("Accept", "application/json")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var got model.BundlePage
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &got))
+		assert.Equal(t, *page, got)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("entry redirect", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("FindLink", mock.Anything, "abc123", mock.MatchedBy(func(v model.Visit) bool {
+			return v.Query.Get("entry") == "1"
+		})).Return(&model.Redirect{URL: "https://blog.example.com", Code: http.StatusTemporaryRedirect, Variant: "1"}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123?entry=1", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, resp.Code)
+		assert.Equal(t, "https://blog.example.com", resp.Header().Get("Location"))
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestBearerAuth(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("new identity gets token header", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		var first uuid.UUID
+		mockService.On("GetUserURLs", mock.Anything, mock.MatchedBy(func(id uuid.UUID) bool {
+			if first == uuid.Nil {
+				first = id
+			}
+			return id == first
+		}), model.LinkFilter{}).Return([]model.UserURLResponse{}, nil).Twice()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		header := resp.Header().Get(cfg.Auth.TokenHeader)
+		assert.True(t, strings.HasPrefix(header, "Bearer "))
+
+		req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		req.Header.Set("Authorization", header)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Empty(t, resp.Header().Get(cfg.Auth.TokenHeader))
+		for _, cookie := range resp.Result().Cookies() {
+			assert.NotEqual(t, cfg.Auth.CookieName, cookie.Name)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("issue token", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		userID := uuid.New()
+		token, _, err := middleware.IssueToken(userID, "")
+		assert.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/token", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: token})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var issued model.AuthToken
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &issued))
+		assert.Equal(t, "Bearer", issued.TokenType)
+		assert.True(t, issued.ExpiresAt.After(time.Now()))
+
+		mockService.On("GetUserURLs", mock.Anything, userID, model.LinkFilter{}).
+			Return([]model.UserURLResponse{}, nil).Once()
+
+		req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		req.Header.Set("Authorization", "bearer "+issued.Token)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid bearer token", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		req.Header.Set("Authorization", "Bearer not-a-token")
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		mockService.AssertNotCalled(t, "GetUserURLs", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	userID := uuid.New()
+	readKey := model.APIKeyPrefix + "read"
+
+	t.Run("read scope", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ResolveAPIKey", mock.Anything, readKey).
+			Return(userID, []string{model.ScopeLinksRead}, nil)
+		mockService.On("GetUserURLs", mock.Anything, userID, model.LinkFilter{}).
+			Return([]model.UserURLResponse{}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		req.Header.Set("Authorization", "Bearer "+readKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Empty(t, resp.Header().Get(cfg.Auth.TokenHeader))
+
+		for _, tc := range []struct {
+			method string
+			path   string
+			body   string
+		}{
+			{http.MethodDelete, "/api/user/urls", `["abc"]`},
+			{http.MethodPost, "/api/shorten", `{"url":"https://example.com"}`},
+			{http.MethodPost, "/api/auth/token", ""},
+			{http.MethodGet, "/api/user/keys", ""},
+		} {
+			req = httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+readKey)
+			resp = httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusForbidden, resp.Code, tc.method+" "+tc.path)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("ResolveAPIKey", mock.Anything, readKey).
+			Return(uuid.Nil, nil, service.ErrAPIKeyInvalid).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		req.Header.Set("Authorization", "Bearer "+readKey)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		mockService.AssertNotCalled(t, "GetUserURLs", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAPIKeyHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	t.Run("create", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		request := model.APIKeyRequest{Name: "ci", Scopes: []string{model.ScopeLinksRead}}
+		created := &model.CreatedAPIKey{
+			APIKey: model.APIKey{ID: uuid.New(), Name: "ci", Prefix: "usk_abcdefgh", Scopes: request.Scopes},
+			Key:    "usk_abcdefghsecret",
+		}
+		mockService.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("uuid.UUID"), request).
+			Return(created, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/keys", strings.NewReader(`{"name":"ci","scopes":["links:read"]}`))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusCreated, resp.Code)
+		assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+		var result model.CreatedAPIKey
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		assert.Equal(t, created.Key, result.Key)
+		assert.Equal(t, created.ID, result.ID)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("create invalid", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("uuid.UUID"), mock.Anything).
+			Return(nil, service.ErrInvalidOptions).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/keys", strings.NewReader(`{"name":"ci","scopes":["admin"]}`))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		keys := []model.APIKey{{ID: uuid.New(), Name: "ci", Prefix: "usk_abcdefgh", Hash: "secret-hash"}}
+		mockService.On("GetAPIKeys", mock.Anything, mock.AnythingOfType("uuid.UUID")).Return(keys, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/user/keys", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.NotContains(t, resp.Body.String(), "secret-hash")
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		id := uuid.New()
+		mockService.On("RevokeAPIKey", mock.Anything, id, mock.AnythingOfType("uuid.UUID")).Return(nil).Once()
+		mockService.On("RevokeAPIKey", mock.Anything, mock.Anything, mock.Anything).Return(service.ErrAPIKeyNotFound).Once()
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/user/keys/"+id.String(), nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		req = httptest.NewRequest(http.MethodDelete, "/api/user/keys/"+uuid.NewString(), nil)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+
+		req = httptest.NewRequest(http.MethodDelete, "/api/user/keys/not-a-uuid", nil)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func writeKeyPEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func TestKeySetRotation(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	rsaPath := writeKeyPEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	rsaPublic, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	assert.NoError(t, err)
+	rsaPublicPath := writeKeyPEM(t, "rsa_public.pem", "PUBLIC KEY", rsaPublic)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	edDER, err := x509.MarshalPKCS8PrivateKey(edKey)
+	assert.NoError(t, err)
+	edPath := writeKeyPEM(t, "ed.pem", "PRIVATE KEY", edDER)
+
+	rsaCfg := util.SigningKey{ID: "rsa-1", Algorithm: middleware.AlgorithmRS256, PrivateKeyPath: rsaPath}
+	edCfg := util.SigningKey{ID: "ed-1", Algorithm: middleware.AlgorithmEdDSA, PrivateKeyPath: edPath}
+	expiresAt := time.Now().Add(time.Hour)
+
+	legacy, err := middleware.NewKeySet(util.Auth{SecretKey: "old-secret"})
+	assert.NoError(t, err)
+	legacyToken, err := legacy.Sign("user-1", "", expiresAt)
+	assert.NoError(t, err)
+
+	// Переход на RS256: токены без kid продолжают проверяться старым секретом.
+	rotated, err := middleware.NewKeySet(util.Auth{SecretKey: "old-secret", SigningKeyID: rsaCfg.ID, Keys: []util.SigningKey{rsaCfg}})
+	assert.NoError(t, err)
+	claims, err := rotated.Parse(legacyToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	rsaToken, err := rotated.Sign("user-2", "", expiresAt)
+	assert.NoError(t, err)
+
+	// Следующая ротация на EdDSA: ключ RSA остается только для проверки.
+	rsaVerifyOnly := util.SigningKey{ID: rsaCfg.ID, Algorithm: middleware.AlgorithmRS256, PublicKeyPath: rsaPublicPath}
+	next, err := middleware.NewKeySet(util.Auth{SigningKeyID: edCfg.ID, Keys: []util.SigningKey{rsaVerifyOnly, edCfg}})
+	assert.NoError(t, err)
+	claims, err = next.Parse(rsaToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-2", claims.UserID)
+	edToken, err := next.Sign("user-3", "", expiresAt)
+	assert.NoError(t, err)
+	claims, err = next.Parse(edToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-3", claims.UserID)
+
+	_, err = next.Parse(legacyToken)
+	assert.Error(t, err)
+	_, err = rotated.Parse(edToken)
+	assert.Error(t, err)
+
+	// Токен с чужим алгоритмом не принимается даже при известном kid.
+	forged, err := legacy.Sign("user-4", "", expiresAt)
+	assert.NoError(t, err)
+	parts := strings.Split(forged, ".")
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT", "kid": rsaCfg.ID})
+	_, err = next.Parse(base64.RawURLEncoding.EncodeToString(header) + "." + parts[1] + "." + parts[2])
+	assert.Error(t, err)
+
+	jwks := next.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "ed-1", jwks.Keys[0].Kid)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "rsa-1", jwks.Keys[1].Kid)
+	assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+
+	_, err = middleware.NewKeySet(util.Auth{SigningKeyID: rsaCfg.ID, Keys: []util.SigningKey{rsaVerifyOnly}})
+	assert.Error(t, err)
+	_, err = middleware.NewKeySet(util.Auth{SigningKeyID: "missing", Keys: []util.SigningKey{rsaCfg}})
+	assert.Error(t, err)
+	_, err = middleware.NewKeySet(util.Auth{SigningKeyID: rsaCfg.ID, Keys: []util.SigningKey{{ID: rsaCfg.ID, Algorithm: middleware.AlgorithmEdDSA, PrivateKeyPath: rsaPath}}})
+	assert.Error(t, err)
+}
+
+func TestJWKSHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	mockService := new(mocks.MockLinkService)
+	router := setupRouter(mockService)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var jwks model.JWKS
+	assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &jwks))
+	assert.NotNil(t, jwks.Keys)
+	assert.Empty(t, resp.Result().Cookies())
+}
+
+func TestAccountHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	creds := model.Credentials{Email: "alice@example.com", Password: "correct horse"}
+	body := `{"email":"alice@example.com","password":"correct horse"}`
+	user := &model.User{ID: uuid.New(), Email: creds.Email}
+
+	t.Run("sign up", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("SignUp", mock.Anything, mock.AnythingOfType("uuid.UUID"), creds).Return(user, 2, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/signup", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusCreated, resp.Code)
+		var result model.AccountResponse
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		assert.Equal(t, user.ID, result.User.ID)
+		assert.Equal(t, 2, result.MergedLinks)
+		assert.NotEmpty(t, result.Token)
+
+		var session string
+		for _, cookie := range resp.Result().Cookies() {
+			if cookie.Name == cfg.Auth.CookieName {
+				session = cookie.Value
+			}
+		}
+		assert.Equal(t, result.Token, session)
+
+		mockService.On("GetUserURLs", mock.Anything, user.ID, model.LinkFilter{}).
+			Return([]model.UserURLResponse{}, nil).Once()
+		req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: session})
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("SignUp", mock.Anything, mock.Anything, creds).Return(nil, 0, service.ErrEmailTaken).Once()
+		mockService.On("SignUp", mock.Anything, mock.Anything, mock.Anything).Return(nil, 0, service.ErrInvalidOptions).Once()
+		mockService.On("Login", mock.Anything, mock.Anything, mock.Anything).Return(nil, 0, service.ErrInvalidCredentials).Once()
+
+		for _, tc := range []struct {
+			path   string
+			body   string
+			status int
+		}{
+			{"/api/auth/signup", body, http.StatusConflict},
+			{"/api/auth/signup", `{"email":"alice","password":"x"}`, http.StatusBadRequest},
+			{"/api/auth/signup", `{`, http.StatusBadRequest},
+			{"/api/auth/login", body, http.StatusUnauthorized},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, tc.status, resp.Code, tc.path+" "+tc.body)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("login", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		mockService.On("Login", mock.Anything, mock.AnythingOfType("uuid.UUID"), creds).Return(user, 0, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.True(t, strings.HasPrefix(resp.Header().Get(cfg.Auth.TokenHeader), "Bearer "))
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestAdminHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	adminID := uuid.New()
+	adminToken, _, err := middleware.IssueToken(adminID, model.RoleAdmin)
+	assert.NoError(t, err)
+	userToken, _, err := middleware.IssueToken(uuid.New(), "")
+	assert.NoError(t, err)
+
+	t.Run("requires admin role", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/links", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: userToken})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/admin/links", nil)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		key := model.APIKeyPrefix + "admin"
+		mockService.On("ResolveAPIKey", mock.Anything, key).
+			Return(adminID, []string{model.ScopeLinksRead, model.ScopeLinksWrite, model.ScopeLinksDelete}, nil).Once()
+		req = httptest.NewRequest(http.MethodGet, "/api/admin/links", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		mockService.AssertNotCalled(t, "SearchLinks", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("demoted admin", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		mockService.On("UserRole", mock.Anything, adminID).Return("", nil)
+		router := setupRouter(mockService)
+
+		// Токен выпущен с ролью администратора, но роль уже снята с учетной записи.
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/links", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: adminToken})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		mockService.AssertNotCalled(t, "SearchLinks", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("search links", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		mockService.On("UserRole", mock.Anything, adminID).Return(model.RoleAdmin, nil).Maybe()
+		router := setupRouter(mockService)
+
+		owner := uuid.New()
+		filter := model.AdminLinkFilter{URL: "example", UserID: &owner, Limit: 10}
+		links := []model.AdminLink{{Link: model.Link{ID: "abc", Link: "https://example.com", UserID: owner}, ShortURL: "http://localhost:8080/abc"}}
+		mockService.On("SearchLinks", mock.Anything, adminID, filter).Return(links, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/links?url=example&limit=10&user="+owner.String(), nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: adminToken})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var result []model.AdminLink
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		assert.Equal(t, links, result)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/admin/links?user=nobody", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: adminToken})
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("disable and enable link", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		mockService.On("UserRole", mock.Anything, adminID).Return(model.RoleAdmin, nil).Maybe()
+		router := setupRouter(mockService)
+
+		mockService.On("DisableLink", mock.Anything, adminID, "abc", "phishing").Return(nil).Once()
+		mockService.On("EnableLink", mock.Anything, adminID, "abc", "false positive").Return(nil).Once()
+		mockService.On("DisableLink", mock.Anything, adminID, "missing", "phishing").Return(service.ErrURLNotFound).Once()
+		mockService.On("DisableLink", mock.Anything, adminID, "abc", "").Return(service.ErrInvalidOptions).Once()
+
+		for _, tc := range []struct {
+			path   string
+			body   string
+			status int
+		}{
+			{"/api/admin/links/abc/disable", `{"reason":"phishing"}`, http.StatusNoContent},
+			{"/api/admin/links/abc/enable", `{"reason":"false positive"}`, http.StatusNoContent},
+			{"/api/admin/links/missing/disable", `{"reason":"phishing"}`, http.StatusNotFound},
+			{"/api/admin/links/abc/disable", `{}`, http.StatusBadRequest},
+		} {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: adminToken})
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, tc.status, resp.Code, tc.path+" "+tc.body)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("users and actions", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		mockService.On("UserRole", mock.Anything, adminID).Return(model.RoleAdmin, nil).Maybe()
+		router := setupRouter(mockService)
+
+		userID := uuid.New()
+		mockService.On("GetUserStats", mock.Anything, adminID, (*uuid.UUID)(nil), 5).
+			Return([]model.UserStats{{UserID: userID, Links: 3}}, nil).Once()
+		mockService.On("GetUserStats", mock.Anything, adminID, &userID, 0).
+			Return([]model.UserStats{{UserID: userID, Links: 3}}, nil).Once()
+		mockService.On("PurgeUser", mock.Anything, adminID, userID, "spam").Return(3, nil).Once()
+		mockService.On("GetAdminActions", mock.Anything, adminID, 0).
+			Return([]model.AdminAction{{ID: 1, AdminID: adminID, Action: model.AdminActionPurgeUser}}, nil).Once()
+
+		for _, tc := range []struct {
+			method string
+			path   string
+			body   string
+			status int
+		}{
+			{http.MethodGet, "/api/admin/users?limit=5", "", http.StatusOK},
+			{http.MethodGet, "/api/admin/users/" + userID.String(), "", http.StatusOK},
+			{http.MethodGet, "/api/admin/users?limit=x", "", http.StatusBadRequest},
+			{http.MethodDelete, "/api/admin/users/" + userID.String(), `{"reason":"spam"}`, http.StatusOK},
+			{http.MethodGet, "/api/admin/actions", "", http.StatusOK},
+		} {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: adminToken})
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			assert.Equal(t, tc.status, resp.Code, tc.method+" "+tc.path)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("count users", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		mockService.On("UserRole", mock.Anything, adminID).Return(model.RoleAdmin, nil).Maybe()
+		router := setupRouter(mockService)
+
+		count := &model.UserCount{Total: 5, Registered: 2, Anonymous: 3, Active: 1}
+		mockService.On("CountUsers", mock.Anything, adminID).Return(count, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/users/count", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: adminToken})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var result model.UserCount
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		assert.Equal(t, *count, result)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestSessionCookies(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	findCookie := func(resp *httptest.ResponseRecorder) *http.Cookie {
+		var res *http.Cookie
+		for _, cookie := range resp.Result().Cookies() {
+			if cookie.Name == cfg.Auth.CookieName {
+				res = cookie
+			}
+		}
+		return res
+	}
+	// Middleware аутентификации выполняется и для запросов к неизвестным маршрутам.
+	get := func(router *gin.Engine, cookie, bearer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: cookie})
+		}
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("cookie attributes", func(t *testing.T) {
+		router := setupRouter(new(mocks.MockLinkService))
+
+		cookie := findCookie(get(router, "", ""))
+		assert.NotNil(t, cookie)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+		assert.Equal(t, "/", cookie.Path)
+		assert.Equal(t, cfg.Auth.TokenTTL*3600, cookie.MaxAge)
+		assert.False(t, cookie.Secure)
+
+		cfg.Server.EnableHTTPS = true
+		defer func() { cfg.Server.EnableHTTPS = false }()
+		cookie = findCookie(get(router, "", ""))
+		assert.NotNil(t, cookie)
+		assert.True(t, cookie.Secure)
+	})
+
+	t.Run("sliding renewal", func(t *testing.T) {
+		router := setupRouter(new(mocks.MockLinkService))
+		userID := uuid.New()
+
+		fresh, _, err := middleware.IssueToken(userID, "")
+		assert.NoError(t, err)
+		resp := get(router, fresh, "")
+		assert.Nil(t, findCookie(resp))
+		assert.Empty(t, resp.Header().Get(cfg.Auth.TokenHeader))
+
+		expiring, err := middleware.GetKeySet().Sign(userID.String(), "", time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		resp = get(router, expiring, "")
+		cookie := findCookie(resp)
+		assert.NotNil(t, cookie)
+		claims, err := middleware.GetKeySet().Parse(cookie.Value)
+		assert.NoError(t, err)
+		assert.Equal(t, userID.String(), claims.UserID)
+		assert.True(t, claims.ExpiresAt.After(time.Now().Add(24*time.Hour)))
+		assert.Equal(t, "Bearer "+cookie.Value, resp.Header().Get(cfg.Auth.TokenHeader))
+
+		// Токен из заголовка продлевается только в заголовке ответа.
+		resp = get(router, "", expiring)
+		assert.Nil(t, findCookie(resp))
+		assert.NotEmpty(t, resp.Header().Get(cfg.Auth.TokenHeader))
+	})
+
+	t.Run("renewal rereads role", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		userID := uuid.New()
+		mockService.On("UserRole", mock.Anything, userID).Return("", nil).Once()
+		router := setupRouter(mockService)
+
+		expiring, err := middleware.GetKeySet().Sign(userID.String(), model.RoleAdmin, time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		cookie := findCookie(get(router, expiring, ""))
+		if assert.NotNil(t, cookie) {
+			claims, err := middleware.GetKeySet().Parse(cookie.Value)
+			assert.NoError(t, err)
+			assert.Empty(t, claims.Role)
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("logout revokes token", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		userID := uuid.New()
+		token, expiresAt, err := middleware.IssueToken(userID, "")
+		assert.NoError(t, err)
+		claims, err := middleware.GetKeySet().Parse(token)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, claims.ID)
+
+		mockService.On("RevokeToken", mock.Anything, claims.ID, expiresAt).Return(nil).Once()
+		mockService.On("RevokeUserTokens", mock.Anything, userID).Return(nil).Once()
+		router := setupRouter(mockService)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: token})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		cookie := findCookie(resp)
+		assert.NotNil(t, cookie)
+		assert.Empty(t, cookie.Value)
+		assert.Negative(t, cookie.MaxAge)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		userID := uuid.New()
+		token, _, err := middleware.IssueToken(userID, "")
+		assert.NoError(t, err)
+		claims, err := middleware.GetKeySet().Parse(token)
+		assert.NoError(t, err)
+
+		mockService.On("IsTokenRevoked", mock.Anything, userID, claims.ID, claims.IssuedAt.Time).Return(true, nil)
+		router := setupRouter(mockService)
+
+		// Отозванная cookie заменяется токеном нового анонимного пользователя.
+		cookie := findCookie(get(router, token, ""))
+		assert.NotNil(t, cookie)
+		newClaims, err := middleware.GetKeySet().Parse(cookie.Value)
+		assert.NoError(t, err)
+		assert.NotEqual(t, userID.String(), newClaims.UserID)
+
+		resp := get(router, "", token)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("public routes skip token checks", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		userID := uuid.New()
+		token, _, err := middleware.IssueToken(userID, "")
+		assert.NoError(t, err)
+
+		redirect := &model.Redirect{URL: "https://example.com", Code: http.StatusTemporaryRedirect}
+		mockService.On("FindLink", mock.Anything, "abc123", mock.Anything).Return(redirect, nil)
+		mockService.On("LookupLink", mock.Anything, "abc123", mock.Anything).Return(redirect, nil)
+		mockService.On("PreviewLink", mock.Anything, "abc123").
+			Return(&model.LinkPreview{ShortURL: "http://localhost:8080/abc123", OriginalURL: "https://example.com"}, nil)
+		mockService.On("GetPublicShortURL", mock.Anything, "abc123").
+			Return("http://localhost:8080/abc123", nil)
+		router := setupRouter(mockService)
+
+		// Переход, предпросмотр и QR-код не проверяют токен посетителя на отзыв
+		// и не обновляют время его активности.
+		for _, path := range []string{"/abc123", "/abc123+", "/abc123/qr"} {
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				req := httptest.NewRequest(method, path, nil)
+				req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: token})
+				req.Header.Set("Authorization", "Bearer "+token)
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+
+				assert.Less(t, resp.Code, http.StatusBadRequest, method+" "+path)
+				assert.Nil(t, findCookie(resp), method+" "+path)
+			}
+		}
+		mockService.AssertNotCalled(t, "IsTokenRevoked", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		mockService.AssertNotCalled(t, "TouchUser", mock.Anything)
+		mockService.AssertNotCalled(t, "UserRole", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCSRFProtection(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	userID := uuid.New()
+	token, _, err := middleware.IssueToken(userID, "")
+	assert.NoError(t, err)
+
+	cfg.Auth.TrustedOrigins = []string{"https://app.example.org/"}
+	defer func() { cfg.Auth.TrustedOrigins = nil }()
+
+	for _, tc := range []struct {
+		name    string
+		method  string
+		cookie  bool
+		bearer  bool
+		headers map[string]string
+		allowed bool
+	}{
+		{name: "cross-site origin", method: http.MethodPost, cookie: true, headers: map[string]string{"Origin": "https://evil.example"}},
+		{name: "cross-site referer", method: http.MethodPost, cookie: true, headers: map[string]string{"Referer": "https://evil.example/page"}},
+		{name: "null origin", method: http.MethodPost, cookie: true, headers: map[string]string{"Origin": "null"}},
+		{name: "cross-site delete", method: http.MethodDelete, cookie: true, headers: map[string]string{"Origin": "https://evil.example"}},
+		{name: "same host", method: http.MethodPost, cookie: true, headers: map[string]string{"Origin": "http://example.com"}, allowed: true},
+		{name: "trusted origin", method: http.MethodPost, cookie: true, headers: map[string]string{"Origin": "https://APP.example.org"}, allowed: true},
+		{name: "same-site referer", method: http.MethodPost, cookie: true, headers: map[string]string{"Referer": "http://example.com/form"}, allowed: true},
+		{name: "non-browser client", method: http.MethodPost, cookie: true, allowed: true},
+		{name: "bearer token", method: http.MethodPost, bearer: true, headers: map[string]string{"Origin": "https://evil.example"}, allowed: true},
+		{name: "no cookie", method: http.MethodPost, headers: map[string]string{"Origin": "https://evil.example"}, allowed: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(mocks.MockLinkService)
+			router := setupRouter(mockService)
+
+			var req *http.Request
+			if tc.method == http.MethodDelete {
+				mockService.On("DeleteURLs", mock.Anything, []string{"abc"}, mock.AnythingOfType("uuid.UUID")).Return(1, nil).Maybe()
+				req = httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(`["abc"]`))
+			} else {
+				mockService.On("ShorterLink", mock.Anything, "https://yandex.ru", mock.AnythingOfType("uuid.UUID"), model.LinkOptions{}).
+					Return("abc123", nil).Maybe()
+				req = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://yandex.ru"}`))
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if tc.cookie {
+				req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: token})
+			}
+			if tc.bearer {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			if tc.allowed {
+				assert.NotEqual(t, http.StatusForbidden, resp.Code)
+				assert.Less(t, resp.Code, 300)
+				return
+			}
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+			mockService.AssertNotCalled(t, "ShorterLink", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			mockService.AssertNotCalled(t, "DeleteURLs", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOIDCHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	flow := &model.OIDCFlow{State: "state", Nonce: "nonce", Verifier: "verifier"}
+	flowCookie := func(resp *httptest.ResponseRecorder) *http.Cookie {
+		for _, cookie := range resp.Result().Cookies() {
+			if cookie.Name == "oidc_flow" {
+				return cookie
+			}
+		}
+		return nil
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+		mockService.On("StartOIDCLogin", mock.Anything).Return("", nil, service.ErrOIDCDisabled).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("login flow", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+		authURL := "https://idp.example/authorize?state=state"
+		mockService.On("StartOIDCLogin", mock.Anything).Return(authURL, flow, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/login", nil)
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusFound, resp.Code)
+		assert.Equal(t, authURL, resp.Header().Get("Location"))
+		cookie := flowCookie(resp)
+		assert.NotNil(t, cookie)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+		user := &model.User{ID: uuid.New(), Email: "employee@corp.example"}
+		mockService.On("CompleteOIDCLogin", mock.Anything, mock.AnythingOfType("uuid.UUID"), *flow, "state", "code").
+			Return(user, 2, nil).Once()
+		mockService.On("CompleteOIDCLogin", mock.Anything, mock.AnythingOfType("uuid.UUID"), *flow, "forged", "code").
+			Return(nil, 0, service.ErrOIDCRejected).Once()
+
+		req = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=state&code=code", nil)
+		req.AddCookie(cookie)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var account model.AccountResponse
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &account))
+		assert.Equal(t, user.ID, account.User.ID)
+		assert.Equal(t, 2, account.MergedLinks)
+		claims, err := middleware.GetKeySet().Parse(account.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID.String(), claims.UserID)
+		assert.Negative(t, flowCookie(resp).MaxAge)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=forged&code=code", nil)
+		req.AddCookie(cookie)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=state&error=access_denied", nil)
+		req.AddCookie(cookie)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=state&code=code", nil)
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestDeviceHandler(t *testing.T) {
+	cfg := util.GetConfig()
+	util.InitLogger(cfg.Logger)
+
+	laptop, phone := uuid.New(), uuid.New()
+	laptopToken, _, err := middleware.IssueToken(laptop, "")
+	assert.NoError(t, err)
+	phoneToken, _, err := middleware.IssueToken(phone, "")
+	assert.NoError(t, err)
+
+	t.Run("create code and qr", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+		code := &model.CreatedDeviceCode{Code: "12345678", ExpiresAt: time.Now().Add(10 * time.Minute).Truncate(time.Second)}
+		mockService.On("CreateDeviceCode", mock.Anything, laptop).Return(code, nil).Twice()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/user/devices/code", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: laptopToken})
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusCreated, resp.Code)
+		assert.Equal(t, "no-store", resp.Header().Get("Cache-Control"))
+		var result model.CreatedDeviceCode
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		assert.Equal(t, code.Code, result.Code)
+
+		assert.Empty(t, result.QR)
+
+		// QR-код возвращается вместе с кодом и не требует передавать код в URL.
+		req = httptest.NewRequest(http.MethodPost, "/api/user/devices/code?format=svg&size=128", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: laptopToken})
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusCreated, resp.Code)
+		result = model.CreatedDeviceCode{}
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &result))
+		assert.Equal(t, code.Code, result.Code)
+		assert.True(t, strings.HasPrefix(result.QR, "data:image/svg+xml;base64,"))
+
+		// Неверные параметры QR-кода отклоняются до выдачи кода.
+		req = httptest.NewRequest(http.MethodPost, "/api/user/devices/code?format=gif", nil)
+		req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: laptopToken})
+		resp = httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("claim code", func(t *testing.T) {
+		mockService := new(mocks.MockLinkService)
+		router := setupRouter(mockService)
+		mockService.On("ClaimDeviceCode", mock.Anything, phone, mock.Anything, model.DeviceClaimRequest{Code: "12345678"}).
+			Return(&model.User{ID: laptop}, 3, nil).Once()
+		mockService.On("ClaimDeviceCode", mock.Anything, phone, mock.Anything, model.DeviceClaimRequest{Code: "00000000", RefuseMerge: true}).
+			Return(nil, 0, service.ErrDeviceCodeInvalid).Once()
+		mockService.On("ClaimDeviceCode", mock.Anything, phone, mock.Anything, model.DeviceClaimRequest{Code: "11111111"}).
+			Return(nil, 0, service.ErrTooManyAttempts).Once()
+
+		claim := func(body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/api/user/devices/claim", strings.NewReader(body))
+			req.AddCookie(&http.Cookie{Name: cfg.Auth.CookieName, Value: phoneToken})
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := claim(`{"code":"12345678"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		var account model.AccountResponse
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &account))
+		assert.Equal(t, laptop, account.User.ID)
+		assert.Equal(t, 3, account.MergedLinks)
+		claims, err := middleware.GetKeySet().Parse(account.Token)
+		assert.NoError(t, err)
+		assert.Equal(t, laptop.String(), claims.UserID)
+
+		assert.Equal(t, http.StatusBadRequest, claim(`{"code":"00000000","refuse_merge":true}`).Code)
+
+		// Retry-After указывается и при периоде ограничения по умолчанию.
+		prevWindow := cfg.Devices.RedeemWindow
+		cfg.Devices.RedeemWindow = 0
+		t.Cleanup(func() { cfg.Devices.RedeemWindow = prevWindow })
+		resp = claim(`{"code":"11111111"}`)
+		assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+		assert.Equal(t, strconv.Itoa(int(service.RedeemWindow().Seconds())), resp.Header().Get("Retry-After"))
+		assert.NotEqual(t, "0", resp.Header().Get("Retry-After"))
+		mockService.AssertExpectations(t)
 	})
 }
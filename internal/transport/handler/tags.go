@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+)
+
+// assignLabels обрабатывает POST-запрос на массовое назначение меток и папки ссылкам пользователя.
+// Принимает JSON с полями "ids", "add_tags", "remove_tags" и "folder".
+// Возвращает JSON с количеством затронутых ссылок.
+// Статусы ответа:
+// - 200: Метки успешно назначены
+// - 400: Неверный формат запроса
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) assignLabels(c *gin.Context) {
+	var (
+		err error
+		req model.LabelRequest
+	)
+
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+	if len(req.IDs) == 0 {
+		response(c, http.StatusBadRequest, errors.New("empty url list"), nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	count, err := h.service.AssignLabels(c.Request.Context(), userID, req)
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, model.LabelResponse{Updated: count})
+}
+
+// getUserTags обрабатывает GET-запрос на получение меток пользователя.
+// Возвращает массив JSON-объектов с полями "name" и "links".
+// Статусы ответа:
+// - 200: Список меток успешно получен
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getUserTags(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	tags, err := h.service.GetUserTags(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, tags)
+}
+
+// renameTag обрабатывает PUT-запрос на переименование метки пользователя.
+// Принимает название метки в параметре пути и JSON с полем "name".
+// Возвращает JSON с количеством затронутых ссылок.
+// Статусы ответа:
+// - 200: Метка успешно переименована
+// - 400: Неверный формат запроса
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) renameTag(c *gin.Context) {
+	var (
+		err error
+		req model.RenameTagRequest
+	)
+
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	count, err := h.service.RenameTag(c.Request.Context(), userID, c.Param("tag"), req.Name)
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, model.LabelResponse{Updated: count})
+}
+
+// deleteTag обрабатывает DELETE-запрос на удаление метки пользователя.
+// Снимает метку со всех ссылок пользователя.
+// Возвращает JSON с количеством затронутых ссылок.
+// Статусы ответа:
+// - 200: Метка успешно удалена
+// - 400: Неверное название метки
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) deleteTag(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	count, err := h.service.DeleteTag(c.Request.Context(), userID, c.Param("tag"))
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, model.LabelResponse{Updated: count})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ypxd99/yandex-practicm/internal/model"
+	"github.com/ypxd99/yandex-practicm/internal/repository/middleware"
+	"github.com/ypxd99/yandex-practicm/internal/service"
+)
+
+// createTransfer обрабатывает POST-запрос на выпуск токена передачи ссылок другому пользователю.
+// Принимает JSON с полем "ids".
+// Возвращает JSON с полями "token" и "expires_at".
+// Статусы ответа:
+// - 201: Токен успешно выпущен
+// - 400: Неверный формат запроса
+// - 401: Пользователь не авторизован
+// - 404: Одна из ссылок не найдена
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) createTransfer(c *gin.Context) {
+	var (
+		err error
+		req model.TransferRequest
+	)
+
+	err = c.ShouldBindJSON(&req)
+	if err != nil {
+		response(c, http.StatusBadRequest, err, nil)
+		return
+	}
+	if len(req.IDs) == 0 {
+		response(c, http.StatusBadRequest, errors.New("empty url list"), nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	resp, err := h.service.CreateTransfer(c.Request.Context(), req.IDs, userID)
+	if err != nil {
+		response(c, userLinkErrorStatus(err), err, nil)
+		return
+	}
+
+	response(c, http.StatusCreated, nil, resp)
+}
+
+// redeemTransfer обрабатывает POST-запрос на принятие ссылок по токену передачи.
+// Принимает JSON с полем "token".
+// Возвращает JSON с идентификаторами перешедших к пользователю ссылок.
+// Статусы ответа:
+// - 200: Ссылки успешно приняты
+// - 400: Неверный формат запроса или недействительный токен
+// - 401: Пользователь не авторизован
+// - 409: Токен уже был использован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) redeemTransfer(c *gin.Context) {
+	var (
+		err error
+		req model.RedeemRequest
+	)
+
+	err = c.ShouldBindJSON(&req)
+	if err != nil || req.Token == "" {
+		response(c, http.StatusBadRequest, errors.New("empty token"), nil)
+		return
+	}
+
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	resp, err := h.service.RedeemTransfer(c.Request.Context(), req.Token, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTransferInvalid):
+			response(c, http.StatusBadRequest, err, nil)
+		case errors.Is(err, service.ErrTransferUsed):
+			response(c, http.StatusConflict, err, nil)
+		default:
+			response(c, http.StatusInternalServerError, err, nil)
+		}
+		return
+	}
+
+	response(c, http.StatusOK, nil, resp)
+}
+
+// getTransfers обрабатывает GET-запрос на получение журнала передачи ссылок пользователя.
+// Возвращает массив JSON-объектов с полями "token_id", "link_id", "from_user", "to_user" и "transferred_at".
+// Статусы ответа:
+// - 200: Журнал успешно получен
+// - 401: Пользователь не авторизован
+// - 500: Внутренняя ошибка сервера
+func (h *Handler) getTransfers(c *gin.Context) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		response(c, http.StatusUnauthorized, err, nil)
+		return
+	}
+
+	transfers, err := h.service.GetTransfers(c.Request.Context(), userID)
+	if err != nil {
+		response(c, http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	response(c, http.StatusOK, nil, transfers)
+}
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS clicks_left INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS clicks_left;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS active_from timestamptz;
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS active_until timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS active_until;
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS active_from;
+-- +goose StatementEnd
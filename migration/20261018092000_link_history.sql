@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS shortener.link_history (
+    id BIGSERIAL,
+    link_id VARCHAR(8) NOT NULL,
+    link varchar(255) NOT NULL,
+    time_replaced timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT link_history_pkey PRIMARY KEY (id),
+    CONSTRAINT link_history_link_id_fkey FOREIGN KEY (link_id) REFERENCES shortener.links (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_history_link_id ON shortener.link_history (link_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.link_history;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS folder varchar(255) DEFAULT '' NOT NULL;
+
+CREATE TABLE IF NOT EXISTS shortener.link_tags (
+    link_id VARCHAR(8) NOT NULL,
+    user_id UUID NOT NULL,
+    tag varchar(64) NOT NULL,
+    CONSTRAINT link_tags_pkey PRIMARY KEY (link_id, tag),
+    CONSTRAINT link_tags_link_id_fkey FOREIGN KEY (link_id) REFERENCES shortener.links (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_tags_user_tag ON shortener.link_tags (user_id, tag);
+CREATE INDEX IF NOT EXISTS idx_links_user_folder ON shortener.links (user_id, folder);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS shortener.idx_links_user_folder;
+DROP TABLE IF EXISTS shortener.link_tags;
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS folder;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS title varchar(255) DEFAULT '' NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS title;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS redirect_code smallint;
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS cache_max_age integer;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS cache_max_age;
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS redirect_code;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS passthrough varchar(8) DEFAULT '' NOT NULL;
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS utm jsonb;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS utm;
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS passthrough;
+-- +goose StatementEnd
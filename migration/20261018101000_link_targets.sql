@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS targets jsonb;
+
+CREATE TABLE IF NOT EXISTS shortener.link_variant_clicks (
+    link_id VARCHAR(8) NOT NULL,
+    variant varchar(32) NOT NULL,
+    clicks bigint DEFAULT 0 NOT NULL,
+    CONSTRAINT link_variant_clicks_pkey PRIMARY KEY (link_id, variant),
+    CONSTRAINT link_variant_clicks_link_id_fkey FOREIGN KEY (link_id) REFERENCES shortener.links (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.link_variant_clicks;
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS targets;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS time_deleted timestamptz;
+UPDATE shortener.links SET time_deleted = now() WHERE is_deleted = true AND time_deleted IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS time_deleted;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS shortener.link_transfers (
+    token_id varchar(36) NOT NULL,
+    link_id VARCHAR(8) NOT NULL,
+    from_user UUID NOT NULL,
+    to_user UUID NOT NULL,
+    time_transferred timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT link_transfers_pkey PRIMARY KEY (token_id, link_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_transfers_from_user ON shortener.link_transfers (from_user);
+CREATE INDEX IF NOT EXISTS idx_link_transfers_to_user ON shortener.link_transfers (to_user);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.link_transfers;
+-- +goose StatementEnd
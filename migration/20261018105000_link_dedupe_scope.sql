@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links 
+DROP CONSTRAINT IF EXISTS links_link_unique;
+
+CREATE INDEX IF NOT EXISTS idx_links_link_active ON shortener.links (link) WHERE is_deleted = false;
+CREATE INDEX IF NOT EXISTS idx_links_user_link_active ON shortener.links (user_id, link) WHERE is_deleted = false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS shortener.idx_links_user_link_active;
+DROP INDEX IF EXISTS shortener.idx_links_link_active;
+
+-- Ограничение восстанавливается, только если в таблице нет повторяющихся URL:
+-- удаление ссылок-дубликатов при откате привело бы к потере данных.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM shortener.links GROUP BY link HAVING count(*) > 1) THEN
+        RAISE NOTICE 'links_link_unique is not restored: shortener.links contains duplicate urls';
+    ELSE
+        ALTER TABLE shortener.links
+        ADD CONSTRAINT links_link_unique UNIQUE (link);
+    END IF;
+END $$;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS shortener.users (
+    id UUID NOT NULL,
+    email VARCHAR(320) NOT NULL,
+    password_hash VARCHAR(72) NOT NULL,
+    time_created timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT users_pkey PRIMARY KEY (id),
+    CONSTRAINT users_email_unique UNIQUE (email)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.users;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS time_disabled timestamptz;
+ALTER TABLE shortener.links ADD COLUMN IF NOT EXISTS disabled_reason TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE shortener.users ADD COLUMN IF NOT EXISTS role VARCHAR(32) NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS shortener.admin_actions (
+    id BIGSERIAL NOT NULL,
+    admin_id UUID NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    target TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    time_created timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT admin_actions_pkey PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.admin_actions;
+
+ALTER TABLE shortener.users DROP COLUMN IF EXISTS role;
+
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS disabled_reason;
+ALTER TABLE shortener.links DROP COLUMN IF EXISTS time_disabled;
+-- +goose StatementEnd
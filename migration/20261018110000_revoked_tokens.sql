@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS shortener.revoked_tokens (
+    jti VARCHAR(64) NOT NULL,
+    time_expires timestamptz NOT NULL,
+    CONSTRAINT revoked_tokens_pkey PRIMARY KEY (jti)
+);
+
+CREATE INDEX IF NOT EXISTS revoked_tokens_time_expires_idx ON shortener.revoked_tokens (time_expires);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.revoked_tokens;
+-- +goose StatementEnd
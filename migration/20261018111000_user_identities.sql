@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE shortener.users ALTER COLUMN email DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS shortener.user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL,
+    time_created timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT user_identities_pkey PRIMARY KEY (issuer, subject),
+    CONSTRAINT user_identities_user_id_fkey FOREIGN KEY (user_id) REFERENCES shortener.users (id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.user_identities;
+
+DELETE FROM shortener.users WHERE email IS NULL;
+ALTER TABLE shortener.users ALTER COLUMN email SET NOT NULL;
+-- +goose StatementEnd
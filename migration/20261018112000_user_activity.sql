@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS shortener.user_activity (
+    user_id UUID NOT NULL,
+    time_created timestamptz DEFAULT now() NOT NULL,
+    time_last_seen timestamptz DEFAULT now() NOT NULL,
+    CONSTRAINT user_activity_pkey PRIMARY KEY (user_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_activity_time_last_seen_idx ON shortener.user_activity (time_last_seen);
+
+-- Время последней активности существующих пользователей неизвестно, поэтому отсчет
+-- неактивности начинается с момента миграции, а не с создания их последней ссылки.
+INSERT INTO shortener.user_activity (user_id, time_created, time_last_seen)
+SELECT user_id, min(time_created), now()
+FROM shortener.links
+GROUP BY user_id
+ON CONFLICT (user_id) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.user_activity;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS shortener.device_codes (
+    code_hash VARCHAR(64) NOT NULL,
+    user_id UUID NOT NULL,
+    time_created timestamptz DEFAULT now() NOT NULL,
+    time_expires timestamptz NOT NULL,
+    CONSTRAINT device_codes_pkey PRIMARY KEY (code_hash)
+);
+
+CREATE INDEX IF NOT EXISTS device_codes_user_id_idx ON shortener.device_codes (user_id);
+CREATE INDEX IF NOT EXISTS device_codes_time_expires_idx ON shortener.device_codes (time_expires);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.device_codes;
+-- +goose StatementEnd
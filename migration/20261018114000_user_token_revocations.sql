@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS shortener.revoked_user_tokens (
+    user_id UUID NOT NULL,
+    time_revoked timestamptz NOT NULL,
+    CONSTRAINT revoked_user_tokens_pkey PRIMARY KEY (user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS shortener.revoked_user_tokens;
+-- +goose StatementEnd
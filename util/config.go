@@ -36,14 +36,80 @@ type Config struct {
 	Server          Server    `yaml:"Server"`
 	Postgres        Postgres  `yaml:"Postgres"`
 	Auth            Auth      `yaml:"Auth"`
+	Links           Links     `yaml:"Links"`
+	Users           Users     `yaml:"Users"`
+	Devices         Devices   `yaml:"Devices"`
 	FileStoragePath string    `yaml:"FileStoragePath"`
 	UseDecode       bool      `yaml:"UseDecode"`
 }
 
 // Auth содержит конфигурацию, связанную с аутентификацией.
 type Auth struct {
-	SecretKey  string `yaml:"SecretKey"`
-	CookieName string `yaml:"CookieName"`
+	SecretKey      string       `yaml:"SecretKey"` // секрет HS256 для токенов без заголовка kid
+	CookieName     string       `yaml:"CookieName"`
+	TokenHeader    string       `yaml:"TokenHeader"`  // заголовок ответа, в котором новый пользователь получает свой токен
+	SigningKeyID   string       `yaml:"SigningKeyID"` // идентификатор ключа из Keys, которым подписываются новые токены, пустое значение означает SecretKey
+	Keys           []SigningKey `yaml:"Keys"`         // ключи, которыми проверяются токены с заголовком kid
+	TokenTTL       int          `yaml:"TokenTTL"`     // время жизни токена пользователя в часах
+	RenewBefore    int          `yaml:"RenewBefore"`  // время в часах до истечения токена, начиная с которого он продлевается, 0 отключает продление
+	Cookie         Cookie       `yaml:"Cookie"`
+	OIDC           OIDC         `yaml:"OIDC"`
+	TrustedOrigins []string     `yaml:"TrustedOrigins"` // источники, кроме BaseURL и хоста запроса, которым разрешены изменяющие запросы с cookie
+}
+
+// OIDC содержит настройки входа через провайдер OpenID Connect.
+type OIDC struct {
+	Issuer       string   `yaml:"Issuer"` // адрес провайдера, пустое значение отключает вход через OpenID Connect
+	ClientID     string   `yaml:"ClientID"`
+	ClientSecret string   `yaml:"ClientSecret"`
+	RedirectURL  string   `yaml:"RedirectURL"` // адрес возврата от провайдера, по умолчанию BaseURL + /api/auth/oidc/callback
+	Scopes       []string `yaml:"Scopes"`      // области доступа, запрашиваемые помимо openid
+}
+
+// Cookie содержит атрибуты cookie, которые устанавливает сервис.
+type Cookie struct {
+	Domain   string `yaml:"Domain"`   // домен cookie, пустое значение ограничивает cookie текущим хостом
+	Secure   bool   `yaml:"Secure"`   // передавать cookie только по HTTPS, включается автоматически при Server.EnableHTTPS
+	SameSite string `yaml:"SameSite"` // политика SameSite: lax, strict или none
+}
+
+// SigningKey описывает ключ подписи JWT токенов.
+// Для HS256 задается Secret, для RS256 и EdDSA - путь к приватному ключу или,
+// если ключ используется только для проверки токенов, путь к публичному ключу.
+type SigningKey struct {
+	ID             string `yaml:"ID"`             // идентификатор ключа, передаваемый в заголовке kid
+	Algorithm      string `yaml:"Algorithm"`      // алгоритм подписи: HS256, RS256 или EdDSA
+	Secret         string `yaml:"Secret"`         // секрет HS256
+	PrivateKeyPath string `yaml:"PrivateKeyPath"` // путь к приватному ключу в формате PEM
+	PublicKeyPath  string `yaml:"PublicKeyPath"`  // путь к публичному ключу в формате PEM
+}
+
+// Links содержит настройки поведения сокращенных ссылок.
+type Links struct {
+	NotActivePage string `yaml:"NotActivePage"` // путь к HTML-странице для ссылок, окно активности которых еще не открылось
+	RedirectCode  int    `yaml:"RedirectCode"`  // HTTP-статус редиректа по умолчанию: 301, 302, 303, 307 или 308, другие значения заменяются на 307
+	CacheMaxAge   int    `yaml:"CacheMaxAge"`   // время кэширования редиректа по умолчанию в секундах, 0 запрещает кэширование
+	VisitorCookie string `yaml:"VisitorCookie"` // имя cookie с идентификатором посетителя для закрепления варианта адреса, по умолчанию visitor_id
+	RestoreWindow int    `yaml:"RestoreWindow"` // время в часах, в течение которого удаленную ссылку можно восстановить, 0 снимает ограничение
+	TransferTTL   int    `yaml:"TransferTTL"`   // время жизни токена передачи ссылок в часах
+	DedupeScope   string `yaml:"DedupeScope"`   // область дедупликации оригинальных URL: global, user или none, по умолчанию global
+}
+
+// Users содержит настройки реестра пользователей.
+type Users struct {
+	SeenInterval    int `yaml:"SeenInterval"`    // период в секундах, с которым накопленные время последней активности пользователей и переходы по вариантам адресов сохраняются в хранилище
+	InactiveTTL     int `yaml:"InactiveTTL"`     // время в часах без активности, после которого ссылки анонимного пользователя безвозвратно удаляются, 0 (по умолчанию) отключает очистку
+	CleanupInterval int `yaml:"CleanupInterval"` // период запуска очистки неактивных пользователей в минутах
+}
+
+// Devices содержит настройки привязки устройств по коду.
+type Devices struct {
+	CodeTTL        int `yaml:"CodeTTL"`        // время жизни кода привязки в секундах
+	CodeLength     int `yaml:"CodeLength"`     // количество цифр в коде привязки
+	RedeemAttempts int `yaml:"RedeemAttempts"` // количество попыток ввода кода с одного адреса за период RedeemWindow
+	RedeemWindow   int `yaml:"RedeemWindow"`   // период ограничения попыток ввода кода в минутах
+	RedeemFailures int `yaml:"RedeemFailures"` // количество неудачных попыток ввода кода со всех адресов за период RedeemWindow, после которого попытки замедляются
+	RedeemDelay    int `yaml:"RedeemDelay"`    // интервал в миллисекундах между попытками ввода кода со всех адресов после превышения RedeemFailures
 }
 
 // Server содержит конфигурацию HTTP-сервера.
@@ -57,6 +123,9 @@ type Server struct {
 	EnableHTTPS   bool   `yaml:"EnableHTTPS"`
 	TLSCertPath   string `yaml:"TLSCertPath"`
 	TLSKeyPath    string `yaml:"TLSKeyPath"`
+	// TrustedProxies адреса и подсети прокси, заголовкам X-Forwarded-For и X-Real-IP которых доверяет сервер.
+	// Пустой список означает, что адресом клиента считается адрес соединения.
+	TrustedProxies []string `yaml:"TrustedProxies"`
 }
 
 // Postgres содержит конфигурацию базы данных PostgreSQL.
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
@@ -48,30 +49,36 @@ func (r *RSA) Decrypt(data []byte) ([]byte, error) {
 	return rsa.DecryptOAEP(sha256.New(), rand.Reader, r.privateKey, data, nil)
 }
 
-func loadPublicKey(path string) (*rsa.PublicKey, error) {
+// readPEM читает первый PEM-блок из файла.
+// kind используется в сообщениях об ошибках: public или private.
+func readPEM(path, kind string) (*pem.Block, error) {
 	file, err := os.ReadFile(path)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error occurred while reading public key file")
+		return nil, errors.WithMessagef(err, "error occurred while reading %s key file", kind)
 	}
 	block, _ := pem.Decode(file)
 	if block == nil {
-		return nil, errors.WithMessage(errors.New("cant decode PEM"), "error occured while decoding public PEM")
+		return nil, errors.WithMessagef(errors.New("cant decode PEM"), "error occured while decoding %s PEM", kind)
 	}
-	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	return block, nil
+}
+
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	pub, err := LoadPublicKey(path)
 	if err != nil {
-		return nil, errors.WithMessage(err, "error occurred while parsing public key")
+		return nil, err
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not RSA")
 	}
-	return pub.(*rsa.PublicKey), nil
+	return rsaPub, nil
 }
 
 func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
-	file, err := os.ReadFile(path)
+	block, err := readPEM(path, "private")
 	if err != nil {
-		return nil, errors.WithMessage(err, "error occurred while reading private key file")
-	}
-	block, _ := pem.Decode(file)
-	if block == nil {
-		return nil, errors.WithMessage(errors.New("cant decode PEM"), "error occured while decoding private PEM")
+		return nil, err
 	}
 	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
@@ -80,6 +87,41 @@ func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
 	return priv, nil
 }
 
+// LoadPublicKey загружает публичный ключ в формате PKIX из PEM-файла.
+// Возвращает *rsa.PublicKey, ed25519.PublicKey или *ecdsa.PublicKey в зависимости от типа ключа.
+func LoadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path, "public")
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while parsing public key")
+	}
+	return pub, nil
+}
+
+// LoadSigningKey загружает приватный ключ из PEM-файла.
+// Поддерживает ключи RSA в формате PKCS#1, как их сохраняет GenerateRSA, и ключи в формате PKCS#8.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path, "private")
+	if err != nil {
+		return nil, err
+	}
+	if priv, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return priv, nil
+	}
+	priv, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.WithMessage(err, "error occurred while parsing private key")
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("private key can not be used for signing")
+	}
+	return signer, nil
+}
+
 // GetRSA возвращает глобальный экземпляр RSA, инициализируя его при первом вызове.
 // Загружает приватный и публичный ключи из файлов.
 func GetRSA() *RSA {